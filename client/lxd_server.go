@@ -102,6 +102,23 @@ func (r *ProtocolLXD) GetServerResources() (*api.Resources, error) {
 	return &resources, nil
 }
 
+// GetMetadataDevices returns the configuration schema of each device type.
+func (r *ProtocolLXD) GetMetadataDevices() ([]api.MetadataDevice, error) {
+	if !r.HasExtension("device_schema") {
+		return nil, fmt.Errorf("The server is missing the required \"device_schema\" API extension")
+	}
+
+	devices := []api.MetadataDevice{}
+
+	// Fetch the raw value
+	_, err := r.queryStruct("GET", "/metadata/devices", nil, "", &devices)
+	if err != nil {
+		return nil, err
+	}
+
+	return devices, nil
+}
+
 // UseProject returns a client that will use a specific project.
 func (r *ProtocolLXD) UseProject(name string) InstanceServer {
 	return &ProtocolLXD{
@@ -14,6 +14,7 @@ import (
 	clusterConfig "github.com/lxc/lxd/lxd/cluster/config"
 	"github.com/lxc/lxd/lxd/config"
 	"github.com/lxc/lxd/lxd/db"
+	"github.com/lxc/lxd/lxd/device"
 	instanceDrivers "github.com/lxc/lxd/lxd/instance/drivers"
 	"github.com/lxc/lxd/lxd/lifecycle"
 	"github.com/lxc/lxd/lxd/node"
@@ -38,6 +39,8 @@ var api10Cmd = APIEndpoint{
 var api10 = []APIEndpoint{
 	api10Cmd,
 	api10ResourcesCmd,
+	api10ResourcesQuarantineCmd,
+	api10ResourcesQuarantineDeviceCmd,
 	certificateCmd,
 	certificatesCmd,
 	clusterCmd,
@@ -116,6 +119,7 @@ var api10 = []APIEndpoint{
 	warningsCmd,
 	warningCmd,
 	metricsCmd,
+	metadataDevicesCmd,
 }
 
 // swagger:operation GET /1.0?public server server_get_untrusted
@@ -710,6 +714,10 @@ func doApi10UpdateTriggers(d *Daemon, nodeChanged, clusterChanged map[string]str
 	lokiChanged := false
 	acmeDomainChanged := false
 	acmeCAURLChanged := false
+	usbQuiesceChanged := false
+	usbReconcileChanged := false
+	deviceOperationsChanged := false
+	usbSysfsPathsChanged := false
 
 	for key := range clusterChanged {
 		switch key {
@@ -796,6 +804,14 @@ func doApi10UpdateTriggers(d *Daemon, nodeChanged, clusterChanged map[string]str
 			bgpChanged = true
 		case "core.dns_address":
 			dnsChanged = true
+		case "devices.usb.quiesce", "devices.usb.quiesce.policy":
+			usbQuiesceChanged = true
+		case "devices.usb.reconcile.delay":
+			usbReconcileChanged = true
+		case "devices.operations.concurrency":
+			deviceOperationsChanged = true
+		case "devices.usb.sysfs.paths":
+			usbSysfsPathsChanged = true
 		}
 	}
 
@@ -864,6 +880,33 @@ func doApi10UpdateTriggers(d *Daemon, nodeChanged, clusterChanged map[string]str
 		}
 	}
 
+	if deviceOperationsChanged {
+		device.SetOperationsLimit(nodeConfig.DevicesOperationsConcurrency())
+	}
+
+	if usbSysfsPathsChanged {
+		device.SetUSBSysfsPaths(nodeConfig.DevicesUSBSysfsPaths())
+	}
+
+	if usbReconcileChanged {
+		err := device.USBCoalesce(s, nodeConfig.DevicesUSBReconcileDelay())
+		if err != nil {
+			return err
+		}
+	}
+
+	if usbQuiesceChanged {
+		quiesce, policy := nodeConfig.DevicesUSBQuiesce()
+		if quiesce {
+			err := device.USBQuiesce(s, policy)
+			if err != nil {
+				return err
+			}
+		} else {
+			device.USBResume(s)
+		}
+	}
+
 	if maasChanged {
 		url, key := clusterConfig.MAASController()
 		machine := nodeConfig.MAASMachine()
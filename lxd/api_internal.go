@@ -52,6 +52,7 @@ var apiInternal = []APIEndpoint{
 	internalContainerOnStartCmd,
 	internalContainerOnStopCmd,
 	internalContainerOnStopNSCmd,
+	internalDevicesRecheckCmd,
 	internalGarbageCollectorCmd,
 	internalImageOptimizeCmd,
 	internalImageRefreshCmd,
@@ -135,6 +136,12 @@ var internalBGPStateCmd = APIEndpoint{
 	Get: APIEndpointAction{Handler: internalBGPState},
 }
 
+var internalDevicesRecheckCmd = APIEndpoint{
+	Path: "devices/recheck",
+
+	Post: APIEndpointAction{Handler: internalDevicesRecheck},
+}
+
 type internalImageOptimizePost struct {
 	Image api.Image `json:"image" yaml:"image"`
 	Pool  string    `json:"pool" yaml:"pool"`
@@ -205,6 +212,14 @@ func internalOptimizeImage(d *Daemon, r *http.Request) response.Response {
 	return response.EmptySyncResponse
 }
 
+// internalDevicesRecheck re-checks the devices of the running instances of the project on this member against its
+// restrictions, when notified by the member that changed them.
+func internalDevicesRecheck(d *Daemon, r *http.Request) response.Response {
+	devicesRecheckRestrictions(d.State(), projectParam(r))
+
+	return response.EmptySyncResponse
+}
+
 func internalRefreshImage(d *Daemon, r *http.Request) response.Response {
 	err := autoUpdateImages(d.shutdownCtx, d)
 	if err != nil {
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"net/http"
+
+	"github.com/lxc/lxd/lxd/device"
+	"github.com/lxc/lxd/lxd/response"
+)
+
+var metadataDevicesCmd = APIEndpoint{
+	Path: "metadata/devices",
+
+	Get: APIEndpointAction{Handler: metadataDevicesGet, AccessHandler: allowAuthenticated},
+}
+
+// swagger:operation GET /1.0/metadata/devices server metadata_devices_get
+//
+// Get the device configuration schema
+//
+// Gets the configuration keys accepted by each device type, derived from the validation rules of the server.
+//
+// ---
+// produces:
+//   - application/json
+// responses:
+//   "200":
+//     description: Device configuration schema
+//     schema:
+//       type: object
+//       description: Sync response
+//       properties:
+//         type:
+//           type: string
+//           description: Response type
+//           example: sync
+//         status:
+//           type: string
+//           description: Status description
+//           example: Success
+//         status_code:
+//           type: integer
+//           description: Status code
+//           example: 200
+//         metadata:
+//           type: array
+//           description: Configuration schema of each device type
+//           items:
+//             $ref: "#/definitions/MetadataDevice"
+//   "403":
+//     $ref: "#/responses/Forbidden"
+//   "500":
+//     $ref: "#/responses/InternalServerError"
+func metadataDevicesGet(d *Daemon, r *http.Request) response.Response {
+	return response.SyncResponse(true, device.Schema(d.State()))
+}
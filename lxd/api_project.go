@@ -721,6 +721,21 @@ func projectChange(d *Daemon, project *api.Project, req api.ProjectPut) response
 		return response.SmartError(err)
 	}
 
+	// Stop the devices of the running instances that the changed restrictions now forbid, and start those that
+	// they allow again. As AllowProjectUpdate rejects changes to the restricted.* keys that conflict with the
+	// devices in use, devices are only stopped when restricted itself is enabled. The project has been updated
+	// by now, so a failure to re-check the devices only affects the running instances and is logged.
+	for _, key := range configChanged {
+		if key == "restricted" || strings.HasPrefix(key, "restricted.") {
+			err = devicesRecheckProjectRestrictions(d, project.Name)
+			if err != nil {
+				logger.Warn("Failed notifying other members to re-check device restrictions", logger.Ctx{"project": project.Name, "err": err})
+			}
+
+			break
+		}
+	}
+
 	return response.EmptySyncResponse
 }
 
@@ -1073,17 +1088,10 @@ func projectValidateConfig(s *state.State, config map[string]string) error {
 		"restricted.containers.lowlevel":       isEitherAllowOrBlock,
 		"restricted.containers.privilege":      validate.Optional(validate.IsOneOf("allow", "unprivileged", "isolated")),
 		"restricted.virtual-machines.lowlevel": isEitherAllowOrBlock,
-		"restricted.devices.unix-char":         isEitherAllowOrBlock,
-		"restricted.devices.unix-block":        isEitherAllowOrBlock,
-		"restricted.devices.unix-hotplug":      isEitherAllowOrBlock,
-		"restricted.devices.infiniband":        isEitherAllowOrBlock,
-		"restricted.devices.gpu":               isEitherAllowOrBlock,
-		"restricted.devices.usb":               isEitherAllowOrBlock,
-		"restricted.devices.pci":               isEitherAllowOrBlock,
-		"restricted.devices.proxy":             isEitherAllowOrBlock,
 		"restricted.devices.nic":               isEitherAllowOrBlockOrManaged,
 		"restricted.devices.disk":              isEitherAllowOrBlockOrManaged,
 		"restricted.devices.disk.paths":        validate.Optional(validate.IsListOf(validate.IsAbsFilePath)),
+		"restricted.devices.usb.usbmon":        isEitherAllowOrBlock,
 		"restricted.idmap.uid":                 validate.Optional(validate.IsListOf(validate.IsUint32Range)),
 		"restricted.idmap.gid":                 validate.Optional(validate.IsListOf(validate.IsUint32Range)),
 		"restricted.networks.access":           validate.Optional(validate.IsListOf(validate.IsAny)),
@@ -1095,6 +1103,10 @@ func projectValidateConfig(s *state.State, config map[string]string) error {
 		"restricted.snapshots":      isEitherAllowOrBlock,
 	}
 
+	for deviceType := range projecthelpers.RestrictedDeviceTypes {
+		projectConfigKeys["restricted.devices."+deviceType] = isEitherAllowOrBlock
+	}
+
 	for k, v := range config {
 		key := k
 
@@ -825,6 +825,27 @@ func (cg *CGroup) SetCpuset(limit string) error {
 	return ErrUnknownVersion
 }
 
+// GetDevices returns the device access rules of the cgroup (in the format of devices.list, such as "c 1:3 rwm").
+// The rules can only be read back with the V1 devices controller, as with V2 they are an eBPF program.
+func (cg *CGroup) GetDevices() ([]string, error) {
+	version := cgControllers["devices"]
+	switch version {
+	case Unavailable:
+		return nil, ErrControllerMissing
+	case V1:
+		val, err := cg.rw.Get(version, "devices", "devices.list")
+		if err != nil {
+			return nil, err
+		}
+
+		return shared.SplitNTrimSpace(val, "\n", -1, true), nil
+	case V2:
+		return nil, ErrControllerMissing
+	}
+
+	return nil, ErrUnknownVersion
+}
+
 // GetMemoryStats returns memory stats.
 func (cg *CGroup) GetMemoryStats() (map[string]uint64, error) {
 	var (
@@ -36,6 +36,7 @@ import (
 	"github.com/lxc/lxd/lxd/db"
 	clusterDB "github.com/lxc/lxd/lxd/db/cluster"
 	"github.com/lxc/lxd/lxd/db/warningtype"
+	"github.com/lxc/lxd/lxd/device"
 	"github.com/lxc/lxd/lxd/dns"
 	"github.com/lxc/lxd/lxd/endpoints"
 	"github.com/lxc/lxd/lxd/events"
@@ -1452,7 +1453,34 @@ func (d *Daemon) init() error {
 
 	var instances []instance.Instance
 
+	// Limit the number of device operations run concurrently.
+	device.SetOperationsLimit(d.localConfig.DevicesOperationsConcurrency())
+
+	// Scan the configured sysfs paths for USB devices.
+	device.SetUSBSysfsPaths(d.localConfig.DevicesUSBSysfsPaths())
+
 	if !d.os.MockMode {
+		// Coalesce USB hotplug events if configured.
+		err = device.USBCoalesce(d.State(), d.localConfig.DevicesUSBReconcileDelay())
+		if err != nil {
+			logger.Warn("Failed coalescing USB hotplug events", logger.Ctx{"err": err})
+		}
+
+		// Keep the quarantined devices unbound, as drivers may have bound to them since LXD stopped.
+		err = device.QuarantineRestore()
+		if err != nil {
+			logger.Warn("Failed restoring device quarantine", logger.Ctx{"err": err})
+		}
+
+		// Keep USB hotplug quiesced if it was when LXD stopped.
+		usbQuiesce, usbQuiescePolicy := d.localConfig.DevicesUSBQuiesce()
+		if usbQuiesce {
+			err = device.USBQuiesce(d.State(), usbQuiescePolicy)
+			if err != nil {
+				logger.Warn("Failed quiescing USB hotplug", logger.Ctx{"err": err})
+			}
+		}
+
 		// Start the scheduler
 		go deviceEventListener(d.State())
 
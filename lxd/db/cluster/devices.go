@@ -4,6 +4,8 @@ package cluster
 
 import (
 	"fmt"
+
+	deviceConfig "github.com/lxc/lxd/lxd/device/config"
 )
 
 // Code generation directives.
@@ -39,7 +41,7 @@ type DeviceFilter struct {
 	Config *ConfigFilter
 }
 
-// Supported device types.
+// Supported device types. The code of each type is its index in deviceConfig.Types.
 const (
 	TypeNone        = DeviceType(0)
 	TypeNIC         = DeviceType(1)
@@ -53,69 +55,34 @@ const (
 	TypeUnixHotplug = DeviceType(9)
 	TypeTPM         = DeviceType(10)
 	TypePCI         = DeviceType(11)
+	TypePerf        = DeviceType(12)
+	TypeInput       = DeviceType(13)
+	TypeTimer       = DeviceType(14)
+	TypeIPMI        = DeviceType(15)
+	TypeSCSI        = DeviceType(16)
+	TypeHwmon       = DeviceType(17)
 )
 
 func (t DeviceType) String() string {
-	switch t {
-	case TypeNone:
-		return "none"
-	case TypeNIC:
-		return "nic"
-	case TypeDisk:
-		return "disk"
-	case TypeUnixChar:
-		return "unix-char"
-	case TypeUnixBlock:
-		return "unix-block"
-	case TypeUSB:
-		return "usb"
-	case TypeGPU:
-		return "gpu"
-	case TypeInfiniband:
-		return "infiniband"
-	case TypeProxy:
-		return "proxy"
-	case TypeUnixHotplug:
-		return "unix-hotplug"
-	case TypeTPM:
-		return "tpm"
-	case TypePCI:
-		return "pci"
+	if t < 0 || int(t) >= len(deviceConfig.Types) {
+		return ""
 	}
 
-	return ""
+	return deviceConfig.Types[t]
 }
 
 // NewDeviceType determines the device type from the given string, if supported.
 func NewDeviceType(t string) (DeviceType, error) {
-	switch t {
-	case "none":
-		return TypeNone, nil
-	case "nic":
-		return TypeNIC, nil
-	case "disk":
-		return TypeDisk, nil
-	case "unix-char":
-		return TypeUnixChar, nil
-	case "unix-block":
-		return TypeUnixBlock, nil
-	case "usb":
-		return TypeUSB, nil
-	case "gpu":
-		return TypeGPU, nil
-	case "infiniband":
-		return TypeInfiniband, nil
-	case "proxy":
-		return TypeProxy, nil
-	case "unix-hotplug":
-		return TypeUnixHotplug, nil
-	case "tpm":
-		return TypeTPM, nil
-	case "pci":
-		return TypePCI, nil
-	default:
-		return -1, fmt.Errorf("Invalid device type %s", t)
+	// Device type aliases are stored as the current device type.
+	t, _ = deviceConfig.ResolveType(t)
+
+	for code, name := range deviceConfig.Types {
+		if name == t {
+			return DeviceType(code), nil
+		}
 	}
+
+	return -1, fmt.Errorf("Invalid device type %s", t)
 }
 
 // DevicesToAPI takes a map of devices and converts them to API format.
@@ -0,0 +1,45 @@
+package cluster_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/lxc/lxd/lxd/db/cluster"
+)
+
+func TestDeviceTypes(t *testing.T) {
+	// Check the device types keep the codes they are stored with.
+	types := map[cluster.DeviceType]string{
+		cluster.TypeNone:        "none",
+		cluster.TypeNIC:         "nic",
+		cluster.TypeDisk:        "disk",
+		cluster.TypeUnixChar:    "unix-char",
+		cluster.TypeUnixBlock:   "unix-block",
+		cluster.TypeUSB:         "usb",
+		cluster.TypeGPU:         "gpu",
+		cluster.TypeInfiniband:  "infiniband",
+		cluster.TypeProxy:       "proxy",
+		cluster.TypeUnixHotplug: "unix-hotplug",
+		cluster.TypeTPM:         "tpm",
+		cluster.TypePCI:         "pci",
+		cluster.TypePerf:        "perf",
+		cluster.TypeInput:       "input",
+		cluster.TypeTimer:       "timer",
+		cluster.TypeIPMI:        "ipmi",
+		cluster.TypeSCSI:        "scsi",
+		cluster.TypeHwmon:       "hwmon",
+	}
+
+	for deviceType, name := range types {
+		assert.Equal(t, name, deviceType.String())
+
+		code, err := cluster.NewDeviceType(name)
+		assert.NoError(t, err)
+		assert.Equal(t, deviceType, code)
+	}
+
+	_, err := cluster.NewDeviceType("invalid")
+	assert.Error(t, err)
+	assert.Equal(t, "", cluster.DeviceType(-1).String())
+}
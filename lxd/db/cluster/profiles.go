@@ -7,6 +7,7 @@ import (
 	"database/sql"
 
 	"github.com/lxc/lxd/lxd/device/config"
+	"github.com/lxc/lxd/shared"
 	"github.com/lxc/lxd/shared/api"
 )
 
@@ -142,8 +143,15 @@ func ExpandInstanceDevices(devices config.Devices, profiles []api.Profile) confi
 		}
 	}
 
-	// Stick the given devices on top
+	// Stick the given devices on top, merging devices that inherit from a profile device of the
+	// same name and type.
 	for k, v := range devices {
+		parent, found := expandedDevices[k]
+		if found && shared.IsTrue(v["inherit"]) && v["type"] == parent["type"] {
+			expandedDevices[k] = v.Inherit(parent)
+			continue
+		}
+
 		expandedDevices[k] = v
 	}
 
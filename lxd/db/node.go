@@ -7,6 +7,7 @@ import (
 	"database/sql"
 	"fmt"
 	"net/http"
+	"sort"
 	"strconv"
 	"strings"
 	"time"
@@ -1092,19 +1093,40 @@ func (c *ClusterTx) GetNodeOfflineThreshold(ctx context.Context) (time.Duration,
 // an operation). If archs is not empty, then return only nodes with an
 // architecture in that list.
 func (c *ClusterTx) GetNodeWithLeastInstances(ctx context.Context, archs []int, defaultArch int, group string, allowedGroups []string) (string, error) {
+	names, err := c.GetNodesWithLeastInstances(ctx, archs, defaultArch, group, allowedGroups)
+	if err != nil {
+		return "", err
+	}
+
+	if len(names) == 0 {
+		return "", nil
+	}
+
+	return names[0], nil
+}
+
+// GetNodesWithLeastInstances returns the names of all the non-offline nodes that
+// GetNodeWithLeastInstances chooses from, in the order it prefers them: nodes with
+// the default architecture first, then those with the least number of containers.
+// This allows choosing the next best node when the preferred one isn't suitable.
+func (c *ClusterTx) GetNodesWithLeastInstances(ctx context.Context, archs []int, defaultArch int, group string, allowedGroups []string) ([]string, error) {
 	threshold, err := c.GetNodeOfflineThreshold(ctx)
 	if err != nil {
-		return "", fmt.Errorf("Failed to get offline threshold: %w", err)
+		return nil, fmt.Errorf("Failed to get offline threshold: %w", err)
 	}
 
 	nodes, err := c.GetNodes(ctx)
 	if err != nil {
-		return "", fmt.Errorf("Failed to get current cluster members: %w", err)
+		return nil, fmt.Errorf("Failed to get current cluster members: %w", err)
+	}
+
+	type candidate struct {
+		name          string
+		count         int
+		isDefaultArch bool
 	}
 
-	name := ""
-	containers := -1
-	isDefaultArchChosen := false
+	candidates := []candidate{}
 	for _, node := range nodes {
 		// Skip evacuated members.
 		if node.State == ClusterMemberStateEvacuated || node.IsOffline(threshold) {
@@ -1144,7 +1166,7 @@ func (c *ClusterTx) GetNodeWithLeastInstances(ctx context.Context, archs []int,
 		// Get member personalities too.
 		personalities, err := osarch.ArchitecturePersonalities(node.Architecture)
 		if err != nil {
-			return "", err
+			return nil, err
 		}
 
 		supported := []int{node.Architecture}
@@ -1165,33 +1187,35 @@ func (c *ClusterTx) GetNodeWithLeastInstances(ctx context.Context, archs []int,
 			continue
 		}
 
-		if !isDefaultArch && isDefaultArchChosen {
-			continue
-		}
-
 		// Fetch the number of instances already created on this node.
 		created, err := query.Count(ctx, c.tx, "instances", "node_id=?", node.ID)
 		if err != nil {
-			return "", fmt.Errorf("Failed to get instances count: %w", err)
+			return nil, fmt.Errorf("Failed to get instances count: %w", err)
 		}
 
 		// Fetch the number of instances currently being created on this node.
 		pending, err := query.Count(ctx, c.tx, "operations", "node_id=? AND type=?", node.ID, operationtype.InstanceCreate)
 		if err != nil {
-			return "", fmt.Errorf("Failed to get pending instances count: %w", err)
+			return nil, fmt.Errorf("Failed to get pending instances count: %w", err)
 		}
 
-		count := created + pending
-		if containers == -1 || count < containers || (isDefaultArch && !isDefaultArchChosen) {
-			containers = count
-			name = node.Name
-			if isDefaultArch {
-				isDefaultArchChosen = true
-			}
+		candidates = append(candidates, candidate{name: node.Name, count: created + pending, isDefaultArch: isDefaultArch})
+	}
+
+	sort.SliceStable(candidates, func(i, j int) bool {
+		if candidates[i].isDefaultArch != candidates[j].isDefaultArch {
+			return candidates[i].isDefaultArch
 		}
+
+		return candidates[i].count < candidates[j].count
+	})
+
+	names := make([]string, 0, len(candidates))
+	for _, candidate := range candidates {
+		names = append(names, candidate.name)
 	}
 
-	return name, nil
+	return names, nil
 }
 
 // SetNodeVersion updates the schema and API version of the node with the
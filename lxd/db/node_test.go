@@ -367,6 +367,25 @@ INSERT INTO operations (id, uuid, node_id, type, project_id) VALUES (1, 'abc', 1
 	assert.Equal(t, "buzz", name)
 }
 
+// All the candidate nodes are returned, ordered by their number of containers.
+func TestGetNodesWithLeastInstances(t *testing.T) {
+	tx, cleanup := db.NewTestClusterTx(t)
+	defer cleanup()
+
+	_, err := tx.CreateNode("buzz", "1.2.3.4:666")
+	require.NoError(t, err)
+
+	// Add a container to the default node (ID 1)
+	_, err = tx.Tx().Exec(`
+INSERT INTO instances (id, node_id, name, architecture, type, project_id, description) VALUES (1, 1, 'foo', 1, 1, 1, '')
+`)
+	require.NoError(t, err)
+
+	names, err := tx.GetNodesWithLeastInstances(context.Background(), nil, -1, "", nil)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"buzz", "none"}, names)
+}
+
 // If specific architectures were selected, return only nodes with those
 // architectures.
 func TestGetNodeWithLeastInstances_Architecture(t *testing.T) {
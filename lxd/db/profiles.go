@@ -8,6 +8,7 @@ import (
 
 	"github.com/lxc/lxd/lxd/db/cluster"
 	deviceConfig "github.com/lxc/lxd/lxd/device/config"
+	"github.com/lxc/lxd/shared"
 	"github.com/lxc/lxd/shared/api"
 )
 
@@ -213,10 +214,36 @@ func ExpandInstanceDevices(devices deviceConfig.Devices, profiles []api.Profile)
 		}
 	}
 
-	// Stick the given devices on top
+	// Stick the given devices on top, merging devices that inherit from a profile device of the
+	// same name and type.
 	for k, v := range devices {
+		parent, found := expandedDevices[k]
+		if found && shared.IsTrue(v["inherit"]) && v["type"] == parent["type"] {
+			expandedDevices[k] = v.Inherit(parent)
+			continue
+		}
+
 		expandedDevices[k] = v
 	}
 
 	return expandedDevices
 }
+
+// ExpandInstanceDeviceSources returns where each of the given instance's expanded devices is defined, using the
+// same precedence as ExpandInstanceDevices. The source is "instance" for devices defined in the instance's own
+// config (including those inheriting from a profile device) and "profile:<name>" for profile devices.
+func ExpandInstanceDeviceSources(devices deviceConfig.Devices, profiles []api.Profile) map[string]string {
+	sources := map[string]string{}
+
+	for _, profile := range profiles {
+		for name := range profile.Devices {
+			sources[name] = fmt.Sprintf("profile:%s", profile.Name)
+		}
+	}
+
+	for name := range devices {
+		sources[name] = "instance"
+	}
+
+	return sources
+}
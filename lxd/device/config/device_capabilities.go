@@ -0,0 +1,34 @@
+package config
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Capabilities lists the names of the Linux capabilities, in the format used by LXC (without the "cap_" prefix).
+var Capabilities = []string{
+	"chown", "dac_override", "dac_read_search", "fowner", "fsetid", "kill", "setgid", "setuid", "setpcap",
+	"linux_immutable", "net_bind_service", "net_broadcast", "net_admin", "net_raw", "ipc_lock", "ipc_owner",
+	"sys_module", "sys_rawio", "sys_chroot", "sys_ptrace", "sys_pacct", "sys_admin", "sys_boot", "sys_nice",
+	"sys_resource", "sys_time", "sys_tty_config", "mknod", "lease", "audit_write", "audit_control", "setfcap",
+	"mac_override", "mac_admin", "syslog", "wake_alarm", "block_suspend", "audit_read", "perfmon", "bpf",
+	"checkpoint_restore",
+}
+
+// CapabilityName returns the name of the capability in the format used by LXC, accepting names with or without the
+// "cap_" prefix in any case (such as "CAP_SYS_RAWIO" or "sys_rawio").
+func CapabilityName(name string) string {
+	return strings.TrimPrefix(strings.ToLower(strings.TrimSpace(name)), "cap_")
+}
+
+// IsCapability validates whether the value is the name of a Linux capability.
+func IsCapability(value string) error {
+	name := CapabilityName(value)
+	for _, capability := range Capabilities {
+		if name == capability {
+			return nil
+		}
+	}
+
+	return fmt.Errorf("Unknown capability %q", value)
+}
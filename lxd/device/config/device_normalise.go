@@ -0,0 +1,125 @@
+package config
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+)
+
+// normaliseKeys lists the config keys of each device type whose values can be written in multiple equivalent forms.
+var normaliseKeys = map[string][]string{
+	"usb":          {"vendorid", "productid", "fallback", "standby"},
+	"unix-hotplug": {"vendorid", "productid"},
+	"gpu":          {"vendorid", "productid", "pci"},
+	"pci":          {"address"},
+	"nic":          {"hwaddr"},
+	"infiniband":   {"hwaddr"},
+}
+
+// normalisers maps the device config keys whose values can be written in multiple equivalent forms to the
+// function converting a value to its canonical form.
+var normalisers = map[string]func(string) string{
+	"vendorid":  normaliseDeviceID,
+	"productid": normaliseDeviceID,
+	"fallback":  normaliseUSBMatchList,
+	"standby":   normaliseUSBMatch,
+	"pci":       NormalisePCIAddress,
+	"address":   NormalisePCIAddress,
+	"hwaddr":    normaliseMAC,
+}
+
+// Normalise rewrites the values of the device config keys that can be written in multiple equivalent forms to
+// their canonical forms, so that equivalent configs compare equal and match the host devices however they were
+// written. Values that can't be normalised are left for the validation to report.
+func (device Device) Normalise() {
+	typeName, _ := ResolveType(device["type"])
+
+	for _, key := range normaliseKeys[typeName] {
+		if device[key] == "" {
+			continue
+		}
+
+		device[key] = normalisers[key](device[key])
+	}
+}
+
+// Normalise rewrites the config of each device in the set to its canonical form (see Device.Normalise).
+func (list Devices) Normalise() {
+	for _, device := range list {
+		device.Normalise()
+	}
+}
+
+// NormalisePCIAddress converts common PCI address notation to the kernel's notation.
+func NormalisePCIAddress(addr string) string {
+	// PCI devices can be specified as "0000:XX:XX.X" or "XX:XX.X", in either case and with or without the
+	// leading zeros. However, the devices in /sys/bus/pci/devices use the long zero-padded format which is
+	// why we need to make sure the prefix and padding are present.
+	parts := strings.Split(strings.TrimSpace(addr), ":")
+	if len(parts) == 2 {
+		parts = append([]string{"0"}, parts...)
+	}
+
+	if len(parts) == 3 {
+		slot, function, _ := strings.Cut(parts[2], ".")
+
+		domainNum, errDomain := strconv.ParseUint(parts[0], 16, 32)
+		busNum, errBus := strconv.ParseUint(parts[1], 16, 8)
+		slotNum, errSlot := strconv.ParseUint(slot, 16, 8)
+		functionNum, errFunction := strconv.ParseUint(function, 16, 8)
+		if errDomain == nil && errBus == nil && errSlot == nil && errFunction == nil {
+			return fmt.Sprintf("%04x:%02x:%02x.%x", domainNum, busNum, slotNum, functionNum)
+		}
+	}
+
+	// Leave addresses that can't be parsed for validation to report, ensuring they are lowercase.
+	return strings.ToLower(addr)
+}
+
+// normaliseDeviceID converts a hex vendor or product ID to four lower case hex characters, accepting upper case,
+// an "0x" prefix and missing leading zeros (such as "0x46D" for "046d").
+func normaliseDeviceID(value string) string {
+	trimmed := strings.TrimSpace(value)
+	if len(trimmed) > 2 && strings.EqualFold(trimmed[:2], "0x") {
+		trimmed = trimmed[2:]
+	}
+
+	id, err := strconv.ParseUint(trimmed, 16, 16)
+	if err != nil {
+		return value
+	}
+
+	return fmt.Sprintf("%04x", id)
+}
+
+// normaliseUSBMatch normalises the vendor and product IDs of a "vendorid[:productid]" match criterion.
+func normaliseUSBMatch(value string) string {
+	vendorID, productID, hasProductID := strings.Cut(value, ":")
+	if !hasProductID {
+		return normaliseDeviceID(vendorID)
+	}
+
+	return fmt.Sprintf("%s:%s", normaliseDeviceID(vendorID), normaliseDeviceID(productID))
+}
+
+// normaliseUSBMatchList normalises each of the comma-separated "vendorid[:productid]" match criteria.
+func normaliseUSBMatchList(value string) string {
+	criteria := strings.Split(value, ",")
+	for i, criterion := range criteria {
+		criteria[i] = normaliseUSBMatch(strings.TrimSpace(criterion))
+	}
+
+	return strings.Join(criteria, ",")
+}
+
+// normaliseMAC converts a MAC address (including the longer InfiniBand ones) to lower case hex bytes separated by
+// colons, accepting upper case and the "-" and "." separated forms (such as "00-16-3E-AB-CD-EF").
+func normaliseMAC(value string) string {
+	mac, err := net.ParseMAC(strings.TrimSpace(value))
+	if err != nil {
+		return value
+	}
+
+	return mac.String()
+}
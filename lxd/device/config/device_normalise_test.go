@@ -0,0 +1,90 @@
+package config
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDeviceNormalise(t *testing.T) {
+	tests := []struct {
+		typeName string
+		key      string
+		values   []string
+		expected string
+	}{
+		{typeName: "usb", key: "vendorid", values: []string{"046d", "046D", "46d", "0x046d", "0X46D", " 046d "}, expected: "046d"},
+		{typeName: "usb", key: "productid", values: []string{"c52b", "C52B", "0xc52b"}, expected: "c52b"},
+		{typeName: "unix-hotplug", key: "productid", values: []string{"0001", "1", "01", "0x1"}, expected: "0001"},
+		{typeName: "usb", key: "fallback", values: []string{"046d:c534,046d", "046D:C534,46d", "0x46d:0xc534, 046D"}, expected: "046d:c534,046d"},
+		{typeName: "usb", key: "standby", values: []string{"0403:6015", "403:6015", "0403:0x6015"}, expected: "0403:6015"},
+		{typeName: "gpu", key: "pci", values: []string{"0000:01:00.0", "01:00.0", "0:1:0.0", "0000:1:0.0"}, expected: "0000:01:00.0"},
+		{typeName: "pci", key: "address", values: []string{"0000:af:1f.7", "AF:1F.7", "0:af:1f.7"}, expected: "0000:af:1f.7"},
+		{typeName: "nic", key: "hwaddr", values: []string{"00:16:3e:ab:cd:ef", "00:16:3E:AB:CD:EF", "00-16-3e-ab-cd-ef", "0016.3eab.cdef"}, expected: "00:16:3e:ab:cd:ef"},
+		{typeName: "infiniband", key: "hwaddr", values: []string{"00:16:3e:ab:cd:ef:01:02", "00-16-3E-AB-CD-EF-01-02"}, expected: "00:16:3e:ab:cd:ef:01:02"},
+	}
+
+	for _, test := range tests {
+		for _, value := range test.values {
+			device := Device{"type": test.typeName, test.key: value}
+			device.Normalise()
+			assert.Equal(t, test.expected, device[test.key], "%s=%q", test.key, value)
+		}
+	}
+
+	// Check the values that can't be normalised are left for the validation to report.
+	device := Device{
+		"type":      "usb",
+		"vendorid":  "logitech",
+		"productid": "12345",
+		"fallback":  "046d:zzzz",
+	}
+
+	device.Normalise()
+	assert.Equal(t, Device{
+		"type":      "usb",
+		"vendorid":  "logitech",
+		"productid": "12345",
+		"fallback":  "046d:zzzz",
+	}, device)
+
+	// Check only the keys of the device type are normalised, including for type aliases.
+	device = Device{"type": "disk", "vendorid": "046D", "hwaddr": "00:16:3E:AB:CD:EF"}
+	device.Normalise()
+	assert.Equal(t, Device{"type": "disk", "vendorid": "046D", "hwaddr": "00:16:3E:AB:CD:EF"}, device)
+
+	TypeAliases["hotplug"] = "unix-hotplug"
+	t.Cleanup(func() { delete(TypeAliases, "hotplug") })
+
+	device = Device{"type": "hotplug", "vendorid": "46D"}
+	device.Normalise()
+	assert.Equal(t, "046d", device["vendorid"])
+}
+
+func TestDevicesNormaliseUpdate(t *testing.T) {
+	oldDevices := Devices{
+		"usb0": Device{"type": "usb", "vendorid": "0x46D", "productid": "C52B"},
+		"eth0": Device{"type": "nic", "nictype": "bridged", "parent": "lxdbr0", "hwaddr": "00-16-3E-AB-CD-EF"},
+		"gpu0": Device{"type": "gpu", "pci": "1:0.0"},
+	}
+
+	newDevices := Devices{
+		"usb0": Device{"type": "usb", "vendorid": "046d", "productid": "c52b"},
+		"eth0": Device{"type": "nic", "nictype": "bridged", "parent": "lxdbr0", "hwaddr": "00:16:3e:ab:cd:ef"},
+		"gpu0": Device{"type": "gpu", "pci": "0000:01:00.0"},
+	}
+
+	// Check equivalent configs are replaced until normalised.
+	removed, added, _, _ := oldDevices.Update(newDevices, nil)
+	assert.Len(t, removed, 3)
+	assert.Len(t, added, 3)
+
+	oldDevices.Normalise()
+	newDevices.Normalise()
+
+	removed, added, updated, changedKeys := oldDevices.Update(newDevices, nil)
+	assert.Empty(t, removed)
+	assert.Empty(t, added)
+	assert.Empty(t, updated)
+	assert.Empty(t, changedKeys)
+}
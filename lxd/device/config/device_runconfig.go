@@ -13,6 +13,9 @@ const MountOwnerShiftDynamic = "dynamic"
 // MountOwnerShiftStatic statically modify ownership.
 const MountOwnerShiftStatic = "static"
 
+// MountOwnerShiftIdmapped use an idmapped mount for dynamic owner shifting.
+const MountOwnerShiftIdmapped = "idmapped"
+
 // RunConfigItem represents a single config item.
 type RunConfigItem struct {
 	Key   string
@@ -28,7 +31,7 @@ type MountEntryItem struct {
 	Opts       []string // Describes the mount options associated with the filesystem.
 	Freq       int      // Used by dump(8) to determine which filesystems need to be dumped. Defaults to zero (don't dump) if not present.
 	PassNo     int      // Used by fsck(8) to determine the order in which filesystem checks are done at boot time. Defaults to zero (don't fsck) if not present.
-	OwnerShift string   // Ownership shifting mode, use constants MountOwnerShiftNone, MountOwnerShiftStatic or MountOwnerShiftDynamic.
+	OwnerShift string   // Ownership shifting mode, use constants MountOwnerShiftNone, MountOwnerShiftStatic, MountOwnerShiftDynamic or MountOwnerShiftIdmapped.
 }
 
 // RootFSEntryItem represents the root filesystem options for an Instance.
@@ -55,6 +58,7 @@ type RunConfig struct {
 	USBDevice        []USBDeviceItem  // USB device configuration settings.
 	TPMDevice        []RunConfigItem  // TPM device configuration settings.
 	PCIDevice        []RunConfigItem  // PCI device configuration settings.
+	Environment      []RunConfigItem  // Environment variables to set in the instance's init environment.
 	Revert           revert.Hook      // Revert setup of device on post-setup error.
 }
 
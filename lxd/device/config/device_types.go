@@ -0,0 +1,27 @@
+package config
+
+// Types lists the names of the current device types. The index of each type is the code it is stored with in
+// the database (see cluster.DeviceType), so the order must never change and new types must be appended.
+var Types = []string{"none", "nic", "disk", "unix-char", "unix-block", "usb", "gpu", "infiniband", "proxy", "unix-hotplug", "tpm", "pci", "perf", "input", "timer", "ipmi", "scsi", "hwmon"}
+
+// TypeAliases maps the names that device types used to go by to the name of the current device type that
+// implements them, so that configs using an old name keep working when a device type is renamed. Aliases must
+// not shadow the name of a current device type. No device type has been renamed yet.
+var TypeAliases = map[string]string{}
+
+// ResolveType returns the name of the current device type for the supplied device type name and whether the
+// supplied name is an alias. Current device type names are always returned as is.
+func ResolveType(name string) (string, bool) {
+	for _, typeName := range Types {
+		if name == typeName {
+			return name, false
+		}
+	}
+
+	typeName, found := TypeAliases[name]
+	if !found {
+		return name, false
+	}
+
+	return typeName, true
+}
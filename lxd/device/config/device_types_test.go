@@ -0,0 +1,33 @@
+package config
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTypeAliases(t *testing.T) {
+	TypeAliases["hotplug"] = "unix-hotplug"
+	t.Cleanup(func() { delete(TypeAliases, "hotplug") })
+
+	for alias, typeName := range TypeAliases {
+		// Check aliases don't shadow current device types and map to one.
+		assert.NotContains(t, Types, alias)
+		assert.Contains(t, Types, typeName, alias)
+
+		resolved, legacy := ResolveType(alias)
+		assert.Equal(t, typeName, resolved)
+		assert.True(t, legacy)
+	}
+
+	for _, typeName := range Types {
+		resolved, legacy := ResolveType(typeName)
+		assert.Equal(t, typeName, resolved)
+		assert.False(t, legacy)
+	}
+
+	// Check unknown types are returned unchanged.
+	resolved, legacy := ResolveType("invalid")
+	assert.Equal(t, "invalid", resolved)
+	assert.False(t, legacy)
+}
@@ -4,8 +4,36 @@ import (
 	"fmt"
 	"sort"
 	"strings"
+
+	"github.com/lxc/lxd/shared/validate"
 )
 
+// commonRules are the validation rules for the config keys that apply to all device types.
+var commonRules = map[string]func(value string) error{
+	"requires.device":              validate.Optional(validate.IsDeviceName),
+	"requires.device.policy":       validate.Optional(validate.IsOneOf("skip", "fail")),
+	"requires.capabilities":        validate.Optional(validate.IsListOf(IsCapability)),
+	"requires.capabilities.policy": validate.Optional(validate.IsOneOf("warn", "fail")),
+	"start.async":                  validate.Optional(validate.IsBool),
+	"start.async.grace":            validate.Optional(validate.IsUint32),
+	"stop.verify":                  validate.Optional(validate.IsBool),
+}
+
+// Rules returns the rules that Validate checks the device config with, which are the supplied device specific rules
+// along with the rules of the keys that apply to all device types (unless overridden by the device specific rules).
+func Rules(rules map[string]func(value string) error) map[string]func(value string) error {
+	merged := make(map[string]func(value string) error, len(rules)+len(commonRules))
+	for k, validator := range commonRules {
+		merged[k] = validator
+	}
+
+	for k, validator := range rules {
+		merged[k] = validator
+	}
+
+	return merged
+}
+
 // Device represents a LXD container device.
 type Device map[string]string
 
@@ -20,11 +48,27 @@ func (device Device) Clone() Device {
 	return copy
 }
 
+// Inherit returns a copy of the supplied parent device with the keys of the device applied on top.
+func (device Device) Inherit(parent Device) Device {
+	merged := parent.Clone()
+
+	for k, v := range device {
+		if k == "inherit" {
+			continue
+		}
+
+		merged[k] = v
+	}
+
+	return merged
+}
+
 // Validate accepts a map of field/validation functions to run against the device's config.
 func (device Device) Validate(rules map[string]func(value string) error) error {
 	checkedFields := map[string]struct{}{}
 
-	for k, validator := range rules {
+	// Check the device specific keys along with the common ones.
+	for k, validator := range Rules(rules) {
 		checkedFields[k] = struct{}{} //Mark field as checked.
 		err := validator(device[k])
 		if err != nil {
@@ -188,16 +232,15 @@ func (list Devices) Sorted() DevicesSortable {
 	}
 
 	sort.Sort(sortable)
-	return sortable
+	return sortable.orderRequired()
 }
 
 // Reversed returns the name of all devices in the set, sorted reversed.
 func (list Devices) Reversed() DevicesSortable {
-	sortable := DevicesSortable{}
-	for k, d := range list {
-		sortable = append(sortable, DeviceNamed{k, d})
+	sortable := list.Sorted()
+	for i, j := 0, len(sortable)-1; i < j; i, j = i+1, j-1 {
+		sortable[i], sortable[j] = sortable[j], sortable[i]
 	}
 
-	sort.Sort(sort.Reverse(sortable))
 	return sortable
 }
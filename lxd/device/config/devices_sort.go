@@ -69,3 +69,53 @@ func (devices DevicesSortable) Less(i, j int) bool {
 func (devices DevicesSortable) Swap(i, j int) {
 	devices[i], devices[j] = devices[j], devices[i]
 }
+
+// orderRequired returns the devices with any that require another device (using the "requires.device" key)
+// moved after the device they require, otherwise keeping the existing order. Devices whose required device
+// doesn't exist or that require each other are moved to the end.
+func (devices DevicesSortable) orderRequired() DevicesSortable {
+	names := make(map[string]struct{}, len(devices))
+	for _, dev := range devices {
+		names[dev.Name] = struct{}{}
+	}
+
+	result := make(DevicesSortable, 0, len(devices))
+	placed := make(map[string]struct{}, len(devices))
+	waiting := map[string]DevicesSortable{}
+
+	var place func(dev DeviceNamed)
+	place = func(dev DeviceNamed) {
+		result = append(result, dev)
+		placed[dev.Name] = struct{}{}
+
+		// Place any devices that were waiting for this one.
+		dependents := waiting[dev.Name]
+		delete(waiting, dev.Name)
+		for _, dependent := range dependents {
+			place(dependent)
+		}
+	}
+
+	for _, dev := range devices {
+		required := dev.Config["requires.device"]
+		_, exists := names[required]
+		_, isPlaced := placed[required]
+
+		if required == "" || required == dev.Name || !exists || isPlaced {
+			place(dev)
+			continue
+		}
+
+		waiting[required] = append(waiting[required], dev)
+	}
+
+	// Add any devices left waiting due to circular requirements in their original order.
+	for _, dev := range devices {
+		_, isPlaced := placed[dev.Name]
+		if !isPlaced {
+			result = append(result, dev)
+		}
+	}
+
+	return result
+}
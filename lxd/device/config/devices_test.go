@@ -1,8 +1,11 @@
 package config
 
 import (
+	"fmt"
 	"reflect"
 	"testing"
+
+	"github.com/lxc/lxd/shared/validate"
 )
 
 func TestSortableDevices(t *testing.T) {
@@ -37,3 +40,147 @@ func TestSortableDevices(t *testing.T) {
 		t.Error("devices reverse sorted incorrectly")
 	}
 }
+
+func TestDeviceInherit(t *testing.T) {
+	parent := Device{"type": "usb", "vendorid": "1050", "mode": "0660"}
+	device := Device{"type": "usb", "inherit": "true", "mode": "0666"}
+
+	expected := Device{"type": "usb", "vendorid": "1050", "mode": "0666"}
+
+	result := device.Inherit(parent)
+	if !reflect.DeepEqual(result, expected) {
+		t.Errorf("device inherited incorrectly: %v", result)
+	}
+
+	if parent["mode"] != "0660" {
+		t.Error("parent device modified by inherit")
+	}
+}
+
+func TestDeviceInheritValidate(t *testing.T) {
+	parent := Device{"type": "usb", "vendorid": "1050", "mode": "0660"}
+	rules := map[string]func(value string) error{
+		"vendorid": validate.Optional(validate.IsDeviceID),
+		"mode":     validate.Optional(validate.IsUint32),
+	}
+
+	tests := []struct {
+		device Device
+		valid  bool
+	}{
+		{device: Device{"type": "usb", "inherit": "true", "mode": "0666"}, valid: true},
+		{device: Device{"type": "usb", "inherit": "true", "user.note": "spare"}, valid: true},
+		{device: Device{"type": "usb", "inherit": "true", "mode": "invalid"}, valid: false},
+		{device: Device{"type": "usb", "inherit": "true", "unknown": "1"}, valid: false},
+		{device: Device{"type": "usb", "inherit": "true", "vendorid": "invalid"}, valid: false},
+	}
+
+	for _, test := range tests {
+		err := test.device.Inherit(parent).Validate(rules)
+		if test.valid && err != nil {
+			t.Errorf("override %v should be valid: %v", test.device, err)
+		} else if !test.valid && err == nil {
+			t.Errorf("override %v should be invalid", test.device)
+		}
+	}
+}
+
+func TestSortableDevicesRequires(t *testing.T) {
+	devices := Devices{
+		"dev1": Device{"type": "proxy", "requires.device": "eth0"},
+		"dev2": Device{"type": "disk", "path": "/foo"},
+		"eth0": Device{"type": "nic"},
+	}
+
+	expectedSorted := DevicesSortable{
+		DeviceNamed{Name: "eth0", Config: Device{"type": "nic"}},
+		DeviceNamed{Name: "dev2", Config: Device{"type": "disk", "path": "/foo"}},
+		DeviceNamed{Name: "dev1", Config: Device{"type": "proxy", "requires.device": "eth0"}},
+	}
+
+	result := devices.Sorted()
+	if !reflect.DeepEqual(result, expectedSorted) {
+		t.Errorf("devices sorted incorrectly: %v", result)
+	}
+
+	// Device required by a device sorted before it.
+	devices = Devices{
+		"dev1": Device{"type": "disk", "path": "/foo", "requires.device": "dev2"},
+		"dev2": Device{"type": "disk", "path": "/foo/bar"},
+		"dev3": Device{"type": "disk", "path": "/foo/bar/baz", "requires.device": "missing"},
+	}
+
+	expectedSorted = DevicesSortable{
+		DeviceNamed{Name: "dev2", Config: Device{"type": "disk", "path": "/foo/bar"}},
+		DeviceNamed{Name: "dev1", Config: Device{"type": "disk", "path": "/foo", "requires.device": "dev2"}},
+		DeviceNamed{Name: "dev3", Config: Device{"type": "disk", "path": "/foo/bar/baz", "requires.device": "missing"}},
+	}
+
+	result = devices.Sorted()
+	if !reflect.DeepEqual(result, expectedSorted) {
+		t.Errorf("devices sorted incorrectly: %v", result)
+	}
+
+	expectedReversed := DevicesSortable{expectedSorted[2], expectedSorted[1], expectedSorted[0]}
+
+	result = devices.Reversed()
+	if !reflect.DeepEqual(result, expectedReversed) {
+		t.Errorf("devices reverse sorted incorrectly: %v", result)
+	}
+
+	// Circular requirements keep all devices.
+	devices = Devices{
+		"dev1": Device{"type": "nic", "requires.device": "dev2"},
+		"dev2": Device{"type": "nic", "requires.device": "dev1"},
+	}
+
+	result = devices.Sorted()
+	if len(result) != 2 || result[0].Name != "dev1" || result[1].Name != "dev2" {
+		t.Errorf("devices with circular requirements sorted incorrectly: %v", result)
+	}
+}
+
+func TestDeviceValidateCommonKeys(t *testing.T) {
+	rules := map[string]func(string) error{
+		"path": func(string) error { return nil },
+	}
+
+	device := Device{"type": "disk", "path": "/foo", "requires.device": "eth0", "requires.device.policy": "fail"}
+	err := device.Validate(rules)
+	if err != nil {
+		t.Errorf("unexpected validation error: %v", err)
+	}
+
+	device = Device{"type": "disk", "path": "/foo", "requires.device.policy": "invalid"}
+	err = device.Validate(rules)
+	if err == nil {
+		t.Error("expected validation error for invalid policy")
+	}
+}
+
+func TestDeviceRules(t *testing.T) {
+	rules := map[string]func(string) error{
+		"path":        func(string) error { return nil },
+		"start.async": func(string) error { return fmt.Errorf("Overridden") },
+	}
+
+	// Check the device specific rules are returned along with the common rules.
+	merged := Rules(rules)
+	for _, key := range []string{"path", "requires.device", "requires.device.policy", "start.async"} {
+		_, found := merged[key]
+		if !found {
+			t.Errorf("rule for %q wasn't returned", key)
+		}
+	}
+
+	// Check the device specific rules override the common rules.
+	err := merged["start.async"]("true")
+	if err == nil {
+		t.Error("expected the device specific rule for \"start.async\" to override the common one")
+	}
+
+	// Check the supplied rules aren't modified.
+	if len(rules) != 2 {
+		t.Errorf("expected the supplied rules to be left unchanged, got %d rules", len(rules))
+	}
+}
@@ -0,0 +1,154 @@
+package device
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/lxc/lxd/lxd/instance"
+	"github.com/lxc/lxd/lxd/state"
+	"github.com/lxc/lxd/shared"
+	"github.com/lxc/lxd/shared/logger"
+)
+
+// asyncStartRetryInterval is how long to wait between attempts to start a required device within its grace period.
+const asyncStartRetryInterval = 2 * time.Second
+
+// StartAsync indicates whether the device is started asynchronously once the instance has started rather than
+// whilst it starts (using the "start.async" key), and the grace period that a required device has to start in
+// (using the "start.async.grace" key). Required devices are only started asynchronously if they have a grace period.
+func StartAsync(dev Device) (bool, time.Duration) {
+	config := dev.Config()
+	if !shared.IsTrue(config["start.async"]) || !dev.CanHotPlug() {
+		return false, 0
+	}
+
+	grace, _ := strconv.ParseUint(config["start.async.grace"], 10, 32) // Validated by commonRules.
+
+	required := true
+	requiredIndicator, ok := dev.(RequiredIndicator)
+	if ok {
+		required = requiredIndicator.Required()
+	}
+
+	if required && grace == 0 {
+		return false, 0
+	}
+
+	return true, time.Duration(grace) * time.Second
+}
+
+// validateStartAsync checks the device can be started asynchronously if requested.
+func validateStartAsync(dev Device) error {
+	config := dev.Config()
+	if shared.IsTrue(config["start.async"]) && !dev.CanHotPlug() {
+		return fmt.Errorf("Asynchronous start can only be used with devices that can be hotplugged")
+	}
+
+	if config["start.async.grace"] != "" && !shared.IsTrue(config["start.async"]) {
+		return fmt.Errorf(`"start.async.grace" can only be used with "start.async=true"`)
+	}
+
+	return nil
+}
+
+// StartAsyncRun starts the devices in order in the background once the instance has started, reporting them as
+// starting until then. Each device is started with the start function, which hotplugs the device into the running
+// instance. A device that fails to start is reported as failed, with the error as the reason. If a required device
+// hasn't started by the end of its grace period, the instance is stopped with the stop function as it can't run
+// without it. Stopping the instance's devices waits for their asynchronous start to complete (see StartAsyncWait).
+func StartAsyncRun(s *state.State, inst instance.Instance, devs []Device, start func(dev Device) error, stop func(err error)) {
+	if len(devs) == 0 {
+		return
+	}
+
+	done := make([]chan struct{}, 0, len(devs))
+
+	deviceRuntimesMu.Lock()
+	for _, dev := range devs {
+		ch := make(chan struct{})
+		deviceRuntimeGet(inst, dev.Name()).asyncStart = ch
+		done = append(done, ch)
+	}
+
+	deviceRuntimesMu.Unlock()
+
+	for _, dev := range devs {
+		SetStatus(s, inst, dev.Name(), StatusStarting, "")
+	}
+
+	go func() {
+		var failure error
+
+		for i, dev := range devs {
+			if failure != nil {
+				SetStatus(s, inst, dev.Name(), StatusSkipped, "The instance is being stopped")
+				close(done[i])
+				continue
+			}
+
+			_, grace := StartAsync(dev)
+			err := asyncStartRetry(grace, asyncStartRetryInterval, inst.IsRunning, func() error {
+				SetStatus(s, inst, dev.Name(), StatusStarting, "")
+				return start(dev)
+			})
+
+			if err != nil {
+				SetStatus(s, inst, dev.Name(), StatusFailed, err.Error())
+				logger.Error("Failed asynchronous device start", logger.Ctx{"project": inst.Project().Name, "instance": inst.Name(), "device": dev.Name(), "err": err})
+
+				if grace > 0 {
+					failure = fmt.Errorf("Required device %q didn't start within its grace period: %w", dev.Name(), err)
+				}
+			} else if !inst.IsRunning() {
+				status, _ := Status(inst, dev.Name())
+				if status == StatusStarting {
+					SetStatus(s, inst, dev.Name(), StatusStopped, "")
+				}
+			}
+
+			close(done[i])
+		}
+
+		// The devices' asynchronous start must be complete before stopping the instance, as stopping waits for it.
+		if failure != nil {
+			stop(failure)
+		}
+	}()
+}
+
+// StartAsyncWait waits for the asynchronous start of the device to complete, if one is in progress.
+func StartAsyncWait(inst instance.Instance, deviceName string) {
+	var done chan struct{}
+
+	deviceRuntimesMu.Lock()
+	runtime, ok := deviceRuntimes[deviceRuntimeKey(inst.Project().Name, inst.Name(), deviceName)]
+	if ok {
+		done = runtime.asyncStart
+	}
+
+	deviceRuntimesMu.Unlock()
+
+	if done != nil {
+		<-done
+	}
+}
+
+// asyncStartRetry runs the start function until it succeeds, retrying every interval until the grace period has
+// elapsed (a grace period of zero means there is a single attempt). It stops retrying once running returns false,
+// returning the error of the last attempt (if any).
+func asyncStartRetry(grace time.Duration, interval time.Duration, running func() bool, start func() error) error {
+	deadline := time.Now().Add(grace)
+
+	var err error
+	for running() {
+		err = start()
+		if err == nil || !time.Now().Add(interval).Before(deadline) {
+			return err
+		}
+
+		time.Sleep(interval)
+	}
+
+	return err
+}
@@ -0,0 +1,67 @@
+package device
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAsyncStartRetry(t *testing.T) {
+	running := func() bool { return true }
+
+	// Check a device without a grace period only gets a single attempt.
+	attempts := 0
+	err := asyncStartRetry(0, time.Millisecond, running, func() error {
+		attempts++
+		return fmt.Errorf("Failed")
+	})
+
+	assert.Error(t, err)
+	assert.Equal(t, 1, attempts)
+
+	// Check a device is retried within its grace period until it starts.
+	attempts = 0
+	err = asyncStartRetry(time.Second, time.Millisecond, running, func() error {
+		attempts++
+		if attempts < 3 {
+			return fmt.Errorf("Failed")
+		}
+
+		return nil
+	})
+
+	assert.NoError(t, err)
+	assert.Equal(t, 3, attempts)
+
+	// Check the last error is returned once the grace period has elapsed.
+	attempts = 0
+	err = asyncStartRetry(20*time.Millisecond, 5*time.Millisecond, running, func() error {
+		attempts++
+		return fmt.Errorf("Attempt %d failed", attempts)
+	})
+
+	assert.EqualError(t, err, fmt.Sprintf("Attempt %d failed", attempts))
+	assert.Greater(t, attempts, 1)
+
+	// Check nothing is attempted once the instance isn't running.
+	attempts = 0
+	err = asyncStartRetry(time.Second, time.Millisecond, func() bool { return false }, func() error {
+		attempts++
+		return nil
+	})
+
+	assert.NoError(t, err)
+	assert.Equal(t, 0, attempts)
+
+	// Check retrying stops once the instance stops running.
+	attempts = 0
+	err = asyncStartRetry(time.Second, time.Millisecond, func() bool { return attempts < 2 }, func() error {
+		attempts++
+		return fmt.Errorf("Failed")
+	})
+
+	assert.Error(t, err)
+	assert.Equal(t, 2, attempts)
+}
@@ -0,0 +1,138 @@
+package device
+
+import (
+	"fmt"
+	"strings"
+
+	deviceConfig "github.com/lxc/lxd/lxd/device/config"
+	"github.com/lxc/lxd/lxd/instance"
+	"github.com/lxc/lxd/lxd/instance/instancetype"
+	"github.com/lxc/lxd/lxd/state"
+	"github.com/lxc/lxd/lxd/sys"
+	"github.com/lxc/lxd/shared"
+	"github.com/lxc/lxd/shared/logger"
+)
+
+// capabilitiesInitialUserNamespace lists the capabilities that the kernel only honours in the initial user
+// namespace, so that processes in unprivileged containers never have them for the host's devices and kernel.
+var capabilitiesInitialUserNamespace = []string{
+	"audit_control", "audit_read", "block_suspend", "bpf", "linux_immutable", "mac_admin", "mac_override",
+	"mknod", "perfmon", "sys_module", "sys_pacct", "sys_rawio", "sys_time", "syslog", "wake_alarm",
+}
+
+// requiredCapabilities returns the capabilities that the device needs, both those it declares itself (see
+// CapabilityRequirer) and those listed in its "requires.capabilities" key.
+func requiredCapabilities(dev Device) []string {
+	required := []string{}
+
+	requirer, ok := dev.(CapabilityRequirer)
+	if ok {
+		required = append(required, requirer.RequiredCapabilities()...)
+	}
+
+	for _, name := range shared.SplitNTrimSpace(dev.Config()["requires.capabilities"], ",", -1, true) {
+		name = deviceConfig.CapabilityName(name)
+		if !shared.StringInSlice(name, required) {
+			required = append(required, name)
+		}
+	}
+
+	return required
+}
+
+// missingCapabilities returns the capabilities in the required list that processes in the container won't have,
+// each with the reason it's missing. This accounts for the privilege level of the container, the capabilities
+// dropped from privileged containers and the capabilities dropped or kept by "lxc.cap.drop" and "lxc.cap.keep" in
+// its "raw.lxc" config. Virtual machines have all capabilities, so none are missing.
+func missingCapabilities(s *state.State, instConf instance.ConfigReader, required []string) []string {
+	if instConf.Type() != instancetype.Container || len(required) == 0 {
+		return nil
+	}
+
+	// The instance config isn't known when validating profile devices or the local devices on their own.
+	config := instConf.ExpandedConfig()
+	if config == nil {
+		return nil
+	}
+	privileged := shared.IsTrue(config["security.privileged"])
+
+	// Without the host OS details assume the most capabilities are dropped.
+	sysOS := &sys.OS{}
+	if s != nil && s.OS != nil {
+		sysOS = s.OS
+	}
+
+	defaultDrop := []string{}
+	if privileged {
+		defaultDrop = instance.PrivilegedCapabilitiesDrop(sysOS)
+	}
+
+	// The raw.lxc config is applied after the capabilities dropped by LXD, which an empty "lxc.cap.drop" clears.
+	drop := append([]string{}, defaultDrop...)
+	var keep []string
+	for _, line := range strings.Split(config["raw.lxc"], "\n") {
+		key, value, err := instance.ParseRawLXC(line)
+		if err != nil {
+			continue
+		}
+
+		names := []string{}
+		for _, name := range strings.Fields(value) {
+			names = append(names, deviceConfig.CapabilityName(name))
+		}
+
+		switch key {
+		case "lxc.cap.drop":
+			if len(names) == 0 {
+				drop = []string{}
+				defaultDrop = []string{}
+			}
+
+			drop = append(drop, names...)
+		case "lxc.cap.keep":
+			if len(names) == 0 {
+				keep = nil
+			} else {
+				keep = append(keep, names...)
+			}
+		}
+	}
+
+	missing := []string{}
+	for _, name := range required {
+		var reason string
+		if !privileged && shared.StringInSlice(name, capabilitiesInitialUserNamespace) {
+			reason = "not available to unprivileged containers"
+		} else if keep != nil && !shared.StringInSlice(name, keep) {
+			reason = `not kept by "lxc.cap.keep"`
+		} else if keep == nil && shared.StringInSlice(name, defaultDrop) {
+			reason = "dropped from privileged containers"
+		} else if keep == nil && shared.StringInSlice(name, drop) {
+			reason = `dropped by "lxc.cap.drop"`
+		}
+
+		if reason != "" {
+			missing = append(missing, fmt.Sprintf("%s (%s)", name, reason))
+		}
+	}
+
+	return missing
+}
+
+// validateCapabilities checks whether the container will have the capabilities that the device needs. If not then
+// depending on the "requires.capabilities.policy" key either a warning is logged (warn, the default) or an
+// error is returned (fail), listing the missing capabilities along with the reason each is missing.
+func validateCapabilities(s *state.State, instConf instance.ConfigReader, dev Device) error {
+	missing := missingCapabilities(s, instConf, requiredCapabilities(dev))
+	if len(missing) == 0 {
+		return nil
+	}
+
+	if dev.Config()["requires.capabilities.policy"] == "fail" {
+		return fmt.Errorf("Device requires capabilities that the instance doesn't have: %s", strings.Join(missing, ", "))
+	}
+
+	logger.Warn("Device requires capabilities that the instance doesn't have", logger.Ctx{"project": instConf.Project().Name, "device": dev.Name(), "missing": strings.Join(missing, ", ")})
+
+	return nil
+}
@@ -0,0 +1,62 @@
+package device
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	deviceConfig "github.com/lxc/lxd/lxd/device/config"
+	"github.com/lxc/lxd/lxd/instance/instancetype"
+	"github.com/lxc/lxd/lxd/state"
+	"github.com/lxc/lxd/lxd/sys"
+)
+
+// capabilitiesTestConfigReader is an instance config with only the instance type and config.
+type capabilitiesTestConfigReader struct {
+	schemaConfigReader
+
+	config map[string]string
+}
+
+func (c *capabilitiesTestConfigReader) ExpandedConfig() map[string]string {
+	return c.config
+}
+
+func TestMissingCapabilities(t *testing.T) {
+	s := &state.State{OS: &sys.OS{AppArmorStacking: true}}
+	required := []string{"sys_rawio", "net_admin"}
+
+	container := func(config map[string]string) *capabilitiesTestConfigReader {
+		return &capabilitiesTestConfigReader{schemaConfigReader: schemaConfigReader{instanceType: instancetype.Container}, config: config}
+	}
+
+	// Check unprivileged containers don't have the capabilities only honoured in the initial user namespace.
+	assert.Equal(t, []string{"sys_rawio (not available to unprivileged containers)"}, missingCapabilities(s, container(map[string]string{}), required))
+
+	// Check privileged containers don't have the capabilities dropped from them by default.
+	privileged := map[string]string{"security.privileged": "true"}
+	assert.Equal(t, []string{"sys_rawio (dropped from privileged containers)"}, missingCapabilities(s, container(privileged), required))
+
+	// Check clearing the dropped capabilities in raw.lxc keeps them, but later drops are honoured.
+	privileged["raw.lxc"] = "lxc.cap.drop =\n# Comment\nlxc.cap.drop = net_admin sys_time"
+	assert.Equal(t, []string{"net_admin (dropped by \"lxc.cap.drop\")"}, missingCapabilities(s, container(privileged), required))
+
+	// Check only the kept capabilities are available when lxc.cap.keep is used.
+	privileged["raw.lxc"] = "lxc.cap.drop =\nlxc.cap.keep = CAP_SYS_RAWIO"
+	assert.Equal(t, []string{"net_admin (not kept by \"lxc.cap.keep\")"}, missingCapabilities(s, container(privileged), required))
+
+	// Check nothing is missing for virtual machines or when the instance config isn't known (such as for profiles).
+	assert.Empty(t, missingCapabilities(s, &capabilitiesTestConfigReader{schemaConfigReader: schemaConfigReader{instanceType: instancetype.VM}, config: map[string]string{}}, required))
+	assert.Empty(t, missingCapabilities(s, container(nil), required))
+	assert.Empty(t, missingCapabilities(s, container(map[string]string{}), []string{"net_admin"}))
+}
+
+func TestRequiredCapabilities(t *testing.T) {
+	dev := &perf{deviceCommon{config: deviceConfig.Device{"type": "perf", "requires.capabilities": "CAP_NET_ADMIN, sys_rawio"}}}
+
+	// Check the capabilities declared by the device are merged with those in its config.
+	assert.Equal(t, []string{"sys_rawio", "net_admin"}, requiredCapabilities(dev))
+
+	dev.config["msr"] = "false"
+	assert.Equal(t, []string{"net_admin", "sys_rawio"}, requiredCapabilities(dev))
+}
@@ -0,0 +1,139 @@
+package device
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"golang.org/x/sys/unix"
+
+	"github.com/lxc/lxd/lxd/instance"
+	"github.com/lxc/lxd/lxd/storage/filesystem"
+	"github.com/lxc/lxd/shared"
+	"github.com/lxc/lxd/shared/logger"
+)
+
+// cleanupFilePrefixes lists the prefixes of the host side files (device nodes and mount points) that devices create
+// in the devices directory of the instance.
+var cleanupFilePrefixes = []string{"unix", IBDevPrefix, "disk", "hwmon"}
+
+// cleanupFileMatches indicates whether the host side file name belongs to the device, matching both the file names
+// with the device name encoded and those with it as is.
+func cleanupFileMatches(fileName string, deviceName string) bool {
+	for _, prefix := range cleanupFilePrefixes {
+		for _, devPrefix := range []string{filesystem.PathNameEncode(deviceJoinPath(prefix, deviceName)), deviceJoinPath(prefix, deviceName)} {
+			if fileName == devPrefix || strings.HasPrefix(fileName, devPrefix+".") {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// cleanupResidueFiles returns the host side files of the device that remain in the devices directory. As device
+// names can contain a "." the files of the other devices whose names start with the device name followed by a "."
+// are excluded.
+func cleanupResidueFiles(devicesPath string, deviceName string, otherDeviceNames []string) ([]string, error) {
+	dents, err := os.ReadDir(devicesPath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return []string{}, nil
+		}
+
+		return nil, err
+	}
+
+	residue := []string{}
+	for _, ent := range dents {
+		if !cleanupFileMatches(ent.Name(), deviceName) {
+			continue
+		}
+
+		otherDevice := false
+		for _, otherName := range otherDeviceNames {
+			if strings.HasPrefix(otherName, deviceName+".") && cleanupFileMatches(ent.Name(), otherName) {
+				otherDevice = true
+				break
+			}
+		}
+
+		if !otherDevice {
+			residue = append(residue, filepath.Join(devicesPath, ent.Name()))
+		}
+	}
+
+	sort.Strings(residue)
+
+	return residue, nil
+}
+
+// cleanupRemoveFiles re-attempts removing the host side files, unmounting those that are still mount points first.
+func cleanupRemoveFiles(paths []string) {
+	for _, path := range paths {
+		if filesystem.IsMountPoint(path) {
+			_ = unix.Unmount(path, unix.MNT_DETACH)
+		}
+
+		_ = os.Remove(path)
+	}
+}
+
+// VerifyCleanup checks that stopping the device cleaned up after it when its "stop.verify" key is enabled, for
+// catching the silent cleanup failures that would otherwise accumulate over many start and stop cycles.
+// The host side files of the device must have been removed from the devices directory of the instance and the
+// check function (if not nil) returns any other residue the driver knows about (such as cgroup rules). If there
+// is residue the cleanup is re-attempted, removing the files and calling the retry function (if not nil), and a
+// warning is logged with the details of the residue that remains so that it can be removed manually.
+func VerifyCleanup(inst instance.Instance, dev Device, check func() []string, retry func() error) {
+	if !shared.IsTrue(dev.Config()["stop.verify"]) {
+		return
+	}
+
+	l := logger.AddContext(logger.Log, logger.Ctx{"project": inst.Project().Name, "instance": inst.Name(), "device": dev.Name()})
+
+	otherDeviceNames := []string{}
+	for name := range inst.ExpandedDevices() {
+		if name != dev.Name() {
+			otherDeviceNames = append(otherDeviceNames, name)
+		}
+	}
+
+	residue := func() ([]string, []string) {
+		files, err := cleanupResidueFiles(inst.DevicesPath(), dev.Name(), otherDeviceNames)
+		if err != nil {
+			l.Warn("Failed checking device files were removed", logger.Ctx{"err": err})
+		}
+
+		other := []string{}
+		if check != nil {
+			other = check()
+		}
+
+		return files, other
+	}
+
+	files, other := residue()
+	if len(files) == 0 && len(other) == 0 {
+		return
+	}
+
+	l.Warn("Device cleanup left residue, retrying cleanup", logger.Ctx{"files": strings.Join(files, ", "), "other": strings.Join(other, ", ")})
+
+	cleanupRemoveFiles(files)
+
+	if retry != nil && len(other) > 0 {
+		err := retry()
+		if err != nil {
+			l.Warn("Failed retrying device cleanup", logger.Ctx{"err": err})
+		}
+	}
+
+	files, other = residue()
+	if len(files) == 0 && len(other) == 0 {
+		return
+	}
+
+	l.Warn("Device cleanup left residue that needs removing manually", logger.Ctx{"files": strings.Join(files, ", "), "other": strings.Join(other, ", ")})
+}
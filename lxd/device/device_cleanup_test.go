@@ -0,0 +1,51 @@
+package device
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCleanupResidueFiles(t *testing.T) {
+	devicesPath := t.TempDir()
+
+	for _, name := range []string{
+		"unix.my--dev.dev-ttyUSB0",
+		"disk.my--dev.mnt",
+		"hwmon.my-dev.hwmon0",
+		"infiniband.unix.my--dev.dev-infiniband-uverbs0",
+		"unix.my--dev.other.dev-ttyUSB1",
+		"unix.my--device.dev-ttyUSB2",
+		"virtio-fs.my-dev.sock",
+	} {
+		assert.NoError(t, os.WriteFile(filepath.Join(devicesPath, name), nil, 0600))
+	}
+
+	// Check the files of the device are found, excluding those of other devices with similar names.
+	residue, err := cleanupResidueFiles(devicesPath, "my-dev", []string{"my-dev.other", "my-device"})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{
+		filepath.Join(devicesPath, "disk.my--dev.mnt"),
+		filepath.Join(devicesPath, "hwmon.my-dev.hwmon0"),
+		filepath.Join(devicesPath, "infiniband.unix.my--dev.dev-infiniband-uverbs0"),
+		filepath.Join(devicesPath, "unix.my--dev.dev-ttyUSB0"),
+	}, residue)
+
+	// Check removing the residue leaves the files of the other devices.
+	cleanupRemoveFiles(residue)
+
+	residue, err = cleanupResidueFiles(devicesPath, "my-dev", []string{"my-dev.other", "my-device"})
+	assert.NoError(t, err)
+	assert.Empty(t, residue)
+
+	residue, err = cleanupResidueFiles(devicesPath, "my-device", nil)
+	assert.NoError(t, err)
+	assert.Len(t, residue, 1)
+
+	// Check a missing devices directory has no residue.
+	residue, err = cleanupResidueFiles(filepath.Join(devicesPath, "missing"), "my-dev", nil)
+	assert.NoError(t, err)
+	assert.Empty(t, residue)
+}
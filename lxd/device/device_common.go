@@ -3,15 +3,21 @@ package device
 import (
 	"fmt"
 	"net"
+	"strconv"
+	"time"
 
 	deviceConfig "github.com/lxc/lxd/lxd/device/config"
 	"github.com/lxc/lxd/lxd/instance"
 	"github.com/lxc/lxd/lxd/instance/instancetype"
 	"github.com/lxc/lxd/lxd/network"
 	"github.com/lxc/lxd/lxd/state"
+	"github.com/lxc/lxd/shared"
 	"github.com/lxc/lxd/shared/logger"
 )
 
+// udevSettleTimeoutDefault is the default number of seconds to wait for udev to settle.
+const udevSettleTimeoutDefault = 10
+
 // deviceCommon represents the common struct for all devices.
 type deviceCommon struct {
 	logger      logger.Logger
@@ -116,3 +122,27 @@ func (d *deviceCommon) generateHostName(prefix string, hwaddr string) (string, e
 	// Handle instances.nic.host_name random mode or where no MAC address supplied.
 	return network.RandomDevName(prefix), nil
 }
+
+// settleUdev waits for udev to finish processing its event queue if the device has "udev.settle" enabled.
+// This ensures any udev rules (such as those creating symlinks or setting permissions) have been applied to
+// the host device nodes before they are passed into the instance. The wait is bounded by the
+// "udev.settle.timeout" setting and a timeout is logged rather than failing the device start.
+func (d *deviceCommon) settleUdev() {
+	if shared.IsFalseOrEmpty(d.config["udev.settle"]) {
+		return
+	}
+
+	timeout := udevSettleTimeoutDefault
+	if d.config["udev.settle.timeout"] != "" {
+		timeout, _ = strconv.Atoi(d.config["udev.settle.timeout"])
+	}
+
+	start := time.Now()
+	err := udevSettle(time.Duration(timeout) * time.Second)
+	if err != nil {
+		d.logger.Warn("Failed waiting for udev to settle", logger.Ctx{"timeout": timeout, "err": err})
+		return
+	}
+
+	d.logger.Debug("Waited for udev to settle", logger.Ctx{"duration": time.Since(start)})
+}
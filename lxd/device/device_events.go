@@ -0,0 +1,44 @@
+package device
+
+import (
+	"github.com/lxc/lxd/lxd/instance"
+	"github.com/lxc/lxd/lxd/lifecycle"
+	"github.com/lxc/lxd/lxd/state"
+)
+
+// statusEventActions maps the device start statuses to the action of the lifecycle event sent for them.
+var statusEventActions = map[string]lifecycle.InstanceDeviceAction{
+	StatusStarted: lifecycle.InstanceDeviceAttached,
+	StatusStopped: lifecycle.InstanceDeviceDetached,
+	StatusFailed:  lifecycle.InstanceDeviceFailed,
+	StatusSkipped: lifecycle.InstanceDeviceRequiredMissing,
+	StatusBlocked: lifecycle.InstanceDeviceBlocked,
+}
+
+// hotplugEventAction returns the action of the lifecycle event sent for a hotplug event action.
+func hotplugEventAction(action string) lifecycle.InstanceDeviceAction {
+	if action == "remove" {
+		return lifecycle.InstanceDeviceDetached
+	}
+
+	return lifecycle.InstanceDeviceAttached
+}
+
+// sendInstanceEvent sends a lifecycle event for the device of the instance, along with the reason for it and the
+// identity of the host device it is for (such as its vendor and product IDs), if known.
+func sendInstanceEvent(s *state.State, inst instance.Instance, deviceName string, action lifecycle.InstanceDeviceAction, reason string, identity map[string]string) {
+	if s == nil || s.Events == nil {
+		return
+	}
+
+	ctx := map[string]any{"type": inst.ExpandedDevices()[deviceName]["type"]}
+	if reason != "" {
+		ctx["reason"] = reason
+	}
+
+	if len(identity) > 0 {
+		ctx["identity"] = identity
+	}
+
+	s.Events.SendLifecycle(inst.Project().Name, action.Event(deviceName, inst, ctx))
+}
@@ -0,0 +1,56 @@
+package device
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	deviceConfig "github.com/lxc/lxd/lxd/device/config"
+	"github.com/lxc/lxd/lxd/events"
+	"github.com/lxc/lxd/lxd/operations"
+	"github.com/lxc/lxd/lxd/state"
+	"github.com/lxc/lxd/shared/api"
+)
+
+// eventsTestInstance is an instance with devices that lifecycle events can be sent for.
+type eventsTestInstance struct {
+	lifecycleTestInstance
+}
+
+func (i *eventsTestInstance) ExpandedDevices() deviceConfig.Devices {
+	return deviceConfig.Devices{"dev1": {"type": "usb"}}
+}
+
+func (i *eventsTestInstance) Operation() *operations.Operation {
+	return nil
+}
+
+func TestSetStatusEvent(t *testing.T) {
+	var sent []api.Event
+	s := &state.State{Events: events.NewServer(false, false, func(event api.Event) { sent = append(sent, event) })}
+	inst := &eventsTestInstance{lifecycleTestInstance{name: "c1"}}
+
+	SetStatus(s, inst, "dev1", StatusStarting, "")
+	SetStatus(s, inst, "dev1", StatusFailed, "No matching device")
+
+	// Check only the statuses with a lifecycle action are sent.
+	if !assert.Len(t, sent, 1) {
+		return
+	}
+
+	assert.Equal(t, "default", sent[0].Project)
+	assert.Equal(t, api.EventTypeLifecycle, sent[0].Type)
+
+	var event api.EventLifecycle
+	err := json.Unmarshal(sent[0].Metadata, &event)
+	assert.NoError(t, err)
+	assert.Equal(t, api.EventLifecycleInstanceDeviceFailed, event.Action)
+	assert.Equal(t, "/1.0/instances/c1", event.Source)
+	assert.Equal(t, map[string]any{"device": "dev1", "type": "usb", "reason": "No matching device"}, event.Context)
+
+	// Check the status is still recorded without an events server.
+	SetStatus(nil, inst, "dev1", StatusStarted, "")
+	status, _ := Status(inst, "dev1")
+	assert.Equal(t, StatusStarted, status)
+}
@@ -0,0 +1,152 @@
+package device
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+
+	deviceConfig "github.com/lxc/lxd/lxd/device/config"
+	"github.com/lxc/lxd/lxd/instance"
+	"github.com/lxc/lxd/shared/logger"
+)
+
+// LifecycleHookEvent represents a device lifecycle event that hooks can be registered for.
+type LifecycleHookEvent string
+
+// LifecycleHookPreStart is run before a device is started.
+const LifecycleHookPreStart = LifecycleHookEvent("pre-start")
+
+// LifecycleHookPostStart is run after a device has started and the instance has applied it.
+const LifecycleHookPostStart = LifecycleHookEvent("post-start")
+
+// LifecycleHookPreStop is run before a device is stopped.
+const LifecycleHookPreStop = LifecycleHookEvent("pre-stop")
+
+// LifecycleHookPostStop is run after a device has stopped.
+const LifecycleHookPostStop = LifecycleHookEvent("post-stop")
+
+// LifecycleHookContext is the device context passed to lifecycle hooks.
+type LifecycleHookContext struct {
+	Event    LifecycleHookEvent
+	Project  string
+	Instance string
+	Name     string
+	Config   deviceConfig.Device
+}
+
+// LifecycleHook is a function that is called for device lifecycle events.
+type LifecycleHook func(ctx LifecycleHookContext) error
+
+// lifecycleHook represents a registered lifecycle hook.
+type lifecycleHook struct {
+	events     []LifecycleHookEvent
+	failClosed bool
+	hook       LifecycleHook
+}
+
+// lifecycleHooks stores the registered lifecycle hooks keyed on registration name.
+var lifecycleHooks = map[string]lifecycleHook{}
+
+// lifecycleHooksMu controls access to the lifecycleHooks map.
+var lifecycleHooksMu sync.Mutex
+
+// RegisterLifecycleHook registers a hook to be called for the specified device lifecycle events.
+// If failClosed is true, an error (or panic) from the hook fails the device operation, otherwise it
+// is only logged. Registering a hook with an existing name replaces the previous registration.
+func RegisterLifecycleHook(name string, events []LifecycleHookEvent, failClosed bool, hook LifecycleHook) {
+	lifecycleHooksMu.Lock()
+	defer lifecycleHooksMu.Unlock()
+
+	lifecycleHooks[name] = lifecycleHook{
+		events:     events,
+		failClosed: failClosed,
+		hook:       hook,
+	}
+}
+
+// UnregisterLifecycleHook removes a registered lifecycle hook.
+func UnregisterLifecycleHook(name string) {
+	lifecycleHooksMu.Lock()
+	defer lifecycleHooksMu.Unlock()
+
+	delete(lifecycleHooks, name)
+}
+
+// LifecycleHooksRegistered returns whether any lifecycle hooks are registered for the event.
+func LifecycleHooksRegistered(event LifecycleHookEvent) bool {
+	lifecycleHooksMu.Lock()
+	defer lifecycleHooksMu.Unlock()
+
+	for _, h := range lifecycleHooks {
+		for _, hookEvent := range h.events {
+			if hookEvent == event {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// RunLifecycleHooks runs the lifecycle hooks registered for the event against the device, in order of
+// registration name. Returns an error if a fail-closed hook fails.
+func RunLifecycleHooks(event LifecycleHookEvent, inst instance.Instance, dev Device) error {
+	lifecycleHooksMu.Lock()
+	names := make([]string, 0, len(lifecycleHooks))
+	hooks := make(map[string]lifecycleHook, len(lifecycleHooks))
+	for name, h := range lifecycleHooks {
+		for _, hookEvent := range h.events {
+			if hookEvent == event {
+				names = append(names, name)
+				hooks[name] = h
+				break
+			}
+		}
+	}
+
+	lifecycleHooksMu.Unlock()
+
+	if len(names) == 0 {
+		return nil
+	}
+
+	sort.Strings(names)
+
+	ctx := LifecycleHookContext{
+		Event:    event,
+		Project:  inst.Project().Name,
+		Instance: inst.Name(),
+		Name:     dev.Name(),
+	}
+
+	for _, name := range names {
+		// Give each hook its own copy of the config so it can't modify the device.
+		ctx.Config = dev.Config().Clone()
+
+		err := runLifecycleHook(hooks[name].hook, ctx)
+		if err != nil {
+			l := logger.AddContext(logger.Log, logger.Ctx{"project": ctx.Project, "instance": ctx.Instance, "device": ctx.Name, "event": event, "hook": name, "err": err})
+
+			if hooks[name].failClosed {
+				l.Error("Device lifecycle hook failed")
+				return fmt.Errorf("Device lifecycle hook %q failed for %q event: %w", name, event, err)
+			}
+
+			l.Warn("Device lifecycle hook failed")
+		}
+	}
+
+	return nil
+}
+
+// runLifecycleHook runs a single lifecycle hook, converting any panic into an error.
+func runLifecycleHook(hook LifecycleHook, ctx LifecycleHookContext) (err error) {
+	defer func() {
+		r := recover()
+		if r != nil {
+			err = fmt.Errorf("Hook panicked: %v", r)
+		}
+	}()
+
+	return hook(ctx)
+}
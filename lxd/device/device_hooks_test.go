@@ -0,0 +1,83 @@
+package device
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	deviceConfig "github.com/lxc/lxd/lxd/device/config"
+)
+
+func TestRunLifecycleHooks(t *testing.T) {
+	inst := &lifecycleTestInstance{name: "c1"}
+	dev := &usb{deviceCommon: deviceCommon{inst: inst, name: "usb0", config: deviceConfig.Device{"type": "usb", "vendorid": "1234"}}}
+
+	calls := []string{}
+	RegisterLifecycleHook("b-record", []LifecycleHookEvent{LifecycleHookPreStart, LifecycleHookPostStop}, false, func(ctx LifecycleHookContext) error {
+		calls = append(calls, fmt.Sprintf("%s/%s/%s/%s", ctx.Project, ctx.Instance, ctx.Name, ctx.Event))
+
+		// Modifying the config must not modify the device.
+		ctx.Config["vendorid"] = "5678"
+
+		return nil
+	})
+
+	defer UnregisterLifecycleHook("b-record")
+
+	// Check the registered events are reported.
+	assert.True(t, LifecycleHooksRegistered(LifecycleHookPreStart))
+	assert.True(t, LifecycleHooksRegistered(LifecycleHookPostStop))
+	assert.False(t, LifecycleHooksRegistered(LifecycleHookPreStop))
+
+	// Check only the hooks registered for the event are run, with their own copy of the config.
+	require.NoError(t, RunLifecycleHooks(LifecycleHookPreStop, inst, dev))
+	assert.Empty(t, calls)
+
+	require.NoError(t, RunLifecycleHooks(LifecycleHookPreStart, inst, dev))
+	assert.Equal(t, []string{"default/c1/usb0/pre-start"}, calls)
+	assert.Equal(t, "1234", dev.config["vendorid"])
+
+	// Check a failing or panicking fail-open hook is isolated and the hooks after it are still run.
+	RegisterLifecycleHook("a-panic", []LifecycleHookEvent{LifecycleHookPreStart}, false, func(ctx LifecycleHookContext) error {
+		panic("broken hook")
+	})
+
+	defer UnregisterLifecycleHook("a-panic")
+
+	RegisterLifecycleHook("a-fail", []LifecycleHookEvent{LifecycleHookPreStart}, false, func(ctx LifecycleHookContext) error {
+		return fmt.Errorf("Failed hook")
+	})
+
+	defer UnregisterLifecycleHook("a-fail")
+
+	require.NoError(t, RunLifecycleHooks(LifecycleHookPreStart, inst, dev))
+	assert.Len(t, calls, 2)
+
+	// Check a failing fail-closed hook fails the event, and that the hooks after it aren't run.
+	RegisterLifecycleHook("a-fail", []LifecycleHookEvent{LifecycleHookPreStart}, true, func(ctx LifecycleHookContext) error {
+		return fmt.Errorf("Failed hook")
+	})
+
+	err := RunLifecycleHooks(LifecycleHookPreStart, inst, dev)
+	assert.ErrorContains(t, err, "Failed hook")
+	assert.Len(t, calls, 2)
+
+	// Check a panicking fail-closed hook fails the event rather than LXD.
+	UnregisterLifecycleHook("a-fail")
+	RegisterLifecycleHook("a-panic", []LifecycleHookEvent{LifecycleHookPreStart}, true, func(ctx LifecycleHookContext) error {
+		panic("broken hook")
+	})
+
+	err = RunLifecycleHooks(LifecycleHookPreStart, inst, dev)
+	assert.ErrorContains(t, err, "broken hook")
+	assert.Len(t, calls, 2)
+
+	// Check unregistered hooks are no longer run.
+	UnregisterLifecycleHook("a-panic")
+	UnregisterLifecycleHook("b-record")
+	assert.False(t, LifecycleHooksRegistered(LifecycleHookPreStart))
+	require.NoError(t, RunLifecycleHooks(LifecycleHookPreStart, inst, dev))
+	assert.Len(t, calls, 2)
+}
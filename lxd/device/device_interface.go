@@ -36,6 +36,13 @@ type Device interface {
 	Config() deviceConfig.Device
 	Name() string
 
+	// LifecycleState returns the current lifecycle state of the device.
+	LifecycleState() LifecycleState
+
+	// Transition moves the device to the specified lifecycle state, returning an error if the
+	// transition from the current state isn't valid.
+	Transition(to LifecycleState) error
+
 	// Add performs any host-side setup when a device is added to an instance.
 	// It is called irrespective of whether the instance is running or not.
 	Add() error
@@ -78,11 +85,51 @@ type device interface {
 	// init stores the Instance, daemon State and Config into device and performs any setup.
 	init(instance.Instance, *state.State, string, deviceConfig.Device, VolatileGetter, VolatileSetter)
 
+	// configRules returns the rules that validateConfig checks the Config stored by init() with.
+	configRules(instance.ConfigReader) (map[string]func(value string) error, error)
+
 	// validateConfig checks Config stored by init() is valid for the instance type.
 	validateConfig(instance.ConfigReader) error
 }
 
+// PreStager provides the ability for a device to be pre-staged on a stopped instance.
+type PreStager interface {
+	// PreStage validates the runtime environment of the device and resolves its host hardware so that
+	// problems are reported when the device is configured rather than when the instance next starts.
+	// Any resolution is cached for use when the device is next started.
+	PreStage() error
+}
+
+// RequiredIndicator provides the ability for a device to indicate whether it must start for the instance to start.
+// Devices that don't implement it are considered required.
+type RequiredIndicator interface {
+	Required() bool
+}
+
+// CapabilityRequirer provides the ability for a device to declare the Linux capabilities that processes in the
+// instance need for the device to be usable (such as "sys_rawio"), in the format used by LXC.
+type CapabilityRequirer interface {
+	RequiredCapabilities() []string
+}
+
+// NewConfigValidator provides the ability for a device to check rules that only apply to new or changed device
+// configs, so that the configs stored before the rules were introduced keep working.
+type NewConfigValidator interface {
+	ValidateNewConfig() error
+}
+
+// ResourcesChecker provides the ability for a device that needs specific hardware to check that it is present in
+// a view of the hardware of a host, which isn't necessarily the local host (such as another cluster member).
+type ResourcesChecker interface {
+	CheckResources(resources *api.Resources) error
+}
+
 // NICState provides the ability to access NIC state.
 type NICState interface {
 	State() (*api.InstanceStateNetwork, error)
 }
+
+// DeviceState provides the ability to access device state.
+type DeviceState interface {
+	DeviceState() (*api.InstanceStateDevice, error)
+}
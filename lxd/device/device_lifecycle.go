@@ -0,0 +1,234 @@
+package device
+
+import (
+	"fmt"
+	"time"
+
+	deviceConfig "github.com/lxc/lxd/lxd/device/config"
+	"github.com/lxc/lxd/lxd/instance"
+	"github.com/lxc/lxd/lxd/revert"
+)
+
+// LifecycleState represents the state of a device in its lifecycle.
+type LifecycleState string
+
+// LifecycleStateUnknown indicates the device state hasn't been tracked since LXD started.
+// This is the case for devices of instances that were already running when LXD started.
+const LifecycleStateUnknown = LifecycleState("")
+
+// LifecycleStateStopped indicates the device is stopped.
+const LifecycleStateStopped = LifecycleState("stopped")
+
+// LifecycleStateStarting indicates the device is being started.
+const LifecycleStateStarting = LifecycleState("starting")
+
+// LifecycleStateStarted indicates the device has been started.
+const LifecycleStateStarted = LifecycleState("started")
+
+// LifecycleStateStopping indicates the device is being stopped.
+const LifecycleStateStopping = LifecycleState("stopping")
+
+// lifecycleTransitions lists the states that each state can transition to.
+var lifecycleTransitions = map[LifecycleState][]LifecycleState{
+	LifecycleStateUnknown:  {LifecycleStateStarting, LifecycleStateStarted, LifecycleStateStopping, LifecycleStateStopped},
+	LifecycleStateStopped:  {LifecycleStateStarting},
+	LifecycleStateStarting: {LifecycleStateStarted, LifecycleStateStopped},
+	LifecycleStateStarted:  {LifecycleStateStopping},
+	LifecycleStateStopping: {LifecycleStateStopped, LifecycleStateStarted},
+}
+
+// lifecycleValidateTransition checks whether a device can transition between the supplied states.
+func lifecycleValidateTransition(from LifecycleState, to LifecycleState) error {
+	for _, allowed := range lifecycleTransitions[from] {
+		if allowed == to {
+			return nil
+		}
+	}
+
+	if from == LifecycleStateUnknown {
+		return fmt.Errorf("Invalid device lifecycle state %q", to)
+	}
+
+	return fmt.Errorf("Invalid device lifecycle transition from %q to %q", from, to)
+}
+
+// lifecycleValidateRegister checks whether a device in the supplied state can register for events.
+func lifecycleValidateRegister(state LifecycleState) error {
+	if state == LifecycleStateStopping || state == LifecycleStateStopped {
+		return fmt.Errorf("Device cannot register for events when %s", state)
+	}
+
+	return nil
+}
+
+// lifecycleStateByKey returns the lifecycle state of the device with the supplied runtime key.
+func lifecycleStateByKey(key string) LifecycleState {
+	deviceRuntimesMu.Lock()
+	defer deviceRuntimesMu.Unlock()
+
+	runtime, ok := deviceRuntimes[key]
+	if !ok {
+		return LifecycleStateUnknown
+	}
+
+	return runtime.lifecycle
+}
+
+// lifecycleHandlesEvents indicates whether the device with the supplied hotplug handler key (which uses the same
+// format as the runtime key) should have events processed for it. Events are not processed for stopped devices.
+func lifecycleHandlesEvents(key string) bool {
+	return lifecycleValidateRegister(lifecycleStateByKey(key)) == nil
+}
+
+// LifecycleState returns the current lifecycle state of the device.
+func (d *deviceCommon) LifecycleState() LifecycleState {
+	return lifecycleStateByKey(deviceRuntimeKey(d.inst.Project().Name, d.inst.Name(), d.name))
+}
+
+// Transition moves the device to the specified lifecycle state, returning an error if the transition from
+// the current state isn't valid (such as stopping a device that is already stopped).
+func (d *deviceCommon) Transition(to LifecycleState) error {
+	deviceRuntimesMu.Lock()
+	defer deviceRuntimesMu.Unlock()
+
+	runtime := deviceRuntimeGet(d.inst, d.name)
+
+	err := lifecycleValidateTransition(runtime.lifecycle, to)
+	if err != nil {
+		return fmt.Errorf("Device %q: %w", d.name, err)
+	}
+
+	runtime.lifecycle = to
+
+	if to == LifecycleStateStopped {
+		runtime.runConf = nil
+	}
+
+	return nil
+}
+
+// Start starts the device unless it is already started, in which case starting it again is a no-op that returns
+// the run-time configuration the device was started with along with true, so that it can be safely retried.
+// Otherwise the device moves to the starting state, the pre-start lifecycle hooks are run and the device is started
+// (limiting the number of device operations run concurrently on the host). If this fails, the device moves back
+// to the stopped state. Once the returned run-time configuration has been applied, the caller records it with
+// RecordRunConfig and moves the device to the started state.
+func Start(inst instance.Instance, dev Device) (*deviceConfig.RunConfig, bool, error) {
+	deviceRuntimesMu.Lock()
+	runtime := deviceRuntimeGet(inst, dev.Name())
+	if runtime.lifecycle == LifecycleStateStarted {
+		runConf := runtime.runConf
+		deviceRuntimesMu.Unlock()
+
+		return runConf, true, nil
+	}
+
+	err := lifecycleValidateTransition(runtime.lifecycle, LifecycleStateStarting)
+	if err == nil {
+		runtime.lifecycle = LifecycleStateStarting
+	}
+
+	deviceRuntimesMu.Unlock()
+
+	if err != nil {
+		return nil, false, fmt.Errorf("Device %q: %w", dev.Name(), err)
+	}
+
+	revert := revert.New()
+	defer revert.Fail()
+	revert.Add(func() { _ = dev.Transition(LifecycleStateStopped) })
+
+	err = RunLifecycleHooks(LifecycleHookPreStart, inst, dev)
+	if err != nil {
+		return nil, false, err
+	}
+
+	release := OperationAcquire()
+	defer release()
+
+	start := time.Now()
+	runConf, err := dev.Start()
+	RecordTiming(inst, dev.Name(), "start", time.Since(start))
+	if err != nil {
+		return nil, false, err
+	}
+
+	revert.Success()
+	return runConf, false, nil
+}
+
+// Stop stops the device unless it is already stopped, in which case stopping it again is a no-op that returns
+// true, so that it can be safely retried. Otherwise the device moves to the stopping state, the pre-stop lifecycle
+// hooks are run and the device is stopped (limiting the number of device operations run concurrently on the host).
+// If this fails, the device moves back to the started state so that stopping it can be retried. Once the returned
+// run-time configuration has been applied, the caller moves the device to the stopped state.
+func Stop(inst instance.Instance, dev Device) (*deviceConfig.RunConfig, bool, error) {
+	deviceRuntimesMu.Lock()
+	runtime := deviceRuntimeGet(inst, dev.Name())
+	if runtime.lifecycle == LifecycleStateStopped {
+		deviceRuntimesMu.Unlock()
+
+		return nil, true, nil
+	}
+
+	err := lifecycleValidateTransition(runtime.lifecycle, LifecycleStateStopping)
+	if err == nil {
+		runtime.lifecycle = LifecycleStateStopping
+	}
+
+	deviceRuntimesMu.Unlock()
+
+	if err != nil {
+		return nil, false, fmt.Errorf("Device %q: %w", dev.Name(), err)
+	}
+
+	revert := revert.New()
+	defer revert.Fail()
+	revert.Add(func() { _ = dev.Transition(LifecycleStateStarted) })
+
+	err = RunLifecycleHooks(LifecycleHookPreStop, inst, dev)
+	if err != nil {
+		return nil, false, err
+	}
+
+	release := OperationAcquire()
+	defer release()
+
+	runConf, err := dev.Stop()
+	if err != nil {
+		return nil, false, err
+	}
+
+	revert.Success()
+	return runConf, false, nil
+}
+
+// RecordRunConfig records the run-time configuration that the device was started with, so that it can be
+// returned if the device is started again whilst started (see Start).
+func RecordRunConfig(inst instance.Instance, deviceName string, runConf *deviceConfig.RunConfig) {
+	deviceRuntimesMu.Lock()
+	defer deviceRuntimesMu.Unlock()
+
+	deviceRuntimeGet(inst, deviceName).runConf = runConf
+}
+
+// ValidateRegister returns an error if the device's lifecycle state doesn't allow it to register for events.
+func ValidateRegister(dev Device) error {
+	err := lifecycleValidateRegister(dev.LifecycleState())
+	if err != nil {
+		return fmt.Errorf("Device %q: %w", dev.Name(), err)
+	}
+
+	return nil
+}
+
+// ResetLifecycleState discards the lifecycle state of the instance's device.
+// This is used when the instance isn't running, as any state left from a previous run is then stale.
+func ResetLifecycleState(inst instance.Instance, deviceName string) {
+	deviceRuntimesMu.Lock()
+	defer deviceRuntimesMu.Unlock()
+
+	runtime := deviceRuntimeGet(inst, deviceName)
+	runtime.lifecycle = LifecycleStateUnknown
+	runtime.runConf = nil
+}
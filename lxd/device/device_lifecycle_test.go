@@ -0,0 +1,201 @@
+package device
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	deviceConfig "github.com/lxc/lxd/lxd/device/config"
+	"github.com/lxc/lxd/lxd/instance"
+	"github.com/lxc/lxd/shared/api"
+)
+
+// lifecycleTestInstance is an instance that only has a project and a name, which is all that is needed to track
+// the lifecycle state of its devices.
+type lifecycleTestInstance struct {
+	instance.Instance
+
+	name string
+}
+
+func (i *lifecycleTestInstance) Project() api.Project {
+	return api.Project{Name: "default"}
+}
+
+func (i *lifecycleTestInstance) Name() string {
+	return i.name
+}
+
+func TestLifecycleValidateTransition(t *testing.T) {
+	// Check the normal lifecycle is allowed.
+	states := []LifecycleState{LifecycleStateStopped, LifecycleStateStarting, LifecycleStateStarted, LifecycleStateStopping, LifecycleStateStopped}
+	for i := 1; i < len(states); i++ {
+		assert.NoError(t, lifecycleValidateTransition(states[i-1], states[i]))
+	}
+
+	// Check failing to start or stop is allowed.
+	assert.NoError(t, lifecycleValidateTransition(LifecycleStateStarting, LifecycleStateStopped))
+	assert.NoError(t, lifecycleValidateTransition(LifecycleStateStopping, LifecycleStateStarted))
+
+	// Check any state is allowed when the state isn't known (such as after LXD restarts).
+	for _, state := range []LifecycleState{LifecycleStateStarting, LifecycleStateStarted, LifecycleStateStopping, LifecycleStateStopped} {
+		assert.NoError(t, lifecycleValidateTransition(LifecycleStateUnknown, state))
+	}
+
+	// Check illegal transitions are rejected.
+	illegal := [][2]LifecycleState{
+		{LifecycleStateStopped, LifecycleStateStopping},  // Double stop.
+		{LifecycleStateStopped, LifecycleStateStopped},   // Double stop.
+		{LifecycleStateStarted, LifecycleStateStarting},  // Double start.
+		{LifecycleStateStarting, LifecycleStateStarting}, // Double start.
+		{LifecycleStateStopped, LifecycleStateStarted},   // Started without starting.
+		{LifecycleStateStarting, LifecycleStateStopping}, // Stopped whilst starting.
+		{LifecycleStateStopping, LifecycleStateStarting}, // Started whilst stopping.
+		{LifecycleStateStarted, LifecycleStateStopped},   // Stopped without stopping.
+		{LifecycleStateUnknown, LifecycleState("invalid")},
+	}
+
+	for _, transition := range illegal {
+		assert.Error(t, lifecycleValidateTransition(transition[0], transition[1]), "%q to %q", transition[0], transition[1])
+	}
+}
+
+func TestLifecycleValidateRegister(t *testing.T) {
+	// Check registering is allowed when starting, started or unknown (such as when LXD starts).
+	for _, state := range []LifecycleState{LifecycleStateUnknown, LifecycleStateStarting, LifecycleStateStarted} {
+		assert.NoError(t, lifecycleValidateRegister(state))
+	}
+
+	// Check registering before start or after stop is rejected.
+	for _, state := range []LifecycleState{LifecycleStateStopping, LifecycleStateStopped} {
+		assert.Error(t, lifecycleValidateRegister(state))
+	}
+}
+
+func TestLifecycleHandlesEvents(t *testing.T) {
+	key := deviceRuntimeKey("default", "c1", "dev1")
+	defer ForgetRuntime("default", "c1", "dev1")
+
+	// Check events are processed for devices whose state isn't known.
+	assert.True(t, lifecycleHandlesEvents(key))
+
+	deviceRuntimesMu.Lock()
+	deviceRuntimes[key] = &deviceRuntime{lifecycle: LifecycleStateStarted}
+	deviceRuntimesMu.Unlock()
+	assert.True(t, lifecycleHandlesEvents(key))
+
+	// Check no events are processed in the stopped state.
+	deviceRuntimesMu.Lock()
+	deviceRuntimes[key].lifecycle = LifecycleStateStopped
+	deviceRuntimesMu.Unlock()
+	assert.False(t, lifecycleHandlesEvents(key))
+}
+
+// lifecycleTestUSB is a usb device whose Start and Stop functions only count how many times they are called
+// and fail when requested, so that its lifecycle can be tested without a host USB device.
+type lifecycleTestUSB struct {
+	usb
+
+	starts  int
+	stops   int
+	fail    bool
+	runConf *deviceConfig.RunConfig
+}
+
+func (d *lifecycleTestUSB) Start() (*deviceConfig.RunConfig, error) {
+	d.starts++
+	if d.fail {
+		return nil, fmt.Errorf("Failed starting")
+	}
+
+	return d.runConf, nil
+}
+
+func (d *lifecycleTestUSB) Stop() (*deviceConfig.RunConfig, error) {
+	d.stops++
+	if d.fail {
+		return nil, fmt.Errorf("Failed stopping")
+	}
+
+	return &deviceConfig.RunConfig{}, nil
+}
+
+func TestLifecycleIdempotentUSB(t *testing.T) {
+	inst := &lifecycleTestInstance{name: "c1"}
+	dev := &lifecycleTestUSB{usb: usb{deviceCommon: deviceCommon{inst: inst, name: "usb0", config: deviceConfig.Device{"type": "usb", "vendorid": "1234"}}}}
+	dev.runConf = &deviceConfig.RunConfig{PostHooks: []func() error{dev.Register}}
+	defer ForgetRuntime("default", "c1", "usb0")
+
+	// Check a device whose state isn't known is started.
+	runConf, started, err := Start(inst, dev)
+	require.NoError(t, err)
+	assert.False(t, started)
+	assert.Same(t, dev.runConf, runConf)
+	assert.Equal(t, LifecycleStateStarting, dev.LifecycleState())
+
+	// Check starting the device again whilst it is being started fails rather than starting it twice.
+	_, _, err = Start(inst, dev)
+	assert.Error(t, err)
+	assert.Equal(t, 1, dev.starts)
+
+	RecordRunConfig(inst, dev.Name(), runConf)
+	require.NoError(t, dev.Transition(LifecycleStateStarted))
+
+	// Check starting the started device again is a no-op returning the same run-time configuration.
+	for i := 0; i < 2; i++ {
+		runConf, started, err = Start(inst, dev)
+		require.NoError(t, err)
+		assert.True(t, started)
+		assert.Same(t, dev.runConf, runConf)
+		assert.Equal(t, 1, dev.starts)
+		assert.Equal(t, LifecycleStateStarted, dev.LifecycleState())
+	}
+
+	// Check a device that fails to stop is considered still started, so that stopping it can be retried.
+	dev.fail = true
+	_, _, err = Stop(inst, dev)
+	assert.Error(t, err)
+	assert.Equal(t, LifecycleStateStarted, dev.LifecycleState())
+
+	// Check the started device is stopped.
+	dev.fail = false
+	_, stopped, err := Stop(inst, dev)
+	require.NoError(t, err)
+	assert.False(t, stopped)
+	assert.Equal(t, 2, dev.stops)
+	assert.Equal(t, LifecycleStateStopping, dev.LifecycleState())
+	require.NoError(t, dev.Transition(LifecycleStateStopped))
+
+	// Check stopping the stopped device again is a no-op.
+	for i := 0; i < 2; i++ {
+		_, stopped, err = Stop(inst, dev)
+		require.NoError(t, err)
+		assert.True(t, stopped)
+		assert.Equal(t, 2, dev.stops)
+		assert.Equal(t, LifecycleStateStopped, dev.LifecycleState())
+	}
+
+	// Check a device that fails to start is stopped, so that starting it can be retried.
+	dev.fail = true
+	_, _, err = Start(inst, dev)
+	assert.Error(t, err)
+	assert.Equal(t, LifecycleStateStopped, dev.LifecycleState())
+
+	// Check the stopped device is started again, rather than returning the discarded run-time configuration.
+	dev.fail = false
+	_, started, err = Start(inst, dev)
+	require.NoError(t, err)
+	assert.False(t, started)
+	assert.Equal(t, 3, dev.starts)
+	RecordRunConfig(inst, dev.Name(), dev.runConf)
+	require.NoError(t, dev.Transition(LifecycleStateStarted))
+
+	// Check resetting the state discards the run-time configuration, so that the device is started again.
+	ResetLifecycleState(inst, dev.Name())
+	_, started, err = Start(inst, dev)
+	require.NoError(t, err)
+	assert.False(t, started)
+	assert.Equal(t, 4, dev.starts)
+}
@@ -2,14 +2,40 @@ package device
 
 import (
 	"fmt"
+	"sync"
+	"time"
 
 	deviceConfig "github.com/lxc/lxd/lxd/device/config"
 	"github.com/lxc/lxd/lxd/device/nictype"
 	"github.com/lxc/lxd/lxd/instance"
 	"github.com/lxc/lxd/lxd/state"
+	"github.com/lxc/lxd/shared/logger"
 	"github.com/lxc/lxd/shared/validate"
 )
 
+// typeAliasesWarned records the device type aliases that a deprecation warning has been logged for.
+var typeAliasesWarned sync.Map
+
+// resolveTypeAlias returns a copy of the device config with a device type alias (see deviceConfig.TypeAliases)
+// replaced by the current device type name, logging a deprecation warning the first time each alias is used.
+// The config is returned as is if it doesn't use a device type alias.
+func resolveTypeAlias(conf deviceConfig.Device) deviceConfig.Device {
+	typeName, alias := deviceConfig.ResolveType(conf["type"])
+	if !alias {
+		return conf
+	}
+
+	_, warned := typeAliasesWarned.LoadOrStore(conf["type"], true)
+	if !warned {
+		logger.Warn("Device type name is deprecated, please use the current name instead", logger.Ctx{"type": conf["type"], "current": typeName})
+	}
+
+	conf = conf.Clone()
+	conf["type"] = typeName
+
+	return conf
+}
+
 // newByType returns a new unitialised device based of the type indicated by the project and device config.
 func newByType(state *state.State, projectName string, conf deviceConfig.Device) (device, error) {
 	if conf["type"] == "" {
@@ -81,6 +107,18 @@ func newByType(state *state.State, projectName string, conf deviceConfig.Device)
 		dev = &tpm{}
 	case "pci":
 		dev = &pci{}
+	case "perf":
+		dev = &perf{}
+	case "input":
+		dev = &input{}
+	case "timer":
+		dev = &timer{}
+	case "ipmi":
+		dev = &ipmi{}
+	case "scsi":
+		dev = &scsi{}
+	case "hwmon":
+		dev = &hwmon{}
 	}
 
 	// Check a valid device type has been found.
@@ -94,6 +132,7 @@ func newByType(state *state.State, projectName string, conf deviceConfig.Device)
 // load instantiates a device and initialises its internal state. It does not validate the config supplied.
 func load(inst instance.Instance, state *state.State, projectName string, name string, conf deviceConfig.Device, volatileGet VolatileGetter, volatileSet VolatileSetter) (device, error) {
 	// Warning: When validating a profile, inst is expected to be provided as nil.
+	conf = resolveTypeAlias(conf)
 	dev, err := newByType(state, projectName, conf)
 	if err != nil {
 		return nil, fmt.Errorf("Failed loading device %q: %w", name, err)
@@ -125,7 +164,14 @@ func New(inst instance.Instance, state *state.State, name string, conf deviceCon
 		return dev, err
 	}
 
+	start := time.Now()
 	err = dev.validateConfig(inst)
+	RecordTiming(inst, name, "validate", time.Since(start))
+	if err != nil {
+		return dev, err
+	}
+
+	err = validateStartAsync(dev)
 	if err != nil {
 		return dev, err
 	}
@@ -147,13 +193,54 @@ func Validate(instConfig instance.ConfigReader, state *state.State, name string,
 		return err
 	}
 
-	return dev.validateConfig(instConfig)
+	err = dev.validateConfig(instConfig)
+	if err != nil {
+		return err
+	}
+
+	err = validateStartAsync(dev)
+	if err != nil {
+		return err
+	}
+
+	return validateCapabilities(state, instConfig, dev)
+}
+
+// ValidateNewDevices checks the devices that are added or changed in the new devices compared to the old ones
+// against the rules that only apply to new or changed device configs (see NewConfigValidator).
+func ValidateNewDevices(state *state.State, projectName string, oldDevices deviceConfig.Devices, newDevices deviceConfig.Devices) error {
+	// Compare with the normalised old configs so that equivalent configs aren't considered changed.
+	oldDevices = oldDevices.Clone()
+	oldDevices.Normalise()
+
+	for name, conf := range newDevices {
+		if oldDevices.Contains(name, conf) {
+			continue
+		}
+
+		dev, err := load(nil, state, projectName, name, conf.Clone(), nil, nil)
+		if err != nil {
+			continue // Invalid devices are reported by their validation.
+		}
+
+		validator, ok := dev.(NewConfigValidator)
+		if !ok {
+			continue
+		}
+
+		err = validator.ValidateNewConfig()
+		if err != nil {
+			return fmt.Errorf("Device validation failed for %q: %w", name, err)
+		}
+	}
+
+	return nil
 }
 
 // LoadByType loads a device by type based on its project and config.
 // It does not validate config beyond the type fields.
 func LoadByType(state *state.State, projectName string, conf deviceConfig.Device) (Type, error) {
-	dev, err := newByType(state, projectName, conf)
+	dev, err := newByType(state, projectName, resolveTypeAlias(conf))
 	if err != nil {
 		return nil, fmt.Errorf("Failed loading device type: %w", err)
 	}
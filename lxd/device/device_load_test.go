@@ -0,0 +1,31 @@
+package device
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	deviceConfig "github.com/lxc/lxd/lxd/device/config"
+)
+
+func TestValidateNewDevices(t *testing.T) {
+	oldDevices := deviceConfig.Devices{
+		"disk0": deviceConfig.Device{"type": "disk", "source": "/srv", "path": "/srv", "limits.max": "10MB", "limits.read": "5MB"},
+	}
+
+	// Check the existing devices aren't subject to the rules for new device configs.
+	newDevices := oldDevices.Clone()
+	assert.NoError(t, ValidateNewDevices(nil, "default", oldDevices, newDevices))
+
+	// Check the changed devices are.
+	newDevices["disk0"]["limits.read"] = "6MB"
+	assert.ErrorContains(t, ValidateNewDevices(nil, "default", oldDevices, newDevices), `Cannot use "limits.max" together with "limits.read"`)
+
+	// Check the added devices are.
+	newDevices = oldDevices.Clone()
+	newDevices["disk1"] = deviceConfig.Device{"type": "disk", "source": "/data", "path": "/data", "readonly": "true", "limits.write": "5MB"}
+	assert.ErrorContains(t, ValidateNewDevices(nil, "default", oldDevices, newDevices), `Cannot use "readonly=true" together with "limits.write"`)
+
+	newDevices["disk1"]["limits.write"] = ""
+	assert.NoError(t, ValidateNewDevices(nil, "default", oldDevices, newDevices))
+}
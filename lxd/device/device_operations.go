@@ -0,0 +1,83 @@
+package device
+
+import (
+	"runtime"
+	"sync"
+
+	"github.com/lxc/lxd/shared/logger"
+)
+
+// deviceOperationsMinLimit is the minimum number of concurrent device operations when the limit is automatic.
+const deviceOperationsMinLimit = 4
+
+// deviceOperations limits the number of device operations (starting or stopping a device) run concurrently on the
+// host, such as when many instances are started at once. Access is controlled by mu.
+var deviceOperations = struct {
+	mu      sync.Mutex
+	cond    *sync.Cond
+	limit   int
+	running int
+}{}
+
+func init() {
+	deviceOperations.cond = sync.NewCond(&deviceOperations.mu)
+	deviceOperations.limit = deviceOperationsAutoLimit()
+}
+
+// deviceOperationsAutoLimit returns the automatic limit of concurrent device operations, based on the number of
+// CPUs of the host.
+func deviceOperationsAutoLimit() int {
+	limit := runtime.NumCPU()
+	if limit < deviceOperationsMinLimit {
+		return deviceOperationsMinLimit
+	}
+
+	return limit
+}
+
+// SetOperationsLimit sets the maximum number of device operations run concurrently on the host. A limit of zero
+// uses the automatic limit (the number of CPUs, with a minimum of 4). Operations that are already running when
+// the limit is lowered are allowed to complete.
+func SetOperationsLimit(limit int) {
+	if limit <= 0 {
+		limit = deviceOperationsAutoLimit()
+	}
+
+	deviceOperations.mu.Lock()
+	defer deviceOperations.mu.Unlock()
+
+	if deviceOperations.limit == limit {
+		return
+	}
+
+	deviceOperations.limit = limit
+	logger.Debug("Set device operations limit", logger.Ctx{"limit": limit})
+
+	// Operations waiting for a slot may be able to run now.
+	deviceOperations.cond.Broadcast()
+}
+
+// OperationAcquire waits until the device operation can run within the concurrency limit and returns the function
+// to call once it has completed. The limit must only be held whilst the device's own operation runs and never
+// whilst waiting for other devices (such as the devices it requires), so that devices can't deadlock each other.
+func OperationAcquire() func() {
+	deviceOperations.mu.Lock()
+	defer deviceOperations.mu.Unlock()
+
+	for deviceOperations.running >= deviceOperations.limit {
+		deviceOperations.cond.Wait()
+	}
+
+	deviceOperations.running++
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			deviceOperations.mu.Lock()
+			defer deviceOperations.mu.Unlock()
+
+			deviceOperations.running--
+			deviceOperations.cond.Signal()
+		})
+	}
+}
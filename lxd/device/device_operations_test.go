@@ -0,0 +1,63 @@
+package device
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOperationAcquire(t *testing.T) {
+	SetOperationsLimit(2)
+	defer SetOperationsLimit(0)
+
+	var mu sync.Mutex
+	running := 0
+	maxRunning := 0
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			release := OperationAcquire()
+			defer release()
+
+			mu.Lock()
+			running++
+			if running > maxRunning {
+				maxRunning = running
+			}
+
+			mu.Unlock()
+
+			time.Sleep(10 * time.Millisecond)
+
+			mu.Lock()
+			running--
+			mu.Unlock()
+		}()
+	}
+
+	wg.Wait()
+	assert.Equal(t, 2, maxRunning)
+
+	// Releasing more than once only frees the slot once.
+	release := OperationAcquire()
+	release()
+	release()
+	assert.Equal(t, 0, deviceOperations.running)
+
+	// Raising the limit lets waiting operations run.
+	SetOperationsLimit(1)
+	release = OperationAcquire()
+
+	acquired := make(chan func())
+	go func() { acquired <- OperationAcquire() }()
+
+	SetOperationsLimit(2)
+	(<-acquired)()
+	release()
+}
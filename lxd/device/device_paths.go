@@ -0,0 +1,109 @@
+package device
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"golang.org/x/sys/unix"
+
+	deviceConfig "github.com/lxc/lxd/lxd/device/config"
+	"github.com/lxc/lxd/lxd/instance"
+)
+
+// pathsContainerPath returns the absolute container path of the mount target path, which is relative to the root of
+// the container.
+func pathsContainerPath(targetPath string) string {
+	return filepath.Join("/", targetPath)
+}
+
+// pathsSameDeviceNode indicates whether both host side files are the same character or block device node.
+// Some devices share a device node on the same container path (such as the control nodes of NVIDIA GPUs), which
+// doesn't conflict as the container gets the same device whichever of them is mounted.
+func pathsSameDeviceNode(pathA string, pathB string) bool {
+	var statA, statB unix.Stat_t
+
+	if unix.Stat(pathA, &statA) != nil || unix.Stat(pathB, &statB) != nil {
+		return false
+	}
+
+	typeA := statA.Mode & unix.S_IFMT
+	if typeA != unix.S_IFCHR && typeA != unix.S_IFBLK {
+		return false
+	}
+
+	return typeA == statB.Mode&unix.S_IFMT && statA.Rdev == statB.Rdev
+}
+
+// pathsConflict returns the container path and the name of the other device for the first mount that mounts a
+// different file on the same container path as a mount of another device, checking the other devices in name order.
+func pathsConflict(mounts []deviceConfig.MountEntryItem, otherMounts map[string][]deviceConfig.MountEntryItem) (string, string) {
+	otherNames := make([]string, 0, len(otherMounts))
+	for name := range otherMounts {
+		otherNames = append(otherNames, name)
+	}
+
+	sort.Strings(otherNames)
+
+	for _, mount := range mounts {
+		if mount.DevPath == "" || mount.TargetPath == "" {
+			continue
+		}
+
+		containerPath := pathsContainerPath(mount.TargetPath)
+
+		for _, otherName := range otherNames {
+			for _, otherMount := range otherMounts[otherName] {
+				if otherMount.DevPath == "" || pathsContainerPath(otherMount.TargetPath) != containerPath {
+					continue
+				}
+
+				if otherMount.DevPath == mount.DevPath || pathsSameDeviceNode(otherMount.DevPath, mount.DevPath) {
+					continue
+				}
+
+				return containerPath, otherName
+			}
+		}
+	}
+
+	return "", ""
+}
+
+// CheckPathConflicts checks that none of the container paths that the run-time configuration of the device mounts
+// are also used by another started device of the instance, whatever the types of the devices, returning an error
+// identifying both devices if one is. This stops a device from silently replacing the device node or mount of
+// another device, such as a usb and a unix-char device both creating the same device node.
+func CheckPathConflicts(inst instance.Instance, deviceName string, runConf *deviceConfig.RunConfig) error {
+	if runConf == nil || len(runConf.Mounts) == 0 {
+		return nil
+	}
+
+	prefix := deviceRuntimeKey(inst.Project().Name, inst.Name(), "")
+	devices := inst.ExpandedDevices()
+	otherMounts := map[string][]deviceConfig.MountEntryItem{}
+
+	deviceRuntimesMu.Lock()
+	for key, runtime := range deviceRuntimes {
+		if !strings.HasPrefix(key, prefix) || runtime.runConf == nil {
+			continue
+		}
+
+		// Only check the devices that are still in the instance config.
+		otherName := strings.TrimPrefix(key, prefix)
+		_, found := devices[otherName]
+		if found && otherName != deviceName {
+			otherMounts[otherName] = runtime.runConf.Mounts
+		}
+	}
+
+	deviceRuntimesMu.Unlock()
+
+	containerPath, otherName := pathsConflict(runConf.Mounts, otherMounts)
+	if containerPath != "" {
+		return fmt.Errorf("Device %q and device %q both use the container path %q", deviceName, otherName, containerPath)
+	}
+
+	return nil
+}
@@ -0,0 +1,47 @@
+package device
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	deviceConfig "github.com/lxc/lxd/lxd/device/config"
+)
+
+func TestPathsConflict(t *testing.T) {
+	devicesPath := t.TempDir()
+	for _, name := range []string{"usb", "unix", "disk"} {
+		assert.NoError(t, os.WriteFile(filepath.Join(devicesPath, name), nil, 0600))
+	}
+
+	usbMounts := []deviceConfig.MountEntryItem{
+		{DevPath: filepath.Join(devicesPath, "usb"), TargetPath: "dev/bus/usb/001/002"},
+	}
+
+	otherMounts := map[string][]deviceConfig.MountEntryItem{
+		"disk": {{DevPath: filepath.Join(devicesPath, "disk"), TargetPath: "mnt"}},
+	}
+
+	// Check mounts on different container paths don't conflict.
+	containerPath, otherName := pathsConflict(usbMounts, otherMounts)
+	assert.Empty(t, containerPath)
+	assert.Empty(t, otherName)
+
+	// Check a different file on the same container path conflicts, whether or not the target path is absolute.
+	otherMounts["unix"] = []deviceConfig.MountEntryItem{
+		{DevPath: filepath.Join(devicesPath, "unix"), TargetPath: "/dev/bus/usb/001/002"},
+	}
+
+	containerPath, otherName = pathsConflict(usbMounts, otherMounts)
+	assert.Equal(t, "/dev/bus/usb/001/002", containerPath)
+	assert.Equal(t, "unix", otherName)
+
+	// Check the same file on the same container path doesn't conflict.
+	otherMounts["unix"][0].DevPath = filepath.Join(devicesPath, "usb")
+
+	containerPath, otherName = pathsConflict(usbMounts, otherMounts)
+	assert.Empty(t, containerPath)
+	assert.Empty(t, otherName)
+}
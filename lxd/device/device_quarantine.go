@@ -0,0 +1,238 @@
+package device
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+
+	"gopkg.in/yaml.v2"
+
+	pcidev "github.com/lxc/lxd/lxd/device/pci"
+	"github.com/lxc/lxd/shared"
+	"github.com/lxc/lxd/shared/api"
+	"github.com/lxc/lxd/shared/logger"
+	"github.com/lxc/lxd/shared/validate"
+)
+
+// quarantineDriverOverride is the driver override set on quarantined devices. As there is no driver of that
+// name, this stops any driver from binding to the device.
+const quarantineDriverOverride = "none"
+
+// quarantineMu controls access to the quarantine file.
+var quarantineMu sync.Mutex
+
+// quarantinePath returns the path of the file recording the quarantined devices. It is kept in LXD_DIR so that
+// the quarantine persists across LXD restarts.
+func quarantinePath() string {
+	return shared.VarPath("device-quarantine.yaml")
+}
+
+// quarantineLoad returns the quarantined devices recorded in the file keyed on their PCI address. A missing file
+// has no quarantined devices. The caller must hold quarantineMu.
+func quarantineLoad(path string) (map[string]api.ResourcesQuarantinedDevice, error) {
+	devices := map[string]api.ResourcesQuarantinedDevice{}
+
+	content, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return devices, nil
+		}
+
+		return nil, fmt.Errorf("Failed reading device quarantine: %w", err)
+	}
+
+	entries := []api.ResourcesQuarantinedDevice{}
+	err = yaml.Unmarshal(content, &entries)
+	if err != nil {
+		return nil, fmt.Errorf("Failed parsing device quarantine %q: %w", path, err)
+	}
+
+	for _, entry := range entries {
+		err := validate.IsPCIAddress(entry.PCIAddress)
+		if err != nil {
+			return nil, fmt.Errorf("Invalid quarantined device %q in %q: %w", entry.PCIAddress, path, err)
+		}
+
+		entry.PCIAddress = pcidev.NormaliseAddress(entry.PCIAddress)
+		devices[entry.PCIAddress] = entry
+	}
+
+	return devices, nil
+}
+
+// quarantineSave records the quarantined devices in the file, sorted by PCI address. The file is replaced in one
+// go so that the quarantine isn't lost if LXD stops whilst it is written. The caller must hold quarantineMu.
+func quarantineSave(path string, devices map[string]api.ResourcesQuarantinedDevice) error {
+	entries := quarantineSorted(devices)
+
+	content, err := yaml.Marshal(&entries)
+	if err != nil {
+		return fmt.Errorf("Failed encoding device quarantine: %w", err)
+	}
+
+	tmpPath := filepath.Join(filepath.Dir(path), fmt.Sprintf(".%s.tmp", filepath.Base(path)))
+	err = os.WriteFile(tmpPath, content, 0600)
+	if err != nil {
+		return fmt.Errorf("Failed writing device quarantine: %w", err)
+	}
+
+	err = os.Rename(tmpPath, path)
+	if err != nil {
+		_ = os.Remove(tmpPath)
+		return fmt.Errorf("Failed writing device quarantine: %w", err)
+	}
+
+	return nil
+}
+
+// quarantineSorted returns the quarantined devices sorted by PCI address.
+func quarantineSorted(devices map[string]api.ResourcesQuarantinedDevice) []api.ResourcesQuarantinedDevice {
+	entries := make([]api.ResourcesQuarantinedDevice, 0, len(devices))
+	for _, entry := range devices {
+		entries = append(entries, entry)
+	}
+
+	sort.Slice(entries, func(i, j int) bool { return entries[i].PCIAddress < entries[j].PCIAddress })
+
+	return entries
+}
+
+// quarantineApply unbinds the device from its driver (if any) and stops any driver from binding to it again.
+func quarantineApply(address string) error {
+	pciDev := pcidev.Device{SlotName: address}
+
+	// Set the driver override first so that no driver can bind to the device once it is unbound.
+	err := pcidev.DeviceSetDriverOverride(pciDev, quarantineDriverOverride)
+	if err != nil {
+		return err
+	}
+
+	return pcidev.DeviceUnbind(pciDev)
+}
+
+// quarantineAdd quarantines the device that has been detached from an instance, leaving it unbound and
+// recording it so that it can't be used by any instance until it is released with QuarantineRelease.
+func quarantineAdd(entry api.ResourcesQuarantinedDevice) error {
+	quarantineMu.Lock()
+	defer quarantineMu.Unlock()
+
+	devices, err := quarantineLoad(quarantinePath())
+	if err != nil {
+		return err
+	}
+
+	// Record the device before unbinding it, so that it stays quarantined even if it is only partially unbound.
+	devices[entry.PCIAddress] = entry
+
+	err = quarantineSave(quarantinePath(), devices)
+	if err != nil {
+		return err
+	}
+
+	return quarantineApply(entry.PCIAddress)
+}
+
+// quarantineCheck returns an error if the PCI device is quarantined.
+func quarantineCheck(address string) error {
+	quarantineMu.Lock()
+	defer quarantineMu.Unlock()
+
+	devices, err := quarantineLoad(quarantinePath())
+	if err != nil {
+		return err
+	}
+
+	entry, found := devices[pcidev.NormaliseAddress(address)]
+	if found {
+		return fmt.Errorf("PCI device %q is quarantined since it was detached from device %q of instance %q in project %q and must be released before it can be used", entry.PCIAddress, entry.Device, entry.Instance, entry.Project)
+	}
+
+	return nil
+}
+
+// Quarantined returns the quarantined devices, sorted by PCI address.
+func Quarantined() ([]api.ResourcesQuarantinedDevice, error) {
+	quarantineMu.Lock()
+	defer quarantineMu.Unlock()
+
+	devices, err := quarantineLoad(quarantinePath())
+	if err != nil {
+		return nil, err
+	}
+
+	return quarantineSorted(devices), nil
+}
+
+// QuarantineRelease releases the quarantined device with the PCI address so that it can be used again, binding it
+// to the host driver it was bound to before it was attached to the instance (if the device is still present).
+func QuarantineRelease(address string) error {
+	quarantineMu.Lock()
+	defer quarantineMu.Unlock()
+
+	devices, err := quarantineLoad(quarantinePath())
+	if err != nil {
+		return err
+	}
+
+	entry, found := devices[pcidev.NormaliseAddress(address)]
+	if !found {
+		return api.StatusErrorf(http.StatusNotFound, "PCI device %q isn't quarantined", address)
+	}
+
+	if shared.PathExists(filepath.Join("/sys/bus/pci/devices", entry.PCIAddress)) {
+		pciDev := pcidev.Device{SlotName: entry.PCIAddress}
+
+		if entry.Driver != "" {
+			err = pcidev.DeviceDriverOverride(pciDev, entry.Driver)
+		} else {
+			// Let the kernel pick the driver for devices that weren't bound to one.
+			err = pcidev.DeviceSetDriverOverride(pciDev, "")
+			if err == nil {
+				err = pcidev.DeviceProbe(pciDev)
+			}
+		}
+
+		if err != nil {
+			return fmt.Errorf("Failed rebinding PCI device %q to host driver %q: %w", entry.PCIAddress, entry.Driver, err)
+		}
+	}
+
+	delete(devices, entry.PCIAddress)
+
+	return quarantineSave(quarantinePath(), devices)
+}
+
+// QuarantineRestore applies the quarantine of the recorded devices again when LXD starts, as drivers may have
+// bound to them since LXD stopped (such as after the host rebooted). Devices that aren't present are left
+// quarantined in case they are plugged in again.
+func QuarantineRestore() error {
+	quarantineMu.Lock()
+	defer quarantineMu.Unlock()
+
+	devices, err := quarantineLoad(quarantinePath())
+	if err != nil {
+		return err
+	}
+
+	for _, entry := range quarantineSorted(devices) {
+		l := logger.AddContext(logger.Log, logger.Ctx{"pciAddress": entry.PCIAddress, "project": entry.Project, "instance": entry.Instance, "device": entry.Device})
+
+		if !shared.PathExists(filepath.Join("/sys/bus/pci/devices", entry.PCIAddress)) {
+			l.Warn("Quarantined PCI device isn't present")
+			continue
+		}
+
+		err := quarantineApply(entry.PCIAddress)
+		if err != nil {
+			l.Warn("Failed applying quarantine of PCI device", logger.Ctx{"err": err})
+			continue
+		}
+
+		l.Debug("Applied quarantine of PCI device")
+	}
+
+	return nil
+}
@@ -0,0 +1,54 @@
+package device
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/lxc/lxd/shared/api"
+)
+
+func TestQuarantineLoadSave(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "device-quarantine.yaml")
+
+	// Check a missing file has no quarantined devices.
+	devices, err := quarantineLoad(path)
+	require.NoError(t, err)
+	assert.Empty(t, devices)
+
+	quarantinedAt := time.Date(2022, 10, 14, 10, 0, 0, 0, time.UTC)
+	devices["0000:04:00.0"] = api.ResourcesQuarantinedDevice{PCIAddress: "0000:04:00.0", Driver: "nvme", Project: "default", Instance: "c1", Device: "nvme0", QuarantinedAt: quarantinedAt}
+	devices["0000:03:00.0"] = api.ResourcesQuarantinedDevice{PCIAddress: "0000:03:00.0", Project: "default", Instance: "v1", Device: "gpu0", QuarantinedAt: quarantinedAt}
+
+	// Check the devices are recorded sorted by PCI address and loaded back unchanged.
+	err = quarantineSave(path, devices)
+	require.NoError(t, err)
+
+	loaded, err := quarantineLoad(path)
+	require.NoError(t, err)
+	assert.Equal(t, devices, loaded)
+
+	entries := quarantineSorted(loaded)
+	require.Len(t, entries, 2)
+	assert.Equal(t, "0000:03:00.0", entries[0].PCIAddress)
+	assert.Equal(t, "0000:04:00.0", entries[1].PCIAddress)
+
+	// Check the recorded addresses are normalised.
+	err = os.WriteFile(path, []byte("- pci_address: 03:00.0\n  device: gpu0\n"), 0600)
+	require.NoError(t, err)
+
+	loaded, err = quarantineLoad(path)
+	require.NoError(t, err)
+	assert.Contains(t, loaded, "0000:03:00.0")
+
+	// Check invalid addresses are rejected.
+	err = os.WriteFile(path, []byte("- pci_address: invalid\n"), 0600)
+	require.NoError(t, err)
+
+	_, err = quarantineLoad(path)
+	assert.Error(t, err)
+}
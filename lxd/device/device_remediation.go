@@ -0,0 +1,79 @@
+package device
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"strings"
+
+	"golang.org/x/sys/unix"
+
+	"github.com/lxc/lxd/lxd/instance"
+	"github.com/lxc/lxd/lxd/instance/instancetype"
+	"github.com/lxc/lxd/shared"
+)
+
+// remediationMatchKeys lists the device properties used to match host devices, in the order they are described.
+var remediationMatchKeys = []string{"vendorid", "productid", "serial", "pci", "id", "busnum", "devnum", "source", "path"}
+
+// Remediate returns the error of a device that failed to start with a suggestion of how to resolve it added, if
+// the cause of the error is recognised. Otherwise the error is returned unchanged.
+func Remediate(inst instance.ConfigReader, dev Device, err error) error {
+	if err == nil {
+		return nil
+	}
+
+	var remediationErr RemediationError
+	if errors.As(err, &remediationErr) || errors.Is(err, ErrDevicesPathUnavailable) {
+		return err // Already has a suggestion.
+	}
+
+	suggestion := remediationSuggestion(inst.Type(), inst.ExpandedConfig(), dev.Config(), err)
+	if suggestion == "" {
+		return err
+	}
+
+	return RemediationError{Err: err, Suggestion: suggestion}
+}
+
+// remediationSuggestion returns the suggestion of how to resolve the device error, or an empty string if the
+// cause of the error isn't recognised.
+func remediationSuggestion(instType instancetype.Type, instConfig map[string]string, devConfig map[string]string, err error) string {
+	switch {
+	case errors.Is(err, ErrDeviceNotFound):
+		match := []string{}
+		for _, key := range remediationMatchKeys {
+			if devConfig[key] != "" {
+				match = append(match, fmt.Sprintf("%s=%s", key, devConfig[key]))
+			}
+		}
+
+		if len(match) == 0 {
+			return "check the device is present on the host"
+		}
+
+		return fmt.Sprintf("check the device is present on the host and matches %s", strings.Join(match, ", "))
+
+	case errors.Is(err, fs.ErrPermission):
+		if instType == instancetype.Container && !shared.IsTrue(instConfig["security.privileged"]) {
+			return `the instance is unprivileged, consider setting the device's "uid", "gid" and "mode" or mapping the owner of the host device into the instance with "raw.idmap"`
+		}
+
+		return "check the host device's permissions and that LXD isn't prevented from accessing it (such as by AppArmor)"
+
+	case errors.Is(err, unix.EBUSY):
+		return "the host device is in use, check it isn't attached to another instance or held by a process or driver on the host"
+
+	case errors.Is(err, unix.ENODEV), errors.Is(err, unix.ENXIO):
+		return "the host device has gone away or has no driver, check it is still connected and its kernel module is loaded"
+
+	case errors.Is(err, fs.ErrNotExist):
+		if devConfig["source"] != "" {
+			return fmt.Sprintf("check the source %q exists on the host", devConfig["source"])
+		}
+
+		return "a file needed by the device doesn't exist on the host, check the device is present"
+	}
+
+	return ""
+}
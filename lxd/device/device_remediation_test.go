@@ -0,0 +1,39 @@
+package device
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/sys/unix"
+
+	"github.com/lxc/lxd/lxd/instance/instancetype"
+)
+
+func TestRemediationSuggestion(t *testing.T) {
+	usbConfig := map[string]string{"type": "usb", "vendorid": "1050", "productid": "0407"}
+
+	suggestion := remediationSuggestion(instancetype.Container, nil, usbConfig, fmt.Errorf("Failed starting: %w", deviceNotFoundError{msg: "Required USB device not found"}))
+	assert.Equal(t, "check the device is present on the host and matches vendorid=1050, productid=0407", suggestion)
+
+	permissionErr := &os.PathError{Op: "open", Path: "/dev/ttyUSB0", Err: unix.EACCES}
+
+	suggestion = remediationSuggestion(instancetype.Container, nil, usbConfig, permissionErr)
+	assert.Contains(t, suggestion, "raw.idmap")
+
+	suggestion = remediationSuggestion(instancetype.Container, map[string]string{"security.privileged": "true"}, usbConfig, permissionErr)
+	assert.NotContains(t, suggestion, "raw.idmap")
+
+	suggestion = remediationSuggestion(instancetype.VM, nil, usbConfig, &os.PathError{Op: "open", Path: "/dev/bus/usb/001/002", Err: unix.EBUSY})
+	assert.Contains(t, suggestion, "in use")
+
+	suggestion = remediationSuggestion(instancetype.Container, nil, usbConfig, errors.New("Unrecognised failure"))
+	assert.Equal(t, "", suggestion)
+
+	// The underlying error remains accessible.
+	err := RemediationError{Err: permissionErr, Suggestion: "check the permissions"}
+	assert.True(t, errors.Is(err, os.ErrPermission))
+	assert.Equal(t, "open /dev/ttyUSB0: permission denied (check the permissions)", err.Error())
+}
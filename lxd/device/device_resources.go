@@ -0,0 +1,62 @@
+package device
+
+import (
+	"fmt"
+	"strings"
+
+	deviceConfig "github.com/lxc/lxd/lxd/device/config"
+	"github.com/lxc/lxd/lxd/state"
+	"github.com/lxc/lxd/shared/api"
+)
+
+// resourcesCheckers returns the devices that need specific hardware (see ResourcesChecker), keyed on device name.
+// Devices that can't be loaded are left for the validation of the instance config to report.
+func resourcesCheckers(s *state.State, projectName string, devices deviceConfig.Devices) map[string]ResourcesChecker {
+	checkers := map[string]ResourcesChecker{}
+
+	for _, entry := range devices.Sorted() {
+		dev, err := load(nil, s, projectName, entry.Name, entry.Config, nil, nil)
+		if err != nil {
+			continue
+		}
+
+		checker, ok := dev.(ResourcesChecker)
+		if ok {
+			checkers[entry.Name] = checker
+		}
+	}
+
+	return checkers
+}
+
+// NeedsResources indicates whether any of the devices needs specific hardware, in which case the host that an
+// instance with the devices is placed on must be checked with CheckResources.
+func NeedsResources(s *state.State, projectName string, devices deviceConfig.Devices) bool {
+	return len(resourcesCheckers(s, projectName, devices)) > 0
+}
+
+// CheckResources checks that the hardware that the devices need is present in the hardware view of a host (as
+// returned by its resources API), which needn't be the local host. This allows checking whether a cluster member
+// is suitable for an instance before placing it there. All the devices whose hardware is missing are reported.
+func CheckResources(s *state.State, projectName string, devices deviceConfig.Devices, resources *api.Resources) error {
+	checkers := resourcesCheckers(s, projectName, devices)
+
+	missing := []string{}
+	for _, entry := range devices.Sorted() {
+		checker, ok := checkers[entry.Name]
+		if !ok {
+			continue
+		}
+
+		err := checker.CheckResources(resources)
+		if err != nil {
+			missing = append(missing, fmt.Sprintf("Device %q: %v", entry.Name, err))
+		}
+	}
+
+	if len(missing) > 0 {
+		return fmt.Errorf("%s", strings.Join(missing, "; "))
+	}
+
+	return nil
+}
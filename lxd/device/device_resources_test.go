@@ -0,0 +1,41 @@
+package device
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	deviceConfig "github.com/lxc/lxd/lxd/device/config"
+	"github.com/lxc/lxd/shared/api"
+)
+
+func TestCheckResources(t *testing.T) {
+	res := &api.Resources{}
+	res.USB.Devices = []api.ResourcesUSBDevice{{VendorID: "046d", ProductID: "c534"}}
+	res.PCI.Devices = []api.ResourcesPCIDevice{{PCIAddress: "0000:01:00.0"}}
+	res.GPU.Cards = []api.ResourcesGPUCard{{VendorID: "10de", PCIAddress: "0000:02:00.0", DRM: &api.ResourcesGPUCardDRM{ID: 1}}}
+
+	// Check USB devices are only checked when required, including their fallback criteria.
+	usbDev := &usb{deviceCommon: deviceCommon{config: deviceConfig.Device{"type": "usb", "vendorid": "046d", "productid": "c52b"}}}
+	assert.NoError(t, usbDev.CheckResources(res))
+
+	usbDev.config["required"] = "true"
+	assert.EqualError(t, usbDev.CheckResources(res), "No USB device matching 046d:c52b is present")
+
+	usbDev.config["fallback"] = "046d:c534"
+	assert.NoError(t, usbDev.CheckResources(res))
+
+	// Check PCI devices are matched on their normalised address.
+	pciDev := &pci{deviceCommon: deviceCommon{config: deviceConfig.Device{"type": "pci", "address": "01:00.0"}}}
+	assert.NoError(t, pciDev.CheckResources(res))
+
+	pciDev.config["address"] = "0000:03:00.0"
+	assert.EqualError(t, pciDev.CheckResources(res), `PCI device "0000:03:00.0" isn't present`)
+
+	// Check GPUs are matched on all the selection keys.
+	gpuDev := &gpuPhysical{deviceCommon: deviceCommon{config: deviceConfig.Device{"type": "gpu", "vendorid": "10de", "id": "1"}}}
+	assert.NoError(t, gpuDev.CheckResources(res))
+
+	gpuDev.config["id"] = "0"
+	assert.Error(t, gpuDev.CheckResources(res))
+}
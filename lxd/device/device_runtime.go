@@ -0,0 +1,220 @@
+package device
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	deviceConfig "github.com/lxc/lxd/lxd/device/config"
+	"github.com/lxc/lxd/lxd/instance"
+	"github.com/lxc/lxd/lxd/project"
+	"github.com/lxc/lxd/lxd/state"
+	"github.com/lxc/lxd/shared/logger"
+)
+
+// deviceRuntime represents the in-memory runtime information of a device.
+// As devices are instantiated for each operation, this is kept separately and keyed on the
+// project, instance and device names.
+type deviceRuntime struct {
+	timings      map[string]time.Duration
+	status       string
+	statusReason string
+	lifecycle    LifecycleState
+
+	// The run-time configuration returned by the start of the device, kept until the device is stopped.
+	runConf *deviceConfig.RunConfig
+
+	// Closed once the asynchronous start of the device has completed (see StartAsyncRun).
+	asyncStart chan struct{}
+
+	// Whether a hotplug handler is registered for the device, along with the number of consecutive failures of
+	// the handler and the most recent error (see HotplugErrors).
+	hotplugRegistered bool
+	hotplugErrors     int64
+	hotplugLastError  string
+}
+
+// StatusStarted indicates the device was started successfully.
+const StatusStarted = "started"
+
+// StatusStarting indicates the device is being started asynchronously after the instance has started.
+const StatusStarting = "starting"
+
+// StatusStopped indicates the device was stopped.
+const StatusStopped = "stopped"
+
+// StatusFailed indicates the device failed to start.
+const StatusFailed = "failed"
+
+// StatusSkipped indicates the device start was skipped (such as when a required device isn't started).
+const StatusSkipped = "skipped"
+
+// StatusBlocked indicates the device isn't started as the restrictions of the instance's project forbid it.
+const StatusBlocked = "blocked"
+
+// deviceRuntimes stores the runtime information for each device.
+var deviceRuntimes = map[string]*deviceRuntime{}
+
+// deviceRuntimesMu controls access to the deviceRuntimes map.
+var deviceRuntimesMu sync.Mutex
+
+// deviceRuntimeKey returns the key used to store the runtime information of a device.
+func deviceRuntimeKey(projectName string, instanceName string, deviceName string) string {
+	return fmt.Sprintf("%s\000%s\000%s", projectName, instanceName, deviceName)
+}
+
+// deviceRuntimeGet returns the runtime information for the device, creating it if needed.
+// The caller must hold deviceRuntimesMu.
+func deviceRuntimeGet(inst instance.Instance, deviceName string) *deviceRuntime {
+	key := deviceRuntimeKey(inst.Project().Name, inst.Name(), deviceName)
+
+	runtime, ok := deviceRuntimes[key]
+	if !ok {
+		runtime = &deviceRuntime{
+			timings: map[string]time.Duration{},
+		}
+
+		deviceRuntimes[key] = runtime
+	}
+
+	return runtime
+}
+
+// RecordTiming records how long the specified phase of a device operation took.
+func RecordTiming(inst instance.Instance, deviceName string, phase string, duration time.Duration) {
+	if inst == nil {
+		return
+	}
+
+	deviceRuntimesMu.Lock()
+	defer deviceRuntimesMu.Unlock()
+
+	deviceRuntimeGet(inst, deviceName).timings[phase] = duration
+}
+
+// Timings returns the most recent durations of each recorded phase of the device's operations.
+func Timings(inst instance.Instance, deviceName string) map[string]time.Duration {
+	deviceRuntimesMu.Lock()
+	defer deviceRuntimesMu.Unlock()
+
+	runtime, ok := deviceRuntimes[deviceRuntimeKey(inst.Project().Name, inst.Name(), deviceName)]
+	if !ok || len(runtime.timings) == 0 {
+		return nil
+	}
+
+	timings := make(map[string]time.Duration, len(runtime.timings))
+	for phase, duration := range runtime.timings {
+		timings[phase] = duration
+	}
+
+	return timings
+}
+
+// SetStatus records the start status of the device along with an optional reason.
+// The change is also sent as a lifecycle event of the device.
+func SetStatus(s *state.State, inst instance.Instance, deviceName string, status string, reason string) {
+	deviceRuntimesMu.Lock()
+	runtime := deviceRuntimeGet(inst, deviceName)
+	runtime.status = status
+	runtime.statusReason = reason
+	deviceRuntimesMu.Unlock()
+
+	action, found := statusEventActions[status]
+	if found {
+		sendInstanceEvent(s, inst, deviceName, action, reason, nil)
+	}
+}
+
+// Status returns the most recent start status of the device and the reason for it (if any).
+// Returns an empty status if the device hasn't been started since LXD started.
+func Status(inst instance.Instance, deviceName string) (string, string) {
+	deviceRuntimesMu.Lock()
+	defer deviceRuntimesMu.Unlock()
+
+	runtime, ok := deviceRuntimes[deviceRuntimeKey(inst.Project().Name, inst.Name(), deviceName)]
+	if !ok {
+		return "", ""
+	}
+
+	return runtime.status, runtime.statusReason
+}
+
+// ForgetRuntime removes the runtime information of a device that has been removed from an instance.
+func ForgetRuntime(projectName string, instanceName string, deviceName string) {
+	deviceRuntimesMu.Lock()
+	defer deviceRuntimesMu.Unlock()
+
+	delete(deviceRuntimes, deviceRuntimeKey(projectName, instanceName, deviceName))
+}
+
+// recordTiming records the time elapsed since start for the specified phase of the device operation.
+// It is intended to be deferred at the start of the phase, e.g. defer d.recordTiming("scan", time.Now()).
+func (d *deviceCommon) recordTiming(phase string, start time.Time) {
+	duration := time.Since(start)
+
+	RecordTiming(d.inst, d.name, phase, duration)
+	d.logger.Debug("Device operation timing", logger.Ctx{"phase": phase, "duration": duration})
+}
+
+// CheckRequiredDevice checks whether the sibling device named in the device's "requires.device" key has
+// been started successfully. If it hasn't, then depending on the "requires.device.policy" key either an
+// error is returned (fail) or true is returned to indicate the device start should be skipped (skip, the
+// default). The skipped status and reason are recorded so they can be reported in the device state.
+func CheckRequiredDevice(s *state.State, inst instance.Instance, dev Device) (bool, error) {
+	config := dev.Config()
+	required := config["requires.device"]
+	if required == "" {
+		return false, nil
+	}
+
+	status, _ := Status(inst, required)
+	if status == StatusStarted {
+		return false, nil
+	}
+
+	var reason string
+	_, found := inst.ExpandedDevices()[required]
+	if !found {
+		reason = fmt.Sprintf("Required device %q doesn't exist", required)
+	} else if status == "" {
+		reason = fmt.Sprintf("Required device %q hasn't been started", required)
+	} else {
+		reason = fmt.Sprintf("Required device %q is %s", required, status)
+	}
+
+	if config["requires.device.policy"] == "fail" {
+		SetStatus(s, inst, dev.Name(), StatusFailed, reason)
+		return false, fmt.Errorf("%s", reason)
+	}
+
+	SetStatus(s, inst, dev.Name(), StatusSkipped, reason)
+	logger.Warn("Skipping device start", logger.Ctx{"project": inst.Project().Name, "instance": inst.Name(), "device": dev.Name(), "reason": reason})
+
+	return true, nil
+}
+
+// RestrictedDevices returns why the restrictions of the instance's project forbid its devices, keyed on the names
+// of the forbidden devices. If the restrictions can't be checked, the failure is logged and the devices are
+// considered allowed so that the restrictions last applied to them stay in effect.
+func RestrictedDevices(inst instance.Instance, devices deviceConfig.Devices) map[string]string {
+	forbidden, err := project.ForbiddenDevices(inst.Project(), devices.CloneNative())
+	if err != nil {
+		logger.Error("Failed checking project restrictions for devices", logger.Ctx{"project": inst.Project().Name, "instance": inst.Name(), "err": err})
+		return nil
+	}
+
+	return forbidden
+}
+
+// BlockRestrictedDevices records the blocked status and reason of the instance's devices that the restrictions of
+// its project forbid, and returns the reasons keyed on the names of the blocked devices. It is called once when
+// the instance starts so that the blocked devices are skipped.
+func BlockRestrictedDevices(s *state.State, inst instance.Instance, devices deviceConfig.Devices) map[string]string {
+	forbidden := RestrictedDevices(inst, devices)
+	for name, reason := range forbidden {
+		SetStatus(s, inst, name, StatusBlocked, reason)
+		logger.Warn("Blocking device start", logger.Ctx{"project": inst.Project().Name, "instance": inst.Name(), "device": name, "reason": reason})
+	}
+
+	return forbidden
+}
@@ -0,0 +1,175 @@
+package device
+
+import (
+	"errors"
+	"sort"
+
+	deviceConfig "github.com/lxc/lxd/lxd/device/config"
+	"github.com/lxc/lxd/lxd/instance/instancetype"
+	"github.com/lxc/lxd/lxd/project"
+	"github.com/lxc/lxd/lxd/state"
+	"github.com/lxc/lxd/shared/api"
+	"github.com/lxc/lxd/shared/logger"
+)
+
+// schemaSubtypes lists the subtypes of the device types that have them, along with the key selecting the subtype.
+var schemaSubtypes = map[string]struct {
+	key      string
+	subtypes []string
+}{
+	"nic":        {key: "nictype", subtypes: []string{"bridged", "ipvlan", "macvlan", "ovn", "p2p", "physical", "routed", "sriov"}},
+	"infiniband": {key: "nictype", subtypes: []string{"physical", "sriov"}},
+	"gpu":        {key: "gputype", subtypes: []string{"mdev", "mig", "physical", "sriov"}},
+}
+
+// schemaInstanceTypes lists the instance types that the schema is generated for.
+var schemaInstanceTypes = []instancetype.Type{instancetype.Container, instancetype.VM}
+
+// schemaInvalidValue is a value that no validator of a typed key accepts.
+const schemaInvalidValue = "\000invalid"
+
+// schemaConfigReader is the instance config that the device schemas are generated with, which has no other config
+// or devices.
+type schemaConfigReader struct {
+	instanceType instancetype.Type
+}
+
+// Project returns the default project.
+func (c *schemaConfigReader) Project() api.Project {
+	return api.Project{Name: project.Default}
+}
+
+// Type returns the instance type.
+func (c *schemaConfigReader) Type() instancetype.Type {
+	return c.instanceType
+}
+
+// Architecture returns the architecture of the instance, which isn't known.
+func (c *schemaConfigReader) Architecture() int {
+	return 0
+}
+
+// ExpandedConfig returns an empty instance config.
+func (c *schemaConfigReader) ExpandedConfig() map[string]string {
+	return map[string]string{}
+}
+
+// ExpandedDevices returns no devices.
+func (c *schemaConfigReader) ExpandedDevices() deviceConfig.Devices {
+	return deviceConfig.Devices{}
+}
+
+// ExpandedDeviceSource returns an empty source.
+func (c *schemaConfigReader) ExpandedDeviceSource(name string) string {
+	return ""
+}
+
+// LocalConfig returns an empty instance config.
+func (c *schemaConfigReader) LocalConfig() map[string]string {
+	return map[string]string{}
+}
+
+// LocalDevices returns no devices.
+func (c *schemaConfigReader) LocalDevices() deviceConfig.Devices {
+	return deviceConfig.Devices{}
+}
+
+// schemaKeyType returns the type of the values accepted by the validator (bool, integer or string), found from
+// the sample values it accepts.
+func schemaKeyType(validator func(string) error) string {
+	if validator(schemaInvalidValue) == nil {
+		return "string"
+	}
+
+	if validator("true") == nil && validator("false") == nil {
+		return "bool"
+	}
+
+	if validator("0") == nil || validator("1") == nil {
+		return "integer"
+	}
+
+	return "string"
+}
+
+// Schema returns the configuration schema of each device type (and subtype). The keys of each device type are
+// those of the rules that a device of the type without any other config is validated with, so that the schema
+// matches the validation. A key is required if its validator doesn't accept an empty value.
+func Schema(s *state.State) []api.MetadataDevice {
+	schemas := []api.MetadataDevice{}
+
+	for _, deviceType := range deviceConfig.Types {
+		subtypes := []string{""}
+		subtypeKey := schemaSubtypes[deviceType].key
+		if subtypeKey != "" {
+			subtypes = schemaSubtypes[deviceType].subtypes
+		}
+
+		for _, subtype := range subtypes {
+			schema := api.MetadataDevice{
+				Type:          deviceType,
+				Subtype:       subtype,
+				InstanceTypes: []string{},
+				Keys:          map[string]api.MetadataDeviceKey{},
+			}
+
+			for _, instanceType := range schemaInstanceTypes {
+				conf := deviceConfig.Device{"type": deviceType}
+				if subtypeKey != "" {
+					conf[subtypeKey] = subtype
+				}
+
+				instConf := &schemaConfigReader{instanceType: instanceType}
+				dev, err := load(nil, s, instConf.Project().Name, "schema", conf, nil, nil)
+				if err != nil {
+					logger.Debug("Failed getting device schema", logger.Ctx{"type": deviceType, "subtype": subtype, "instanceType": instanceType.String(), "err": err})
+					continue
+				}
+
+				rules, err := dev.configRules(instConf)
+				if err != nil {
+					if !errors.Is(err, ErrUnsupportedDevType) {
+						logger.Debug("Failed getting device schema", logger.Ctx{"type": deviceType, "subtype": subtype, "instanceType": instanceType.String(), "err": err})
+					}
+
+					continue
+				}
+
+				schema.InstanceTypes = append(schema.InstanceTypes, instanceType.String())
+
+				for k, validator := range deviceConfig.Rules(rules) {
+					// The type keys are validated by the presence of an implementation.
+					if k == "type" || k == subtypeKey {
+						continue
+					}
+
+					key, found := schema.Keys[k]
+					if !found {
+						key = api.MetadataDeviceKey{
+							Type:          schemaKeyType(validator),
+							Required:      validator("") != nil,
+							InstanceTypes: []string{},
+						}
+					}
+
+					key.InstanceTypes = append(key.InstanceTypes, instanceType.String())
+					schema.Keys[k] = key
+				}
+			}
+
+			if len(schema.InstanceTypes) > 0 {
+				schemas = append(schemas, schema)
+			}
+		}
+	}
+
+	sort.SliceStable(schemas, func(i, j int) bool {
+		if schemas[i].Type != schemas[j].Type {
+			return schemas[i].Type < schemas[j].Type
+		}
+
+		return schemas[i].Subtype < schemas[j].Subtype
+	})
+
+	return schemas
+}
@@ -177,14 +177,39 @@ func DiskMountClear(mntPath string) error {
 	return nil
 }
 
-func diskCephRbdMap(clusterName string, userName string, poolName string, volumeName string) (string, error) {
-	devPath, err := shared.RunCommand(
-		"rbd",
+func diskCephRbdMap(clusterName string, userName string, secret string, poolName string, volumeName string) (string, error) {
+	args := []string{
 		"--id", userName,
 		"--cluster", clusterName,
 		"--pool", poolName,
-		"map",
-		volumeName)
+	}
+
+	// Pass the key using a private file that is removed once mapped, so it doesn't appear on the command line.
+	if secret != "" {
+		keyFile, err := os.CreateTemp(shared.VarPath(), "rbd_key_")
+		if err != nil {
+			return "", fmt.Errorf("Failed creating key file: %w", err)
+		}
+
+		defer func() { _ = os.Remove(keyFile.Name()) }()
+
+		_, err = keyFile.WriteString(secret)
+		if err != nil {
+			_ = keyFile.Close()
+			return "", fmt.Errorf("Failed writing key file: %w", err)
+		}
+
+		err = keyFile.Close()
+		if err != nil {
+			return "", fmt.Errorf("Failed writing key file: %w", err)
+		}
+
+		args = append(args, "--keyfile", keyFile.Name())
+	}
+
+	args = append(args, "map", volumeName)
+
+	devPath, err := shared.RunCommand("rbd", args...)
 	if err != nil {
 		return "", err
 	}
@@ -237,7 +262,8 @@ again:
 }
 
 // diskCephfsOptions returns the mntSrcPath and fsOptions to use for mounting a cephfs share.
-func diskCephfsOptions(clusterName string, userName string, fsName string, fsPath string) (string, []string, error) {
+// If secret is empty then the user's key is taken from the host's ceph keyring.
+func diskCephfsOptions(clusterName string, userName string, secret string, fsName string, fsPath string) (string, []string, error) {
 	// Get the monitor list.
 	monAddresses, err := storageDrivers.CephMonitors(clusterName)
 	if err != nil {
@@ -245,9 +271,11 @@ func diskCephfsOptions(clusterName string, userName string, fsName string, fsPat
 	}
 
 	// Get the keyring entry.
-	secret, err := storageDrivers.CephKeyring(clusterName, userName)
-	if err != nil {
-		return "", nil, err
+	if secret == "" {
+		secret, err = storageDrivers.CephKeyring(clusterName, userName)
+		if err != nil {
+			return "", nil, err
+		}
 	}
 
 	// Prepare mount entry.
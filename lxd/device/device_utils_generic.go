@@ -2,8 +2,12 @@ package device
 
 import (
 	"fmt"
+	"os/exec"
+	"regexp"
 	"strings"
+	"time"
 
+	deviceConfig "github.com/lxc/lxd/lxd/device/config"
 	"github.com/lxc/lxd/shared"
 )
 
@@ -12,12 +16,107 @@ func deviceJoinPath(parts ...string) string {
 	return strings.Join(parts, ".")
 }
 
-// validatePCIDevice returns whether a configured PCI device exists. It also returns true, if no device
-// has been specified.
+// validatePCIDevice returns whether a configured PCI device exists and isn't quarantined. It also returns true,
+// if no device has been specified.
 func validatePCIDevice(address string) error {
-	if address != "" && !shared.PathExists(fmt.Sprintf("/sys/bus/pci/devices/%s", address)) {
+	if address == "" {
+		return nil
+	}
+
+	if !shared.PathExists(fmt.Sprintf("/sys/bus/pci/devices/%s", address)) {
 		return fmt.Errorf("Invalid PCI address (no device found): %s", address)
 	}
 
+	// Quarantined devices can't be used until they are released.
+	return quarantineCheck(address)
+}
+
+// deviceKeyConflict describes device config keys that cannot be used together with some other keys.
+// Keys can be specified as "key", which is in use when set to any value, or as "key=true" which is in use when
+// the key is set to a true value.
+type deviceKeyConflict struct {
+	keys          []string
+	conflictsWith []string
+}
+
+// deviceKeyInUse indicates whether the key (in the format used by deviceKeyConflict) is in use in the config.
+func deviceKeyInUse(config deviceConfig.Device, key string) bool {
+	name, value, hasValue := strings.Cut(key, "=")
+	if !hasValue {
+		return config[name] != ""
+	}
+
+	if value == "true" {
+		return shared.IsTrue(config[name])
+	}
+
+	return config[name] == value
+}
+
+// validateConflictingKeys checks that the device config doesn't use any of the supplied conflicting keys
+// together. The returned error names the conflicting keys.
+func validateConflictingKeys(config deviceConfig.Device, conflicts ...deviceKeyConflict) error {
+	for _, conflict := range conflicts {
+		for _, key := range conflict.keys {
+			if !deviceKeyInUse(config, key) {
+				continue
+			}
+
+			for _, otherKey := range conflict.conflictsWith {
+				if deviceKeyInUse(config, otherKey) {
+					return fmt.Errorf("Cannot use %q together with %q", key, otherKey)
+				}
+			}
+		}
+	}
+
+	return nil
+}
+
+// kernelModuleNameRegex matches valid kernel module names.
+var kernelModuleNameRegex = regexp.MustCompile(`^[a-zA-Z0-9][a-zA-Z0-9_-]*$`)
+
+// validateKernelModuleName validates that the supplied value is a valid kernel module name.
+func validateKernelModuleName(value string) error {
+	if !kernelModuleNameRegex.MatchString(value) {
+		return fmt.Errorf("Invalid kernel module name %q", value)
+	}
+
+	return nil
+}
+
+// environmentVariableNameRegex matches valid environment variable names.
+var environmentVariableNameRegex = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_]*$`)
+
+// validateEnvironmentVariableName validates that the supplied value is a valid environment variable name.
+func validateEnvironmentVariableName(value string) error {
+	if !environmentVariableNameRegex.MatchString(value) {
+		return fmt.Errorf("Invalid environment variable name %q", value)
+	}
+
+	return nil
+}
+
+// udevQueuePath exists while udev has events queued or being processed.
+const udevQueuePath = "/run/udev/queue"
+
+// udevSettle waits up to timeout for udev to finish processing its event queue.
+// Uses "udevadm settle" when available, otherwise polls for the udev queue to become empty.
+func udevSettle(timeout time.Duration) error {
+	_, err := exec.LookPath("udevadm")
+	if err == nil {
+		_, err = shared.RunCommand("udevadm", "settle", fmt.Sprintf("--timeout=%d", int(timeout.Seconds())))
+		return err
+	}
+
+	deadline := time.Now().Add(timeout)
+	for shared.PathExists(udevQueuePath) {
+		if time.Now().After(deadline) {
+			return fmt.Errorf("Timed out after %v waiting for the udev queue to empty", timeout)
+		}
+
+		time.Sleep(100 * time.Millisecond)
+	}
+
 	return nil
 }
@@ -0,0 +1,134 @@
+package device
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"gopkg.in/yaml.v2"
+
+	pcidev "github.com/lxc/lxd/lxd/device/pci"
+	"github.com/lxc/lxd/shared"
+)
+
+// LabelProvider is implemented by the host-side inventories that devices can be selected from by label.
+type LabelProvider interface {
+	// Devices returns the sysfs paths of the host devices with the label.
+	Devices(label string) ([]string, error)
+}
+
+// fileLabelProvider is the default LabelProvider. It reads a YAML file mapping the sysfs path of each host
+// device to its list of labels.
+type fileLabelProvider struct {
+	path string
+}
+
+// Devices returns the sysfs paths of the host devices with the label in the file. A missing file has no labels.
+func (p *fileLabelProvider) Devices(label string) ([]string, error) {
+	content, err := os.ReadFile(p.path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+
+		return nil, fmt.Errorf("Failed reading device labels: %w", err)
+	}
+
+	inventory := map[string][]string{}
+	err = yaml.Unmarshal(content, &inventory)
+	if err != nil {
+		return nil, fmt.Errorf("Failed parsing device labels %q: %w", p.path, err)
+	}
+
+	devices := []string{}
+	for sysfsPath, labels := range inventory {
+		if shared.StringInSlice(label, labels) {
+			devices = append(devices, sysfsPath)
+		}
+	}
+
+	sort.Strings(devices)
+
+	return devices, nil
+}
+
+// labelProvider is the provider that device labels are resolved with, or nil to use the default file provider.
+var labelProvider LabelProvider
+
+// labelProviderMu controls access to labelProvider.
+var labelProviderMu sync.Mutex
+
+// SetLabelProvider sets the provider that device labels are resolved with. A nil provider restores the default,
+// which reads the labels from the "device-labels.yaml" file in LXD_DIR.
+func SetLabelProvider(provider LabelProvider) {
+	labelProviderMu.Lock()
+	defer labelProviderMu.Unlock()
+
+	labelProvider = provider
+}
+
+// validLabel validates a label used to select a host device.
+func validLabel(value string) error {
+	if value == "" || strings.TrimSpace(value) != value || strings.ContainsAny(value, ",\000") {
+		return fmt.Errorf("Invalid device label %q", value)
+	}
+
+	return nil
+}
+
+// labelResolve returns the sysfs path of the host device with the label. Only devices that are present are
+// considered, and exactly one of them must have the label.
+func labelResolve(label string) (string, error) {
+	labelProviderMu.Lock()
+	provider := labelProvider
+	labelProviderMu.Unlock()
+
+	if provider == nil {
+		provider = &fileLabelProvider{path: shared.VarPath("device-labels.yaml")}
+	}
+
+	devices, err := provider.Devices(label)
+	if err != nil {
+		return "", err
+	}
+
+	present := []string{}
+	for _, sysfsPath := range devices {
+		if shared.PathExists(sysfsPath) {
+			present = append(present, sysfsPath)
+		}
+	}
+
+	if len(present) == 0 {
+		return "", deviceNotFoundError{msg: fmt.Sprintf("No host device with label %q is present", label)}
+	}
+
+	if len(present) > 1 {
+		return "", fmt.Errorf("Label %q must select exactly one host device but selects %d (%s)", label, len(present), strings.Join(present, ", "))
+	}
+
+	return present[0], nil
+}
+
+// labelResolvePCI returns the address of the PCI device with the label.
+func labelResolvePCI(label string) (string, error) {
+	sysfsPath, err := labelResolve(label)
+	if err != nil {
+		return "", err
+	}
+
+	realPath, err := filepath.EvalSymlinks(sysfsPath)
+	if err != nil {
+		return "", fmt.Errorf("Failed resolving device %q with label %q: %w", sysfsPath, label, err)
+	}
+
+	address := filepath.Base(realPath)
+	if !shared.PathExists(filepath.Join("/sys/bus/pci/devices", address)) {
+		return "", fmt.Errorf("Device %q with label %q isn't a PCI device", sysfsPath, label)
+	}
+
+	return pcidev.NormaliseAddress(address), nil
+}
@@ -0,0 +1,52 @@
+package device
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLabelResolve(t *testing.T) {
+	dir := t.TempDir()
+
+	for _, name := range []string{"gpu0", "gpu1", "nic0"} {
+		err := os.Mkdir(filepath.Join(dir, name), 0755)
+		assert.NoError(t, err)
+	}
+
+	labels := []byte(`
+` + filepath.Join(dir, "gpu0") + `: [gpu-for-ml, gpu]
+` + filepath.Join(dir, "gpu1") + `: [gpu]
+` + filepath.Join(dir, "nic0") + `: [uplink]
+` + filepath.Join(dir, "missing") + `: [uplink, spare]
+`)
+
+	err := os.WriteFile(filepath.Join(dir, "device-labels.yaml"), labels, 0644)
+	assert.NoError(t, err)
+
+	SetLabelProvider(&fileLabelProvider{path: filepath.Join(dir, "device-labels.yaml")})
+	defer SetLabelProvider(nil)
+
+	// Labels resolve to the single present device with them.
+	sysfsPath, err := labelResolve("gpu-for-ml")
+	assert.NoError(t, err)
+	assert.Equal(t, filepath.Join(dir, "gpu0"), sysfsPath)
+
+	sysfsPath, err = labelResolve("uplink")
+	assert.NoError(t, err)
+	assert.Equal(t, filepath.Join(dir, "nic0"), sysfsPath)
+
+	// Labels of several present devices are ambiguous.
+	_, err = labelResolve("gpu")
+	assert.Error(t, err)
+	assert.False(t, errors.Is(err, ErrDeviceNotFound))
+
+	// Labels without a present device aren't found.
+	for _, label := range []string{"spare", "unknown"} {
+		_, err = labelResolve(label)
+		assert.ErrorIs(t, err, ErrDeviceNotFound, label)
+	}
+}
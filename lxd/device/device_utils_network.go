@@ -1011,3 +1011,18 @@ func networkVLANListExpand(rawVLANValues []string) ([]int, error) {
 
 	return networkVLANList, nil
 }
+
+// NetworkNamespacePath returns the path (as seen from the host) of the named network namespace created inside
+// the container with the supplied init PID, such as by "ip netns add".
+func NetworkNamespacePath(pid int, name string) string {
+	return fmt.Sprintf("/proc/%d/root/run/netns/%s", pid, name)
+}
+
+// networkValidNetnsName validates the name of a network namespace inside an instance.
+func networkValidNetnsName(value string) error {
+	if value == "" || value == "." || value == ".." || strings.ContainsAny(value, "/\000") {
+		return fmt.Errorf("Invalid network namespace name %q", value)
+	}
+
+	return nil
+}
@@ -0,0 +1,80 @@
+package device
+
+import (
+	"sync"
+)
+
+// perfParanoid records the lowering of the host-wide perf_event_paranoid setting by the running performance
+// counter devices, so that it is only restored once none of them needs it lowered.
+var perfParanoid = struct {
+	users    map[string]int // Level wanted by each running device, keyed on the same key as usbHandlers.
+	lowered  bool           // Whether LXD lowered the setting.
+	original int            // Value before LXD lowered it.
+	written  int            // Value last written by LXD.
+}{users: map[string]int{}}
+
+// perfParanoidMu controls access to perfParanoid. It is held whilst the setting is read and written.
+var perfParanoidMu sync.Mutex
+
+// perfParanoidAcquire records the device with the supplied key as needing the setting lowered to the wanted level.
+// Returns the value to write given the current one, or false if it is already low enough. Once written, the caller
+// must call perfParanoidWritten. The caller must hold perfParanoidMu.
+func perfParanoidAcquire(key string, wanted int, current int) (int, bool) {
+	perfParanoid.users[key] = wanted
+
+	if current <= wanted {
+		return 0, false
+	}
+
+	return wanted, true
+}
+
+// perfParanoidWritten records that LXD lowered the setting from the current value to the supplied one.
+// The caller must hold perfParanoidMu.
+func perfParanoidWritten(current int, value int) {
+	if !perfParanoid.lowered {
+		perfParanoid.lowered = true
+		perfParanoid.original = current
+	}
+
+	perfParanoid.written = value
+}
+
+// perfParanoidRestore records the device with the supplied key as needing the setting lowered to the wanted level
+// again when LXD starts. If the device recorded the original value of the setting lowered by LXD, the setting is
+// considered lowered by LXD to the current value. The caller must hold perfParanoidMu.
+func perfParanoidRestore(key string, wanted int, original int, recorded bool, current int) {
+	perfParanoid.users[key] = wanted
+
+	if recorded && !perfParanoid.lowered {
+		perfParanoid.lowered = true
+		perfParanoid.original = original
+		perfParanoid.written = current
+	}
+}
+
+// perfParanoidRelease removes the device with the supplied key as a user of the setting. Returns the original
+// value to restore the setting to given the current one, or false if it should be left as is because LXD didn't
+// lower it, other devices still need it lowered or it has been changed since LXD wrote it.
+// The caller must hold perfParanoidMu.
+func perfParanoidRelease(key string, current int) (int, bool) {
+	delete(perfParanoid.users, key)
+
+	if !perfParanoid.lowered || len(perfParanoid.users) > 0 {
+		return 0, false
+	}
+
+	perfParanoid.lowered = false
+
+	if current != perfParanoid.written {
+		return 0, false
+	}
+
+	return perfParanoid.original, true
+}
+
+// perfParanoidOriginal returns the value of the setting before LXD lowered it, and whether LXD lowered it.
+// The caller must hold perfParanoidMu.
+func perfParanoidOriginal() (int, bool) {
+	return perfParanoid.original, perfParanoid.lowered
+}
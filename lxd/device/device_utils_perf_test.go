@@ -0,0 +1,63 @@
+package device
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPerfParanoid(t *testing.T) {
+	c1 := "project\000c1\000perf0"
+	c2 := "project\000c2\000perf0"
+
+	// Check the setting is only restored once the last device needing it lowered stops.
+	value, lower := perfParanoidAcquire(c1, 1, 2)
+	assert.True(t, lower)
+	assert.Equal(t, 1, value)
+	perfParanoidWritten(2, 1)
+
+	value, lower = perfParanoidAcquire(c2, 0, 1)
+	assert.True(t, lower)
+	assert.Equal(t, 0, value)
+	perfParanoidWritten(1, 0)
+
+	original, lowered := perfParanoidOriginal()
+	assert.True(t, lowered)
+	assert.Equal(t, 2, original)
+
+	_, restore := perfParanoidRelease(c2, 0)
+	assert.False(t, restore)
+
+	value, restore = perfParanoidRelease(c1, 0)
+	assert.True(t, restore)
+	assert.Equal(t, 2, value)
+
+	// Check the setting isn't lowered or restored if it is already low enough.
+	_, lower = perfParanoidAcquire(c1, 1, -1)
+	assert.False(t, lower)
+
+	_, restore = perfParanoidRelease(c1, -1)
+	assert.False(t, restore)
+
+	// Check the setting isn't restored if it has been changed since LXD lowered it.
+	_, lower = perfParanoidAcquire(c1, 1, 2)
+	assert.True(t, lower)
+	perfParanoidWritten(2, 1)
+
+	_, restore = perfParanoidRelease(c1, -1)
+	assert.False(t, restore)
+
+	_, lowered = perfParanoidOriginal()
+	assert.False(t, lowered)
+
+	// Check the setting lowered before LXD restarted is restored once the last device stops.
+	perfParanoidRestore(c1, 1, 2, true, 1)
+	perfParanoidRestore(c2, 1, 0, false, 1)
+
+	_, restore = perfParanoidRelease(c1, 1)
+	assert.False(t, restore)
+
+	value, restore = perfParanoidRelease(c2, 1)
+	assert.True(t, restore)
+	assert.Equal(t, 2, value)
+}
@@ -0,0 +1,114 @@
+package device
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/lxc/lxd/shared"
+)
+
+// SecretStore is implemented by the stores that device secrets (such as keys) can be referenced from.
+// Secrets are resolved when the device starts and are never written to the device's config or volatile state.
+type SecretStore interface {
+	// Get returns the value of the named secret of the project, or an error if it doesn't exist or can't be
+	// read. The secrets of a project can only be referenced by the devices of its instances.
+	Get(projectName string, name string) ([]byte, error)
+}
+
+// fileSecretStore is the default SecretStore. It stores each secret in a file named after the secret, in a
+// directory named after its project.
+type fileSecretStore struct {
+	path string
+}
+
+// Get returns the contents of the secret's file. The file must be a regular file that isn't accessible by
+// other users.
+func (s *fileSecretStore) Get(projectName string, name string) ([]byte, error) {
+	projectPath := filepath.Join(s.path, projectName)
+	secretPath := filepath.Join(projectPath, name)
+
+	info, err := os.Stat(secretPath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, fmt.Errorf("Secret %q doesn't exist in %q", name, projectPath)
+		}
+
+		return nil, fmt.Errorf("Failed accessing secret %q: %w", name, err)
+	}
+
+	if !info.Mode().IsRegular() {
+		return nil, fmt.Errorf("Secret %q isn't a regular file", name)
+	}
+
+	if info.Mode().Perm()&0007 != 0 {
+		return nil, fmt.Errorf("Secret %q must not be accessible by other users", name)
+	}
+
+	value, err := os.ReadFile(secretPath)
+	if err != nil {
+		return nil, fmt.Errorf("Failed reading secret %q: %w", name, err)
+	}
+
+	return value, nil
+}
+
+// secretStore is the store that device secrets are resolved from, or nil to use the default file store.
+var secretStore SecretStore
+
+// secretStoreMu controls access to secretStore.
+var secretStoreMu sync.Mutex
+
+// SetSecretStore sets the store that device secrets are resolved from. A nil store restores the default, which
+// reads each secret from a file of the same name in the "secrets/<project>" directory of LXD_DIR.
+func SetSecretStore(store SecretStore) {
+	secretStoreMu.Lock()
+	defer secretStoreMu.Unlock()
+
+	secretStore = store
+}
+
+// validSecretName validates the name of a secret referenced by a device.
+func validSecretName(value string) error {
+	if value == "" || value == "." || value == ".." || strings.ContainsAny(value, "/\000") {
+		return fmt.Errorf("Invalid secret name %q", value)
+	}
+
+	return nil
+}
+
+// secretGet resolves the named secret of the project from the secret store. The value has any trailing new line
+// removed and must not be empty. The value must not be logged or stored.
+func secretGet(projectName string, name string) (string, error) {
+	err := validSecretName(name)
+	if err != nil {
+		return "", err
+	}
+
+	// Project names can't contain a "/" either, but check anyway as the default store uses them as a path.
+	if projectName == "" || projectName == "." || projectName == ".." || strings.ContainsAny(projectName, "/\000") {
+		return "", fmt.Errorf("Invalid project name %q", projectName)
+	}
+
+	secretStoreMu.Lock()
+	store := secretStore
+	secretStoreMu.Unlock()
+
+	if store == nil {
+		store = &fileSecretStore{path: shared.VarPath("secrets")}
+	}
+
+	value, err := store.Get(projectName, name)
+	if err != nil {
+		return "", err
+	}
+
+	secret := strings.TrimRight(string(value), "\r\n")
+	if secret == "" {
+		return "", fmt.Errorf("Secret %q is empty", name)
+	}
+
+	return secret, nil
+}
@@ -0,0 +1,43 @@
+package device
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFileSecretStore(t *testing.T) {
+	dir := t.TempDir()
+
+	err := os.MkdirAll(filepath.Join(dir, "p1"), 0700)
+	assert.NoError(t, err)
+
+	err = os.WriteFile(filepath.Join(dir, "p1", "key"), []byte("AQBx==\n"), 0600)
+	assert.NoError(t, err)
+
+	err = os.WriteFile(filepath.Join(dir, "p1", "public"), []byte("AQBx=="), 0644)
+	assert.NoError(t, err)
+
+	err = os.WriteFile(filepath.Join(dir, "p1", "empty"), []byte("\n"), 0600)
+	assert.NoError(t, err)
+
+	SetSecretStore(&fileSecretStore{path: dir})
+	defer SetSecretStore(nil)
+
+	secret, err := secretGet("p1", "key")
+	assert.NoError(t, err)
+	assert.Equal(t, "AQBx==", secret)
+
+	for _, name := range []string{"missing", "public", "empty", "", "..", "../key", "sub/key", "../p1/key"} {
+		_, err = secretGet("p1", name)
+		assert.Error(t, err, name)
+	}
+
+	// Check the secrets of a project can't be resolved from another project.
+	for _, projectName := range []string{"p2", "", "..", "p2/../p1"} {
+		_, err = secretGet(projectName, "key")
+		assert.Error(t, err, projectName)
+	}
+}
@@ -1,15 +1,21 @@
 package device
 
 import (
+	"bufio"
+	"context"
+	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
+	"regexp"
 	"strconv"
 	"strings"
+	"time"
 
 	"golang.org/x/sys/unix"
 
 	deviceConfig "github.com/lxc/lxd/lxd/device/config"
+	"github.com/lxc/lxd/lxd/instance"
 	"github.com/lxc/lxd/lxd/state"
 	"github.com/lxc/lxd/lxd/storage/filesystem"
 	"github.com/lxc/lxd/shared"
@@ -20,6 +26,25 @@ import (
 // unixDefaultMode default mode to create unix devices with if not specified in device config.
 const unixDefaultMode = 0660
 
+// unixMkdir and unixMknod create the devices path and the device nodes in it.
+// They are variables so that tests can simulate an unavailable devices path.
+var unixMkdir = os.Mkdir
+var unixMknod = unix.Mknod
+
+// unixDevicesPathError returns a descriptive error matching ErrDevicesPathUnavailable if err was caused by the
+// devices path being on a read-only or full filesystem. Otherwise err is returned unchanged.
+func unixDevicesPathError(devicesPath string, err error) error {
+	if errors.Is(err, unix.EROFS) {
+		return devicesPathError{path: devicesPath, reason: "read-only", err: err}
+	}
+
+	if errors.Is(err, unix.ENOSPC) || errors.Is(err, unix.EDQUOT) {
+		return devicesPathError{path: devicesPath, reason: "out of space", err: err}
+	}
+
+	return err
+}
+
 // unixDeviceAttributes returns the decice type, major and minor numbers for a device.
 func unixDeviceAttributes(path string) (string, uint32, uint32, error) {
 	// Get a stat struct from the provided path
@@ -187,9 +212,9 @@ func UnixDeviceCreate(s *state.State, idmapSet *idmap.IdmapSet, devicesPath stri
 
 	// Create the devices directory if missing.
 	if !shared.PathExists(devicesPath) {
-		err := os.Mkdir(devicesPath, 0711)
+		err := unixMkdir(devicesPath, 0711)
 		if err != nil {
-			return nil, fmt.Errorf("Failed to create devices path: %s", err)
+			return nil, fmt.Errorf("Failed to create devices path: %w", unixDevicesPathError(devicesPath, err))
 		}
 	}
 
@@ -198,16 +223,17 @@ func UnixDeviceCreate(s *state.State, idmapSet *idmap.IdmapSet, devicesPath stri
 	devName := filesystem.PathNameEncode(deviceJoinPath(prefix, relativeDestPath))
 	devPath := filepath.Join(devicesPath, devName)
 
-	// Create the new entry.
-	if !s.OS.RunningInUserNS {
-		if s.OS.Nodev {
-			return nil, fmt.Errorf("Can't create device as devices path is mounted nodev")
-		}
+	strategy, err := unixDeviceStrategy(s, m)
+	if err != nil {
+		return nil, err
+	}
 
+	// Create the new entry.
+	if strategy == "mknod" {
 		devNum := int(unix.Mkdev(d.Major, d.Minor))
-		err := unix.Mknod(devPath, uint32(d.Mode), devNum)
+		err := unixMknod(devPath, uint32(d.Mode), devNum)
 		if err != nil {
-			return nil, fmt.Errorf("Failed to create device %s for %s: %w", devPath, srcPath, err)
+			return nil, fmt.Errorf("Failed to create device %s for %s: %w", devPath, srcPath, unixDevicesPathError(devicesPath, err))
 		}
 
 		err = os.Chown(devPath, d.UID, d.GID)
@@ -229,9 +255,13 @@ func UnixDeviceCreate(s *state.State, idmapSet *idmap.IdmapSet, devicesPath stri
 			}
 		}
 	} else {
+		if !shared.PathExists(srcPath) {
+			return nil, fmt.Errorf("Host device %q is required by the %q strategy but doesn't exist", srcPath, strategy)
+		}
+
 		f, err := os.Create(devPath)
 		if err != nil {
-			return nil, err
+			return nil, unixDevicesPathError(devicesPath, err)
 		}
 
 		_ = f.Close()
@@ -247,6 +277,224 @@ func UnixDeviceCreate(s *state.State, idmapSet *idmap.IdmapSet, devicesPath stri
 	return &d, nil
 }
 
+// unixDeviceStrategy returns the strategy used to create the device from the "strategy" device config key.
+// The "mknod" strategy creates a new device node, whereas the "bind" strategy bind-mounts the host device
+// node. When the key is unset or set to "auto", "bind" is used when LXD is running in a user namespace and
+// "mknod" otherwise, which fails if the devices path is mounted nodev.
+func unixDeviceStrategy(s *state.State, m deviceConfig.Device) (string, error) {
+	strategy := m["strategy"]
+	if strategy == "" || strategy == "auto" {
+		if s.OS.RunningInUserNS {
+			return "bind", nil
+		}
+
+		if s.OS.Nodev {
+			return "", fmt.Errorf("Can't create device as devices path is mounted nodev")
+		}
+
+		return "mknod", nil
+	}
+
+	if strategy == "mknod" {
+		if s.OS.RunningInUserNS {
+			return "", fmt.Errorf(`The "mknod" strategy can't be used as LXD is running in a user namespace`)
+		}
+
+		if s.OS.Nodev {
+			return "", fmt.Errorf(`The "mknod" strategy can't be used as the devices path is mounted nodev`)
+		}
+	}
+
+	return strategy, nil
+}
+
+// unixProbeTimeoutDefault is the default number of seconds that a device probe can take.
+const unixProbeTimeoutDefault = 5
+
+// unixDeviceProbe runs the probe configured in the device config against the host device node to check that
+// the device works before attaching it. The "open" probe checks the node can be opened, the "read" probe also
+// checks that reading from it doesn't fail (an empty read or one that would block is fine). The probe fails if it takes longer than "probe.timeout" seconds.
+func unixDeviceProbe(m deviceConfig.Device, devPath string) error {
+	if m["probe"] == "" {
+		return nil
+	}
+
+	timeout := time.Duration(unixProbeTimeoutDefault) * time.Second
+	if m["probe.timeout"] != "" {
+		seconds, err := strconv.ParseUint(m["probe.timeout"], 10, 32)
+		if err != nil {
+			return fmt.Errorf("Invalid probe timeout %q: %w", m["probe.timeout"], err)
+		}
+
+		timeout = time.Duration(seconds) * time.Second
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	// Opening some devices can block regardless of O_NONBLOCK, so bound it with the timeout.
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- unixDeviceProbeNode(devPath, m["probe"] == "read")
+	}()
+
+	select {
+	case err := <-errCh:
+		return err
+	case <-ctx.Done():
+		return fmt.Errorf("Probe of %q timed out after %s", devPath, timeout)
+	}
+}
+
+// unixDeviceProbeNode opens the device node (without blocking or making it the controlling terminal) and if
+// requested reads from it.
+func unixDeviceProbeNode(devPath string, read bool) error {
+	fd, err := unix.Open(devPath, unix.O_RDWR|unix.O_NONBLOCK|unix.O_NOCTTY|unix.O_CLOEXEC, 0)
+	if err != nil {
+		return fmt.Errorf("Failed opening %q: %w", devPath, err)
+	}
+
+	defer func() { _ = unix.Close(fd) }()
+
+	if !read {
+		return nil
+	}
+
+	buf := make([]byte, 1)
+	_, err = unix.Read(fd, buf)
+	if err != nil && !errors.Is(err, unix.EAGAIN) {
+		return fmt.Errorf("Failed reading %q: %w", devPath, err)
+	}
+
+	return nil
+}
+
+// unixNameTemplateRegex matches the device attribute references in a device node name template.
+var unixNameTemplateRegex = regexp.MustCompile(`\$\{([a-z]+)\}`)
+
+// unixValidNameTemplate returns a validator for a device node name template that can reference the supplied
+// device attributes.
+func unixValidNameTemplate(attributes ...string) func(string) error {
+	return func(value string) error {
+		placeholders := map[string]string{}
+		for _, match := range unixNameTemplateRegex.FindAllStringSubmatch(value, -1) {
+			if !shared.StringInSlice(match[1], attributes) {
+				return fmt.Errorf("Unknown device attribute %q (must be one of %s)", match[1], strings.Join(attributes, ", "))
+			}
+
+			placeholders[match[1]] = "x"
+		}
+
+		_, err := unixExpandNameTemplate(value, placeholders)
+		return err
+	}
+}
+
+// unixExpandNameTemplate replaces the device attribute references in the name template with their values and
+// returns the resulting path of the device node inside the instance. Relative names are placed under /dev and
+// the resulting path must be within /dev.
+func unixExpandNameTemplate(template string, attributes map[string]string) (string, error) {
+	var err error
+	name := unixNameTemplateRegex.ReplaceAllStringFunc(template, func(ref string) string {
+		attribute := unixNameTemplateRegex.FindStringSubmatch(ref)[1]
+		value := attributes[attribute]
+		if value == "" || value == "." || value == ".." || strings.ContainsAny(value, "/\x00") {
+			if err == nil {
+				err = fmt.Errorf("Device attribute %q has no usable value for the name template", attribute)
+			}
+		}
+
+		return value
+	})
+	if err != nil {
+		return "", err
+	}
+
+	if strings.Contains(name, "$") {
+		return "", fmt.Errorf("Invalid name template %q", template)
+	}
+
+	destPath := name
+	if !strings.HasPrefix(destPath, "/") {
+		destPath = "/dev/" + destPath
+	}
+
+	if filepath.Clean(destPath) != destPath || !strings.HasPrefix(destPath, "/dev/") {
+		return "", fmt.Errorf("Name template must produce a path within /dev, got %q", destPath)
+	}
+
+	return destPath, nil
+}
+
+// unixDeviceTemplatePath returns the path inside the instance for the device node with the supplied device
+// number by expanding the name template. As templates can produce the same name for different device nodes,
+// a numeric suffix is added to the name if it is already used by another device node in the instance.
+func unixDeviceTemplatePath(devicesPath string, template string, attributes map[string]string, major uint32, minor uint32) (string, error) {
+	destPath, err := unixExpandNameTemplate(template, attributes)
+	if err != nil {
+		return "", err
+	}
+
+	dents, err := os.ReadDir(devicesPath)
+	if err != nil && !os.IsNotExist(err) {
+		return "", err
+	}
+
+	// inUse indicates whether the path is used by a device node other than the one being named.
+	inUse := func(path string) bool {
+		suffix := "." + filesystem.PathNameEncode(strings.TrimPrefix(path, "/"))
+		for _, ent := range dents {
+			if !strings.HasSuffix(ent.Name(), suffix) {
+				continue
+			}
+
+			var stat unix.Stat_t
+			err := unix.Stat(filepath.Join(devicesPath, ent.Name()), &stat)
+			if err != nil || unix.Major(uint64(stat.Rdev)) != major || unix.Minor(uint64(stat.Rdev)) != minor {
+				return true
+			}
+		}
+
+		return false
+	}
+
+	candidate := destPath
+	for i := 2; inUse(candidate); i++ {
+		if i > 1000 {
+			return "", fmt.Errorf("Failed finding a unique name for %q", destPath)
+		}
+
+		candidate = fmt.Sprintf("%s-%d", destPath, i)
+	}
+
+	return candidate, nil
+}
+
+// unixDeviceFindPath returns the path inside the instance of the device node created for the LXD device with
+// the supplied device number, or an empty string if there isn't one. This allows finding device nodes named
+// from a template when the device attributes used for the name are no longer available (such as on removal).
+func unixDeviceFindPath(devicesPath string, typePrefix string, deviceName string, major uint32, minor uint32) string {
+	dents, err := os.ReadDir(devicesPath)
+	if err != nil {
+		return ""
+	}
+
+	ourPrefix := filesystem.PathNameEncode(deviceJoinPath(typePrefix, deviceName)) + "."
+	for _, ent := range dents {
+		if !strings.HasPrefix(ent.Name(), ourPrefix) {
+			continue
+		}
+
+		var stat unix.Stat_t
+		err := unix.Stat(filepath.Join(devicesPath, ent.Name()), &stat)
+		if err == nil && unix.Major(uint64(stat.Rdev)) == major && unix.Minor(uint64(stat.Rdev)) == minor {
+			return "/" + filesystem.PathNameDecode(strings.TrimPrefix(ent.Name(), ourPrefix))
+		}
+	}
+
+	return ""
+}
+
 // unixDeviceSetup creates a UNIX device on host and then configures supplied RunConfig with the
 // mount and cgroup rule instructions to have it be attached to the instance. If defaultMode is true
 // or mode is supplied in the device config then the origin device does not need to be accessed for
@@ -294,6 +542,12 @@ func unixDeviceSetup(s *state.State, devicesPath string, typePrefix string, devi
 	ourPrefix := deviceJoinPath(typePrefix, deviceName)
 	d, err := UnixDeviceCreate(s, nil, devicesPath, ourPrefix, m, defaultMode)
 	if err != nil {
+		// Non-required devices can be configured to be skipped if the devices path is unavailable.
+		if errors.Is(err, ErrDevicesPathUnavailable) && m["devices_path.unavailable"] == "skip" {
+			logger.Warn("Skipping device setup as devices path is unavailable", logger.Ctx{"device": deviceName, "path": unixDeviceDestPath(m), "err": err})
+			return nil
+		}
+
 		return err
 	}
 
@@ -362,6 +616,79 @@ func unixDeviceSetupBlockNum(s *state.State, devicesPath string, typePrefix stri
 	return unixDeviceSetup(s, devicesPath, typePrefix, deviceName, configCopy, defaultMode, runConf)
 }
 
+// unixDeviceDirHook adds a hook to runConf that sets the ownership and mode of the parent directory of each of
+// the device nodes mounted by runConf inside the container, from the "dir.uid", "dir.gid" and "dir.mode"
+// settings. The owner is the one inside the container, so for unprivileged containers it is checked to be mapped
+// in the container's idmap and is translated through it. Nothing is added if none of the settings are used.
+func unixDeviceDirHook(inst instance.Instance, m deviceConfig.Device, runConf *deviceConfig.RunConfig) {
+	if m["dir.uid"] == "" && m["dir.gid"] == "" && m["dir.mode"] == "" {
+		return
+	}
+
+	runConf.PostHooks = append(runConf.PostHooks, func() error {
+		dirs := []string{}
+		for _, mount := range runConf.Mounts {
+			if mount.DevPath == "" {
+				continue
+			}
+
+			// Nodes placed directly in /dev don't have a directory of their own.
+			dir := filepath.Dir(strings.TrimPrefix(mount.TargetPath, "/"))
+			if dir != "." && dir != "dev" && !shared.StringInSlice(dir, dirs) {
+				dirs = append(dirs, dir)
+			}
+		}
+
+		if len(dirs) == 0 {
+			return nil
+		}
+
+		uid, _ := strconv.ParseInt(m["dir.uid"], 10, 64) // Validated by unixValidUserID.
+		gid, _ := strconv.ParseInt(m["dir.gid"], 10, 64) // Validated by unixValidUserID.
+		mode, err := strconv.ParseUint(m["dir.mode"], 8, 32)
+		if err != nil {
+			mode = 0755
+		}
+
+		c, ok := inst.(instance.Container)
+		if ok && !c.IsPrivileged() {
+			idmapSet, err := c.CurrentIdmap()
+			if err != nil {
+				return fmt.Errorf("Failed getting the container's idmap: %w", err)
+			}
+
+			if idmapSet != nil {
+				hostUID, hostGID := idmapSet.ShiftFromNs(uid, gid)
+				if hostUID < 0 || hostGID < 0 {
+					return fmt.Errorf("The owner %d:%d of the device directories isn't mapped in the container", uid, gid)
+				}
+			}
+		}
+
+		// The files API runs in the container's user namespace, which translates the owner through the idmap.
+		files, err := inst.FileSFTP()
+		if err != nil {
+			return fmt.Errorf("Failed connecting to the container's files: %w", err)
+		}
+
+		defer func() { _ = files.Close() }()
+
+		for _, dir := range dirs {
+			err = files.Chown(dir, int(uid), int(gid))
+			if err != nil {
+				return fmt.Errorf("Failed setting the owner of device directory %q: %w", "/"+dir, err)
+			}
+
+			err = files.Chmod(dir, os.FileMode(mode))
+			if err != nil {
+				return fmt.Errorf("Failed setting the mode of device directory %q: %w", "/"+dir, err)
+			}
+		}
+
+		return nil
+	})
+}
+
 // UnixDeviceExists checks if the unix device already exists in devices path.
 func UnixDeviceExists(devicesPath string, prefix string, path string) bool {
 	relativeDestPath := strings.TrimPrefix(path, "/")
@@ -371,13 +698,24 @@ func UnixDeviceExists(devicesPath string, prefix string, path string) bool {
 	return shared.PathExists(devPath)
 }
 
+// unixDeviceFileMatches indicates whether the host side device file name matches the encoded prefix.
+// When a specific file is selected the name must match exactly, as otherwise selecting "/dev/ttyUSB1"
+// would also select "/dev/ttyUSB10".
+func unixDeviceFileMatches(devName string, ourPrefix string, exact bool) bool {
+	if exact {
+		return devName == ourPrefix
+	}
+
+	return strings.HasPrefix(devName, ourPrefix)
+}
+
 // unixRemoveDevice identifies all files related to the supplied typePrefix and deviceName and then
 // populates the supplied runConf with the instructions to remove cgroup rules and unmount devices.
 // It detects if any other devices attached to the instance that share the same prefix have the same
 // relative mount path inside the instance encoded into the file name. If there is another device
 // that shares the same mount path then the unmount rule is not added to the runConf as the device
 // may still be in use with another LXD device.
-// Accepts an optional file prefix that will be used to narrow the selection of files to remove.
+// Accepts an optional relative path inside the instance that will be used to select the file to remove.
 func unixDeviceRemove(devicesPath string, typePrefix string, deviceName string, optPrefix string, runConf *deviceConfig.RunConfig) error {
 	// Load all devices.
 	dents, err := os.ReadDir(devicesPath)
@@ -402,7 +740,7 @@ func unixDeviceRemove(devicesPath string, typePrefix string, deviceName string,
 		devName := ent.Name()
 
 		// This device file belongs our LXD device.
-		if strings.HasPrefix(devName, ourPrefix) {
+		if unixDeviceFileMatches(devName, ourPrefix, optPrefix != "") {
 			ourDevs = append(ourDevs, devName)
 			continue
 		}
@@ -474,7 +812,7 @@ func unixDeviceRemove(devicesPath string, typePrefix string, deviceName string,
 }
 
 // unixDeviceDeleteFiles removes all host side device files for a particular LXD device.
-// Accepts an optional file prefix that will be used to narrow the selection of files to delete.
+// Accepts an optional relative path inside the instance that will be used to select the file to delete.
 // This should be run after the files have been detached from the instance as a post hook.
 func unixDeviceDeleteFiles(s *state.State, devicesPath string, typePrefix string, deviceName string, optPrefix string) error {
 	var ourPrefix string
@@ -498,11 +836,11 @@ func unixDeviceDeleteFiles(s *state.State, devicesPath string, typePrefix string
 		devName := ent.Name()
 
 		// This device file belongs our LXD device.
-		if strings.HasPrefix(devName, ourPrefix) {
+		if unixDeviceFileMatches(devName, ourPrefix, optPrefix != "") {
 			devPath := filepath.Join(devicesPath, devName)
 
-			// Remove the host side mount.
-			if s.OS.RunningInUserNS {
+			// Remove the host side mount (used by the bind strategy).
+			if s.OS.RunningInUserNS || filesystem.IsMountPoint(devPath) {
 				_ = unix.Unmount(devPath, unix.MNT_DETACH)
 			}
 
@@ -545,6 +883,77 @@ func unixValidUserID(value string) error {
 	return nil
 }
 
+// unixUserNameRegex matches the portable UNIX user and group names.
+var unixUserNameRegex = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_.-]*\$?$`)
+
+// unixValidUserName validates a UNIX user or group name.
+func unixValidUserName(value string) error {
+	if value == "" {
+		return nil
+	}
+
+	if len(value) > 32 || !unixUserNameRegex.MatchString(value) {
+		return fmt.Errorf("Invalid value for a UNIX user or group name")
+	}
+
+	return nil
+}
+
+// unixInstanceLookupID looks up the ID of the named user or group in the instance's root filesystem.
+// The dbFile argument is the path of the account database inside the instance (/etc/passwd or /etc/group).
+// The database is opened relative to the instance root filesystem so that symlinks inside the instance cannot
+// cause files on the host to be read.
+func unixInstanceLookupID(rootfsPath string, dbFile string, name string) (string, error) {
+	root, err := os.OpenFile(rootfsPath, unix.O_PATH|unix.O_DIRECTORY|unix.O_CLOEXEC, 0)
+	if err != nil {
+		return "", fmt.Errorf("Failed opening instance root filesystem %q: %w", rootfsPath, err)
+	}
+
+	defer func() { _ = root.Close() }()
+
+	// Requires Linux kernel >= 5.6.
+	fd, err := unix.Openat2(int(root.Fd()), dbFile, &unix.OpenHow{
+		Flags:   unix.O_RDONLY | unix.O_CLOEXEC,
+		Resolve: unix.RESOLVE_IN_ROOT | unix.RESOLVE_NO_MAGICLINKS,
+	})
+	if err != nil {
+		return "", fmt.Errorf("Failed opening %q in instance: %w", dbFile, err)
+	}
+
+	f := os.NewFile(uintptr(fd), dbFile)
+	defer func() { _ = f.Close() }()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		// Both databases use the "name:password:ID:..." format.
+		fields := strings.Split(scanner.Text(), ":")
+		if len(fields) < 3 || fields[0] != name {
+			continue
+		}
+
+		_, err := strconv.ParseUint(fields[2], 10, 32)
+		if err != nil {
+			return "", fmt.Errorf("Invalid ID %q for %q in instance %q", fields[2], name, dbFile)
+		}
+
+		return fields[2], nil
+	}
+
+	err = scanner.Err()
+	if err != nil {
+		return "", fmt.Errorf("Failed reading %q in instance: %w", dbFile, err)
+	}
+
+	return "", fmt.Errorf("%q not found in instance %q", name, dbFile)
+}
+
+// unixStrategyConflict prevents setting the ownership or mode of devices using the "bind" strategy as those of
+// the bind-mounted host device node can't be changed.
+var unixStrategyConflict = deviceKeyConflict{
+	keys:          []string{"strategy=bind"},
+	conflictsWith: []string{"uid", "gid", "mode"},
+}
+
 // unixValidOctalFileMode validates the UNIX file mode.
 func unixValidOctalFileMode(value string) error {
 	if value == "" {
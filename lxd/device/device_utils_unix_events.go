@@ -111,6 +111,11 @@ func unixRunHandlers(state *state.State, event *UnixEvent) {
 			continue
 		}
 
+		// Don't process events for devices that are stopping or stopped.
+		if !lifecycleHandlesEvents(key) {
+			continue
+		}
+
 		// Run handler function.
 		runConf, err := sub.Handler(*event)
 		if err != nil {
@@ -132,6 +137,8 @@ func unixRunHandlers(state *state.State, event *UnixEvent) {
 				logger.Error("Unix event instance handler failed", logger.Ctx{"err": err, "project": projectName, "instance": instanceName, "device": deviceName})
 				continue
 			}
+
+			sendInstanceEvent(state, instance, deviceName, hotplugEventAction(event.Action), "", map[string]string{"path": event.Path})
 		}
 	}
 }
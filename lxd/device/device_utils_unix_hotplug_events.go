@@ -69,6 +69,11 @@ func UnixHotplugRunHandlers(state *state.State, event *UnixHotplugEvent) {
 			continue
 		}
 
+		// Don't process events for devices that are stopping or stopped.
+		if !lifecycleHandlesEvents(key) {
+			continue
+		}
+
 		runConf, err := hook(*event)
 		if err != nil {
 			logger.Error("Unix hotplug event hook failed", logger.Ctx{"err": err, "project": projectName, "instance": instanceName, "device": deviceName})
@@ -89,6 +94,13 @@ func UnixHotplugRunHandlers(state *state.State, event *UnixHotplugEvent) {
 				logger.Error("Unix hotplug event instance handler failed", logger.Ctx{"err": err, "project": projectName, "instance": instanceName, "device": deviceName})
 				continue
 			}
+
+			sendInstanceEvent(state, instance, deviceName, hotplugEventAction(event.Action), "", map[string]string{
+				"vendorid":  event.Vendor,
+				"productid": event.Product,
+				"subsystem": event.Subsystem,
+				"path":      event.Path,
+			})
 		}
 	}
 }
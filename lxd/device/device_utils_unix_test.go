@@ -0,0 +1,162 @@
+package device
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/sys/unix"
+
+	deviceConfig "github.com/lxc/lxd/lxd/device/config"
+	"github.com/lxc/lxd/lxd/state"
+	"github.com/lxc/lxd/lxd/sys"
+)
+
+func TestUnixDevicesPathError(t *testing.T) {
+	devicesPath := "/var/snap/lxd/common/lxd/devices/c1"
+
+	tests := []struct {
+		err    error
+		reason string
+	}{
+		{err: &os.PathError{Op: "mknod", Path: devicesPath + "/unix.dev1.dev-ttyUSB0", Err: unix.EROFS}, reason: "read-only"},
+		{err: &os.PathError{Op: "open", Path: devicesPath + "/unix.dev1.dev-ttyUSB0", Err: unix.ENOSPC}, reason: "out of space"},
+		{err: &os.PathError{Op: "mkdir", Path: devicesPath, Err: unix.EDQUOT}, reason: "out of space"},
+		{err: unix.EROFS, reason: "read-only"}, // As returned by unix.Mknod.
+		{err: unix.ENOSPC, reason: "out of space"},
+	}
+
+	for _, test := range tests {
+		err := unixDevicesPathError(devicesPath, test.err)
+
+		// Check the error matches the sentinel error even when wrapped.
+		assert.ErrorIs(t, err, ErrDevicesPathUnavailable)
+		assert.ErrorIs(t, fmt.Errorf("Failed to create device: %w", err), ErrDevicesPathUnavailable)
+
+		// Check the original error is kept.
+		assert.ErrorIs(t, err, test.err)
+
+		// Check the message is actionable.
+		assert.Contains(t, err.Error(), fmt.Sprintf("Devices path %q is %s", devicesPath, test.reason))
+	}
+
+	// Check other errors are returned unchanged.
+	otherErr := &os.PathError{Op: "mknod", Path: devicesPath, Err: unix.EPERM}
+	err := unixDevicesPathError(devicesPath, otherErr)
+	assert.Equal(t, otherErr, err)
+	assert.False(t, errors.Is(err, ErrDevicesPathUnavailable))
+}
+
+func TestUnixExpandNameTemplate(t *testing.T) {
+	attributes := map[string]string{"serial": "AB12", "pci": "0000:01:00.0", "devnum": "..", "syspath": "/sys/devices/usb1"}
+
+	tests := map[string]string{
+		"tty-${serial}":              "/dev/tty-AB12",
+		"gpu-${pci}":                 "/dev/gpu-0000:01:00.0",
+		"serial/by-serial/${serial}": "/dev/serial/by-serial/AB12",
+		"/dev/usb-${serial}":         "/dev/usb-AB12",
+	}
+
+	for template, expected := range tests {
+		destPath, err := unixExpandNameTemplate(template, attributes)
+		assert.NoError(t, err, template)
+		assert.Equal(t, expected, destPath, template)
+	}
+
+	// Check templates producing unsafe paths or using unusable attribute values are rejected.
+	for _, template := range []string{"../${serial}", "/etc/${serial}", "tty/../../${serial}", "tty//${serial}", "${devnum}", "usb-${syspath}", "usb-${vendorid}", "$serial"} {
+		_, err := unixExpandNameTemplate(template, attributes)
+		assert.Error(t, err, template)
+	}
+}
+
+func TestUnixDeviceStrategy(t *testing.T) {
+	tests := []struct {
+		os       sys.OS
+		strategy string
+		expected string
+	}{
+		{os: sys.OS{}, strategy: "", expected: "mknod"},
+		{os: sys.OS{}, strategy: "auto", expected: "mknod"},
+		{os: sys.OS{}, strategy: "bind", expected: "bind"},
+		{os: sys.OS{RunningInUserNS: true}, strategy: "auto", expected: "bind"},
+		{os: sys.OS{RunningInUserNS: true}, strategy: "mknod", expected: ""},
+		{os: sys.OS{RunningInUserNS: true, Nodev: true}, strategy: "auto", expected: "bind"},
+		{os: sys.OS{Nodev: true}, strategy: "auto", expected: ""},
+		{os: sys.OS{Nodev: true}, strategy: "mknod", expected: ""},
+		{os: sys.OS{Nodev: true}, strategy: "bind", expected: "bind"},
+	}
+
+	for _, test := range tests {
+		hostOS := test.os
+		strategy, err := unixDeviceStrategy(&state.State{OS: &hostOS}, deviceConfig.Device{"strategy": test.strategy})
+		if test.expected == "" {
+			assert.Error(t, err, test)
+			continue
+		}
+
+		assert.NoError(t, err, test)
+		assert.Equal(t, test.expected, strategy, test)
+	}
+
+	// Check the auto strategy keeps failing as before when the devices path is mounted nodev.
+	_, err := unixDeviceStrategy(&state.State{OS: &sys.OS{Nodev: true}}, deviceConfig.Device{})
+	assert.EqualError(t, err, "Can't create device as devices path is mounted nodev")
+}
+
+func TestUnixDeviceSetupDevicesPathUnavailable(t *testing.T) {
+	s := &state.State{OS: &sys.OS{}}
+	m := deviceConfig.Device{"type": "unix-char", "path": "/dev/ttyUSB0", "major": "188", "minor": "0", "mode": "0660"}
+
+	defer func() {
+		unixMkdir = os.Mkdir
+		unixMknod = unix.Mknod
+	}()
+
+	tests := []struct {
+		name       string
+		mkdirErr   error
+		mknodErr   error
+		pathExists bool
+	}{
+		{name: "read-only devices path", mkdirErr: &os.PathError{Op: "mkdir", Err: unix.EROFS}},
+		{name: "full devices path", mknodErr: unix.ENOSPC, pathExists: true},
+	}
+
+	for _, test := range tests {
+		devicesPath := filepath.Join(t.TempDir(), "devices")
+		if test.pathExists {
+			require.NoError(t, os.Mkdir(devicesPath, 0711))
+		}
+
+		unixMkdir = func(path string, mode os.FileMode) error { return test.mkdirErr }
+		unixMknod = func(path string, mode uint32, dev int) error { return test.mknodErr }
+
+		// Check the device fails to start by default, reporting the devices path as unavailable.
+		runConf := deviceConfig.RunConfig{}
+		err := unixDeviceSetup(s, devicesPath, "unix", "dev0", m, false, &runConf)
+		assert.ErrorIs(t, err, ErrDevicesPathUnavailable, test.name)
+		assert.Empty(t, runConf.Mounts, test.name)
+
+		// Check the device is skipped when configured to be.
+		m["devices_path.unavailable"] = "skip"
+		err = unixDeviceSetup(s, devicesPath, "unix", "dev0", m, false, &runConf)
+		delete(m, "devices_path.unavailable")
+		assert.NoError(t, err, test.name)
+		assert.Empty(t, runConf.Mounts, test.name)
+		assert.Empty(t, runConf.CGroups, test.name)
+
+		// Check other errors aren't skipped.
+		unixMkdir = func(path string, mode os.FileMode) error { return &os.PathError{Op: "mkdir", Err: unix.EPERM} }
+		unixMknod = func(path string, mode uint32, dev int) error { return unix.EPERM }
+		m["devices_path.unavailable"] = "skip"
+		err = unixDeviceSetup(s, devicesPath, "unix", "dev0", m, false, &runConf)
+		delete(m, "devices_path.unavailable")
+		assert.ErrorIs(t, err, unix.EPERM, test.name)
+		assert.False(t, errors.Is(err, ErrDevicesPathUnavailable), test.name)
+	}
+}
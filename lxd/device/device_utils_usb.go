@@ -0,0 +1,365 @@
+package device
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+
+	"golang.org/x/sys/unix"
+
+	"github.com/lxc/lxd/lxd/storage/filesystem"
+	"github.com/lxc/lxd/shared/api"
+	"github.com/lxc/lxd/shared/logger"
+)
+
+// USB descriptor types.
+const (
+	usbDescriptorTypeDevice        = 0x01
+	usbDescriptorTypeConfiguration = 0x02
+	usbDescriptorTypeInterface     = 0x04
+	usbDescriptorTypeEndpoint      = 0x05
+)
+
+// usbEndpointTransferTypes maps the endpoint bmAttributes transfer type bits to their names.
+var usbEndpointTransferTypes = []string{"control", "isochronous", "bulk", "interrupt"}
+
+// usbSysfsPaths are the sysfs paths scanned for USB devices, set from the server configuration.
+var usbSysfsPaths = []string{usbDevPath}
+
+// usbSysfsPathsMu controls access to usbSysfsPaths.
+var usbSysfsPathsMu sync.Mutex
+
+// SetUSBSysfsPaths sets the sysfs paths scanned for USB devices. If none are supplied, the default path is used.
+func SetUSBSysfsPaths(sysfsPaths []string) {
+	if len(sysfsPaths) == 0 {
+		sysfsPaths = []string{usbDevPath}
+	}
+
+	usbSysfsPathsMu.Lock()
+	defer usbSysfsPathsMu.Unlock()
+
+	usbSysfsPaths = append([]string{}, sysfsPaths...)
+}
+
+// usbSysfsPathsGet returns the sysfs paths scanned for USB devices and whether they are other than the default one.
+func usbSysfsPathsGet() ([]string, bool) {
+	usbSysfsPathsMu.Lock()
+	defer usbSysfsPathsMu.Unlock()
+
+	custom := len(usbSysfsPaths) != 1 || usbSysfsPaths[0] != usbDevPath
+
+	return append([]string{}, usbSysfsPaths...), custom
+}
+
+// usbCheckSysfsPath checks that the path is on sysfs. The device nodes created for the USB devices found under
+// a path are those described by its files, so a directory made to look like a sysfs tree mustn't be scanned.
+func usbCheckSysfsPath(sysfsPath string) error {
+	st, err := filesystem.StatVFS(sysfsPath)
+	if err != nil {
+		return fmt.Errorf("Failed checking USB sysfs path %q: %w", sysfsPath, err)
+	}
+
+	if int32(st.Type) != unix.SYSFS_MAGIC {
+		return fmt.Errorf("USB sysfs path %q isn't on sysfs", sysfsPath)
+	}
+
+	return nil
+}
+
+// usbScanSysfsPaths returns the sysfs paths to scan for USB devices, leaving out those that don't exist or aren't
+// on sysfs.
+func usbScanSysfsPaths() []string {
+	configured, _ := usbSysfsPathsGet()
+
+	sysfsPaths := make([]string, 0, len(configured))
+	for _, sysfsPath := range configured {
+		err := usbCheckSysfsPath(sysfsPath)
+		if err != nil {
+			if !errors.Is(err, os.ErrNotExist) {
+				logger.Warn("Not scanning USB sysfs path", logger.Ctx{"path": sysfsPath, "err": err})
+			}
+
+			continue
+		}
+
+		sysfsPaths = append(sysfsPaths, sysfsPath)
+	}
+
+	return sysfsPaths
+}
+
+// usbSysfsPath returns the sysfs path of the USB device with the supplied bus and device numbers,
+// searching each of the supplied sysfs paths in order.
+func usbSysfsPath(sysfsPaths []string, busNum int, devNum int) (string, error) {
+	for _, sysfsPath := range sysfsPaths {
+		ents, err := os.ReadDir(sysfsPath)
+		if err != nil {
+			if os.IsNotExist(err) {
+				continue
+			}
+
+			return "", err
+		}
+
+		for _, ent := range ents {
+			// Skip USB interfaces.
+			if strings.Contains(ent.Name(), ":") {
+				continue
+			}
+
+			devPath := filepath.Join(sysfsPath, ent.Name())
+
+			bus, err := usbReadSysfsInt(devPath, "busnum")
+			if err != nil || bus != busNum {
+				continue
+			}
+
+			dev, err := usbReadSysfsInt(devPath, "devnum")
+			if err != nil || dev != devNum {
+				continue
+			}
+
+			return devPath, nil
+		}
+	}
+
+	return "", fmt.Errorf("USB device %03d:%03d not found", busNum, devNum)
+}
+
+// usbReadSysfsInt reads an integer value from the named file in a USB device's sysfs directory.
+func usbReadSysfsInt(devPath string, name string) (int, error) {
+	content, err := os.ReadFile(filepath.Join(devPath, name))
+	if err != nil {
+		return -1, err
+	}
+
+	return strconv.Atoi(strings.TrimSpace(string(content)))
+}
+
+// usbMaxPower returns the maximum power draw in mA of the USB device at the supplied sysfs path.
+// This is read from the bMaxPower attribute of the device's active configuration.
+func usbMaxPower(devPath string) (uint64, error) {
+	content, err := os.ReadFile(filepath.Join(devPath, "bMaxPower"))
+	if err != nil {
+		return 0, err
+	}
+
+	value := strings.TrimSuffix(strings.TrimSpace(string(content)), "mA")
+	if value == "" {
+		// Unconfigured devices don't report a power draw.
+		return 0, nil
+	}
+
+	maxPower, err := strconv.ParseUint(value, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("Invalid bMaxPower value %q: %w", string(content), err)
+	}
+
+	return maxPower, nil
+}
+
+// usbHubMaxPower returns the combined maximum power draw in mA of all USB devices connected to the
+// same hub as the USB device at the supplied sysfs path (including the device itself).
+func usbHubMaxPower(devPath string) (uint64, error) {
+	realPath, err := filepath.EvalSymlinks(devPath)
+	if err != nil {
+		return 0, err
+	}
+
+	hubPath := filepath.Dir(realPath)
+
+	ents, err := os.ReadDir(hubPath)
+	if err != nil {
+		return 0, err
+	}
+
+	var total uint64
+	for _, ent := range ents {
+		// Skip USB interfaces and non-device entries.
+		if strings.Contains(ent.Name(), ":") || !ent.IsDir() {
+			continue
+		}
+
+		childPath := filepath.Join(hubPath, ent.Name())
+		_, err := os.Stat(filepath.Join(childPath, "busnum"))
+		if err != nil {
+			continue
+		}
+
+		maxPower, err := usbMaxPower(childPath)
+		if err != nil {
+			continue
+		}
+
+		total += maxPower
+	}
+
+	return total, nil
+}
+
+// usbBlockDevices returns the block device numbers (major:minor) of the block devices provided by the
+// USB device at the supplied sysfs path (such as for USB mass storage devices).
+func usbBlockDevices(devPath string) ([]string, error) {
+	realPath, err := filepath.EvalSymlinks(devPath)
+	if err != nil {
+		return nil, err
+	}
+
+	blocks := []string{}
+	err = filepath.WalkDir(realPath, func(path string, ent os.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		// Don't follow symlinks (such as "subsystem" or "driver") out of the device tree.
+		if ent.Type()&os.ModeSymlink != 0 {
+			return nil
+		}
+
+		if filepath.Base(filepath.Dir(path)) != "block" || !ent.IsDir() {
+			return nil
+		}
+
+		content, err := os.ReadFile(filepath.Join(path, "dev"))
+		if err != nil {
+			return nil
+		}
+
+		blocks = append(blocks, strings.TrimSpace(string(content)))
+
+		return filepath.SkipDir
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return blocks, nil
+}
+
+// usbNetInterfaces returns the names of the network interfaces provided by the USB device at the supplied
+// sysfs path (such as for USB network adapters and gadgets).
+func usbNetInterfaces(devPath string) ([]string, error) {
+	paths, err := filepath.Glob(filepath.Join(devPath, "*:*", "net", "*"))
+	if err != nil {
+		return nil, err
+	}
+
+	interfaces := make([]string, 0, len(paths))
+	for _, path := range paths {
+		interfaces = append(interfaces, filepath.Base(path))
+	}
+
+	return interfaces, nil
+}
+
+// usbDescriptors reads and parses the descriptors of the USB device at the supplied sysfs path.
+// The sysfs descriptors file contains the device descriptor followed by the descriptors of each of the
+// device's configurations.
+func usbDescriptors(devPath string) (*api.InstanceStateDeviceUSBDescriptors, error) {
+	data, err := os.ReadFile(filepath.Join(devPath, "descriptors"))
+	if err != nil {
+		return nil, err
+	}
+
+	return usbParseDescriptors(data)
+}
+
+// usbBCDVersion formats a binary-coded decimal version number (such as bcdUSB) as a string.
+func usbBCDVersion(value uint16) string {
+	return fmt.Sprintf("%x.%02x", value>>8, value&0xff)
+}
+
+// usbParseDescriptors parses raw USB descriptor data into a summary of the device, its configurations,
+// interfaces and endpoints. Class specific descriptors are skipped.
+func usbParseDescriptors(data []byte) (*api.InstanceStateDeviceUSBDescriptors, error) {
+	if len(data) < 18 || data[1] != usbDescriptorTypeDevice {
+		return nil, fmt.Errorf("Invalid USB device descriptor")
+	}
+
+	bcdUSB := binary.LittleEndian.Uint16(data[2:4])
+
+	descriptors := &api.InstanceStateDeviceUSBDescriptors{
+		USBVersion:     usbBCDVersion(bcdUSB),
+		Class:          int(data[4]),
+		SubClass:       int(data[5]),
+		Protocol:       int(data[6]),
+		MaxPacketSize:  int(data[7]),
+		DeviceVersion:  usbBCDVersion(binary.LittleEndian.Uint16(data[12:14])),
+		Configurations: []api.InstanceStateDeviceUSBConfiguration{},
+	}
+
+	// SuperSpeed devices report the maximum power in units of 8mA rather than 2mA.
+	powerUnit := 2
+	if bcdUSB >= 0x0300 {
+		powerUnit = 8
+	}
+
+	var config *api.InstanceStateDeviceUSBConfiguration
+	var iface *api.InstanceStateDeviceUSBInterface
+
+	for offset := int(data[0]); offset < len(data); {
+		length := int(data[offset])
+		if length < 2 || offset+length > len(data) {
+			return nil, fmt.Errorf("Invalid USB descriptor length %d at offset %d", length, offset)
+		}
+
+		desc := data[offset : offset+length]
+		offset += length
+
+		switch desc[1] {
+		case usbDescriptorTypeConfiguration:
+			if length < 9 {
+				return nil, fmt.Errorf("Invalid USB configuration descriptor")
+			}
+
+			descriptors.Configurations = append(descriptors.Configurations, api.InstanceStateDeviceUSBConfiguration{
+				Value:      int(desc[5]),
+				Attributes: int(desc[7]),
+				MaxPower:   int(desc[8]) * powerUnit,
+				Interfaces: []api.InstanceStateDeviceUSBInterface{},
+			})
+
+			config = &descriptors.Configurations[len(descriptors.Configurations)-1]
+			iface = nil
+		case usbDescriptorTypeInterface:
+			if length < 9 || config == nil {
+				return nil, fmt.Errorf("Invalid USB interface descriptor")
+			}
+
+			config.Interfaces = append(config.Interfaces, api.InstanceStateDeviceUSBInterface{
+				Number:           int(desc[2]),
+				AlternateSetting: int(desc[3]),
+				Class:            int(desc[5]),
+				SubClass:         int(desc[6]),
+				Protocol:         int(desc[7]),
+				Endpoints:        []api.InstanceStateDeviceUSBEndpoint{},
+			})
+
+			iface = &config.Interfaces[len(config.Interfaces)-1]
+		case usbDescriptorTypeEndpoint:
+			if length < 7 || iface == nil {
+				return nil, fmt.Errorf("Invalid USB endpoint descriptor")
+			}
+
+			direction := "out"
+			if desc[2]&0x80 != 0 {
+				direction = "in"
+			}
+
+			iface.Endpoints = append(iface.Endpoints, api.InstanceStateDeviceUSBEndpoint{
+				Address:       int(desc[2]),
+				Direction:     direction,
+				TransferType:  usbEndpointTransferTypes[desc[3]&0x03],
+				MaxPacketSize: int(binary.LittleEndian.Uint16(desc[4:6]) & 0x07ff),
+				Interval:      int(desc[6]),
+			})
+		}
+	}
+
+	return descriptors, nil
+}
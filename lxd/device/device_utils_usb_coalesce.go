@@ -0,0 +1,131 @@
+package device
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/lxc/lxd/lxd/instance"
+	"github.com/lxc/lxd/lxd/state"
+	"github.com/lxc/lxd/shared/logger"
+)
+
+// usbCoalesce stores the USB event coalescing state. Access is controlled by usbMutex.
+var usbCoalesce struct {
+	delay   time.Duration
+	timer   *time.Timer
+	devices map[string]USBEvent // Host USB devices as of the last reconciliation, keyed on bus and device number.
+}
+
+// USBCoalesce sets how long USB events are coalesced for before the instances' devices are reconciled with the
+// host. Rather than running every device's handler for each event, the host is scanned once after the delay and
+// the handlers are run for the devices that were removed and added since the last scan. This bounds the work done
+// on hosts with many instances to one host scan and one pass over the handlers per delay, regardless of the number
+// of events. A delay of zero processes each event straight away (the default).
+func USBCoalesce(s *state.State, delay time.Duration) error {
+	usbMutex.Lock()
+	defer usbMutex.Unlock()
+
+	if delay == usbCoalesce.delay {
+		return nil
+	}
+
+	if delay <= 0 {
+		// Apply any reconciliation that is due before going back to processing events straight away.
+		if usbCoalesce.timer != nil {
+			usbCoalesce.timer.Stop()
+			usbCoalesceReconcile(s)
+		}
+
+		usbCoalesce.delay = 0
+		usbCoalesce.timer = nil
+		usbCoalesce.devices = nil
+		logger.Info("Stopped coalescing USB events")
+
+		return nil
+	}
+
+	if usbCoalesce.delay <= 0 {
+		devices, err := usbHostDevices()
+		if err != nil {
+			return fmt.Errorf("Failed scanning host USB devices: %w", err)
+		}
+
+		usbCoalesce.devices = devices
+	}
+
+	usbCoalesce.delay = delay
+	logger.Info("Coalescing USB events", logger.Ctx{"delay": delay})
+
+	return nil
+}
+
+// usbCoalesceEvent schedules the reconciliation of the devices for the event, unless one is already scheduled
+// (in which case the event is covered by it). The caller must hold usbMutex.
+func usbCoalesceEvent(s *state.State) {
+	if usbCoalesce.timer != nil {
+		return
+	}
+
+	usbCoalesce.timer = time.AfterFunc(usbCoalesce.delay, func() {
+		usbMutex.Lock()
+		defer usbMutex.Unlock()
+
+		usbCoalesce.timer = nil
+		if usbCoalesce.delay <= 0 {
+			return
+		}
+
+		usbCoalesceReconcile(s)
+	})
+}
+
+// usbCoalesceReconcile scans the host USB devices and runs the handlers for the devices that were removed and
+// added since the last scan. Each instance is loaded at most once. If the host can't be scanned, the
+// reconciliation is retried after the delay. The caller must hold usbMutex.
+func usbCoalesceReconcile(s *state.State) {
+	// Whilst quiesced, resuming reconciles the devices instead.
+	if usbQuiesce.enabled {
+		return
+	}
+
+	devices, err := usbHostDevices()
+	if err != nil {
+		logger.Error("Failed scanning host USB devices to reconcile", logger.Ctx{"err": err})
+
+		if usbCoalesce.delay > 0 {
+			usbCoalesceEvent(s)
+		}
+
+		return
+	}
+
+	events := usbReconcileEvents(usbCoalesce.devices, devices)
+	usbCoalesce.devices = devices
+
+	if len(events) == 0 {
+		return
+	}
+
+	logger.Debug("Reconciling coalesced USB events", logger.Ctx{"events": len(events), "handlers": len(usbHandlers)})
+
+	instances := map[string]instance.Instance{}
+	for i := range events {
+		usbRunHandlers(s, &events[i], instances)
+	}
+}
+
+// usbCoalesceRefresh records the USB devices present on the host as reconciled, such as after the devices have
+// been reconciled when resuming from being quiesced. The caller must hold usbMutex.
+func usbCoalesceRefresh() {
+	if usbCoalesce.delay <= 0 {
+		return
+	}
+
+	devices, err := usbHostDevices()
+	if err != nil {
+		logger.Warn("Failed scanning host USB devices after reconciling", logger.Ctx{"err": err})
+		return
+	}
+
+	usbCoalesce.devices = devices
+}
@@ -44,6 +44,10 @@ func usbRegisterHandler(inst instance.Instance, deviceName string, handler func(
 	// Null delimited string of project name, instance name and device name.
 	key := fmt.Sprintf("%s\000%s\000%s", inst.Project().Name, inst.Name(), deviceName)
 	usbHandlers[key] = handler
+
+	deviceRuntimesMu.Lock()
+	deviceRuntimeGet(inst, deviceName).hotplugRegistered = true
+	deviceRuntimesMu.Unlock()
 }
 
 // usbUnregisterHandler removes a registered USB handler function for a device.
@@ -54,45 +58,145 @@ func usbUnregisterHandler(inst instance.Instance, deviceName string) {
 	// Null delimited string of project name, instance name and device name.
 	key := fmt.Sprintf("%s\000%s\000%s", inst.Project().Name, inst.Name(), deviceName)
 	delete(usbHandlers, key)
+	usbWindowClear(key)
+
+	deviceRuntimesMu.Lock()
+	runtime, ok := deviceRuntimes[key]
+	if ok {
+		runtime.hotplugRegistered = false
+		runtime.hotplugErrors = 0
+		runtime.hotplugLastError = ""
+	}
+
+	deviceRuntimesMu.Unlock()
+}
+
+// usbRecordHandlerResult records the result of an operation of the handler registered with the supplied key, so
+// that persistently failing handlers can be monitored (see HotplugErrors). A successful operation resets the
+// error state.
+func usbRecordHandlerResult(key string, err error) {
+	deviceRuntimesMu.Lock()
+	defer deviceRuntimesMu.Unlock()
+
+	runtime, ok := deviceRuntimes[key]
+	if !ok {
+		return
+	}
+
+	if err == nil {
+		runtime.hotplugErrors = 0
+		runtime.hotplugLastError = ""
+		return
+	}
+
+	runtime.hotplugErrors++
+	runtime.hotplugLastError = err.Error()
+}
+
+// HotplugErrors returns the number of consecutive failures of the hotplug handler registered for the device along
+// with the most recent error, and whether a handler is registered. The error state is reset once the handler
+// succeeds.
+func HotplugErrors(inst instance.Instance, deviceName string) (int64, string, bool) {
+	deviceRuntimesMu.Lock()
+	defer deviceRuntimesMu.Unlock()
+
+	runtime, ok := deviceRuntimes[deviceRuntimeKey(inst.Project().Name, inst.Name(), deviceName)]
+	if !ok || !runtime.hotplugRegistered {
+		return 0, "", false
+	}
+
+	return runtime.hotplugErrors, runtime.hotplugLastError, true
 }
 
 // USBRunHandlers executes any handlers registered for USB events.
+// Whilst USB events are quiesced, the event is instead dropped or buffered (see USBQuiesce).
+// Whilst USB events are coalesced, the event instead schedules a reconciliation (see USBCoalesce).
 func USBRunHandlers(state *state.State, event *USBEvent) {
 	usbMutex.Lock()
 	defer usbMutex.Unlock()
 
-	for key, hook := range usbHandlers {
-		keyParts := strings.SplitN(key, "\000", 3)
-		projectName := keyParts[0]
-		instanceName := keyParts[1]
-		deviceName := keyParts[2]
+	if usbQuiesce.enabled {
+		usbQuiesceEvent(*event)
+		return
+	}
+
+	if usbCoalesce.delay > 0 {
+		usbCoalesceEvent(state)
+		return
+	}
 
+	usbRunHandlers(state, event, nil)
+}
+
+// usbRunHandlers executes any handlers registered for USB events.
+// If instances is not nil, it is used to cache the instances loaded by the handlers across calls.
+// The caller must hold usbMutex.
+func usbRunHandlers(state *state.State, event *USBEvent, instances map[string]instance.Instance) {
+	for key, hook := range usbHandlers {
 		if hook == nil {
 			delete(usbHandlers, key)
 			continue
 		}
 
-		runConf, err := hook(*event)
-		if err != nil {
-			logger.Error("USB event hook failed", logger.Ctx{"err": err, "project": projectName, "instance": instanceName, "device": deviceName})
-			continue
-		}
+		usbRunHandler(state, key, hook, event, instances)
+	}
+}
+
+// usbRunHandler executes the handler registered with the supplied key for a USB event.
+// If instances is not nil, it is used to cache the loaded instance. The caller must hold usbMutex.
+func usbRunHandler(state *state.State, key string, hook func(USBEvent) (*deviceConfig.RunConfig, error), event *USBEvent, instances map[string]instance.Instance) {
+	keyParts := strings.SplitN(key, "\000", 3)
+	projectName := keyParts[0]
+	instanceName := keyParts[1]
+	deviceName := keyParts[2]
+
+	// Don't process events for devices that are stopping or stopped.
+	if !lifecycleHandlesEvents(key) {
+		return
+	}
 
-		// If runConf supplied, load instance and call its USB event handler function so
-		// any instance specific device actions can occur.
-		if runConf != nil {
-			instance, err := instance.LoadByProjectAndName(state, projectName, instanceName)
+	runConf, err := hook(*event)
+	if err != nil {
+		logger.Error("USB event hook failed", logger.Ctx{"err": err, "project": projectName, "instance": instanceName, "device": deviceName})
+		usbRecordHandlerResult(key, err)
+		return
+	}
+
+	// If runConf supplied, load instance and call its USB event handler function so
+	// any instance specific device actions can occur.
+	if runConf != nil {
+		instanceKey := fmt.Sprintf("%s\000%s", projectName, instanceName)
+		inst, ok := instances[instanceKey]
+		if !ok {
+			inst, err = instance.LoadByProjectAndName(state, projectName, instanceName)
 			if err != nil {
 				logger.Error("USB event loading instance failed", logger.Ctx{"err": err, "project": projectName, "instance": instanceName, "device": deviceName})
-				continue
+				usbRecordHandlerResult(key, err)
+				return
 			}
 
-			err = instance.DeviceEventHandler(runConf)
-			if err != nil {
-				logger.Error("USB event instance handler failed", logger.Ctx{"err": err, "project": projectName, "instance": instanceName, "device": deviceName})
-				continue
+			if instances != nil {
+				instances[instanceKey] = inst
 			}
 		}
+
+		err = inst.DeviceEventHandler(runConf)
+		if err != nil {
+			logger.Error("USB event instance handler failed", logger.Ctx{"err": err, "project": projectName, "instance": instanceName, "device": deviceName})
+			usbRecordHandlerResult(key, err)
+			return
+		}
+
+		// Only events that the device acted on reset the error state, unlike those of other USB devices.
+		usbRecordHandlerResult(key, nil)
+
+		sendInstanceEvent(state, inst, deviceName, hotplugEventAction(event.Action), "", map[string]string{
+			"vendorid":  event.Vendor,
+			"productid": event.Product,
+			"busnum":    fmt.Sprintf("%03d", event.BusNum),
+			"devnum":    fmt.Sprintf("%03d", event.DevNum),
+			"path":      event.Path,
+		})
 	}
 }
 
@@ -0,0 +1,56 @@
+package device
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	deviceConfig "github.com/lxc/lxd/lxd/device/config"
+)
+
+func TestUSBHotplugErrors(t *testing.T) {
+	inst := &lifecycleTestInstance{name: "c1"}
+	key := deviceRuntimeKey("default", "c1", "usb0")
+	defer ForgetRuntime("default", "c1", "usb0")
+
+	// Check devices without a registered handler have no error state.
+	_, _, registered := HotplugErrors(inst, "usb0")
+	assert.False(t, registered)
+
+	failures := 0
+	usbRegisterHandler(inst, "usb0", func(e USBEvent) (*deviceConfig.RunConfig, error) {
+		failures++
+		return nil, fmt.Errorf("Failure %d", failures)
+	})
+
+	count, lastErr, registered := HotplugErrors(inst, "usb0")
+	assert.True(t, registered)
+	assert.Equal(t, int64(0), count)
+	assert.Empty(t, lastErr)
+
+	// Check the handler failures are counted along with the most recent error.
+	usbMutex.Lock()
+	usbRunHandler(nil, key, usbHandlers[key], &USBEvent{Action: "add"}, nil)
+	usbRunHandler(nil, key, usbHandlers[key], &USBEvent{Action: "add"}, nil)
+	usbMutex.Unlock()
+
+	count, lastErr, _ = HotplugErrors(inst, "usb0")
+	assert.Equal(t, int64(2), count)
+	assert.Equal(t, "Failure 2", lastErr)
+
+	// Check a successful operation resets the error state.
+	usbRecordHandlerResult(key, nil)
+
+	count, lastErr, _ = HotplugErrors(inst, "usb0")
+	assert.Equal(t, int64(0), count)
+	assert.Empty(t, lastErr)
+
+	// Check unregistering the handler discards the error state.
+	usbRecordHandlerResult(key, fmt.Errorf("Failure"))
+	usbUnregisterHandler(inst, "usb0")
+
+	count, _, registered = HotplugErrors(inst, "usb0")
+	assert.False(t, registered)
+	assert.Equal(t, int64(0), count)
+}
@@ -0,0 +1,83 @@
+package device
+
+import (
+	"sync"
+	"time"
+)
+
+// usbGrace represents the grace period of a required USB device that can be attached after the instance started.
+type usbGrace struct {
+	deadline time.Time
+	timer    *time.Timer
+}
+
+// usbGraces stores the running grace periods keyed on the same key as usbHandlers.
+var usbGraces = map[string]*usbGrace{}
+
+// usbGracesMu controls access to the usbGraces map.
+var usbGracesMu sync.Mutex
+
+// usbGraceStart starts the grace period of the device, replacing any existing one. The expire function is called
+// once the grace period elapses unless it is ended with usbGraceEnd first.
+func usbGraceStart(key string, grace time.Duration, expire func()) {
+	usbGracesMu.Lock()
+	defer usbGracesMu.Unlock()
+
+	existing, ok := usbGraces[key]
+	if ok {
+		existing.timer.Stop()
+	}
+
+	g := &usbGrace{deadline: time.Now().Add(grace)}
+	g.timer = time.AfterFunc(grace, func() {
+		usbGracesMu.Lock()
+		current := usbGraces[key] == g
+		if current {
+			delete(usbGraces, key)
+		}
+
+		usbGracesMu.Unlock()
+
+		// Don't hold the lock when expiring, as stopping the instance ends the grace period too.
+		if current {
+			expire()
+		}
+	})
+
+	usbGraces[key] = g
+}
+
+// usbGraceEnd ends the grace period of the device (if any) without it expiring, and indicates whether there was one.
+func usbGraceEnd(key string) bool {
+	usbGracesMu.Lock()
+	defer usbGracesMu.Unlock()
+
+	g, ok := usbGraces[key]
+	if !ok {
+		return false
+	}
+
+	g.timer.Stop()
+	delete(usbGraces, key)
+
+	return true
+}
+
+// usbGraceRemaining returns how long is left of the grace period of the device at the supplied time, and whether
+// its grace period is running.
+func usbGraceRemaining(key string, now time.Time) (time.Duration, bool) {
+	usbGracesMu.Lock()
+	defer usbGracesMu.Unlock()
+
+	g, ok := usbGraces[key]
+	if !ok {
+		return 0, false
+	}
+
+	remaining := g.deadline.Sub(now)
+	if remaining < 0 {
+		remaining = 0
+	}
+
+	return remaining, true
+}
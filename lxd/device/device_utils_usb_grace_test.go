@@ -0,0 +1,56 @@
+package device
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUSBGrace(t *testing.T) {
+	key := "project\000c1\000usb0"
+	expired := make(chan struct{}, 1)
+	expire := func() { expired <- struct{}{} }
+
+	// Check the remaining time is reported whilst the grace period runs and that ending it stops it expiring.
+	usbGraceStart(key, time.Hour, expire)
+
+	remaining, ok := usbGraceRemaining(key, time.Now())
+	assert.True(t, ok)
+	assert.True(t, remaining > 59*time.Minute && remaining <= time.Hour, remaining)
+
+	remaining, ok = usbGraceRemaining(key, time.Now().Add(2*time.Hour))
+	assert.True(t, ok)
+	assert.Equal(t, time.Duration(0), remaining)
+
+	assert.True(t, usbGraceEnd(key))
+	assert.False(t, usbGraceEnd(key))
+
+	_, ok = usbGraceRemaining(key, time.Now())
+	assert.False(t, ok)
+
+	// Check a grace period that isn't ended expires once.
+	usbGraceStart(key, 10*time.Millisecond, expire)
+
+	select {
+	case <-expired:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Grace period didn't expire")
+	}
+
+	_, ok = usbGraceRemaining(key, time.Now())
+	assert.False(t, ok)
+	assert.False(t, usbGraceEnd(key))
+
+	// Check a replaced grace period doesn't expire.
+	usbGraceStart(key, 10*time.Millisecond, expire)
+	usbGraceStart(key, time.Hour, expire)
+
+	select {
+	case <-expired:
+		t.Fatal("Replaced grace period expired")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	assert.True(t, usbGraceEnd(key))
+}
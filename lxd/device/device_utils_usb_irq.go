@@ -0,0 +1,166 @@
+package device
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/lxc/lxd/lxd/resources"
+	"github.com/lxc/lxd/shared"
+)
+
+// usbIRQAffinity records the original and the applied affinity of a USB controller interrupt.
+type usbIRQAffinity struct {
+	original string
+	applied  string
+}
+
+// usbIRQAffinityCPUs returns the list of CPUs to set the USB controller interrupt affinity to. This is the CPU set
+// the instance is pinned to ("limits.cpu"), or the supplied subset of it.
+func usbIRQAffinityCPUs(instConfig map[string]string, cpus string) (string, error) {
+	limit := instConfig["limits.cpu"]
+	_, err := strconv.Atoi(limit)
+	if limit == "" || err == nil {
+		return "", fmt.Errorf(`The instance isn't pinned to specific CPUs ("limits.cpu" must be a CPU set)`)
+	}
+
+	if cpus == "" {
+		return limit, nil
+	}
+
+	pinned, err := resources.ParseCpuset(limit)
+	if err != nil {
+		return "", err
+	}
+
+	requested, err := resources.ParseCpuset(cpus)
+	if err != nil {
+		return "", err
+	}
+
+	for _, cpu := range requested {
+		if !shared.Int64InSlice(cpu, pinned) {
+			return "", fmt.Errorf("CPU %d isn't in the instance's CPU set %q", cpu, limit)
+		}
+	}
+
+	return cpus, nil
+}
+
+// usbControllerIRQs returns the interrupts of the host controller that the USB device at the supplied sysfs
+// path is connected to. This walks up from the USB device to the first parent device with interrupts, which is
+// the controller (such as its PCI device).
+func usbControllerIRQs(devPath string) ([]int, error) {
+	realPath, err := filepath.EvalSymlinks(devPath)
+	if err != nil {
+		return nil, err
+	}
+
+	for dir := filepath.Dir(realPath); dir != "/sys/devices" && dir != "/"; dir = filepath.Dir(dir) {
+		// Controllers using MSI or MSI-X have an interrupt for each vector.
+		entries, err := os.ReadDir(filepath.Join(dir, "msi_irqs"))
+		if err == nil && len(entries) > 0 {
+			irqs := make([]int, 0, len(entries))
+			for _, entry := range entries {
+				irq, err := strconv.Atoi(entry.Name())
+				if err == nil {
+					irqs = append(irqs, irq)
+				}
+			}
+
+			return irqs, nil
+		}
+
+		content, err := os.ReadFile(filepath.Join(dir, "irq"))
+		if err == nil {
+			irq, err := strconv.Atoi(strings.TrimSpace(string(content)))
+			if err == nil && irq > 0 {
+				return []int{irq}, nil
+			}
+		}
+	}
+
+	return nil, fmt.Errorf("Couldn't find the interrupts of the USB controller of %q", devPath)
+}
+
+// usbSetIRQAffinity sets the affinity of the interrupt to the supplied CPU list and returns the original and
+// the resulting affinity (which the kernel may have normalised).
+func usbSetIRQAffinity(irq int, cpus string) (usbIRQAffinity, error) {
+	affinityPath := fmt.Sprintf("/proc/irq/%d/smp_affinity_list", irq)
+
+	original, err := os.ReadFile(affinityPath)
+	if err != nil {
+		return usbIRQAffinity{}, err
+	}
+
+	err = os.WriteFile(affinityPath, []byte(cpus), 0)
+	if err != nil {
+		return usbIRQAffinity{}, err
+	}
+
+	applied, err := os.ReadFile(affinityPath)
+	if err != nil {
+		return usbIRQAffinity{}, err
+	}
+
+	return usbIRQAffinity{original: strings.TrimSpace(string(original)), applied: strings.TrimSpace(string(applied))}, nil
+}
+
+// usbRestoreIRQAffinity restores the original affinity of the interrupt, unless it has been changed since it
+// was applied (such as by the administrator).
+func usbRestoreIRQAffinity(irq int, affinity usbIRQAffinity) error {
+	affinityPath := fmt.Sprintf("/proc/irq/%d/smp_affinity_list", irq)
+
+	current, err := os.ReadFile(affinityPath)
+	if err != nil {
+		return err
+	}
+
+	if strings.TrimSpace(string(current)) != affinity.applied {
+		return nil
+	}
+
+	return os.WriteFile(affinityPath, []byte(affinity.original), 0)
+}
+
+// usbParseIRQAffinities parses the "<irq>=<original>/<applied>;..." format used to record the affinities.
+func usbParseIRQAffinities(value string) map[int]usbIRQAffinity {
+	affinities := map[int]usbIRQAffinity{}
+
+	for _, entry := range strings.Split(value, ";") {
+		irqStr, cpus, found := strings.Cut(entry, "=")
+		if !found {
+			continue
+		}
+
+		irq, err := strconv.Atoi(irqStr)
+		if err != nil {
+			continue
+		}
+
+		original, applied, _ := strings.Cut(cpus, "/")
+		affinities[irq] = usbIRQAffinity{original: original, applied: applied}
+	}
+
+	return affinities
+}
+
+// usbFormatIRQAffinities returns the affinities in the format parsed by usbParseIRQAffinities.
+func usbFormatIRQAffinities(affinities map[int]usbIRQAffinity) string {
+	irqs := make([]int, 0, len(affinities))
+	for irq := range affinities {
+		irqs = append(irqs, irq)
+	}
+
+	sort.Ints(irqs)
+
+	entries := make([]string, 0, len(irqs))
+	for _, irq := range irqs {
+		entries = append(entries, fmt.Sprintf("%d=%s/%s", irq, affinities[irq].original, affinities[irq].applied))
+	}
+
+	return strings.Join(entries, ";")
+}
@@ -0,0 +1,76 @@
+package device
+
+import (
+	"sync"
+
+	"github.com/lxc/lxd/shared"
+)
+
+// usbModule records the use of a host kernel module by the running USB devices that list it in "modules".
+type usbModule struct {
+	users  map[string]struct{} // Keyed on the same key as usbHandlers.
+	loaded bool                // Whether LXD loaded the module (rather than it already being loaded).
+}
+
+// usbModules stores the host kernel modules used by the running USB devices keyed on the module name, so that
+// a module is only unloaded once no device uses it.
+var usbModules = map[string]*usbModule{}
+
+// usbModulesMu controls access to the usbModules map. It is held whilst the modules are loaded and unloaded so
+// that a module can't be unloaded whilst another device starts using it.
+var usbModulesMu sync.Mutex
+
+// usbModulesUse records the device with the supplied key as a user of the modules, marking those also in loaded as
+// loaded by LXD. The caller must hold usbModulesMu.
+func usbModulesUse(key string, modules []string, loaded []string) {
+	for _, name := range modules {
+		module, ok := usbModules[name]
+		if !ok {
+			module = &usbModule{users: map[string]struct{}{}}
+			usbModules[name] = module
+		}
+
+		module.users[key] = struct{}{}
+
+		if shared.StringInSlice(name, loaded) {
+			module.loaded = true
+		}
+	}
+}
+
+// usbModulesRelease removes the device with the supplied key as a user of the modules and returns those that LXD
+// loaded and that no other device uses, in reverse order so that dependent modules are unloaded first. The
+// returned modules stay recorded as loaded by LXD until usbModulesUnloaded is called, whereas unused modules
+// that LXD didn't load are forgotten. The caller must hold usbModulesMu.
+func usbModulesRelease(key string, modules []string) []string {
+	unused := []string{}
+	for i := len(modules) - 1; i >= 0; i-- {
+		module, ok := usbModules[modules[i]]
+		if !ok {
+			continue
+		}
+
+		delete(module.users, key)
+		if len(module.users) > 0 {
+			continue
+		}
+
+		if !module.loaded {
+			delete(usbModules, modules[i])
+			continue
+		}
+
+		unused = append(unused, modules[i])
+	}
+
+	return unused
+}
+
+// usbModulesUnloaded forgets the module once it has been unloaded, unless a device has started using it again.
+// The caller must hold usbModulesMu.
+func usbModulesUnloaded(name string) {
+	module, ok := usbModules[name]
+	if ok && len(module.users) == 0 {
+		delete(usbModules, name)
+	}
+}
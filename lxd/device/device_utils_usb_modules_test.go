@@ -0,0 +1,46 @@
+package device
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUSBModules(t *testing.T) {
+	c1 := "project\000c1\000usb0"
+	c2 := "project\000c2\000usb0"
+
+	// Restore the modules that LXD tracks once the test is done, as they are shared by all the devices.
+	oldUSBModules := usbModules
+	usbModules = map[string]*usbModule{}
+	t.Cleanup(func() { usbModules = oldUSBModules })
+
+	// Check a module loaded by LXD is only released once the last device using it stops.
+	usbModulesUse(c1, []string{"ftdi_sio", "usbserial"}, []string{"ftdi_sio"})
+	usbModulesUse(c2, []string{"ftdi_sio"}, nil)
+
+	assert.Equal(t, []string{}, usbModulesRelease(c1, []string{"ftdi_sio", "usbserial"}))
+	assert.NotContains(t, usbModules, "usbserial")
+	assert.Equal(t, []string{"ftdi_sio"}, usbModulesRelease(c2, []string{"ftdi_sio"}))
+
+	// Check a module that failed to unload is released again by the next device using it.
+	usbModulesUse(c1, []string{"ftdi_sio"}, nil)
+	assert.Equal(t, []string{"ftdi_sio"}, usbModulesRelease(c1, []string{"ftdi_sio"}))
+
+	usbModulesUnloaded("ftdi_sio")
+	assert.NotContains(t, usbModules, "ftdi_sio")
+
+	// Check a module that was already loaded is never released.
+	usbModulesUse(c1, []string{"cdc_acm"}, nil)
+	assert.Equal(t, []string{}, usbModulesRelease(c1, []string{"cdc_acm"}))
+
+	// Check the modules are released in the reverse order to how they are listed.
+	usbModulesUse(c1, []string{"usbserial", "ftdi_sio"}, []string{"usbserial", "ftdi_sio"})
+	assert.Equal(t, []string{"ftdi_sio", "usbserial"}, usbModulesRelease(c1, []string{"usbserial", "ftdi_sio"}))
+
+	// Check using the modules again is idempotent, such as when LXD starts.
+	usbModulesUse(c1, []string{"cdc_acm"}, nil)
+	usbModulesUse(c1, []string{"cdc_acm"}, nil)
+	usbModulesUse(c2, []string{"cdc_acm"}, nil)
+	assert.Len(t, usbModules["cdc_acm"].users, 2)
+}
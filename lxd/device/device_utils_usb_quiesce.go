@@ -0,0 +1,187 @@
+package device
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/lxc/lxd/lxd/instance"
+	"github.com/lxc/lxd/lxd/state"
+	"github.com/lxc/lxd/shared/logger"
+)
+
+// USBQuiescePolicyDrop drops USB events whilst quiesced and reconciles the devices with the host on resume.
+const USBQuiescePolicyDrop = "drop"
+
+// USBQuiescePolicyBuffer buffers USB events whilst quiesced and replays them on resume.
+const USBQuiescePolicyBuffer = "buffer"
+
+// usbQuiesceBufferMax is the maximum number of events buffered whilst quiesced. If more events occur then
+// the buffered events are discarded and the devices are reconciled with the host on resume instead.
+const usbQuiesceBufferMax = 1024
+
+// usbQuiesce stores the USB event quiescing state. Access is controlled by usbMutex.
+var usbQuiesce struct {
+	enabled  bool
+	policy   string
+	buffer   []USBEvent
+	overflow bool
+	devices  map[string]USBEvent // Host USB devices present when quiesced, keyed on bus and device number.
+}
+
+// usbHostDevices returns the USB devices present on the host keyed on their bus and device number.
+// The events include the uevent that the kernel would send when adding the device.
+func usbHostDevices() (map[string]USBEvent, error) {
+	usbs, err := usbLoadPath(usbDevPath)
+	if err != nil {
+		return nil, err
+	}
+
+	devices := make(map[string]USBEvent, len(usbs))
+	for _, usb := range usbs {
+		devPath, err := usbSysfsPath([]string{usbDevPath}, usb.BusNum, usb.DevNum)
+		if err != nil {
+			continue // Device has gone away since scanning.
+		}
+
+		realPath, err := filepath.EvalSymlinks(devPath)
+		if err != nil {
+			continue
+		}
+
+		usb.UeventParts = []string{
+			fmt.Sprintf("DEVPATH=%s", strings.TrimPrefix(realPath, "/sys")),
+			"SUBSYSTEM=usb",
+			"DEVTYPE=usb_device",
+			fmt.Sprintf("DEVNAME=%s", strings.TrimPrefix(usb.Path, "/dev/")),
+			fmt.Sprintf("MAJOR=%d", usb.Major),
+			fmt.Sprintf("MINOR=%d", usb.Minor),
+			fmt.Sprintf("BUSNUM=%03d", usb.BusNum),
+			fmt.Sprintf("DEVNUM=%03d", usb.DevNum),
+		}
+
+		devices[fmt.Sprintf("%03d:%03d", usb.BusNum, usb.DevNum)] = usb
+	}
+
+	return devices, nil
+}
+
+// usbSyntheticEvent returns a copy of the USB device event with the supplied action and a matching uevent.
+func usbSyntheticEvent(action string, usb USBEvent) USBEvent {
+	devPath := strings.TrimPrefix(usb.UeventParts[0], "DEVPATH=")
+
+	usb.Action = action
+	usb.UeventParts = append([]string{fmt.Sprintf("%s@%s", action, devPath), fmt.Sprintf("ACTION=%s", action)}, usb.UeventParts...)
+	usb.UeventLen = len(strings.Join(usb.UeventParts, "\000"))
+
+	return usb
+}
+
+// usbReconcileEvents returns the events needed to go from the old to the new set of host USB devices.
+// Devices that have been removed (or replaced by a different device) are removed before adding new devices.
+func usbReconcileEvents(oldDevices map[string]USBEvent, newDevices map[string]USBEvent) []USBEvent {
+	removed := []USBEvent{}
+	added := []USBEvent{}
+
+	for key, oldUSB := range oldDevices {
+		newUSB, found := newDevices[key]
+		if !found || newUSB.Vendor != oldUSB.Vendor || newUSB.Product != oldUSB.Product {
+			removed = append(removed, usbSyntheticEvent("remove", oldUSB))
+		}
+	}
+
+	for key, newUSB := range newDevices {
+		oldUSB, found := oldDevices[key]
+		if !found || newUSB.Vendor != oldUSB.Vendor || newUSB.Product != oldUSB.Product {
+			added = append(added, usbSyntheticEvent("add", newUSB))
+		}
+	}
+
+	return append(removed, added...)
+}
+
+// USBQuiesce pauses the processing of USB events by the device handlers until USBResume is called, such as
+// during host maintenance. Events are either dropped or buffered depending on the policy.
+// The USB devices present on the host are recorded so that they can be reconciled on resume.
+func USBQuiesce(s *state.State, policy string) error {
+	usbMutex.Lock()
+	defer usbMutex.Unlock()
+
+	if usbQuiesce.enabled {
+		usbQuiesce.policy = policy
+		return nil
+	}
+
+	// Apply any coalesced events first, as the devices present now are what is reconciled against on resume.
+	if usbCoalesce.timer != nil {
+		usbCoalesce.timer.Stop()
+		usbCoalesce.timer = nil
+		usbCoalesceReconcile(s)
+	}
+
+	devices, err := usbHostDevices()
+	if err != nil {
+		return fmt.Errorf("Failed scanning host USB devices: %w", err)
+	}
+
+	usbQuiesce.enabled = true
+	usbQuiesce.policy = policy
+	usbQuiesce.devices = devices
+	logger.Info("Quiesced USB hotplug", logger.Ctx{"policy": policy})
+
+	return nil
+}
+
+// USBResume resumes the processing of USB events. With the buffer policy, the buffered events are replayed in
+// order. Otherwise (or if too many events occurred to be buffered) the devices are reconciled by comparing the
+// USB devices present with those present when quiesced, and running the handlers for each removed and added
+// device. New events are held until this has completed so that they are processed in order.
+func USBResume(s *state.State) {
+	usbMutex.Lock()
+	defer usbMutex.Unlock()
+
+	if !usbQuiesce.enabled {
+		return
+	}
+
+	events := usbQuiesce.buffer
+	if usbQuiesce.policy != USBQuiescePolicyBuffer || usbQuiesce.overflow {
+		devices, err := usbHostDevices()
+		if err != nil {
+			logger.Error("Failed scanning host USB devices to reconcile", logger.Ctx{"err": err})
+		} else {
+			events = usbReconcileEvents(usbQuiesce.devices, devices)
+		}
+	}
+
+	logger.Info("Resuming USB hotplug", logger.Ctx{"policy": usbQuiesce.policy, "events": len(events)})
+
+	instances := map[string]instance.Instance{}
+	for i := range events {
+		usbRunHandlers(s, &events[i], instances)
+	}
+
+	usbQuiesce.enabled = false
+	usbCoalesceRefresh()
+	usbQuiesce.policy = ""
+	usbQuiesce.buffer = nil
+	usbQuiesce.overflow = false
+	usbQuiesce.devices = nil
+}
+
+// usbQuiesceEvent drops or buffers the event according to the quiesce policy.
+// The caller must hold usbMutex.
+func usbQuiesceEvent(event USBEvent) {
+	if usbQuiesce.policy != USBQuiescePolicyBuffer || usbQuiesce.overflow {
+		return
+	}
+
+	if len(usbQuiesce.buffer) >= usbQuiesceBufferMax {
+		logger.Warn("Too many USB events whilst quiesced, will reconcile on resume instead", logger.Ctx{"max": usbQuiesceBufferMax})
+		usbQuiesce.buffer = nil
+		usbQuiesce.overflow = true
+		return
+	}
+
+	usbQuiesce.buffer = append(usbQuiesce.buffer, event)
+}
@@ -0,0 +1,49 @@
+package device
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUSBReconcileEvents(t *testing.T) {
+	newUSB := func(vendor string, product string, busNum int, devNum int) USBEvent {
+		return USBEvent{Action: "add", Vendor: vendor, Product: product, BusNum: busNum, DevNum: devNum, UeventParts: []string{"DEVPATH=/devices/usb1/1-1"}}
+	}
+
+	oldDevices := map[string]USBEvent{
+		"001:002": newUSB("046d", "c52b", 1, 2), // Unchanged.
+		"001:003": newUSB("1050", "0407", 1, 3), // Removed.
+		"001:004": newUSB("0781", "5581", 1, 4), // Replaced by another device.
+	}
+
+	newDevices := map[string]USBEvent{
+		"001:002": newUSB("046d", "c52b", 1, 2),
+		"001:004": newUSB("0bda", "8153", 1, 4),
+		"001:005": newUSB("1050", "0407", 1, 5), // Removed device has come back with a new device number.
+	}
+
+	events := usbReconcileEvents(oldDevices, newDevices)
+	assert.Len(t, events, 4)
+
+	// Check removals come first.
+	removed := map[string]bool{}
+	for _, e := range events[:2] {
+		assert.Equal(t, "remove", e.Action)
+		assert.Equal(t, []string{"remove@/devices/usb1/1-1", "ACTION=remove", "DEVPATH=/devices/usb1/1-1"}, e.UeventParts)
+		removed[e.Vendor] = true
+	}
+
+	assert.Equal(t, map[string]bool{"1050": true, "0781": true}, removed)
+
+	added := map[string]bool{}
+	for _, e := range events[2:] {
+		assert.Equal(t, "add", e.Action)
+		added[e.Vendor] = true
+	}
+
+	assert.Equal(t, map[string]bool{"0bda": true, "1050": true}, added)
+
+	// Check nothing happens if the devices haven't changed.
+	assert.Empty(t, usbReconcileEvents(newDevices, newDevices))
+}
@@ -0,0 +1,249 @@
+package device
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/lxc/lxd/lxd/util"
+	"github.com/lxc/lxd/shared"
+	"github.com/lxc/lxd/shared/logger"
+)
+
+// usbmonDevPathFormat is the format of the path of the binary usbmon device node of each USB bus.
+const usbmonDevPathFormat = "/dev/usbmon%d"
+
+// usbmonHeaderSize is the size of the event header preceding the data in each read of a usbmon device node.
+const usbmonHeaderSize = 48
+
+// usbmonSnapLen is the maximum size of the data captured for each event.
+const usbmonSnapLen = 65536
+
+// usbmonLinkType is the pcap link type of captures made of the usbmon events with the 48 byte header.
+const usbmonLinkType = 189
+
+// usbmonCapture represents a capture of the USB traffic of the buses of a device to a pcap file.
+// The events and the capture file are in little endian byte order, as USB devices aren't supported on the big
+// endian architectures.
+type usbmonCapture struct {
+	mu      sync.Mutex
+	path    string
+	file    *os.File
+	writer  *bufio.Writer
+	readers map[int]*os.File
+	written int64
+	limit   int64
+	timer   *time.Timer
+	stopped bool
+	wg      sync.WaitGroup
+}
+
+// usbmonCaptures stores the active captures keyed on the same key as deviceRuntimes.
+var usbmonCaptures = map[string]*usbmonCapture{}
+
+// usbmonCapturesMu controls access to the usbmonCaptures map.
+var usbmonCapturesMu sync.Mutex
+
+// usbmonAvailable checks that the usbmon device nodes are available on the host, loading the module if needed.
+func usbmonAvailable() error {
+	if shared.PathExists(fmt.Sprintf(usbmonDevPathFormat, 0)) {
+		return nil
+	}
+
+	err := util.LoadModule("usbmon")
+	if err != nil || !shared.PathExists(fmt.Sprintf(usbmonDevPathFormat, 0)) {
+		return fmt.Errorf("USB monitoring (usbmon) isn't available on the host")
+	}
+
+	return nil
+}
+
+// usbmonStart starts capturing the traffic of the USB buses to the pcap file at the path, replacing any existing
+// capture for the device. The capture stops once the file reaches the size limit (if not zero) or the duration has
+// elapsed (if not zero), or when stopped with usbmonStop.
+func usbmonStart(key string, buses []int, path string, limit int64, duration time.Duration) error {
+	usbmonStop(key)
+
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0600)
+	if err != nil {
+		return fmt.Errorf("Failed creating USB capture file %q: %w", path, err)
+	}
+
+	c := &usbmonCapture{
+		path:    path,
+		file:    f,
+		writer:  bufio.NewWriter(f),
+		readers: map[int]*os.File{},
+		limit:   limit,
+	}
+
+	// Write the pcap file header.
+	header := make([]byte, 24)
+	binary.LittleEndian.PutUint32(header[0:4], 0xa1b2c3d4)
+	binary.LittleEndian.PutUint16(header[4:6], 2)
+	binary.LittleEndian.PutUint16(header[6:8], 4)
+	binary.LittleEndian.PutUint32(header[16:20], usbmonHeaderSize+usbmonSnapLen)
+	binary.LittleEndian.PutUint32(header[20:24], usbmonLinkType)
+
+	c.mu.Lock()
+	c.write(header)
+	c.mu.Unlock()
+
+	for _, bus := range buses {
+		err := c.addBus(bus)
+		if err != nil {
+			c.stop()
+			return err
+		}
+	}
+
+	if duration > 0 {
+		c.mu.Lock()
+		c.timer = time.AfterFunc(duration, c.stop)
+		c.mu.Unlock()
+	}
+
+	usbmonCapturesMu.Lock()
+	usbmonCaptures[key] = c
+	usbmonCapturesMu.Unlock()
+
+	return nil
+}
+
+// usbmonAddBus adds the USB bus to the active capture of the device (if any), such as when a device is hotplugged
+// on another bus.
+func usbmonAddBus(key string, bus int) {
+	usbmonCapturesMu.Lock()
+	c := usbmonCaptures[key]
+	usbmonCapturesMu.Unlock()
+
+	if c == nil {
+		return
+	}
+
+	err := c.addBus(bus)
+	if err != nil {
+		logger.Warn("Failed adding USB bus to capture", logger.Ctx{"path": c.path, "bus": bus, "err": err})
+	}
+}
+
+// usbmonStop stops the active capture of the device (if any), flushing it to the capture file.
+func usbmonStop(key string) {
+	usbmonCapturesMu.Lock()
+	c := usbmonCaptures[key]
+	delete(usbmonCaptures, key)
+	usbmonCapturesMu.Unlock()
+
+	if c != nil {
+		c.stop()
+	}
+}
+
+// addBus starts capturing the traffic of the USB bus, unless it is already captured.
+func (c *usbmonCapture) addBus(bus int) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	_, found := c.readers[bus]
+	if found || c.stopped {
+		return nil
+	}
+
+	r, err := os.Open(fmt.Sprintf(usbmonDevPathFormat, bus))
+	if err != nil {
+		return fmt.Errorf("Failed opening usbmon for USB bus %d: %w", bus, err)
+	}
+
+	c.readers[bus] = r
+	c.wg.Add(1)
+
+	go func() {
+		defer c.wg.Done()
+
+		// Each read returns the header and data of a single event.
+		buf := make([]byte, usbmonHeaderSize+usbmonSnapLen)
+		for {
+			n, err := r.Read(buf)
+			if err != nil {
+				return
+			}
+
+			if n < usbmonHeaderSize {
+				continue
+			}
+
+			// Write the pcap record header with the time of the event, followed by the event.
+			record := make([]byte, 16, 16+n)
+			binary.LittleEndian.PutUint32(record[0:4], uint32(binary.LittleEndian.Uint64(buf[16:24])))
+			binary.LittleEndian.PutUint32(record[4:8], binary.LittleEndian.Uint32(buf[24:28]))
+			binary.LittleEndian.PutUint32(record[8:12], uint32(n))
+			binary.LittleEndian.PutUint32(record[12:16], usbmonHeaderSize+binary.LittleEndian.Uint32(buf[32:36]))
+
+			c.mu.Lock()
+			full := c.write(append(record, buf[:n]...))
+			c.mu.Unlock()
+
+			if full {
+				go c.stop()
+				return
+			}
+		}
+	}()
+
+	return nil
+}
+
+// write writes the data to the capture file unless it has been stopped, returning true once the size limit has
+// been reached. The caller must hold c.mu.
+func (c *usbmonCapture) write(data []byte) bool {
+	if c.stopped {
+		return false
+	}
+
+	if c.limit > 0 && c.written+int64(len(data)) > c.limit {
+		return true
+	}
+
+	_, err := c.writer.Write(data)
+	if err != nil {
+		logger.Warn("Failed writing USB capture", logger.Ctx{"path": c.path, "err": err})
+		return true
+	}
+
+	c.written += int64(len(data))
+
+	return false
+}
+
+// stop stops the capture and flushes it to the capture file. Stopping an already stopped capture is a no-op.
+func (c *usbmonCapture) stop() {
+	c.mu.Lock()
+	if c.stopped {
+		c.mu.Unlock()
+		return
+	}
+
+	c.stopped = true
+
+	if c.timer != nil {
+		c.timer.Stop()
+	}
+
+	// Closing the usbmon device nodes ends the reads.
+	for _, r := range c.readers {
+		_ = r.Close()
+	}
+
+	c.mu.Unlock()
+	c.wg.Wait()
+
+	err := c.writer.Flush()
+	if err != nil {
+		logger.Warn("Failed flushing USB capture", logger.Ctx{"path": c.path, "err": err})
+	}
+
+	_ = c.file.Close()
+}
@@ -0,0 +1,40 @@
+package device
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUsbmonCapture(t *testing.T) {
+	capturePath := filepath.Join(t.TempDir(), "usbmon.pcap")
+	key := deviceRuntimeKey("default", "c1", "usb0")
+
+	// Check the capture starts with the pcap file header.
+	assert.NoError(t, usbmonStart(key, nil, capturePath, 64, 0))
+
+	c := usbmonCaptures[key]
+	assert.NotNil(t, c)
+
+	// Check the capture is limited to the size limit.
+	c.mu.Lock()
+	assert.False(t, c.write(make([]byte, 40)))
+	assert.True(t, c.write(make([]byte, 1)))
+	c.mu.Unlock()
+
+	// Check stopping flushes the capture, and stopping again is a no-op.
+	usbmonStop(key)
+	usbmonStop(key)
+
+	content, err := os.ReadFile(capturePath)
+	assert.NoError(t, err)
+	assert.Len(t, content, 64)
+	assert.Equal(t, []byte{0xd4, 0xc3, 0xb2, 0xa1}, content[0:4])
+	assert.Equal(t, []byte{189, 0, 0, 0}, content[20:24])
+
+	// Check nothing is written once stopped.
+	assert.False(t, c.write(make([]byte, 1)))
+	assert.Empty(t, usbmonCaptures)
+}
@@ -0,0 +1,184 @@
+package device
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/lxc/lxd/lxd/state"
+	"github.com/lxc/lxd/shared/logger"
+)
+
+// usbWindowRetryInterval is how long to wait before retrying to apply the deferred attachments if the window
+// opens whilst USB events are quiesced.
+const usbWindowRetryInterval = time.Minute
+
+// usbWindowQueue stores the USB device add events of a device deferred until its hotplug window opens.
+type usbWindowQueue struct {
+	events []USBEvent
+	timer  *time.Timer
+}
+
+// usbWindowPending stores the deferred USB device add events keyed on the same key as usbHandlers.
+// Access is controlled by usbMutex.
+var usbWindowPending = map[string]*usbWindowQueue{}
+
+// hotplugWindowParse parses a "HH:MM-HH:MM" hotplug window and returns its start and end as offsets from
+// midnight. Windows where the end is before the start span midnight.
+func hotplugWindowParse(value string) (time.Duration, time.Duration, error) {
+	startStr, endStr, found := strings.Cut(value, "-")
+	if !found {
+		return 0, 0, fmt.Errorf("Invalid hotplug window %q (must be HH:MM-HH:MM)", value)
+	}
+
+	offsets := make([]time.Duration, 0, 2)
+	for _, s := range []string{startStr, endStr} {
+		t, err := time.Parse("15:04", strings.TrimSpace(s))
+		if err != nil {
+			return 0, 0, fmt.Errorf("Invalid hotplug window time %q (must be HH:MM)", s)
+		}
+
+		offsets = append(offsets, time.Duration(t.Hour())*time.Hour+time.Duration(t.Minute())*time.Minute)
+	}
+
+	if offsets[0] == offsets[1] {
+		return 0, 0, fmt.Errorf("Hotplug window %q must not start and end at the same time", value)
+	}
+
+	return offsets[0], offsets[1], nil
+}
+
+// validateHotplugWindow validates a "HH:MM-HH:MM" hotplug window.
+func validateHotplugWindow(value string) error {
+	_, _, err := hotplugWindowParse(value)
+	return err
+}
+
+// hotplugWindowNextOpen returns zero if the hotplug window is open at the supplied time, otherwise it returns
+// how long it is until the window next opens. An empty window is always open.
+func hotplugWindowNextOpen(window string, now time.Time) time.Duration {
+	if window == "" {
+		return 0
+	}
+
+	start, end, err := hotplugWindowParse(window)
+	if err != nil {
+		return 0 // Validated by the device config.
+	}
+
+	midnight := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	offset := now.Sub(midnight)
+
+	if start < end && offset >= start && offset < end {
+		return 0
+	}
+
+	if start > end && (offset >= start || offset < end) {
+		return 0
+	}
+
+	// Use the wall clock to find the next opening so that daylight saving changes are handled.
+	opens := midnight.Add(start)
+	if !opens.After(now) {
+		opens = time.Date(now.Year(), now.Month(), now.Day()+1, 0, 0, 0, 0, now.Location()).Add(start)
+	}
+
+	return opens.Sub(now)
+}
+
+// usbWindowDefer queues the USB device add event until the device's hotplug window opens, when it is passed
+// to the device's handler. The caller must hold usbMutex.
+func usbWindowDefer(s *state.State, key string, e USBEvent, wait time.Duration) {
+	queue, ok := usbWindowPending[key]
+	if !ok {
+		queue = &usbWindowQueue{}
+		usbWindowPending[key] = queue
+	}
+
+	for _, pending := range queue.events {
+		if pending.Path == e.Path {
+			return
+		}
+	}
+
+	queue.events = append(queue.events, e)
+
+	if queue.timer == nil {
+		queue.timer = time.AfterFunc(wait, func() { usbWindowOpen(s, key) })
+	}
+}
+
+// usbWindowForget removes the deferred add event of the removed USB device (if any) and indicates whether there
+// was one. The caller must hold usbMutex.
+func usbWindowForget(key string, e USBEvent) bool {
+	queue, ok := usbWindowPending[key]
+	if !ok {
+		return false
+	}
+
+	for i, pending := range queue.events {
+		if pending.Path == e.Path {
+			queue.events = append(queue.events[:i], queue.events[i+1:]...)
+			return true
+		}
+	}
+
+	return false
+}
+
+// usbWindowClear discards the deferred add events of a device. The caller must hold usbMutex.
+func usbWindowClear(key string) {
+	queue, ok := usbWindowPending[key]
+	if !ok {
+		return
+	}
+
+	if queue.timer != nil {
+		queue.timer.Stop()
+	}
+
+	delete(usbWindowPending, key)
+}
+
+// usbWindowOpen passes the deferred add events of a device to its handler when its hotplug window opens.
+func usbWindowOpen(s *state.State, key string) {
+	usbMutex.Lock()
+	defer usbMutex.Unlock()
+
+	queue, ok := usbWindowPending[key]
+	if !ok {
+		return
+	}
+
+	// Wait for USB events to be resumed so that these events are processed in order with the others.
+	if usbQuiesce.enabled {
+		queue.timer = time.AfterFunc(usbWindowRetryInterval, func() { usbWindowOpen(s, key) })
+		return
+	}
+
+	delete(usbWindowPending, key)
+
+	hook := usbHandlers[key]
+	if hook == nil {
+		return
+	}
+
+	logger.Info("Hotplug window opened, applying deferred USB device attachments", logger.Ctx{"device": strings.ReplaceAll(key, "\000", "/"), "count": len(queue.events)})
+
+	for i := range queue.events {
+		usbRunHandler(s, key, hook, &queue.events[i], nil)
+	}
+}
+
+// usbWindowPendingEvents returns the deferred USB device add events of a device.
+func usbWindowPendingEvents(key string) []USBEvent {
+	usbMutex.Lock()
+	defer usbMutex.Unlock()
+
+	queue, ok := usbWindowPending[key]
+	if !ok {
+		return nil
+	}
+
+	return append([]USBEvent{}, queue.events...)
+}
@@ -0,0 +1,38 @@
+package device
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHotplugWindowNextOpen(t *testing.T) {
+	at := func(hour int, minute int) time.Time {
+		return time.Date(2022, time.March, 10, hour, minute, 0, 0, time.UTC)
+	}
+
+	tests := []struct {
+		window   string
+		now      time.Time
+		expected time.Duration
+	}{
+		{window: "", now: at(12, 0), expected: 0},
+		{window: "09:00-17:00", now: at(9, 0), expected: 0},
+		{window: "09:00-17:00", now: at(16, 59), expected: 0},
+		{window: "09:00-17:00", now: at(8, 30), expected: 30 * time.Minute},
+		{window: "09:00-17:00", now: at(17, 0), expected: 16 * time.Hour},
+		{window: "22:00-06:00", now: at(23, 0), expected: 0},
+		{window: "22:00-06:00", now: at(5, 0), expected: 0},
+		{window: "22:00-06:00", now: at(6, 0), expected: 16 * time.Hour},
+	}
+
+	for _, test := range tests {
+		assert.Equal(t, test.expected, hotplugWindowNextOpen(test.window, test.now), "%q at %s", test.window, test.now)
+	}
+
+	// Check invalid windows are rejected.
+	for _, window := range []string{"09:00", "9-17", "09:00-25:00", "09:00-09:00"} {
+		assert.Error(t, validateHotplugWindow(window), window)
+	}
+}
@@ -114,6 +114,11 @@ func (d *disk) isRequired(devConfig deviceConfig.Device) bool {
 	return false
 }
 
+// Required indicates whether the device must start for the instance to start.
+func (d *disk) Required() bool {
+	return d.isRequired(d.config)
+}
+
 // sourceIsLocalPath returns true if the source supplied should be considered a local path on the host.
 // It returns false if the disk source is empty, a VM cloud-init config drive, or a remote ceph/cephfs path.
 func (d *disk) sourceIsLocalPath(source string) bool {
@@ -132,10 +137,10 @@ func (d *disk) sourceIsLocalPath(source string) bool {
 	return true
 }
 
-// validateConfig checks the supplied config for correctness.
-func (d *disk) validateConfig(instConf instance.ConfigReader) error {
+// configRules returns the rules that the supplied config is validated with.
+func (d *disk) configRules(instConf instance.ConfigReader) (map[string]func(value string) error, error) {
 	if !instanceSupported(instConf.Type(), instancetype.Container, instancetype.VM) {
-		return ErrUnsupportedDevType
+		return nil, ErrUnsupportedDevType
 	}
 
 	// Supported propagation types.
@@ -156,6 +161,7 @@ func (d *disk) validateConfig(instConf instance.ConfigReader) error {
 		"readonly":          validate.Optional(validate.IsBool),
 		"recursive":         validate.Optional(validate.IsBool),
 		"shift":             validate.Optional(validate.IsBool),
+		"shift.idmapped":    validate.Optional(validate.IsBool),
 		"source":            validate.IsAny,
 		"limits.read":       validate.IsAny,
 		"limits.write":      validate.IsAny,
@@ -167,11 +173,22 @@ func (d *disk) validateConfig(instConf instance.ConfigReader) error {
 		"raw.mount.options": validate.IsAny,
 		"ceph.cluster_name": validate.IsAny,
 		"ceph.user_name":    validate.IsAny,
+		"ceph.secret":       validate.Optional(validSecretName),
 		"boot.priority":     validate.Optional(validate.IsUint32),
 		"path":              validate.IsAny,
 	}
 
-	err := d.config.Validate(rules)
+	return rules, nil
+}
+
+// validateConfig checks the supplied config for correctness.
+func (d *disk) validateConfig(instConf instance.ConfigReader) error {
+	rules, err := d.configRules(instConf)
+	if err != nil {
+		return err
+	}
+
+	err = d.config.Validate(rules)
 	if err != nil {
 		return err
 	}
@@ -209,8 +226,13 @@ func (d *disk) validateConfig(instConf instance.ConfigReader) error {
 	}
 
 	// Check ceph options are only used when ceph or cephfs type source is specified.
-	if !shared.StringHasPrefix(d.config["source"], "ceph:", "cephfs:") && (d.config["ceph.cluster_name"] != "" || d.config["ceph.user_name"] != "") {
-		return fmt.Errorf("Invalid options ceph.cluster_name/ceph.user_name for source %q", d.config["source"])
+	if !shared.StringHasPrefix(d.config["source"], "ceph:", "cephfs:") && (d.config["ceph.cluster_name"] != "" || d.config["ceph.user_name"] != "" || d.config["ceph.secret"] != "") {
+		return fmt.Errorf("Invalid options ceph.cluster_name/ceph.user_name/ceph.secret for source %q", d.config["source"])
+	}
+
+	// Ceph RBD volumes are passed to QEMU using the host's ceph configuration and keyring.
+	if instConf.Type() == instancetype.VM && strings.HasPrefix(d.config["source"], "ceph:") && d.config["ceph.secret"] != "" {
+		return fmt.Errorf(`The "ceph.secret" option isn't supported for Ceph RBD volumes of virtual machines`)
 	}
 
 	// Check no other devices also have the same path as us. Use LocalDevices for this check so
@@ -249,6 +271,10 @@ func (d *disk) validateConfig(instConf instance.ConfigReader) error {
 			return fmt.Errorf(`The "shift" property cannot be used with custom storage volumes (set "security.shifted=true" on the volume instead)`)
 		}
 
+		if d.config["shift.idmapped"] != "" {
+			return fmt.Errorf(`The "shift.idmapped" property cannot be used with custom storage volumes (set "security.shifted=true" on the volume instead)`)
+		}
+
 		if srcPathIsAbs {
 			return fmt.Errorf("Storage volumes cannot be specified as absolute paths")
 		}
@@ -317,6 +343,23 @@ func (d *disk) validateConfig(instConf instance.ConfigReader) error {
 	return nil
 }
 
+// ValidateNewConfig checks the rules that only apply to new or changed device configs, which aren't enforced for
+// the configs stored before they were introduced.
+func (d *disk) ValidateNewConfig() error {
+	// The "limits.max" key overrides both "limits.read" and "limits.write", and write limits have no effect
+	// on read-only disks.
+	return validateConflictingKeys(d.config,
+		deviceKeyConflict{
+			keys:          []string{"limits.max"},
+			conflictsWith: []string{"limits.read", "limits.write"},
+		},
+		deviceKeyConflict{
+			keys:          []string{"readonly=true"},
+			conflictsWith: []string{"limits.write"},
+		},
+	)
+}
+
 // getDevicePath returns the absolute path on the host for this instance and supplied device config.
 func (d *disk) getDevicePath(devName string, devConfig deviceConfig.Device) string {
 	relativeDestPath := strings.TrimPrefix(devConfig["path"], "/")
@@ -356,8 +399,10 @@ func (d *disk) validateEnvironmentSourcePath() error {
 				return fmt.Errorf("Disk source path %q not allowed by project for disk %q", d.config["source"], d.name)
 			}
 
-			if shared.IsTrue(d.config["shift"]) {
-				return fmt.Errorf(`The "shift" property cannot be used with a restricted source path`)
+			for _, key := range []string{"shift", "shift.idmapped"} {
+				if shared.IsTrue(d.config[key]) {
+					return fmt.Errorf("The %q property cannot be used with a restricted source path", key)
+				}
 			}
 
 			d.restrictedParentSourcePath = shared.HostPath(restrictedParentSourcePath)
@@ -471,6 +516,26 @@ func (d *disk) Start() (*deviceConfig.RunConfig, error) {
 	return runConfig, nil
 }
 
+// idmappedOwnerShift returns the owner shift mode to use for a source path when shift.idmapped is enabled.
+// Unlike shift=true, which falls back to shiftfs, an idmapped mount is required and an error is returned if
+// idmapped mounts aren't supported for the path.
+func (d *disk) idmappedOwnerShift(srcPath string) (string, error) {
+	if d.inst.IsPrivileged() {
+		return deviceConfig.MountOwnerShiftNone, nil
+	}
+
+	c, ok := d.inst.(instance.Container)
+	if !ok {
+		return deviceConfig.MountOwnerShiftNone, nil
+	}
+
+	if c.IdmappedStorage(srcPath) != idmap.IdmapStorageIdmapped {
+		return "", fmt.Errorf("Idmapped mounts not supported for source path %q", srcPath)
+	}
+
+	return deviceConfig.MountOwnerShiftIdmapped, nil
+}
+
 // startContainer starts the disk device for a container instance.
 func (d *disk) startContainer() (*deviceConfig.RunConfig, error) {
 	runConf := deviceConfig.RunConfig{}
@@ -527,7 +592,14 @@ func (d *disk) startContainer() (*deviceConfig.RunConfig, error) {
 		isRecursive := shared.IsTrue(d.config["recursive"])
 
 		ownerShift := deviceConfig.MountOwnerShiftNone
-		if shared.IsTrue(d.config["shift"]) {
+		if shared.IsTrue(d.config["shift.idmapped"]) {
+			var err error
+
+			ownerShift, err = d.idmappedOwnerShift(srcPath)
+			if err != nil {
+				return nil, err
+			}
+		} else if shared.IsTrue(d.config["shift"]) {
 			ownerShift = deviceConfig.MountOwnerShiftDynamic
 		}
 
@@ -1251,8 +1323,13 @@ func (d *disk) createDevice(srcPath string) (func(), string, bool, error) {
 			mdsPath := fields[1]
 			clusterName, userName := d.cephCreds()
 
+			secret, err := d.cephSecret()
+			if err != nil {
+				return nil, "", false, err
+			}
+
 			// Get the mount options.
-			mntSrcPath, fsOptions, fsErr := diskCephfsOptions(clusterName, userName, mdsName, mdsPath)
+			mntSrcPath, fsOptions, fsErr := diskCephfsOptions(clusterName, userName, secret, mdsName, mdsPath)
 			if fsErr != nil {
 				return nil, "", false, fsErr
 			}
@@ -1271,8 +1348,13 @@ func (d *disk) createDevice(srcPath string) (func(), string, bool, error) {
 			volumeName := fields[1]
 			clusterName, userName := d.cephCreds()
 
+			secret, err := d.cephSecret()
+			if err != nil {
+				return nil, "", false, err
+			}
+
 			// Map the RBD.
-			rbdPath, err := diskCephRbdMap(clusterName, userName, poolName, volumeName)
+			rbdPath, err := diskCephRbdMap(clusterName, userName, secret, poolName, volumeName)
 			if err != nil {
 				return nil, "", false, diskSourceNotFoundError{msg: "Failed mapping Ceph RBD volume", err: err}
 			}
@@ -1700,7 +1782,7 @@ func (d *disk) getDiskLimits() (map[string]diskBlockLimit, error) {
 		}
 
 		// Parse the user input
-		readBps, readIops, writeBps, writeIops, err := d.parseDiskLimit(dev["limits.read"], dev["limits.write"])
+		readBps, readIops, writeBps, writeIops, err := parseDiskLimit(dev["limits.read"], dev["limits.write"])
 		if err != nil {
 			return nil, err
 		}
@@ -1809,7 +1891,7 @@ func (d *disk) getDiskLimits() (map[string]diskBlockLimit, error) {
 	return result, nil
 }
 
-func (d *disk) parseDiskLimit(readSpeed string, writeSpeed string) (int64, int64, int64, int64, error) {
+func parseDiskLimit(readSpeed string, writeSpeed string) (int64, int64, int64, int64, error) {
 	parseValue := func(value string) (int64, int64, error) {
 		var err error
 
@@ -2101,3 +2183,18 @@ func (d *disk) cephCreds() (string, string) {
 
 	return clusterName, userName
 }
+
+// cephSecret returns the ceph user's key from the secret referenced by "ceph.secret", or an empty string if no
+// secret is referenced (in which case the host's keyring is used).
+func (d *disk) cephSecret() (string, error) {
+	if d.config["ceph.secret"] == "" {
+		return "", nil
+	}
+
+	secret, err := secretGet(d.inst.Project().Name, d.config["ceph.secret"])
+	if err != nil {
+		return "", fmt.Errorf(`Failed resolving "ceph.secret": %w`, err)
+	}
+
+	return secret, nil
+}
@@ -21,3 +21,57 @@ var ErrCannotUpdate = fmt.Errorf("Device does not support updates")
 
 // ErrMissingVirtiofsd is the error that occurs if virtiofsd is missing.
 var ErrMissingVirtiofsd = UnsupportedError{msg: "Virtiofsd missing"}
+
+// ErrDevicesPathUnavailable is the error that occurs when device files cannot be created because the
+// instance's devices path is on a read-only or full filesystem.
+var ErrDevicesPathUnavailable = fmt.Errorf("Devices path unavailable")
+
+// devicesPathError describes why the devices path is unavailable. It matches ErrDevicesPathUnavailable.
+type devicesPathError struct {
+	path   string
+	reason string
+	err    error
+}
+
+func (e devicesPathError) Error() string {
+	return fmt.Sprintf("Devices path %q is %s, free up space or remount the filesystem read-write: %v", e.path, e.reason, e.err)
+}
+
+func (e devicesPathError) Unwrap() error {
+	return e.err
+}
+
+func (e devicesPathError) Is(target error) bool {
+	return target == ErrDevicesPathUnavailable
+}
+
+// ErrDeviceNotFound is the error that occurs when the host device (or devices) matching the device can't be found.
+var ErrDeviceNotFound = fmt.Errorf("Device not found")
+
+// deviceNotFoundError describes which device couldn't be found. It matches ErrDeviceNotFound.
+type deviceNotFoundError struct {
+	msg string
+}
+
+func (e deviceNotFoundError) Error() string {
+	return e.msg
+}
+
+func (e deviceNotFoundError) Is(target error) bool {
+	return target == ErrDeviceNotFound
+}
+
+// RemediationError is a device error with a suggestion of how to resolve it.
+// The underlying error is available using errors.Is and errors.As.
+type RemediationError struct {
+	Err        error
+	Suggestion string
+}
+
+func (e RemediationError) Error() string {
+	return fmt.Sprintf("%v (%s)", e.Err, e.Suggestion)
+}
+
+func (e RemediationError) Unwrap() error {
+	return e.Err
+}
@@ -13,11 +13,17 @@ func gpuValidationRules(requiredFields []string, optionalFields []string) map[st
 		"pci":       validate.IsPCIAddress,
 		"uid":       unixValidUserID,
 		"gid":       unixValidUserID,
+		"user":      unixValidUserName,
+		"group":     unixValidUserName,
 		"mode":      unixValidOctalFileMode,
 		"mig.gi":    validate.IsUint8,
 		"mig.ci":    validate.IsUint8,
 		"mig.uuid":  gpuValidMigUUID,
 		"mdev":      validate.IsAny,
+		"label":     validLabel,
+		"required":  validate.IsBool,
+
+		"name.template": unixValidNameTemplate(gpuNameTemplateAttributes...),
 	}
 
 	validators := map[string]func(value string) error{}
@@ -145,7 +145,7 @@ func (d *gpuMdev) startVM() (*deviceConfig.RunConfig, error) {
 	}
 
 	if pciAddress == "" {
-		return nil, fmt.Errorf("Failed to detect requested GPU device")
+		return nil, deviceNotFoundError{msg: "Failed to detect requested GPU device"}
 	}
 
 	// Get PCI information about the GPU device.
@@ -204,10 +204,10 @@ func (d *gpuMdev) postStop() error {
 	return nil
 }
 
-// validateConfig checks the supplied config for correctness.
-func (d *gpuMdev) validateConfig(instConf instance.ConfigReader) error {
+// configRules returns the rules that the supplied config is validated with.
+func (d *gpuMdev) configRules(instConf instance.ConfigReader) (map[string]func(value string) error, error) {
 	if !instanceSupported(instConf.Type(), instancetype.VM) {
-		return ErrUnsupportedDevType
+		return nil, ErrUnsupportedDevType
 	}
 
 	requiredFields := []string{
@@ -221,7 +221,19 @@ func (d *gpuMdev) validateConfig(instConf instance.ConfigReader) error {
 		"pci",
 	}
 
-	err := d.config.Validate(gpuValidationRules(requiredFields, optionalFields))
+	return gpuValidationRules(requiredFields, optionalFields), nil
+}
+
+// validateConfig checks the supplied config for correctness.
+func (d *gpuMdev) validateConfig(instConf instance.ConfigReader) error {
+	rules, err := d.configRules(instConf)
+	if err != nil {
+		return err
+	}
+
+	d.config.Normalise()
+
+	err = d.config.Validate(rules)
 	if err != nil {
 		return err
 	}
@@ -232,8 +244,6 @@ func (d *gpuMdev) validateConfig(instConf instance.ConfigReader) error {
 				return fmt.Errorf(`Cannot use %q when when "pci" is set`, field)
 			}
 		}
-
-		d.config["pci"] = pcidev.NormaliseAddress(d.config["pci"])
 	}
 
 	if d.config["id"] != "" {
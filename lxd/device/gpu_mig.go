@@ -5,7 +5,6 @@ import (
 	"strings"
 
 	deviceConfig "github.com/lxc/lxd/lxd/device/config"
-	pcidev "github.com/lxc/lxd/lxd/device/pci"
 	"github.com/lxc/lxd/lxd/instance"
 	"github.com/lxc/lxd/lxd/instance/instancetype"
 	"github.com/lxc/lxd/lxd/resources"
@@ -20,10 +19,10 @@ type gpuMIG struct {
 // GPUNvidiaDeviceKey is the key used for NVIDIA devices through libnvidia-container.
 const GPUNvidiaDeviceKey = "nvidia.device"
 
-// validateConfig checks the supplied config for correctness.
-func (d *gpuMIG) validateConfig(instConf instance.ConfigReader) error {
+// configRules returns the rules that the supplied config is validated with.
+func (d *gpuMIG) configRules(instConf instance.ConfigReader) (map[string]func(value string) error, error) {
 	if !instanceSupported(instConf.Type(), instancetype.Container) {
-		return ErrUnsupportedDevType
+		return nil, ErrUnsupportedDevType
 	}
 
 	requiredFields := []string{}
@@ -38,7 +37,19 @@ func (d *gpuMIG) validateConfig(instConf instance.ConfigReader) error {
 		"mig.uuid",
 	}
 
-	err := d.config.Validate(gpuValidationRules(requiredFields, optionalFields))
+	return gpuValidationRules(requiredFields, optionalFields), nil
+}
+
+// validateConfig checks the supplied config for correctness.
+func (d *gpuMIG) validateConfig(instConf instance.ConfigReader) error {
+	rules, err := d.configRules(instConf)
+	if err != nil {
+		return err
+	}
+
+	d.config.Normalise()
+
+	err = d.config.Validate(rules)
 	if err != nil {
 		return err
 	}
@@ -49,8 +60,6 @@ func (d *gpuMIG) validateConfig(instConf instance.ConfigReader) error {
 				return fmt.Errorf(`Cannot use %q when when "pci" is set`, field)
 			}
 		}
-
-		d.config["pci"] = pcidev.NormaliseAddress(d.config["pci"])
 	}
 
 	if d.config["id"] != "" {
@@ -158,7 +167,7 @@ func (d *gpuMIG) Start() (*deviceConfig.RunConfig, error) {
 	}
 
 	if pciAddress == "" {
-		return nil, fmt.Errorf("Failed to detect requested GPU device")
+		return nil, deviceNotFoundError{msg: "Failed to detect requested GPU device"}
 	}
 
 	return &runConf, nil
@@ -1,6 +1,7 @@
 package device
 
 import (
+	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
@@ -17,10 +18,15 @@ import (
 	"github.com/lxc/lxd/lxd/resources"
 	"github.com/lxc/lxd/lxd/util"
 	"github.com/lxc/lxd/shared"
+	"github.com/lxc/lxd/shared/api"
+	"github.com/lxc/lxd/shared/logger"
 )
 
 const gpuDRIDevPath = "/dev/dri"
 
+// gpuNameTemplateAttributes lists the GPU attributes that can be used in the device node name template.
+var gpuNameTemplateAttributes = []string{"pci", "id", "vendorid", "productid", "node"}
+
 // Non-card devices such as {/dev/nvidiactl, /dev/nvidia-uvm, ...}.
 type nvidiaNonCardDevice struct {
 	path  string
@@ -32,10 +38,10 @@ type gpuPhysical struct {
 	deviceCommon
 }
 
-// validateConfig checks the supplied config for correctness.
-func (d *gpuPhysical) validateConfig(instConf instance.ConfigReader) error {
+// configRules returns the rules that the supplied config is validated with.
+func (d *gpuPhysical) configRules(instConf instance.ConfigReader) (map[string]func(value string) error, error) {
 	if !instanceSupported(instConf.Type(), instancetype.Container, instancetype.VM) {
-		return ErrUnsupportedDevType
+		return nil, ErrUnsupportedDevType
 	}
 
 	optionalFields := []string{
@@ -43,13 +49,27 @@ func (d *gpuPhysical) validateConfig(instConf instance.ConfigReader) error {
 		"productid",
 		"id",
 		"pci",
+		"label",
+		"required",
 	}
 
 	if instConf.Type() == instancetype.Container || instConf.Type() == instancetype.Any {
-		optionalFields = append(optionalFields, "uid", "gid", "mode")
+		optionalFields = append(optionalFields, "uid", "gid", "user", "group", "mode", "name.template")
 	}
 
-	err := d.config.Validate(gpuValidationRules(nil, optionalFields))
+	return gpuValidationRules(nil, optionalFields), nil
+}
+
+// validateConfig checks the supplied config for correctness.
+func (d *gpuPhysical) validateConfig(instConf instance.ConfigReader) error {
+	rules, err := d.configRules(instConf)
+	if err != nil {
+		return err
+	}
+
+	d.config.Normalise()
+
+	err = d.config.Validate(rules)
 	if err != nil {
 		return err
 	}
@@ -60,8 +80,6 @@ func (d *gpuPhysical) validateConfig(instConf instance.ConfigReader) error {
 				return fmt.Errorf(`Cannot use %q when when "pci" is set`, field)
 			}
 		}
-
-		d.config["pci"] = pcidev.NormaliseAddress(d.config["pci"])
 	}
 
 	if d.config["id"] != "" {
@@ -72,9 +90,82 @@ func (d *gpuPhysical) validateConfig(instConf instance.ConfigReader) error {
 		}
 	}
 
+	if d.config["label"] != "" {
+		for _, field := range []string{"id", "pci", "productid", "vendorid"} {
+			if d.config[field] != "" {
+				return fmt.Errorf(`Cannot use %q when when "label" is set`, field)
+			}
+		}
+	} else if d.config["required"] != "" {
+		return fmt.Errorf(`The "required" property requires "label" to be set`)
+	}
+
+	if d.config["user"] != "" && d.config["uid"] != "" {
+		return fmt.Errorf(`Cannot use "uid" when "user" is set`)
+	}
+
+	if d.config["group"] != "" && d.config["gid"] != "" {
+		return fmt.Errorf(`Cannot use "gid" when "group" is set`)
+	}
+
 	return nil
 }
 
+// ownershipConfig returns a copy of the device config with the "user" and "group" keys (if set) resolved to
+// "uid" and "gid" respectively using the account databases inside the container. The resulting IDs are those
+// inside the container and so are translated through the instance's idmap when the device nodes are created.
+func (d *gpuPhysical) ownershipConfig() (deviceConfig.Device, error) {
+	if d.config["user"] == "" && d.config["group"] == "" {
+		return d.config, nil
+	}
+
+	config := d.config.Clone()
+
+	if d.config["user"] != "" {
+		uid, err := unixInstanceLookupID(d.inst.RootfsPath(), "/etc/passwd", d.config["user"])
+		if err != nil {
+			return nil, fmt.Errorf("Failed resolving user %q: %w", d.config["user"], err)
+		}
+
+		config["uid"] = uid
+	}
+
+	if d.config["group"] != "" {
+		gid, err := unixInstanceLookupID(d.inst.RootfsPath(), "/etc/group", d.config["group"])
+		if err != nil {
+			return nil, fmt.Errorf("Failed resolving group %q: %w", d.config["group"], err)
+		}
+
+		config["gid"] = gid
+	}
+
+	return config, nil
+}
+
+// CheckResources checks that a GPU matching the device config is present in the hardware view of a host.
+// GPUs selected by label are resolved from the inventory of the host that the device starts on, so aren't checked.
+func (d *gpuPhysical) CheckResources(res *api.Resources) error {
+	if d.config["label"] != "" {
+		return nil
+	}
+
+	for _, gpu := range res.GPU.Cards {
+		if (d.config["vendorid"] != "" && gpu.VendorID != d.config["vendorid"]) ||
+			(d.config["pci"] != "" && gpu.PCIAddress != pcidev.NormaliseAddress(d.config["pci"])) ||
+			(d.config["productid"] != "" && gpu.ProductID != d.config["productid"]) {
+			continue
+		}
+
+		if d.config["id"] != "" && (gpu.DRM == nil || fmt.Sprintf("%d", gpu.DRM.ID) != d.config["id"]) {
+			continue
+		}
+
+		return nil
+	}
+
+	return fmt.Errorf("No GPU matching the device is present")
+}
+
 // validateEnvironment checks the runtime environment for correctness.
 func (d *gpuPhysical) validateEnvironment() error {
 	if d.inst.Type() == instancetype.VM && shared.IsTrue(d.inst.ExpandedConfig()["migration.stateful"]) {
@@ -86,6 +177,21 @@ func (d *gpuPhysical) validateEnvironment() error {
 
 // Start is run when the device is added to the container.
 func (d *gpuPhysical) Start() (*deviceConfig.RunConfig, error) {
+	// Select the card by its address from the label.
+	if d.config["label"] != "" {
+		address, err := labelResolvePCI(d.config["label"])
+		if err != nil {
+			if errors.Is(err, ErrDeviceNotFound) && shared.IsFalse(d.config["required"]) {
+				d.logger.Warn("Skipping GPU device as no host device with its label is present", logger.Ctx{"label": d.config["label"]})
+				return &deviceConfig.RunConfig{}, nil
+			}
+
+			return nil, err
+		}
+
+		d.config["pci"] = address
+	}
+
 	err := d.validateEnvironment()
 	if err != nil {
 		return nil, err
@@ -107,6 +213,40 @@ func (d *gpuPhysical) startContainer() (*deviceConfig.RunConfig, error) {
 		return nil, err
 	}
 
+	ownerConfig, err := d.ownershipConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	// setupNode creates the device node for the card in the container. The node is at the same path as on the
+	// host unless "name.template" is set, in which case it is named from the card attributes.
+	setupNode := func(gpu api.ResourcesGPUCard, major uint32, minor uint32, path string) error {
+		if d.config["name.template"] == "" {
+			return unixDeviceSetupCharNum(d.state, d.inst.DevicesPath(), "unix", d.name, ownerConfig, major, minor, path, false, &runConf)
+		}
+
+		attributes := map[string]string{
+			"pci":       gpu.PCIAddress,
+			"vendorid":  gpu.VendorID,
+			"productid": gpu.ProductID,
+			"node":      filepath.Base(path),
+		}
+
+		if gpu.DRM != nil {
+			attributes["id"] = fmt.Sprintf("%d", gpu.DRM.ID)
+		}
+
+		destPath, err := unixDeviceTemplatePath(d.inst.DevicesPath(), d.config["name.template"], attributes, major, minor)
+		if err != nil {
+			return fmt.Errorf("Failed naming GPU device node: %w", err)
+		}
+
+		nodeConfig := ownerConfig.Clone()
+		nodeConfig["source"] = path
+
+		return unixDeviceSetupCharNum(d.state, d.inst.DevicesPath(), "unix", d.name, nodeConfig, major, minor, destPath, false, &runConf)
+	}
+
 	sawNvidia := false
 	found := false
 
@@ -130,7 +270,7 @@ func (d *gpuPhysical) startContainer() (*deviceConfig.RunConfig, error) {
 					return nil, err
 				}
 
-				err = unixDeviceSetupCharNum(d.state, d.inst.DevicesPath(), "unix", d.name, d.config, major, minor, path, false, &runConf)
+				err = setupNode(gpu, major, minor, path)
 				if err != nil {
 					return nil, err
 				}
@@ -143,7 +283,7 @@ func (d *gpuPhysical) startContainer() (*deviceConfig.RunConfig, error) {
 					return nil, err
 				}
 
-				err = unixDeviceSetupCharNum(d.state, d.inst.DevicesPath(), "unix", d.name, d.config, major, minor, path, false, &runConf)
+				err = setupNode(gpu, major, minor, path)
 				if err != nil {
 					return nil, err
 				}
@@ -156,7 +296,7 @@ func (d *gpuPhysical) startContainer() (*deviceConfig.RunConfig, error) {
 					return nil, err
 				}
 
-				err = unixDeviceSetupCharNum(d.state, d.inst.DevicesPath(), "unix", d.name, d.config, major, minor, path, false, &runConf)
+				err = setupNode(gpu, major, minor, path)
 				if err != nil {
 					return nil, err
 				}
@@ -172,7 +312,7 @@ func (d *gpuPhysical) startContainer() (*deviceConfig.RunConfig, error) {
 				return nil, err
 			}
 
-			err = unixDeviceSetupCharNum(d.state, d.inst.DevicesPath(), "unix", d.name, d.config, major, minor, path, false, &runConf)
+			err = setupNode(gpu, major, minor, path)
 			if err != nil {
 				return nil, err
 			}
@@ -195,7 +335,7 @@ func (d *gpuPhysical) startContainer() (*deviceConfig.RunConfig, error) {
 					continue
 				}
 
-				err = unixDeviceSetupCharNum(d.state, d.inst.DevicesPath(), "unix", d.name, d.config, dev.major, dev.minor, dev.path, false, &runConf)
+				err = unixDeviceSetupCharNum(d.state, d.inst.DevicesPath(), "unix", d.name, ownerConfig, dev.major, dev.minor, dev.path, false, &runConf)
 				if err != nil {
 					return nil, err
 				}
@@ -204,7 +344,7 @@ func (d *gpuPhysical) startContainer() (*deviceConfig.RunConfig, error) {
 	}
 
 	if !found {
-		return nil, fmt.Errorf("Failed to detect requested GPU device")
+		return nil, deviceNotFoundError{msg: "Failed to detect requested GPU device"}
 	}
 
 	return &runConf, nil
@@ -238,7 +378,7 @@ func (d *gpuPhysical) startVM() (*deviceConfig.RunConfig, error) {
 	}
 
 	if pciAddress == "" {
-		return nil, fmt.Errorf("Failed to detect requested GPU device")
+		return nil, deviceNotFoundError{msg: "Failed to detect requested GPU device"}
 	}
 
 	// Make sure that vfio-pci is loaded.
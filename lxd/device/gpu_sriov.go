@@ -21,10 +21,10 @@ type gpuSRIOV struct {
 	deviceCommon
 }
 
-// validateConfig checks the supplied config for correctness.
-func (d *gpuSRIOV) validateConfig(instConf instance.ConfigReader) error {
+// configRules returns the rules that the supplied config is validated with.
+func (d *gpuSRIOV) configRules(instConf instance.ConfigReader) (map[string]func(value string) error, error) {
 	if !instanceSupported(instConf.Type(), instancetype.VM) {
-		return ErrUnsupportedDevType
+		return nil, ErrUnsupportedDevType
 	}
 
 	requiredFields := []string{}
@@ -36,7 +36,19 @@ func (d *gpuSRIOV) validateConfig(instConf instance.ConfigReader) error {
 		"pci",
 	}
 
-	err := d.config.Validate(gpuValidationRules(requiredFields, optionalFields))
+	return gpuValidationRules(requiredFields, optionalFields), nil
+}
+
+// validateConfig checks the supplied config for correctness.
+func (d *gpuSRIOV) validateConfig(instConf instance.ConfigReader) error {
+	rules, err := d.configRules(instConf)
+	if err != nil {
+		return err
+	}
+
+	d.config.Normalise()
+
+	err = d.config.Validate(rules)
 	if err != nil {
 		return err
 	}
@@ -47,8 +59,6 @@ func (d *gpuSRIOV) validateConfig(instConf instance.ConfigReader) error {
 				return fmt.Errorf(`Cannot use %q when when "pci" is set`, field)
 			}
 		}
-
-		d.config["pci"] = pcidev.NormaliseAddress(d.config["pci"])
 	}
 
 	if d.config["id"] != "" {
@@ -168,7 +178,7 @@ func (d *gpuSRIOV) getParentPCIAddresses() ([]string, error) {
 	}
 
 	if len(parentPCIAddresses) == 0 {
-		return nil, fmt.Errorf("Failed to detect requested GPU device")
+		return nil, deviceNotFoundError{msg: "Failed to detect requested GPU device"}
 	}
 
 	return parentPCIAddresses, nil
@@ -0,0 +1,240 @@
+package device
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"golang.org/x/sys/unix"
+
+	deviceConfig "github.com/lxc/lxd/lxd/device/config"
+	"github.com/lxc/lxd/lxd/instance"
+	"github.com/lxc/lxd/lxd/instance/instancetype"
+	"github.com/lxc/lxd/lxd/revert"
+	"github.com/lxc/lxd/shared"
+	"github.com/lxc/lxd/shared/validate"
+)
+
+// hwmonSysfsClass is the sysfs path listing the hardware monitoring chips of the host.
+const hwmonSysfsClass = "/sys/class/hwmon"
+
+// hwmonDefaultPath is the directory in the container that the chips are exposed in by default.
+const hwmonDefaultPath = "/dev/hwmon"
+
+type hwmon struct {
+	deviceCommon
+}
+
+// configRules returns the rules that the supplied config is validated with.
+func (d *hwmon) configRules(instConf instance.ConfigReader) (map[string]func(value string) error, error) {
+	if !instanceSupported(instConf.Type(), instancetype.Container) {
+		return nil, ErrUnsupportedDevType
+	}
+
+	rules := map[string]func(string) error{
+		"chip": validate.IsNotEmpty,
+		"path": validate.Optional(validate.IsAbsFilePath),
+	}
+
+	return rules, nil
+}
+
+// validateConfig checks the supplied config for correctness.
+func (d *hwmon) validateConfig(instConf instance.ConfigReader) error {
+	rules, err := d.configRules(instConf)
+	if err != nil {
+		return err
+	}
+
+	err = d.config.Validate(rules)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// validateEnvironment checks the runtime environment for correctness.
+func (d *hwmon) validateEnvironment() error {
+	chips, err := d.loadChips()
+	if err != nil {
+		return err
+	}
+
+	if len(chips) == 0 {
+		return fmt.Errorf("Hardware monitoring chip %q not found", d.config["chip"])
+	}
+
+	return nil
+}
+
+// path returns the directory in the container that the chips are exposed in.
+func (d *hwmon) path() string {
+	if d.config["path"] != "" {
+		return d.config["path"]
+	}
+
+	return hwmonDefaultPath
+}
+
+// devicePath returns the path of the host side mount of the chip in the instance devices directory.
+func (d *hwmon) devicePath(chip string) string {
+	return filepath.Join(d.inst.DevicesPath(), deviceJoinPath("hwmon", d.name, chip))
+}
+
+// loadChips returns the names of the hardware monitoring chips (such as "hwmon0") on the host matching the device
+// config, matched on the name of the chip driver (such as "coretemp" or "nvme").
+func (d *hwmon) loadChips() ([]string, error) {
+	entries, err := os.ReadDir(hwmonSysfsClass)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return []string{}, nil
+		}
+
+		return nil, fmt.Errorf("Failed listing hardware monitoring chips: %w", err)
+	}
+
+	chips := []string{}
+	for _, entry := range entries {
+		content, err := os.ReadFile(filepath.Join(hwmonSysfsClass, entry.Name(), "name"))
+		if err != nil {
+			continue
+		}
+
+		if strings.TrimSpace(string(content)) == d.config["chip"] {
+			chips = append(chips, entry.Name())
+		}
+	}
+
+	sort.Strings(chips)
+
+	return chips, nil
+}
+
+// Start is run when the device is added to the instance.
+func (d *hwmon) Start() (*deviceConfig.RunConfig, error) {
+	err := d.validateEnvironment()
+	if err != nil {
+		return nil, err
+	}
+
+	chips, err := d.loadChips()
+	if err != nil {
+		return nil, err
+	}
+
+	revert := revert.New()
+	defer revert.Fail()
+
+	// Create the devices directory if missing.
+	if !shared.PathExists(d.inst.DevicesPath()) {
+		err := os.Mkdir(d.inst.DevicesPath(), 0711)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	runConf := deviceConfig.RunConfig{}
+
+	for _, chip := range chips {
+		// The chip entries are symlinks to the chip directory of the device providing the sensors.
+		srcPath, err := filepath.EvalSymlinks(filepath.Join(hwmonSysfsClass, chip))
+		if err != nil {
+			return nil, fmt.Errorf("Failed resolving hardware monitoring chip %q: %w", chip, err)
+		}
+
+		devPath := d.devicePath(chip)
+
+		// Clean any existing entry.
+		err = DiskMountClear(devPath)
+		if err != nil {
+			return nil, err
+		}
+
+		err = os.Mkdir(devPath, 0700)
+		if err != nil {
+			return nil, err
+		}
+
+		revert.Add(func() { _ = DiskMountClear(devPath) })
+
+		// Bind mount the chip read-only on the host side, so that the mount in the container is read-only too.
+		err = DiskMount(srcPath, devPath, true, false, "", nil, "none")
+		if err != nil {
+			return nil, err
+		}
+
+		runConf.Mounts = append(runConf.Mounts, deviceConfig.MountEntryItem{
+			DevName:    d.name,
+			DevPath:    devPath,
+			TargetPath: strings.TrimPrefix(filepath.Join(d.path(), chip), "/"),
+			FSType:     "none",
+			Opts:       []string{"bind", "ro", "create=dir"},
+		})
+	}
+
+	err = d.volatileSet(map[string]string{"last_state.hwmon": strings.Join(chips, ",")})
+	if err != nil {
+		return nil, err
+	}
+
+	// Unmount host-side mounts once instance is started.
+	runConf.PostHooks = append(runConf.PostHooks, d.postStart)
+
+	revert.Success()
+	return &runConf, nil
+}
+
+// chips returns the names of the chips that the device exposes in the instance.
+func (d *hwmon) chips() []string {
+	v := d.volatileGet()
+	if v["last_state.hwmon"] == "" {
+		return []string{}
+	}
+
+	return strings.Split(v["last_state.hwmon"], ",")
+}
+
+// postStart is run after the device is added to the instance.
+func (d *hwmon) postStart() error {
+	for _, chip := range d.chips() {
+		// Unmount the host side.
+		err := unix.Unmount(d.devicePath(chip), unix.MNT_DETACH)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Stop is run when the device is removed from the instance.
+func (d *hwmon) Stop() (*deviceConfig.RunConfig, error) {
+	runConf := deviceConfig.RunConfig{
+		PostHooks: []func() error{d.postStop},
+	}
+
+	// Request an unmount of the chips inside the instance.
+	for _, chip := range d.chips() {
+		runConf.Mounts = append(runConf.Mounts, deviceConfig.MountEntryItem{
+			TargetPath: strings.TrimPrefix(filepath.Join(d.path(), chip), "/"),
+		})
+	}
+
+	return &runConf, nil
+}
+
+// postStop is run after the device is removed from the instance.
+func (d *hwmon) postStop() error {
+	for _, chip := range d.chips() {
+		// Clean any existing host side mount entry.
+		err := DiskMountClear(d.devicePath(chip))
+		if err != nil {
+			return err
+		}
+	}
+
+	return d.volatileSet(map[string]string{"last_state.hwmon": ""})
+}
@@ -17,8 +17,8 @@ type infinibandPhysical struct {
 	deviceCommon
 }
 
-// validateConfig checks the supplied config for correctness.
-func (d *infinibandPhysical) validateConfig(instConf instance.ConfigReader) error {
+// configRules returns the rules that the supplied config is validated with.
+func (d *infinibandPhysical) configRules(instConf instance.ConfigReader) (map[string]func(value string) error, error) {
 	requiredFields := []string{"parent"}
 	optionalFields := []string{
 		"name",
@@ -35,7 +35,19 @@ func (d *infinibandPhysical) validateConfig(instConf instance.ConfigReader) erro
 		return infinibandValidMAC(value)
 	}
 
-	err := d.config.Validate(rules)
+	return rules, nil
+}
+
+// validateConfig checks the supplied config for correctness.
+func (d *infinibandPhysical) validateConfig(instConf instance.ConfigReader) error {
+	rules, err := d.configRules(instConf)
+	if err != nil {
+		return err
+	}
+
+	d.config.Normalise()
+
+	err = d.config.Validate(rules)
 	if err != nil {
 		return err
 	}
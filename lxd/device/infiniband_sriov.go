@@ -24,8 +24,8 @@ type infinibandSRIOV struct {
 	deviceCommon
 }
 
-// validateConfig checks the supplied config for correctness.
-func (d *infinibandSRIOV) validateConfig(instConf instance.ConfigReader) error {
+// configRules returns the rules that the supplied config is validated with.
+func (d *infinibandSRIOV) configRules(instConf instance.ConfigReader) (map[string]func(value string) error, error) {
 	requiredFields := []string{"parent"}
 	optionalFields := []string{
 		"name",
@@ -42,7 +42,19 @@ func (d *infinibandSRIOV) validateConfig(instConf instance.ConfigReader) error {
 		return infinibandValidMAC(value)
 	}
 
-	err := d.config.Validate(rules)
+	return rules, nil
+}
+
+// validateConfig checks the supplied config for correctness.
+func (d *infinibandSRIOV) validateConfig(instConf instance.ConfigReader) error {
+	rules, err := d.configRules(instConf)
+	if err != nil {
+		return err
+	}
+
+	d.config.Normalise()
+
+	err = d.config.Validate(rules)
 	if err != nil {
 		return err
 	}
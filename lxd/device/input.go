@@ -0,0 +1,278 @@
+//go:build linux && cgo
+
+package device
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/jochenvg/go-udev"
+
+	deviceConfig "github.com/lxc/lxd/lxd/device/config"
+	"github.com/lxc/lxd/lxd/instance"
+	"github.com/lxc/lxd/lxd/instance/instancetype"
+	"github.com/lxc/lxd/shared"
+	"github.com/lxc/lxd/shared/logger"
+	"github.com/lxc/lxd/shared/validate"
+)
+
+// inputEventDevPrefix is the prefix of the input event device nodes.
+const inputEventDevPrefix = "/dev/input/event"
+
+// inputCapabilities lists the supported input device capabilities and the udev property that indicates them.
+var inputCapabilities = map[string]string{
+	"keyboard":    "ID_INPUT_KEYBOARD",
+	"key":         "ID_INPUT_KEY",
+	"mouse":       "ID_INPUT_MOUSE",
+	"touchpad":    "ID_INPUT_TOUCHPAD",
+	"touchscreen": "ID_INPUT_TOUCHSCREEN",
+	"tablet":      "ID_INPUT_TABLET",
+	"joystick":    "ID_INPUT_JOYSTICK",
+	"switch":      "ID_INPUT_SWITCH",
+}
+
+// inputIsOurDevice indicates whether the input event device with the supplied udev properties qualifies as
+// part of our device. This function is not defined against the input struct type so that it can be used in
+// event callbacks without needing to keep a reference to the input device struct.
+func inputIsOurDevice(config deviceConfig.Device, properties map[string]string) bool {
+	if properties["SUBSYSTEM"] != "input" || !strings.HasPrefix(properties["DEVNAME"], inputEventDevPrefix) {
+		return false
+	}
+
+	if (config["vendorid"] != "" && config["vendorid"] != properties["ID_VENDOR_ID"]) || (config["productid"] != "" && config["productid"] != properties["ID_MODEL_ID"]) {
+		return false
+	}
+
+	if config["serial"] != "" && config["serial"] != properties["ID_SERIAL_SHORT"] {
+		return false
+	}
+
+	// The device must have at least one of the requested capabilities.
+	if config["capabilities"] != "" {
+		for _, capability := range strings.Split(config["capabilities"], ",") {
+			if properties[inputCapabilities[strings.TrimSpace(capability)]] == "1" {
+				return true
+			}
+		}
+
+		return false
+	}
+
+	return true
+}
+
+type input struct {
+	deviceCommon
+}
+
+// isRequired indicates whether the device config requires this device to start OK.
+func (d *input) isRequired() bool {
+	// Defaults to not required.
+	return shared.IsTrue(d.config["required"])
+}
+
+// Required indicates whether the device must start for the instance to start.
+func (d *input) Required() bool {
+	return d.isRequired()
+}
+
+// configRules returns the rules that the supplied config is validated with.
+func (d *input) configRules(instConf instance.ConfigReader) (map[string]func(value string) error, error) {
+	if !instanceSupported(instConf.Type(), instancetype.Container) {
+		return nil, ErrUnsupportedDevType
+	}
+
+	capabilities := make([]string, 0, len(inputCapabilities))
+	for capability := range inputCapabilities {
+		capabilities = append(capabilities, capability)
+	}
+
+	sort.Strings(capabilities)
+
+	rules := map[string]func(string) error{
+		"capabilities": validate.Optional(validate.IsListOf(validate.IsOneOf(capabilities...))),
+		"vendorid":     validate.Optional(validate.IsDeviceID),
+		"productid":    validate.Optional(validate.IsDeviceID),
+		"serial":       validate.IsAny,
+		"uid":          unixValidUserID,
+		"gid":          unixValidUserID,
+		"mode":         unixValidOctalFileMode,
+		"required":     validate.Optional(validate.IsBool),
+
+		"udev.settle":         validate.Optional(validate.IsBool),
+		"udev.settle.timeout": validate.Optional(validate.IsUint32),
+
+		"devices_path.unavailable": validate.Optional(validate.IsOneOf("fail", "skip")),
+		"strategy":                 validate.Optional(validate.IsOneOf("auto", "mknod", "bind")),
+	}
+
+	return rules, nil
+}
+
+// validateConfig checks the supplied config for correctness.
+func (d *input) validateConfig(instConf instance.ConfigReader) error {
+	rules, err := d.configRules(instConf)
+	if err != nil {
+		return err
+	}
+
+	err = d.config.Validate(rules)
+	if err != nil {
+		return err
+	}
+
+	if d.config["capabilities"] == "" && d.config["vendorid"] == "" && d.config["productid"] == "" && d.config["serial"] == "" {
+		return fmt.Errorf("Input devices require at least one of capabilities, vendorid, productid or serial")
+	}
+
+	err = validateConflictingKeys(d.config, unixStrategyConflict)
+	if err != nil {
+		return err
+	}
+
+	if d.config["devices_path.unavailable"] == "skip" && d.isRequired() {
+		return fmt.Errorf(`"devices_path.unavailable=skip" can only be used with devices that aren't required`)
+	}
+
+	return nil
+}
+
+// Register is run after the device is started or when LXD starts.
+func (d *input) Register() error {
+	// Extract variables needed to run the event hook so that the reference to this device
+	// struct is not needed to be kept in memory.
+	devicesPath := d.inst.DevicesPath()
+	devConfig := d.config
+	deviceName := d.name
+	state := d.state
+
+	// Handler for when a Unix hotplug event occurs for an input device.
+	f := func(e UnixHotplugEvent) (*deviceConfig.RunConfig, error) {
+		if e.Subsystem != "input" || !strings.HasPrefix(e.Path, inputEventDevPrefix) {
+			return nil, nil
+		}
+
+		runConf := deviceConfig.RunConfig{}
+
+		if e.Action == "add" {
+			// The capabilities aren't included in the event, so get them from udev.
+			u := udev.Udev{}
+			device := u.NewDeviceFromDevnum('c', udev.MkDev(int(e.Major), int(e.Minor)))
+			if device == nil || !inputIsOurDevice(devConfig, device.Properties()) {
+				return nil, nil
+			}
+
+			err := unixDeviceSetupCharNum(state, devicesPath, "unix", deviceName, devConfig, e.Major, e.Minor, e.Path, false, &runConf)
+			if err != nil {
+				return nil, err
+			}
+		} else if e.Action == "remove" {
+			relativeTargetPath := strings.TrimPrefix(e.Path, "/")
+			err := unixDeviceRemove(devicesPath, "unix", deviceName, relativeTargetPath, &runConf)
+			if err != nil {
+				return nil, err
+			}
+
+			// Add a post hook function to remove the specific input device file after unmount.
+			runConf.PostHooks = []func() error{func() error {
+				err := unixDeviceDeleteFiles(state, devicesPath, "unix", deviceName, relativeTargetPath)
+				if err != nil {
+					return fmt.Errorf("Failed to delete files for device '%s': %w", deviceName, err)
+				}
+
+				return nil
+			}}
+		}
+
+		runConf.Uevents = append(runConf.Uevents, e.UeventParts)
+
+		return &runConf, nil
+	}
+
+	unixHotplugRegisterHandler(d.inst, d.name, f)
+
+	return nil
+}
+
+// Start is run when the device is added to the instance.
+func (d *input) Start() (*deviceConfig.RunConfig, error) {
+	runConf := deviceConfig.RunConfig{}
+	runConf.PostHooks = []func() error{d.Register}
+
+	d.settleUdev()
+
+	devices := d.loadInputDevices()
+	if d.isRequired() && len(devices) == 0 {
+		return nil, deviceNotFoundError{msg: "Required input device not found"}
+	}
+
+	for _, device := range devices {
+		devnum := device.Devnum()
+
+		err := unixDeviceSetupCharNum(d.state, d.inst.DevicesPath(), "unix", d.name, d.config, uint32(devnum.Major()), uint32(devnum.Minor()), device.Devnode(), false, &runConf)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return &runConf, nil
+}
+
+// Stop is run when the device is removed from the instance.
+func (d *input) Stop() (*deviceConfig.RunConfig, error) {
+	unixHotplugUnregisterHandler(d.inst, d.name)
+
+	runConf := deviceConfig.RunConfig{
+		PostHooks: []func() error{d.postStop},
+	}
+
+	err := unixDeviceRemove(d.inst.DevicesPath(), "unix", d.name, "", &runConf)
+	if err != nil {
+		return nil, err
+	}
+
+	return &runConf, nil
+}
+
+// postStop is run after the device is removed from the instance.
+func (d *input) postStop() error {
+	err := unixDeviceDeleteFiles(d.state, d.inst.DevicesPath(), "unix", d.name, "")
+	if err != nil {
+		return fmt.Errorf("Failed to delete files for device '%s': %w", d.name, err)
+	}
+
+	return nil
+}
+
+// loadInputDevices scans the host machine for input event devices matching the device config.
+func (d *input) loadInputDevices() []*udev.Device {
+	u := udev.Udev{}
+	e := u.NewEnumerate()
+
+	err := e.AddMatchSubsystem("input")
+	if err != nil {
+		logger.Warn("Failed to add subsystem match to input devices", logger.Ctx{"err": err})
+	}
+
+	err = e.AddMatchIsInitialized()
+	if err != nil {
+		logger.Warn("Failed to add initialised property to device", logger.Ctx{"err": err})
+	}
+
+	devices, _ := e.Devices()
+	matches := []*udev.Device{}
+	for _, device := range devices {
+		if device == nil || device.Devnode() == "" {
+			continue
+		}
+
+		if !inputIsOurDevice(d.config, device.Properties()) {
+			continue
+		}
+
+		matches = append(matches, device)
+	}
+
+	return matches
+}
@@ -0,0 +1,243 @@
+package device
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+
+	deviceConfig "github.com/lxc/lxd/lxd/device/config"
+	"github.com/lxc/lxd/lxd/instance"
+	"github.com/lxc/lxd/lxd/instance/instancetype"
+	"github.com/lxc/lxd/lxd/revert"
+	"github.com/lxc/lxd/shared"
+	"github.com/lxc/lxd/shared/logger"
+	"github.com/lxc/lxd/shared/validate"
+)
+
+// ipmiHostPathFormats lists the formats of the paths that the host may expose an IPMI interface's device node at,
+// depending on the udev rules in use.
+var ipmiHostPathFormats = []string{"/dev/ipmi%d", "/dev/ipmi/%d", "/dev/ipmidev/%d"}
+
+// ipmiClaims stores the device claiming each exclusively used IPMI interface, keyed on the interface index.
+// The claims are keyed on the same key as deviceRuntimes.
+var ipmiClaims = map[int]string{}
+
+// ipmiClaimsMu controls access to the ipmiClaims map.
+var ipmiClaimsMu sync.Mutex
+
+// ipmiClaim claims the IPMI interfaces for the device, failing if any are claimed by another device.
+func ipmiClaim(key string, indexes []int) error {
+	ipmiClaimsMu.Lock()
+	defer ipmiClaimsMu.Unlock()
+
+	for _, index := range indexes {
+		owner, found := ipmiClaims[index]
+		if found && owner != key {
+			ownerParts := strings.SplitN(owner, "\000", 3)
+			return fmt.Errorf("IPMI interface %d is already in use by device %q of instance %q in project %q", index, ownerParts[2], ownerParts[1], ownerParts[0])
+		}
+	}
+
+	for _, index := range indexes {
+		ipmiClaims[index] = key
+	}
+
+	return nil
+}
+
+// ipmiRelease releases the IPMI interfaces claimed by the device.
+func ipmiRelease(key string) {
+	ipmiClaimsMu.Lock()
+	defer ipmiClaimsMu.Unlock()
+
+	for index, owner := range ipmiClaims {
+		if owner == key {
+			delete(ipmiClaims, index)
+		}
+	}
+}
+
+type ipmi struct {
+	deviceCommon
+}
+
+// isRequired indicates whether the device config requires this device to start OK.
+func (d *ipmi) isRequired() bool {
+	// Defaults to required.
+	return shared.IsTrueOrEmpty(d.config["required"])
+}
+
+// Required indicates whether the device must start for the instance to start.
+func (d *ipmi) Required() bool {
+	return d.isRequired()
+}
+
+// isExclusive indicates whether the IPMI interfaces can only be used by one instance at a time.
+func (d *ipmi) isExclusive() bool {
+	// Defaults to exclusive.
+	return shared.IsTrueOrEmpty(d.config["exclusive"])
+}
+
+// configRules returns the rules that the supplied config is validated with.
+func (d *ipmi) configRules(instConf instance.ConfigReader) (map[string]func(value string) error, error) {
+	if !instanceSupported(instConf.Type(), instancetype.Container) {
+		return nil, ErrUnsupportedDevType
+	}
+
+	rules := map[string]func(string) error{
+		"index":     validate.Optional(validate.IsListOf(validate.IsUint8)),
+		"exclusive": validate.Optional(validate.IsBool),
+		"uid":       unixValidUserID,
+		"gid":       unixValidUserID,
+		"mode":      unixValidOctalFileMode,
+		"required":  validate.Optional(validate.IsBool),
+	}
+
+	return rules, nil
+}
+
+// validateConfig checks the supplied config for correctness.
+func (d *ipmi) validateConfig(instConf instance.ConfigReader) error {
+	rules, err := d.configRules(instConf)
+	if err != nil {
+		return err
+	}
+
+	err = d.config.Validate(rules)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// indexes returns the indexes of the IPMI interfaces to pass into the instance.
+func (d *ipmi) indexes() []int {
+	if d.config["index"] == "" {
+		return []int{0}
+	}
+
+	indexes := []int{}
+	for _, value := range strings.Split(d.config["index"], ",") {
+		index, err := strconv.Atoi(strings.TrimSpace(value))
+		if err != nil {
+			continue // Validated by validateConfig.
+		}
+
+		if !shared.IntInSlice(index, indexes) {
+			indexes = append(indexes, index)
+		}
+	}
+
+	return indexes
+}
+
+// hostPath returns the path of the host device node of the IPMI interface along with its device numbers.
+func (d *ipmi) hostPath(index int) (string, uint32, uint32, error) {
+	for _, format := range ipmiHostPathFormats {
+		path := fmt.Sprintf(format, index)
+
+		dType, major, minor, err := unixDeviceAttributes(path)
+		if err == nil && dType == "c" {
+			return path, major, minor, nil
+		}
+	}
+
+	return "", 0, 0, fmt.Errorf("The host doesn't expose IPMI interface %d (is the ipmi_devintf kernel module loaded?)", index)
+}
+
+// Register is run after the device is started or when LXD starts.
+func (d *ipmi) Register() error {
+	if !d.isExclusive() {
+		return nil
+	}
+
+	// Restore the claims of running instances when LXD starts.
+	err := ipmiClaim(deviceRuntimeKey(d.inst.Project().Name, d.inst.Name(), d.name), d.indexes())
+	if err != nil {
+		d.logger.Warn("Failed restoring exclusive use of IPMI interfaces", logger.Ctx{"err": err})
+	}
+
+	return nil
+}
+
+// Start is run when the device is added to a running instance or instance is starting up.
+func (d *ipmi) Start() (*deviceConfig.RunConfig, error) {
+	revert := revert.New()
+	defer revert.Fail()
+
+	key := deviceRuntimeKey(d.inst.Project().Name, d.inst.Name(), d.name)
+	indexes := []int{}
+
+	for _, index := range d.indexes() {
+		_, _, _, err := d.hostPath(index)
+		if err != nil {
+			if d.isRequired() {
+				return nil, err
+			}
+
+			d.logger.Warn("Skipping unavailable IPMI interface", logger.Ctx{"index": index, "err": err})
+			continue
+		}
+
+		indexes = append(indexes, index)
+	}
+
+	if d.isExclusive() {
+		err := ipmiClaim(key, indexes)
+		if err != nil {
+			return nil, err
+		}
+
+		revert.Add(func() { ipmiRelease(key) })
+	}
+
+	runConf := deviceConfig.RunConfig{}
+	runConf.PostHooks = []func() error{d.Register}
+
+	revert.Add(func() { _ = unixDeviceDeleteFiles(d.state, d.inst.DevicesPath(), "unix", d.name, "") })
+
+	for _, index := range indexes {
+		_, major, minor, err := d.hostPath(index)
+		if err != nil {
+			return nil, err
+		}
+
+		// Use the kernel's naming inside the instance, whichever path the host uses.
+		err = unixDeviceSetupCharNum(d.state, d.inst.DevicesPath(), "unix", d.name, d.config, major, minor, fmt.Sprintf("/dev/ipmi%d", index), true, &runConf)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	revert.Success()
+	return &runConf, nil
+}
+
+// Stop is run when the device is removed from the instance.
+func (d *ipmi) Stop() (*deviceConfig.RunConfig, error) {
+	runConf := deviceConfig.RunConfig{
+		PostHooks: []func() error{d.postStop},
+	}
+
+	err := unixDeviceRemove(d.inst.DevicesPath(), "unix", d.name, "", &runConf)
+	if err != nil {
+		return nil, err
+	}
+
+	return &runConf, nil
+}
+
+// postStop is run after the device is removed from the instance.
+func (d *ipmi) postStop() error {
+	ipmiRelease(deviceRuntimeKey(d.inst.Project().Name, d.inst.Name(), d.name))
+
+	// Remove host files for this device.
+	err := unixDeviceDeleteFiles(d.state, d.inst.DevicesPath(), "unix", d.name, "")
+	if err != nil {
+		return fmt.Errorf("Failed to delete files for device '%s': %w", d.name, err)
+	}
+
+	return nil
+}
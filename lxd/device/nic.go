@@ -5,6 +5,7 @@ import (
 	"strings"
 
 	"github.com/lxc/lxd/lxd/instance"
+	"github.com/lxc/lxd/lxd/instance/instancetype"
 	"github.com/lxc/lxd/lxd/network/acl"
 	"github.com/lxc/lxd/shared"
 	"github.com/lxc/lxd/shared/validate"
@@ -32,6 +33,7 @@ func nicValidationRules(requiredFields []string, optionalFields []string, instCo
 		"security.port_isolation":              validate.Optional(validate.IsBool),
 		"maas.subnet.ipv4":                     validate.IsAny,
 		"maas.subnet.ipv6":                     validate.IsAny,
+		"netns":                                validate.Optional(networkValidNetnsName, func(_ string) error { return nicCheckNetnsSupported(instConf) }),
 		"ipv4.address":                         validate.Optional(validate.IsNetworkAddressV4),
 		"ipv6.address":                         validate.Optional(validate.IsNetworkAddressV6),
 		"ipv4.routes":                          validate.Optional(validate.IsListOf(validate.IsNetworkV4)),
@@ -125,6 +127,15 @@ func nicCheckNamesUnique(instConf instance.ConfigReader) error {
 	return nil
 }
 
+// nicCheckNetnsSupported checks that the instance supports placing NICs in a named network namespace.
+func nicCheckNetnsSupported(instConf instance.ConfigReader) error {
+	if instConf.Type() != instancetype.Container {
+		return fmt.Errorf("Network namespace selection is only supported for containers")
+	}
+
+	return nil
+}
+
 // nicCheckDNSNameConflict returns if instNameA matches instNameB (case insensitive).
 func nicCheckDNSNameConflict(instNameA string, instNameB string) bool {
 	return strings.EqualFold(instNameA, instNameB)
@@ -57,15 +57,15 @@ func (d *nicBridged) CanMigrate() bool {
 	return d.config["network"] != ""
 }
 
-// validateConfig checks the supplied config for correctness.
-func (d *nicBridged) validateConfig(instConf instance.ConfigReader) error {
+// configRules returns the rules that the supplied config is validated with.
+func (d *nicBridged) configRules(instConf instance.ConfigReader) (map[string]func(value string) error, error) {
 	if !instanceSupported(instConf.Type(), instancetype.Container, instancetype.VM) {
-		return ErrUnsupportedDevType
+		return nil, ErrUnsupportedDevType
 	}
 
-	var requiredFields []string
 	optionalFields := []string{
 		"name",
+		"netns",
 		"network",
 		"parent",
 		"mtu",
@@ -90,6 +90,73 @@ func (d *nicBridged) validateConfig(instConf instance.ConfigReader) error {
 		"vlan",
 	}
 
+	// If no network property supplied, then parent property is required.
+	requiredFields := []string{"parent"}
+	if d.config["network"] != "" {
+		requiredFields = []string{"network"}
+	}
+
+	rules := nicValidationRules(requiredFields, optionalFields, instConf)
+
+	// Add bridge specific vlan validation.
+	rules["vlan"] = func(value string) error {
+		if value == "" || value == "none" {
+			return nil
+		}
+
+		return validate.IsNetworkVLAN(value)
+	}
+
+	// Add bridge specific vlan.tagged validation.
+	rules["vlan.tagged"] = func(value string) error {
+		if value == "" {
+			return nil
+		}
+
+		// Check that none of the supplied VLAN IDs are the same as the untagged VLAN ID.
+		for _, vlanID := range shared.SplitNTrimSpace(value, ",", -1, true) {
+			if vlanID == d.config["vlan"] {
+				return fmt.Errorf("Tagged VLAN ID %q cannot be the same as untagged VLAN ID", vlanID)
+			}
+
+			_, _, err := validate.ParseNetworkVLANRange(vlanID)
+			if err != nil {
+				return err
+			}
+		}
+
+		return nil
+	}
+
+	// Add bridge specific ipv4/ipv6 validation rules
+	rules["ipv4.address"] = func(value string) error {
+		if value == "" || value == "none" {
+			return nil
+		}
+
+		return validate.IsNetworkAddressV4(value)
+	}
+
+	rules["ipv6.address"] = func(value string) error {
+		if value == "" || value == "none" {
+			return nil
+		}
+
+		return validate.IsNetworkAddressV6(value)
+	}
+
+	return rules, nil
+}
+
+// validateConfig checks the supplied config for correctness.
+func (d *nicBridged) validateConfig(instConf instance.ConfigReader) error {
+	rules, err := d.configRules(instConf)
+	if err != nil {
+		return err
+	}
+
+	d.config.Normalise()
+
 	// checkWithManagedNetwork validates the device's settings against the managed network.
 	checkWithManagedNetwork := func(n network.Network) error {
 		if n.Status() != api.NetworkStatusCreated {
@@ -199,8 +266,6 @@ func (d *nicBridged) validateConfig(instConf instance.ConfigReader) error {
 
 	// Check that if network proeperty is set that conflicting keys are not present.
 	if d.config["network"] != "" {
-		requiredFields = append(requiredFields, "network")
-
 		bannedKeys := []string{"nictype", "parent", "mtu", "maas.subnet.ipv4", "maas.subnet.ipv6"}
 		for _, bannedKey := range bannedKeys {
 			if d.config[bannedKey] != "" {
@@ -241,9 +306,6 @@ func (d *nicBridged) validateConfig(instConf instance.ConfigReader) error {
 			}
 		}
 	} else {
-		// If no network property supplied, then parent property is required.
-		requiredFields = append(requiredFields, "parent")
-
 		// Check if parent is a managed network.
 		// project.Default is used here as bridge networks don't support projects.
 		d.network, _ = network.LoadByName(d.state, project.Default, d.config["parent"])
@@ -296,57 +358,8 @@ func (d *nicBridged) validateConfig(instConf instance.ConfigReader) error {
 		}
 	}
 
-	rules := nicValidationRules(requiredFields, optionalFields, instConf)
-
-	// Add bridge specific vlan validation.
-	rules["vlan"] = func(value string) error {
-		if value == "" || value == "none" {
-			return nil
-		}
-
-		return validate.IsNetworkVLAN(value)
-	}
-
-	// Add bridge specific vlan.tagged validation.
-	rules["vlan.tagged"] = func(value string) error {
-		if value == "" {
-			return nil
-		}
-
-		// Check that none of the supplied VLAN IDs are the same as the untagged VLAN ID.
-		for _, vlanID := range shared.SplitNTrimSpace(value, ",", -1, true) {
-			if vlanID == d.config["vlan"] {
-				return fmt.Errorf("Tagged VLAN ID %q cannot be the same as untagged VLAN ID", vlanID)
-			}
-
-			_, _, err := validate.ParseNetworkVLANRange(vlanID)
-			if err != nil {
-				return err
-			}
-		}
-
-		return nil
-	}
-
-	// Add bridge specific ipv4/ipv6 validation rules
-	rules["ipv4.address"] = func(value string) error {
-		if value == "" || value == "none" {
-			return nil
-		}
-
-		return validate.IsNetworkAddressV4(value)
-	}
-
-	rules["ipv6.address"] = func(value string) error {
-		if value == "" || value == "none" {
-			return nil
-		}
-
-		return validate.IsNetworkAddressV6(value)
-	}
-
 	// Now run normal validation.
-	err := d.config.Validate(rules)
+	err = d.config.Validate(rules)
 	if err != nil {
 		return err
 	}
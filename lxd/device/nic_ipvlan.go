@@ -27,15 +27,16 @@ func (d *nicIPVLAN) CanHotPlug() bool {
 	return false
 }
 
-// validateConfig checks the supplied config for correctness.
-func (d *nicIPVLAN) validateConfig(instConf instance.ConfigReader) error {
+// configRules returns the rules that the supplied config is validated with.
+func (d *nicIPVLAN) configRules(instConf instance.ConfigReader) (map[string]func(value string) error, error) {
 	if !instanceSupported(instConf.Type(), instancetype.Container) {
-		return ErrUnsupportedDevType
+		return nil, ErrUnsupportedDevType
 	}
 
 	requiredFields := []string{"parent"}
 	optionalFields := []string{
 		"name",
+		"netns",
 		"mtu",
 		"hwaddr",
 		"vlan",
@@ -126,7 +127,19 @@ func (d *nicIPVLAN) validateConfig(instConf instance.ConfigReader) error {
 		rules["ipv6.gateway"] = validate.Optional(validate.IsNetworkAddressV6)
 	}
 
-	err := d.config.Validate(rules)
+	return rules, nil
+}
+
+// validateConfig checks the supplied config for correctness.
+func (d *nicIPVLAN) validateConfig(instConf instance.ConfigReader) error {
+	rules, err := d.configRules(instConf)
+	if err != nil {
+		return err
+	}
+
+	d.config.Normalise()
+
+	err = d.config.Validate(rules)
 	if err != nil {
 		return err
 	}
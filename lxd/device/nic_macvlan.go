@@ -31,15 +31,15 @@ func (d *nicMACVLAN) CanMigrate() bool {
 	return d.config["network"] != ""
 }
 
-// validateConfig checks the supplied config for correctness.
-func (d *nicMACVLAN) validateConfig(instConf instance.ConfigReader) error {
+// configRules returns the rules that the supplied config is validated with.
+func (d *nicMACVLAN) configRules(instConf instance.ConfigReader) (map[string]func(value string) error, error) {
 	if !instanceSupported(instConf.Type(), instancetype.Container, instancetype.VM) {
-		return ErrUnsupportedDevType
+		return nil, ErrUnsupportedDevType
 	}
 
-	var requiredFields []string
 	optionalFields := []string{
 		"name",
+		"netns",
 		"network",
 		"parent",
 		"mtu",
@@ -51,10 +51,26 @@ func (d *nicMACVLAN) validateConfig(instConf instance.ConfigReader) error {
 		"gvrp",
 	}
 
-	// Check that if network proeperty is set that conflicting keys are not present.
+	// If no network property supplied, then parent property is required.
+	requiredFields := []string{"parent"}
 	if d.config["network"] != "" {
-		requiredFields = append(requiredFields, "network")
+		requiredFields = []string{"network"}
+	}
 
+	return nicValidationRules(requiredFields, optionalFields, instConf), nil
+}
+
+// validateConfig checks the supplied config for correctness.
+func (d *nicMACVLAN) validateConfig(instConf instance.ConfigReader) error {
+	rules, err := d.configRules(instConf)
+	if err != nil {
+		return err
+	}
+
+	d.config.Normalise()
+
+	// Check that if network proeperty is set that conflicting keys are not present.
+	if d.config["network"] != "" {
 		bannedKeys := []string{"nictype", "parent", "mtu", "vlan", "maas.subnet.ipv4", "maas.subnet.ipv6", "gvrp"}
 		for _, bannedKey := range bannedKeys {
 			if d.config[bannedKey] != "" {
@@ -91,12 +107,9 @@ func (d *nicMACVLAN) validateConfig(instConf instance.ConfigReader) error {
 				d.config[inheritKey] = netConfig[inheritKey]
 			}
 		}
-	} else {
-		// If no network property supplied, then parent property is required.
-		requiredFields = append(requiredFields, "parent")
 	}
 
-	err := d.config.Validate(nicValidationRules(requiredFields, optionalFields, instConf))
+	err = d.config.Validate(rules)
 	if err != nil {
 		return err
 	}
@@ -63,10 +63,10 @@ func (d *nicOVN) UpdatableFields(oldDevice Type) []string {
 	return []string{"security.acls"}
 }
 
-// validateConfig checks the supplied config for correctness.
-func (d *nicOVN) validateConfig(instConf instance.ConfigReader) error {
+// configRules returns the rules that the supplied config is validated with.
+func (d *nicOVN) configRules(instConf instance.ConfigReader) (map[string]func(value string) error, error) {
 	if !instanceSupported(instConf.Type(), instancetype.Container, instancetype.VM) {
-		return ErrUnsupportedDevType
+		return nil, ErrUnsupportedDevType
 	}
 
 	requiredFields := []string{
@@ -75,6 +75,7 @@ func (d *nicOVN) validateConfig(instConf instance.ConfigReader) error {
 
 	optionalFields := []string{
 		"name",
+		"netns",
 		"hwaddr",
 		"host_name",
 		"mtu",
@@ -93,6 +94,18 @@ func (d *nicOVN) validateConfig(instConf instance.ConfigReader) error {
 		"acceleration",
 	}
 
+	return nicValidationRules(requiredFields, optionalFields, instConf), nil
+}
+
+// validateConfig checks the supplied config for correctness.
+func (d *nicOVN) validateConfig(instConf instance.ConfigReader) error {
+	rules, err := d.configRules(instConf)
+	if err != nil {
+		return err
+	}
+
+	d.config.Normalise()
+
 	// The NIC's network may be a non-default project, so lookup project and get network's project name.
 	networkProjectName, _, err := project.NetworkProject(d.state.DB.Cluster, instConf.Project().Name)
 	if err != nil {
@@ -186,8 +199,6 @@ func (d *nicOVN) validateConfig(instConf instance.ConfigReader) error {
 		}
 	}
 
-	rules := nicValidationRules(requiredFields, optionalFields, instConf)
-
 	// Now run normal validation.
 	err = d.config.Validate(rules)
 	if err != nil {
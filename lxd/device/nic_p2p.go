@@ -20,14 +20,15 @@ func (d *nicP2P) CanHotPlug() bool {
 	return true
 }
 
-// validateConfig checks the supplied config for correctness.
-func (d *nicP2P) validateConfig(instConf instance.ConfigReader) error {
+// configRules returns the rules that the supplied config is validated with.
+func (d *nicP2P) configRules(instConf instance.ConfigReader) (map[string]func(value string) error, error) {
 	if !instanceSupported(instConf.Type(), instancetype.Container, instancetype.VM) {
-		return ErrUnsupportedDevType
+		return nil, ErrUnsupportedDevType
 	}
 
 	optionalFields := []string{
 		"name",
+		"netns",
 		"mtu",
 		"hwaddr",
 		"host_name",
@@ -39,7 +40,19 @@ func (d *nicP2P) validateConfig(instConf instance.ConfigReader) error {
 		"boot.priority",
 	}
 
-	err := d.config.Validate(nicValidationRules([]string{}, optionalFields, instConf))
+	return nicValidationRules([]string{}, optionalFields, instConf), nil
+}
+
+// validateConfig checks the supplied config for correctness.
+func (d *nicP2P) validateConfig(instConf instance.ConfigReader) error {
+	rules, err := d.configRules(instConf)
+	if err != nil {
+		return err
+	}
+
+	d.config.Normalise()
+
+	err = d.config.Validate(rules)
 	if err != nil {
 		return err
 	}
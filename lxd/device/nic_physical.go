@@ -23,10 +23,10 @@ func (d *nicPhysical) CanHotPlug() bool {
 	return true
 }
 
-// validateConfig checks the supplied config for correctness.
-func (d *nicPhysical) validateConfig(instConf instance.ConfigReader) error {
+// configRules returns the rules that the supplied config is validated with.
+func (d *nicPhysical) configRules(instConf instance.ConfigReader) (map[string]func(value string) error, error) {
 	if !instanceSupported(instConf.Type(), instancetype.Container, instancetype.VM) {
-		return ErrUnsupportedDevType
+		return nil, ErrUnsupportedDevType
 	}
 
 	requiredFields := []string{"parent"}
@@ -42,7 +42,19 @@ func (d *nicPhysical) validateConfig(instConf instance.ConfigReader) error {
 		optionalFields = append(optionalFields, "mtu", "hwaddr", "vlan")
 	}
 
-	err := d.config.Validate(nicValidationRules(requiredFields, optionalFields, instConf))
+	return nicValidationRules(requiredFields, optionalFields, instConf), nil
+}
+
+// validateConfig checks the supplied config for correctness.
+func (d *nicPhysical) validateConfig(instConf instance.ConfigReader) error {
+	rules, err := d.configRules(instConf)
+	if err != nil {
+		return err
+	}
+
+	d.config.Normalise()
+
+	err = d.config.Validate(rules)
 	if err != nil {
 		return err
 	}
@@ -46,20 +46,16 @@ func (d *nicRouted) UpdatableFields(oldDevice Type) []string {
 	return []string{"limits.ingress", "limits.egress", "limits.max"}
 }
 
-// validateConfig checks the supplied config for correctness.
-func (d *nicRouted) validateConfig(instConf instance.ConfigReader) error {
+// configRules returns the rules that the supplied config is validated with.
+func (d *nicRouted) configRules(instConf instance.ConfigReader) (map[string]func(value string) error, error) {
 	if !instanceSupported(instConf.Type(), instancetype.Container, instancetype.VM) {
-		return ErrUnsupportedDevType
-	}
-
-	err := d.isUniqueWithGatewayAutoMode(instConf)
-	if err != nil {
-		return err
+		return nil, ErrUnsupportedDevType
 	}
 
 	requiredFields := []string{}
 	optionalFields := []string{
 		"name",
+		"netns",
 		"parent",
 		"mtu",
 		"hwaddr",
@@ -86,6 +82,23 @@ func (d *nicRouted) validateConfig(instConf instance.ConfigReader) error {
 	rules["ipv4.neighbor_probe"] = validate.Optional(validate.IsBool)
 	rules["ipv6.neighbor_probe"] = validate.Optional(validate.IsBool)
 
+	return rules, nil
+}
+
+// validateConfig checks the supplied config for correctness.
+func (d *nicRouted) validateConfig(instConf instance.ConfigReader) error {
+	rules, err := d.configRules(instConf)
+	if err != nil {
+		return err
+	}
+
+	d.config.Normalise()
+
+	err = d.isUniqueWithGatewayAutoMode(instConf)
+	if err != nil {
+		return err
+	}
+
 	err = d.config.Validate(rules)
 	if err != nil {
 		return err
@@ -30,13 +30,12 @@ func (d *nicSRIOV) CanMigrate() bool {
 	return d.config["network"] != ""
 }
 
-// validateConfig checks the supplied config for correctness.
-func (d *nicSRIOV) validateConfig(instConf instance.ConfigReader) error {
+// configRules returns the rules that the supplied config is validated with.
+func (d *nicSRIOV) configRules(instConf instance.ConfigReader) (map[string]func(value string) error, error) {
 	if !instanceSupported(instConf.Type(), instancetype.Container, instancetype.VM) {
-		return ErrUnsupportedDevType
+		return nil, ErrUnsupportedDevType
 	}
 
-	var requiredFields []string
 	optionalFields := []string{
 		"name",
 		"network",
@@ -49,10 +48,31 @@ func (d *nicSRIOV) validateConfig(instConf instance.ConfigReader) error {
 		"boot.priority",
 	}
 
-	// Check that if network proeperty is set that conflicting keys are not present.
+	// If no network property supplied, then parent property is required.
+	requiredFields := []string{"parent"}
 	if d.config["network"] != "" {
-		requiredFields = append(requiredFields, "network")
+		requiredFields = []string{"network"}
+	}
 
+	// For VMs only NIC properties that can be specified on the parent's VF settings are controllable.
+	if instConf.Type() == instancetype.Container || instConf.Type() == instancetype.Any {
+		optionalFields = append(optionalFields, "mtu")
+	}
+
+	return nicValidationRules(requiredFields, optionalFields, instConf), nil
+}
+
+// validateConfig checks the supplied config for correctness.
+func (d *nicSRIOV) validateConfig(instConf instance.ConfigReader) error {
+	rules, err := d.configRules(instConf)
+	if err != nil {
+		return err
+	}
+
+	d.config.Normalise()
+
+	// Check that if network proeperty is set that conflicting keys are not present.
+	if d.config["network"] != "" {
 		bannedKeys := []string{"nictype", "parent", "mtu", "vlan", "maas.subnet.ipv4", "maas.subnet.ipv6"}
 		for _, bannedKey := range bannedKeys {
 			if d.config[bannedKey] != "" {
@@ -89,17 +109,9 @@ func (d *nicSRIOV) validateConfig(instConf instance.ConfigReader) error {
 				d.config[inheritKey] = netConfig[inheritKey]
 			}
 		}
-	} else {
-		// If no network property supplied, then parent property is required.
-		requiredFields = append(requiredFields, "parent")
-	}
-
-	// For VMs only NIC properties that can be specified on the parent's VF settings are controllable.
-	if instConf.Type() == instancetype.Container || instConf.Type() == instancetype.Any {
-		optionalFields = append(optionalFields, "mtu")
 	}
 
-	err := d.config.Validate(nicValidationRules(requiredFields, optionalFields, instConf))
+	err = d.config.Validate(rules)
 	if err != nil {
 		return err
 	}
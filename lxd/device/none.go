@@ -14,11 +14,21 @@ func (d *none) CanMigrate() bool {
 	return true
 }
 
-// validateConfig checks the supplied config for correctness.
+// configRules returns the rules that the supplied config is validated with.
+func (d *none) configRules(instConf instance.ConfigReader) (map[string]func(value string) error, error) {
+	rules := map[string]func(string) error{} // No fields allowed.
+
+	return rules, nil
+}
+
 // validateConfig checks the supplied config for correctness.
 func (d *none) validateConfig(instConf instance.ConfigReader) error {
-	rules := map[string]func(string) error{} // No fields allowed.
-	err := d.config.Validate(rules)
+	rules, err := d.configRules(instConf)
+	if err != nil {
+		return err
+	}
+
+	err = d.config.Validate(rules)
 	if err != nil {
 		return err
 	}
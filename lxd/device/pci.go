@@ -1,58 +1,167 @@
 package device
 
 import (
+	"errors"
 	"fmt"
+	"io/fs"
+	"os"
 	"path/filepath"
+	"strconv"
+	"time"
+
+	"golang.org/x/sys/unix"
 
 	deviceConfig "github.com/lxc/lxd/lxd/device/config"
 	pcidev "github.com/lxc/lxd/lxd/device/pci"
 	"github.com/lxc/lxd/lxd/instance"
 	"github.com/lxc/lxd/lxd/instance/instancetype"
+	"github.com/lxc/lxd/lxd/revert"
 	"github.com/lxc/lxd/lxd/util"
 	"github.com/lxc/lxd/shared"
+	"github.com/lxc/lxd/shared/api"
+	"github.com/lxc/lxd/shared/logger"
 	"github.com/lxc/lxd/shared/validate"
 )
 
+// pciRebindAttemptsDefault is the default number of attempts made to rebind a device to its host driver.
+const pciRebindAttemptsDefault = 5
+
+// pciRebindAttemptsMax is the maximum number of attempts made to rebind a device to its host driver. As the
+// instance stop waits for the rebind, this bounds the wait to about 30s.
+const pciRebindAttemptsMax = 10
+
+// pciRebindInterval is the base delay between host driver rebind attempts, multiplied by the attempt number.
+const pciRebindInterval = 500 * time.Millisecond
+
 type pci struct {
 	deviceCommon
 }
 
-// validateConfig checks the supplied config for correctness.
-func (d *pci) validateConfig(instConf instance.ConfigReader) error {
-	if !instanceSupported(instConf.Type(), instancetype.VM) {
-		return ErrUnsupportedDevType
+// configRules returns the rules that the supplied config is validated with.
+func (d *pci) configRules(instConf instance.ConfigReader) (map[string]func(value string) error, error) {
+	if !instanceSupported(instConf.Type(), instancetype.Container, instancetype.VM) {
+		return nil, ErrUnsupportedDevType
 	}
 
 	rules := map[string]func(string) error{
-		"address": validate.IsPCIAddress,
+		"address":         validate.Optional(validate.IsPCIAddress),
+		"label":           validate.Optional(validLabel),
+		"required":        validate.Optional(validate.IsBool),
+		"rebind.attempts": validate.Optional(validate.IsInRange(1, pciRebindAttemptsMax)),
+		"quarantine":      validate.Optional(validate.IsBool),
+	}
+
+	// Ownership and mode of the VFIO device nodes only apply to containers.
+	if instConf.Type() != instancetype.VM {
+		rules["uid"] = unixValidUserID
+		rules["gid"] = unixValidUserID
+		rules["mode"] = unixValidOctalFileMode
+	}
+
+	return rules, nil
+}
+
+// validateConfig checks the supplied config for correctness.
+func (d *pci) validateConfig(instConf instance.ConfigReader) error {
+	rules, err := d.configRules(instConf)
+	if err != nil {
+		return err
 	}
 
-	err := d.config.Validate(rules)
+	d.config.Normalise()
+
+	err = d.config.Validate(rules)
 	if err != nil {
 		return fmt.Errorf("Failed to validate config: %w", err)
 	}
 
-	d.config["address"] = pcidev.NormaliseAddress(d.config["address"])
+	// The device is selected either by its address or by its label.
+	if d.config["label"] != "" {
+		if d.config["address"] != "" {
+			return fmt.Errorf(`Cannot use "address" when "label" is set`)
+		}
+
+		return nil
+	}
+
+	if d.config["address"] == "" {
+		return fmt.Errorf(`Either "address" or "label" must be set`)
+	}
+
+	if d.config["required"] != "" {
+		return fmt.Errorf(`The "required" property requires "label" to be set`)
+	}
 
 	return nil
 }
 
+// CheckResources checks that the PCI device is present in the hardware view of a host.
+// Devices selected by label are resolved from the inventory of the host that the device starts on, so aren't
+// checked.
+func (d *pci) CheckResources(res *api.Resources) error {
+	if d.config["address"] == "" {
+		return nil
+	}
+
+	address := pcidev.NormaliseAddress(d.config["address"])
+	for _, pciDev := range res.PCI.Devices {
+		if pciDev.PCIAddress == address {
+			return nil
+		}
+	}
+
+	return fmt.Errorf("PCI device %q isn't present", address)
+}
+
 // validateEnvironment checks if the PCI device is available.
 func (d *pci) validateEnvironment() error {
 	if d.inst.Type() == instancetype.VM && shared.IsTrue(d.inst.ExpandedConfig()["migration.stateful"]) {
 		return fmt.Errorf("PCI devices cannot be used when migration.stateful is enabled")
 	}
 
-	return validatePCIDevice(d.config["address"])
+	err := validatePCIDevice(d.config["address"])
+	if err != nil {
+		return err
+	}
+
+	// Check the device belongs to an IOMMU group, as this is required for VFIO to work.
+	_, err = pcidev.DeviceIOMMUGroup(d.config["address"])
+	if err != nil {
+		if os.IsNotExist(err) {
+			return fmt.Errorf("PCI device %q is not in an IOMMU group (ensure IOMMU is enabled in the firmware and kernel)", d.config["address"])
+		}
+
+		return fmt.Errorf("Failed getting IOMMU group for PCI device %q: %w", d.config["address"], err)
+	}
+
+	return nil
 }
 
 // Start is run when the device is added to the instance.
 func (d *pci) Start() (*deviceConfig.RunConfig, error) {
+	// Select the device by its address from the label.
+	if d.config["label"] != "" {
+		address, err := labelResolvePCI(d.config["label"])
+		if err != nil {
+			if errors.Is(err, ErrDeviceNotFound) && shared.IsFalse(d.config["required"]) {
+				d.logger.Warn("Skipping PCI device as no host device with its label is present", logger.Ctx{"label": d.config["label"]})
+				return &deviceConfig.RunConfig{}, nil
+			}
+
+			return nil, err
+		}
+
+		d.config["address"] = address
+	}
+
 	err := d.validateEnvironment()
 	if err != nil {
 		return nil, fmt.Errorf("Failed to validate environment: %w", err)
 	}
 
+	revert := revert.New()
+	defer revert.Fail()
+
 	runConf := deviceConfig.RunConfig{}
 	saveData := make(map[string]string)
 
@@ -78,18 +187,77 @@ func (d *pci) Start() (*deviceConfig.RunConfig, error) {
 		return nil, fmt.Errorf("Failed to override IOMMU group driver: %w", err)
 	}
 
+	revert.Add(func() {
+		_ = pcidev.DeviceDriverOverride(pcidev.Device{Driver: "vfio-pci", SlotName: pciDev.SlotName}, pciDev.Driver)
+	})
+
+	err = d.volatileSet(saveData)
+	if err != nil {
+		return nil, err
+	}
+
+	if d.inst.Type() == instancetype.Container {
+		err = d.startContainer(pciDev, &runConf)
+		if err != nil {
+			return nil, err
+		}
+
+		revert.Success()
+		return &runConf, nil
+	}
+
 	runConf.PCIDevice = append(runConf.PCIDevice,
 		[]deviceConfig.RunConfigItem{
 			{Key: "devName", Value: d.name},
 			{Key: "pciSlotName", Value: saveData["last_state.pci.slot.name"]},
 		}...)
 
-	err = d.volatileSet(saveData)
+	revert.Success()
+	return &runConf, nil
+}
+
+// startContainer passes the VFIO container and group device nodes for the PCI device into the
+// container so that userspace drivers can access the device.
+func (d *pci) startContainer(pciDev pcidev.Device, runConf *deviceConfig.RunConfig) error {
+	iommuGroup, err := pcidev.DeviceIOMMUGroup(pciDev.SlotName)
 	if err != nil {
-		return nil, err
+		return fmt.Errorf("Failed getting IOMMU group for PCI device %q: %w", pciDev.SlotName, err)
 	}
 
-	return &runConf, nil
+	vfioPaths := []string{"/dev/vfio/vfio", fmt.Sprintf("/dev/vfio/%d", iommuGroup)}
+
+	for _, vfioPath := range vfioPaths {
+		// The VFIO group device node is created asynchronously after binding to vfio-pci.
+		err = d.waitPath(vfioPath)
+		if err != nil {
+			return err
+		}
+
+		_, major, minor, err := unixDeviceAttributes(vfioPath)
+		if err != nil {
+			return fmt.Errorf("Failed getting device attributes for %q: %w", vfioPath, err)
+		}
+
+		err = unixDeviceSetupCharNum(d.state, d.inst.DevicesPath(), "unix", d.name, d.config, major, minor, vfioPath, true, runConf)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// waitPath waits for a device node to appear on the host.
+func (d *pci) waitPath(devPath string) error {
+	for i := 0; i < 20; i++ {
+		if shared.PathExists(devPath) {
+			return nil
+		}
+
+		time.Sleep(50 * time.Millisecond)
+	}
+
+	return fmt.Errorf("Device node %q took too long to appear", devPath)
 }
 
 // Stop is run when the device is removed from the instance.
@@ -98,6 +266,13 @@ func (d *pci) Stop() (*deviceConfig.RunConfig, error) {
 		PostHooks: []func() error{d.postStop},
 	}
 
+	if d.inst.Type() == instancetype.Container {
+		err := unixDeviceRemove(d.inst.DevicesPath(), "unix", d.name, "", &runConf)
+		if err != nil {
+			return nil, err
+		}
+	}
+
 	return &runConf, nil
 }
 
@@ -112,6 +287,14 @@ func (d *pci) postStop() error {
 
 	v := d.volatileGet()
 
+	if d.inst.Type() == instancetype.Container {
+		// Remove host files for this device.
+		err := unixDeviceDeleteFiles(d.state, d.inst.DevicesPath(), "unix", d.name, "")
+		if err != nil {
+			return fmt.Errorf("Failed to delete files for device '%s': %w", d.name, err)
+		}
+	}
+
 	// Unbind from vfio-pci and bind back to host driver.
 	if v["last_state.pci.slot.name"] != "" {
 		pciDev := pcidev.Device{
@@ -119,11 +302,73 @@ func (d *pci) postStop() error {
 			SlotName: v["last_state.pci.slot.name"],
 		}
 
-		err := pcidev.DeviceDriverOverride(pciDev, v["last_state.pci.driver"])
+		// Leave a quarantined device unbound from all drivers until it is released, so that failing hardware
+		// can be inspected or replaced without another instance or the host using it.
+		if shared.IsTrue(d.config["quarantine"]) {
+			err := quarantineAdd(api.ResourcesQuarantinedDevice{
+				PCIAddress:    pciDev.SlotName,
+				Driver:        v["last_state.pci.driver"],
+				Project:       d.inst.Project().Name,
+				Instance:      d.inst.Name(),
+				Device:        d.name,
+				QuarantinedAt: time.Now().UTC(),
+			})
+			if err != nil {
+				d.logger.Warn("Failed quarantining device", logger.Ctx{"pciSlotName": pciDev.SlotName, "err": err})
+			} else {
+				d.logger.Info("Quarantined device until it is released", logger.Ctx{"pciSlotName": pciDev.SlotName})
+			}
+
+			return nil
+		}
+
+		// Don't fail the stop if the rebind ultimately fails, as that would leave the instance in a
+		// half-stopped state, but warn so that the unbound device can be investigated.
+		err := d.rebindHostDriver(pciDev, v["last_state.pci.driver"])
 		if err != nil {
-			return err
+			d.logger.Warn("Failed rebinding device to host driver", logger.Ctx{"pciSlotName": pciDev.SlotName, "driver": v["last_state.pci.driver"], "err": err})
 		}
 	}
 
 	return nil
 }
+
+// rebindHostDriver rebinds the device to the specified host driver, retrying up to the configured number of
+// attempts as the rebind can transiently fail if the device is still being reset.
+func (d *pci) rebindHostDriver(pciDev pcidev.Device, driver string) error {
+	attempts := pciRebindAttemptsDefault
+	if d.config["rebind.attempts"] != "" {
+		var err error
+		attempts, err = strconv.Atoi(d.config["rebind.attempts"])
+		if err != nil {
+			return fmt.Errorf("Invalid rebind.attempts: %w", err)
+		}
+	}
+
+	var err error
+	for i := 1; i <= attempts; i++ {
+		err = pcidev.DeviceDriverOverride(pciDev, driver)
+		if err == nil {
+			return nil
+		}
+
+		d.logger.Debug("Failed rebinding device to host driver", logger.Ctx{"pciSlotName": pciDev.SlotName, "driver": driver, "attempt": i, "attempts": attempts, "err": err})
+
+		// Retrying won't help if the device or driver is gone or the rebind isn't allowed.
+		if !pciRebindErrorTransient(err) {
+			return err
+		}
+
+		if i < attempts {
+			time.Sleep(time.Duration(i) * pciRebindInterval)
+		}
+	}
+
+	return fmt.Errorf("Failed after %d attempts: %w", attempts, err)
+}
+
+// pciRebindErrorTransient returns whether a host driver rebind failure may succeed when retried, such as when
+// the device is busy being reset.
+func pciRebindErrorTransient(err error) bool {
+	return !errors.Is(err, fs.ErrNotExist) && !errors.Is(err, fs.ErrPermission) && !errors.Is(err, unix.EINVAL)
+}
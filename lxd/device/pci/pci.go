@@ -9,6 +9,7 @@ import (
 	"strings"
 	"time"
 
+	deviceConfig "github.com/lxc/lxd/lxd/device/config"
 	"github.com/lxc/lxd/lxd/revert"
 	"github.com/lxc/lxd/shared"
 )
@@ -160,17 +161,7 @@ func deviceProbeWait(pciDev Device) error {
 
 // NormaliseAddress converts common PCI address notation to the kernel's notation.
 func NormaliseAddress(addr string) string {
-	// PCI devices can be specified as "0000:XX:XX.X" or "XX:XX.X".
-	// However, the devices in /sys/bus/pci/devices use the long format which
-	// is why we need to make sure the prefix is present.
-	if len(addr) == 7 {
-		addr = fmt.Sprintf("0000:%s", addr)
-	}
-
-	// Ensure all addresses are lowercase.
-	addr = strings.ToLower(addr)
-
-	return addr
+	return deviceConfig.NormalisePCIAddress(addr)
 }
 
 // DeviceIOMMUGroup returns the IOMMU group for a PCI device.
@@ -17,6 +17,10 @@ func TestNormaliseAddress(t *testing.T) {
 		"0000:AB:00.0": "0000:ab:00.0",
 		"1000:AB:00.0": "1000:ab:00.0",
 		"00:AB.0":      "0000:00:ab.0",
+		"0:1:0.0":      "0000:01:00.0",
+		"0000:1:2.3":   "0000:01:02.3",
+		" 00:01.0 ":    "0000:00:01.0",
+		"invalid":      "invalid",
 	}
 
 	for k, v := range cases {
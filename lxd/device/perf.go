@@ -0,0 +1,303 @@
+package device
+
+import (
+	"fmt"
+	"math"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	deviceConfig "github.com/lxc/lxd/lxd/device/config"
+	"github.com/lxc/lxd/lxd/instance"
+	"github.com/lxc/lxd/lxd/instance/instancetype"
+	"github.com/lxc/lxd/lxd/revert"
+	"github.com/lxc/lxd/lxd/util"
+	"github.com/lxc/lxd/shared"
+	"github.com/lxc/lxd/shared/logger"
+	"github.com/lxc/lxd/shared/validate"
+)
+
+// perfMSRDevGlob matches the per-CPU model-specific register device nodes.
+const perfMSRDevGlob = "/dev/cpu/[0-9]*/msr"
+
+// perfEventParanoidSysctl is the sysctl controlling unprivileged access to perf events.
+const perfEventParanoidSysctl = "kernel/perf_event_paranoid"
+
+type perf struct {
+	deviceCommon
+}
+
+// configRules returns the rules that the supplied config is validated with.
+func (d *perf) configRules(instConf instance.ConfigReader) (map[string]func(value string) error, error) {
+	if !instanceSupported(instConf.Type(), instancetype.Container) {
+		return nil, ErrUnsupportedDevType
+	}
+
+	rules := map[string]func(string) error{
+		"security.acknowledged": validate.Optional(validate.IsBool),
+		"msr":                   validate.Optional(validate.IsBool),
+		"perf.paranoid":         validate.Optional(validate.IsInRange(-1, 2)),
+		"uid":                   unixValidUserID,
+		"gid":                   unixValidUserID,
+		"mode":                  unixValidOctalFileMode,
+	}
+
+	return rules, nil
+}
+
+// validateConfig checks the supplied config for correctness.
+func (d *perf) validateConfig(instConf instance.ConfigReader) error {
+	rules, err := d.configRules(instConf)
+	if err != nil {
+		return err
+	}
+
+	err = d.config.Validate(rules)
+	if err != nil {
+		return err
+	}
+
+	// This device grants access to host hardware state and performance data that can be used to
+	// observe other workloads on the host, so require an explicit opt-in.
+	if shared.IsFalseOrEmpty(d.config["security.acknowledged"]) {
+		return fmt.Errorf(`Performance counter devices expose host CPU registers and performance data to the instance, which allows observing (and with MSR write access, affecting) other workloads on the host. Set "security.acknowledged=true" to accept this`)
+	}
+
+	if shared.IsFalse(d.config["msr"]) && d.config["perf.paranoid"] == "" {
+		return fmt.Errorf(`At least one of "msr" or "perf.paranoid" must be enabled`)
+	}
+
+	// Access to the MSR device nodes requires CAP_SYS_RAWIO in the initial user namespace.
+	if instConf.Type() == instancetype.Container && d.msrEnabled() && shared.IsFalseOrEmpty(instConf.ExpandedConfig()["security.privileged"]) {
+		return fmt.Errorf(`MSR access requires a privileged container ("security.privileged=true") as it needs CAP_SYS_RAWIO on the host, set "msr=false" to only allow perf access`)
+	}
+
+	return nil
+}
+
+// RequiredCapabilities returns the capabilities that processes in the container need to use the device.
+func (d *perf) RequiredCapabilities() []string {
+	if d.msrEnabled() {
+		return []string{"sys_rawio"}
+	}
+
+	return nil
+}
+
+// msrEnabled returns whether the MSR device nodes should be passed into the instance.
+func (d *perf) msrEnabled() bool {
+	// Defaults to enabled.
+	return !shared.IsFalse(d.config["msr"])
+}
+
+// validateEnvironment checks the runtime environment for correctness.
+func (d *perf) validateEnvironment() error {
+	if d.msrEnabled() {
+		// Load the msr module which creates the /dev/cpu/*/msr devices.
+		if !shared.PathExists("/dev/cpu/0/msr") {
+			err := util.LoadModule("msr")
+			if err != nil {
+				return fmt.Errorf("Failed to load kernel module %q: %w", "msr", err)
+			}
+		}
+
+		paths, err := filepath.Glob(perfMSRDevGlob)
+		if err != nil || len(paths) == 0 {
+			return fmt.Errorf("The host doesn't expose any MSR devices at %q", perfMSRDevGlob)
+		}
+	}
+
+	if d.config["perf.paranoid"] != "" {
+		_, err := util.SysctlGet(perfEventParanoidSysctl)
+		if err != nil {
+			return fmt.Errorf("The host doesn't support perf events: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// Start is run when the device is added to a running instance or instance is starting up.
+func (d *perf) Start() (*deviceConfig.RunConfig, error) {
+	err := d.validateEnvironment()
+	if err != nil {
+		return nil, err
+	}
+
+	revert := revert.New()
+	defer revert.Fail()
+
+	runConf := deviceConfig.RunConfig{}
+
+	if d.msrEnabled() {
+		paths, err := filepath.Glob(perfMSRDevGlob)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, path := range paths {
+			_, major, minor, err := unixDeviceAttributes(path)
+			if err != nil {
+				return nil, fmt.Errorf("Failed getting device attributes for %q: %w", path, err)
+			}
+
+			err = unixDeviceSetupCharNum(d.state, d.inst.DevicesPath(), "unix", d.name, d.config, major, minor, path, true, &runConf)
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		revert.Add(func() { _ = unixDeviceDeleteFiles(d.state, d.inst.DevicesPath(), "unix", d.name, "") })
+	}
+
+	if d.config["perf.paranoid"] != "" {
+		err = d.lowerPerfEventParanoid()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	revert.Success()
+	return &runConf, nil
+}
+
+// Register is run after the device is started or when LXD starts.
+func (d *perf) Register() error {
+	if d.config["perf.paranoid"] == "" {
+		return nil
+	}
+
+	wanted, err := strconv.Atoi(d.config["perf.paranoid"])
+	if err != nil {
+		return err
+	}
+
+	perfParanoidMu.Lock()
+	defer perfParanoidMu.Unlock()
+
+	current, err := perfEventParanoidGet()
+	if err != nil {
+		return err
+	}
+
+	// Record the use of the setting again when LXD starts, so that it isn't restored whilst still needed.
+	original, err := strconv.Atoi(d.volatileGet()["last_state.perf_event_paranoid"])
+	perfParanoidRestore(deviceRuntimeKey(d.inst.Project().Name, d.inst.Name(), d.name), wanted, original, err == nil, current)
+
+	return nil
+}
+
+// perfEventParanoidGet returns the current value of the host perf_event_paranoid setting.
+func perfEventParanoidGet() (int, error) {
+	value, err := util.SysctlGet(perfEventParanoidSysctl)
+	if err != nil {
+		return 0, err
+	}
+
+	current, err := strconv.Atoi(strings.TrimSpace(value))
+	if err != nil {
+		return 0, fmt.Errorf("Invalid %q value %q: %w", perfEventParanoidSysctl, value, err)
+	}
+
+	return current, nil
+}
+
+// lowerPerfEventParanoid lowers the host perf_event_paranoid setting to the configured level if needed,
+// recording the original value so it can be restored once no running device needs it lowered.
+// As this is a host-wide setting, it is never raised whilst any device uses it.
+func (d *perf) lowerPerfEventParanoid() error {
+	wanted, err := strconv.Atoi(d.config["perf.paranoid"])
+	if err != nil {
+		return err
+	}
+
+	perfParanoidMu.Lock()
+	defer perfParanoidMu.Unlock()
+
+	current, err := perfEventParanoidGet()
+	if err != nil {
+		return err
+	}
+
+	key := deviceRuntimeKey(d.inst.Project().Name, d.inst.Name(), d.name)
+	value, lower := perfParanoidAcquire(key, wanted, current)
+	if lower {
+		err = util.SysctlSet(perfEventParanoidSysctl, strconv.Itoa(value))
+		if err != nil {
+			perfParanoidRelease(key, current)
+			return fmt.Errorf("Failed setting %q: %w", perfEventParanoidSysctl, err)
+		}
+
+		perfParanoidWritten(current, value)
+		d.logger.Warn("Lowered host perf_event_paranoid for performance counter device", logger.Ctx{"previous": current, "value": value})
+	}
+
+	// Record the original value so that it can still be restored if LXD restarts whilst the device is running.
+	original, lowered := perfParanoidOriginal()
+	if !lowered {
+		return nil
+	}
+
+	return d.volatileSet(map[string]string{"last_state.perf_event_paranoid": strconv.Itoa(original)})
+}
+
+// restorePerfEventParanoid restores the host perf_event_paranoid setting lowered by LXD once no other running
+// device needs it lowered, unless it has been changed since LXD lowered it.
+func (d *perf) restorePerfEventParanoid() {
+	perfParanoidMu.Lock()
+	defer perfParanoidMu.Unlock()
+
+	current, err := perfEventParanoidGet()
+	if err != nil {
+		d.logger.Warn("Failed getting host perf_event_paranoid", logger.Ctx{"err": err})
+		current = math.MinInt
+	}
+
+	original, restore := perfParanoidRelease(deviceRuntimeKey(d.inst.Project().Name, d.inst.Name(), d.name), current)
+	if !restore {
+		return
+	}
+
+	err = util.SysctlSet(perfEventParanoidSysctl, strconv.Itoa(original))
+	if err != nil {
+		d.logger.Warn("Failed restoring host perf_event_paranoid", logger.Ctx{"value": original, "err": err})
+		return
+	}
+
+	d.logger.Info("Restored host perf_event_paranoid", logger.Ctx{"value": original})
+}
+
+// Stop is run when the device is removed from the instance.
+func (d *perf) Stop() (*deviceConfig.RunConfig, error) {
+	runConf := deviceConfig.RunConfig{
+		PostHooks: []func() error{d.postStop},
+	}
+
+	err := unixDeviceRemove(d.inst.DevicesPath(), "unix", d.name, "", &runConf)
+	if err != nil {
+		return nil, err
+	}
+
+	return &runConf, nil
+}
+
+// postStop is run after the device is removed from the instance.
+func (d *perf) postStop() error {
+	// Restore the host perf_event_paranoid setting if this was the last device needing it lowered.
+	if d.config["perf.paranoid"] != "" || d.volatileGet()["last_state.perf_event_paranoid"] != "" {
+		d.restorePerfEventParanoid()
+
+		err := d.volatileSet(map[string]string{"last_state.perf_event_paranoid": ""})
+		if err != nil {
+			return err
+		}
+	}
+
+	// Remove host files for this device.
+	err := unixDeviceDeleteFiles(d.state, d.inst.DevicesPath(), "unix", d.name, "")
+	if err != nil {
+		return fmt.Errorf("Failed to delete files for device '%s': %w", d.name, err)
+	}
+
+	return nil
+}
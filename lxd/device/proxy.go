@@ -48,6 +48,8 @@ type proxyProcInfo struct {
 	securityUID    string
 	securityGID    string
 	proxyProtocol  string
+	listenNetnsFd  string
+	connectNetnsFd string
 	inheritFds     []*os.File
 }
 
@@ -56,10 +58,10 @@ func (d *proxy) CanHotPlug() bool {
 	return true
 }
 
-// validateConfig checks the supplied config for correctness.
-func (d *proxy) validateConfig(instConf instance.ConfigReader) error {
+// configRules returns the rules that the supplied config is validated with.
+func (d *proxy) configRules(instConf instance.ConfigReader) (map[string]func(value string) error, error) {
 	if !instanceSupported(instConf.Type(), instancetype.Container, instancetype.VM) {
-		return ErrUnsupportedDevType
+		return nil, ErrUnsupportedDevType
 	}
 
 	validateAddr := func(input string) error {
@@ -88,9 +90,20 @@ func (d *proxy) validateConfig(instConf instance.ConfigReader) error {
 		"security.uid":   validate.Optional(unixValidUserID),
 		"security.gid":   validate.Optional(unixValidUserID),
 		"proxy_protocol": validate.Optional(validate.IsBool),
+		"netns":          validate.Optional(networkValidNetnsName),
 	}
 
-	err := d.config.Validate(rules)
+	return rules, nil
+}
+
+// validateConfig checks the supplied config for correctness.
+func (d *proxy) validateConfig(instConf instance.ConfigReader) error {
+	rules, err := d.configRules(instConf)
+	if err != nil {
+		return err
+	}
+
+	err = d.config.Validate(rules)
 	if err != nil {
 		return err
 	}
@@ -114,6 +127,10 @@ func (d *proxy) validateConfig(instConf instance.ConfigReader) error {
 		return fmt.Errorf("Mismatch between listen port(s) and connect port(s) count")
 	}
 
+	if d.config["netns"] != "" && shared.IsTrue(d.config["nat"]) {
+		return fmt.Errorf("Network namespace selection can't be used in NAT mode")
+	}
+
 	if shared.IsTrue(d.config["proxy_protocol"]) && (!strings.HasPrefix(d.config["connect"], "tcp") || shared.IsTrue(d.config["nat"])) {
 		return fmt.Errorf("The PROXY header can only be sent to tcp servers in non-nat mode")
 	}
@@ -235,6 +252,8 @@ func (d *proxy) Start() (*deviceConfig.RunConfig, error) {
 				proxyValues.securityGID,
 				proxyValues.securityUID,
 				proxyValues.proxyProtocol,
+				proxyValues.listenNetnsFd,
+				proxyValues.connectNetnsFd,
 			}
 
 			p, err := subprocess.NewProcess(command, forkproxyargs, logPath, logPath)
@@ -497,7 +516,29 @@ func (d *proxy) setupProxyProcInfo() (*proxyProcInfo, error) {
 		}
 	}
 
+	// Pass the selected network namespace inside the instance (if any) to the instance side of the proxy.
+	netnsFd := -1
+	if d.config["netns"] != "" {
+		netnsFile, err := os.Open(NetworkNamespacePath(cc.InitPid(), d.config["netns"]))
+		if err != nil {
+			for _, file := range inheritFd {
+				_ = file.Close()
+			}
+
+			if os.IsNotExist(err) {
+				return nil, fmt.Errorf("Network namespace %q doesn't exist in the instance", d.config["netns"])
+			}
+
+			return nil, fmt.Errorf("Failed opening network namespace %q: %w", d.config["netns"], err)
+		}
+
+		netnsFd = 3 + len(inheritFd)
+		inheritFd = append(inheritFd, netnsFile)
+	}
+
 	var listenPid, listenPidFd, connectPid, connectPidFd string
+	listenNetnsFd := "-1"
+	connectNetnsFd := "-1"
 
 	connectAddr := d.config["connect"]
 	listenAddr := d.config["listen"]
@@ -509,11 +550,13 @@ func (d *proxy) setupProxyProcInfo() (*proxyProcInfo, error) {
 
 		connectPid = containerPid
 		connectPidFd = fmt.Sprintf("%d", containerPidFd)
+		connectNetnsFd = fmt.Sprintf("%d", netnsFd)
 
 		listenAddr = d.rewriteHostAddr(listenAddr)
 	case "instance", "guest", "container":
 		listenPid = containerPid
 		listenPidFd = fmt.Sprintf("%d", containerPidFd)
+		listenNetnsFd = fmt.Sprintf("%d", netnsFd)
 
 		connectPid = lxdPid
 		connectPidFd = fmt.Sprintf("%d", lxdPidFd)
@@ -541,6 +584,8 @@ func (d *proxy) setupProxyProcInfo() (*proxyProcInfo, error) {
 		securityGID:    d.config["security.gid"],
 		securityUID:    d.config["security.uid"],
 		proxyProtocol:  d.config["proxy_protocol"],
+		listenNetnsFd:  listenNetnsFd,
+		connectNetnsFd: connectNetnsFd,
 		inheritFds:     inheritFd,
 	}
 
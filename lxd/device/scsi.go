@@ -0,0 +1,416 @@
+package device
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	deviceConfig "github.com/lxc/lxd/lxd/device/config"
+	"github.com/lxc/lxd/lxd/instance"
+	"github.com/lxc/lxd/lxd/instance/instancetype"
+	"github.com/lxc/lxd/lxd/revert"
+	"github.com/lxc/lxd/shared"
+	"github.com/lxc/lxd/shared/logger"
+	"github.com/lxc/lxd/shared/validate"
+)
+
+// scsiSysfsDevices is the sysfs path of the SCSI devices, keyed on their address (host:channel:target:lun).
+const scsiSysfsDevices = "/sys/bus/scsi/devices"
+
+// scsiNodeClasses lists the device classes of the SCSI device nodes that are passed through. Each SCSI device has
+// a directory for each class it has device nodes of, listing the nodes (such as "sg0" for SCSI generic and "st0"
+// and "nst0" for tape devices, along with their mode variants).
+var scsiNodeClasses = []string{"scsi_generic", "scsi_tape"}
+
+// scsiClaims stores the device claiming each exclusively used SCSI device, keyed on the SCSI address.
+// The claims are keyed on the same key as deviceRuntimes.
+var scsiClaims = map[string]string{}
+
+// scsiClaimsMu controls access to the scsiClaims map.
+var scsiClaimsMu sync.Mutex
+
+// scsiClaim claims the SCSI devices for the device, failing if any are claimed by another device.
+func scsiClaim(key string, addresses []string) error {
+	scsiClaimsMu.Lock()
+	defer scsiClaimsMu.Unlock()
+
+	for _, address := range addresses {
+		owner, found := scsiClaims[address]
+		if found && owner != key {
+			ownerParts := strings.SplitN(owner, "\000", 3)
+			return fmt.Errorf("SCSI device %q is already in use by device %q of instance %q in project %q", address, ownerParts[2], ownerParts[1], ownerParts[0])
+		}
+	}
+
+	for _, address := range addresses {
+		scsiClaims[address] = key
+	}
+
+	return nil
+}
+
+// scsiRelease releases the supplied SCSI devices claimed by the device, or all of them if none are supplied.
+func scsiRelease(key string, addresses ...string) {
+	scsiClaimsMu.Lock()
+	defer scsiClaimsMu.Unlock()
+
+	for address, owner := range scsiClaims {
+		if owner == key && (len(addresses) == 0 || shared.StringInSlice(address, addresses)) {
+			delete(scsiClaims, address)
+		}
+	}
+}
+
+// scsiEventAddress returns the address of the SCSI device of the device node of the hotplug event, or an empty
+// string if it can't be determined. The address is taken from the sysfs path of the event as the SCSI device is
+// no longer in sysfs when its removal is handled.
+func scsiEventAddress(e UnixHotplugEvent) string {
+	for _, part := range e.UeventParts {
+		devPath := strings.TrimPrefix(part, "DEVPATH=")
+		if devPath == part {
+			continue
+		}
+
+		// The device nodes are at "<SCSI device>/<class>/<node>".
+		return filepath.Base(filepath.Dir(filepath.Dir(devPath)))
+	}
+
+	return ""
+}
+
+// scsiNode represents a device node of a SCSI device.
+type scsiNode struct {
+	path  string
+	major uint32
+	minor uint32
+}
+
+// scsiReadAttribute returns the value of the sysfs attribute of the SCSI device, without the padding.
+func scsiReadAttribute(sysfsPath string, name string) string {
+	content, err := os.ReadFile(filepath.Join(sysfsPath, name))
+	if err != nil {
+		return ""
+	}
+
+	return strings.TrimSpace(string(content))
+}
+
+// scsiSerial returns the serial number of the SCSI device from its unit serial number VPD page, if it has one.
+func scsiSerial(sysfsPath string) string {
+	content, err := os.ReadFile(filepath.Join(sysfsPath, "vpd_pg80"))
+	if err != nil || len(content) <= 4 {
+		return ""
+	}
+
+	// Skip the page header.
+	return strings.Trim(string(content[4:]), " \000")
+}
+
+// scsiIsOurDevice indicates whether the SCSI device at the sysfs path matches the device config. This function is
+// not defined against the scsi struct type so that it can be used in event callbacks without needing to keep a
+// reference to the scsi device struct.
+func scsiIsOurDevice(config deviceConfig.Device, sysfsPath string) bool {
+	if config["vendor"] != "" && config["vendor"] != scsiReadAttribute(sysfsPath, "vendor") {
+		return false
+	}
+
+	if config["model"] != "" && config["model"] != scsiReadAttribute(sysfsPath, "model") {
+		return false
+	}
+
+	if config["serial"] != "" && config["serial"] != scsiSerial(sysfsPath) {
+		return false
+	}
+
+	return true
+}
+
+// scsiNodes returns the device nodes of the SCSI device at the sysfs path.
+func scsiNodes(sysfsPath string) ([]scsiNode, error) {
+	nodes := []scsiNode{}
+
+	for _, class := range scsiNodeClasses {
+		entries, err := os.ReadDir(filepath.Join(sysfsPath, class))
+		if err != nil {
+			if os.IsNotExist(err) {
+				continue
+			}
+
+			return nil, fmt.Errorf("Failed listing %s device nodes of SCSI device %q: %w", class, filepath.Base(sysfsPath), err)
+		}
+
+		for _, entry := range entries {
+			dev := scsiReadAttribute(filepath.Join(sysfsPath, class, entry.Name()), "dev")
+			fields := strings.SplitN(dev, ":", 2)
+			if len(fields) != 2 {
+				continue
+			}
+
+			major, err := strconv.ParseUint(fields[0], 10, 32)
+			if err != nil {
+				continue
+			}
+
+			minor, err := strconv.ParseUint(fields[1], 10, 32)
+			if err != nil {
+				continue
+			}
+
+			nodes = append(nodes, scsiNode{path: filepath.Join("/dev", entry.Name()), major: uint32(major), minor: uint32(minor)})
+		}
+	}
+
+	return nodes, nil
+}
+
+type scsi struct {
+	deviceCommon
+}
+
+// isRequired indicates whether the device config requires this device to start OK.
+func (d *scsi) isRequired() bool {
+	// Defaults to required.
+	return shared.IsTrueOrEmpty(d.config["required"])
+}
+
+// Required indicates whether the device must start for the instance to start.
+func (d *scsi) Required() bool {
+	return d.isRequired()
+}
+
+// isExclusive indicates whether the SCSI devices can only be used by one instance at a time.
+func (d *scsi) isExclusive() bool {
+	// Defaults to exclusive.
+	return shared.IsTrueOrEmpty(d.config["exclusive"])
+}
+
+// configRules returns the rules that the supplied config is validated with.
+func (d *scsi) configRules(instConf instance.ConfigReader) (map[string]func(value string) error, error) {
+	if !instanceSupported(instConf.Type(), instancetype.Container) {
+		return nil, ErrUnsupportedDevType
+	}
+
+	rules := map[string]func(string) error{
+		"vendor":    validate.IsAny,
+		"model":     validate.IsAny,
+		"serial":    validate.IsAny,
+		"exclusive": validate.Optional(validate.IsBool),
+		"uid":       unixValidUserID,
+		"gid":       unixValidUserID,
+		"mode":      unixValidOctalFileMode,
+		"required":  validate.Optional(validate.IsBool),
+	}
+
+	return rules, nil
+}
+
+// validateConfig checks the supplied config for correctness.
+func (d *scsi) validateConfig(instConf instance.ConfigReader) error {
+	rules, err := d.configRules(instConf)
+	if err != nil {
+		return err
+	}
+
+	err = d.config.Validate(rules)
+	if err != nil {
+		return err
+	}
+
+	if d.config["vendor"] == "" && d.config["model"] == "" && d.config["serial"] == "" {
+		return fmt.Errorf("SCSI devices require at least one of vendor, model or serial")
+	}
+
+	return nil
+}
+
+// loadSCSIDevices returns the addresses of the SCSI devices on the host matching the device config.
+func (d *scsi) loadSCSIDevices() ([]string, error) {
+	entries, err := os.ReadDir(scsiSysfsDevices)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return []string{}, nil
+		}
+
+		return nil, fmt.Errorf("Failed listing SCSI devices: %w", err)
+	}
+
+	addresses := []string{}
+	for _, entry := range entries {
+		sysfsPath := filepath.Join(scsiSysfsDevices, entry.Name())
+
+		// Only the SCSI devices themselves have a vendor, not the hosts and targets.
+		if !shared.PathExists(filepath.Join(sysfsPath, "vendor")) {
+			continue
+		}
+
+		if scsiIsOurDevice(d.config, sysfsPath) {
+			addresses = append(addresses, entry.Name())
+		}
+	}
+
+	sort.Strings(addresses)
+
+	return addresses, nil
+}
+
+// Register is run after the device is started or when LXD starts.
+func (d *scsi) Register() error {
+	key := deviceRuntimeKey(d.inst.Project().Name, d.inst.Name(), d.name)
+
+	// Restore the claims of running instances when LXD starts.
+	if d.isExclusive() {
+		addresses, err := d.loadSCSIDevices()
+		if err == nil {
+			err = scsiClaim(key, addresses)
+		}
+
+		if err != nil {
+			d.logger.Warn("Failed restoring exclusive use of SCSI devices", logger.Ctx{"err": err})
+		}
+	}
+
+	// Extract variables needed to run the event hook so that the reference to this device
+	// struct is not needed to be kept in memory.
+	devicesPath := d.inst.DevicesPath()
+	devConfig := d.config
+	deviceName := d.name
+	exclusive := d.isExclusive()
+	state := d.state
+
+	// Handler for when a Unix hotplug event occurs for a SCSI device node (such as a USB attached tape drive).
+	f := func(e UnixHotplugEvent) (*deviceConfig.RunConfig, error) {
+		if !shared.StringInSlice(e.Subsystem, scsiNodeClasses) {
+			return nil, nil
+		}
+
+		runConf := deviceConfig.RunConfig{}
+
+		if e.Action == "add" {
+			sysfsPath, err := filepath.EvalSymlinks(filepath.Join("/sys/class", e.Subsystem, filepath.Base(e.Path), "device"))
+			if err != nil || !scsiIsOurDevice(devConfig, sysfsPath) {
+				return nil, nil
+			}
+
+			if exclusive {
+				err = scsiClaim(key, []string{filepath.Base(sysfsPath)})
+				if err != nil {
+					return nil, err
+				}
+			}
+
+			err = unixDeviceSetupCharNum(state, devicesPath, "unix", deviceName, devConfig, e.Major, e.Minor, e.Path, true, &runConf)
+			if err != nil {
+				return nil, err
+			}
+		} else if e.Action == "remove" {
+			address := scsiEventAddress(e)
+			if exclusive && address != "" {
+				scsiRelease(key, address)
+			}
+
+			relativeTargetPath := strings.TrimPrefix(e.Path, "/")
+			err := unixDeviceRemove(devicesPath, "unix", deviceName, relativeTargetPath, &runConf)
+			if err != nil {
+				return nil, err
+			}
+
+			// Add a post hook function to remove the specific SCSI device file after unmount.
+			runConf.PostHooks = []func() error{func() error {
+				err := unixDeviceDeleteFiles(state, devicesPath, "unix", deviceName, relativeTargetPath)
+				if err != nil {
+					return fmt.Errorf("Failed to delete files for device '%s': %w", deviceName, err)
+				}
+
+				return nil
+			}}
+		}
+
+		runConf.Uevents = append(runConf.Uevents, e.UeventParts)
+
+		return &runConf, nil
+	}
+
+	unixHotplugRegisterHandler(d.inst, d.name, f)
+
+	return nil
+}
+
+// Start is run when the device is added to the instance.
+func (d *scsi) Start() (*deviceConfig.RunConfig, error) {
+	revert := revert.New()
+	defer revert.Fail()
+
+	addresses, err := d.loadSCSIDevices()
+	if err != nil {
+		return nil, err
+	}
+
+	if d.isRequired() && len(addresses) == 0 {
+		return nil, deviceNotFoundError{msg: "Required SCSI device not found"}
+	}
+
+	key := deviceRuntimeKey(d.inst.Project().Name, d.inst.Name(), d.name)
+	if d.isExclusive() {
+		err := scsiClaim(key, addresses)
+		if err != nil {
+			return nil, err
+		}
+
+		revert.Add(func() { scsiRelease(key) })
+	}
+
+	runConf := deviceConfig.RunConfig{}
+	runConf.PostHooks = []func() error{d.Register}
+
+	revert.Add(func() { _ = unixDeviceDeleteFiles(d.state, d.inst.DevicesPath(), "unix", d.name, "") })
+
+	for _, address := range addresses {
+		nodes, err := scsiNodes(filepath.Join(scsiSysfsDevices, address))
+		if err != nil {
+			return nil, err
+		}
+
+		for _, node := range nodes {
+			err = unixDeviceSetupCharNum(d.state, d.inst.DevicesPath(), "unix", d.name, d.config, node.major, node.minor, node.path, true, &runConf)
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	revert.Success()
+	return &runConf, nil
+}
+
+// Stop is run when the device is removed from the instance.
+func (d *scsi) Stop() (*deviceConfig.RunConfig, error) {
+	unixHotplugUnregisterHandler(d.inst, d.name)
+
+	runConf := deviceConfig.RunConfig{
+		PostHooks: []func() error{d.postStop},
+	}
+
+	err := unixDeviceRemove(d.inst.DevicesPath(), "unix", d.name, "", &runConf)
+	if err != nil {
+		return nil, err
+	}
+
+	return &runConf, nil
+}
+
+// postStop is run after the device is removed from the instance.
+func (d *scsi) postStop() error {
+	scsiRelease(deviceRuntimeKey(d.inst.Project().Name, d.inst.Name(), d.name))
+
+	// Remove host files for this device.
+	err := unixDeviceDeleteFiles(d.state, d.inst.DevicesPath(), "unix", d.name, "")
+	if err != nil {
+		return fmt.Errorf("Failed to delete files for device '%s': %w", d.name, err)
+	}
+
+	return nil
+}
@@ -0,0 +1,31 @@
+package device
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSCSIEventRelease(t *testing.T) {
+	t.Cleanup(func() { scsiRelease("p\000c1\000tape0") })
+
+	err := scsiClaim("p\000c1\000tape0", []string{"0:0:0:0", "1:0:0:0"})
+	assert.NoError(t, err)
+
+	e := UnixHotplugEvent{
+		Action:      "remove",
+		Subsystem:   "scsi_tape",
+		UeventParts: []string{"remove@/devices/pci0000:00/0000:00:14.0/usb2/2-1/2-1:1.0/host1/target1:0:0/1:0:0:0/scsi_tape/st0", "ACTION=remove", "DEVPATH=/devices/pci0000:00/0000:00:14.0/usb2/2-1/2-1:1.0/host1/target1:0:0/1:0:0:0/scsi_tape/st0"},
+	}
+
+	address := scsiEventAddress(e)
+	assert.Equal(t, "1:0:0:0", address)
+
+	// Check only the removed SCSI device is released.
+	scsiRelease("p\000c1\000tape0", address)
+	assert.NoError(t, scsiClaim("p\000c2\000tape0", []string{"1:0:0:0"}))
+	assert.Error(t, scsiClaim("p\000c2\000tape0", []string{"0:0:0:0"}))
+	scsiRelease("p\000c2\000tape0")
+
+	assert.Equal(t, "", scsiEventAddress(UnixHotplugEvent{Action: "remove"}))
+}
@@ -0,0 +1,163 @@
+package device
+
+import (
+	"fmt"
+	"strings"
+
+	deviceConfig "github.com/lxc/lxd/lxd/device/config"
+	"github.com/lxc/lxd/lxd/instance"
+	"github.com/lxc/lxd/lxd/instance/instancetype"
+	"github.com/lxc/lxd/lxd/revert"
+	"github.com/lxc/lxd/shared"
+	"github.com/lxc/lxd/shared/logger"
+	"github.com/lxc/lxd/shared/validate"
+)
+
+// timerDevPaths lists the supported timers and the host device node providing them.
+var timerDevPaths = map[string]string{
+	"hpet": "/dev/hpet",
+	"rtc":  "/dev/rtc0",
+}
+
+type timer struct {
+	deviceCommon
+}
+
+// isRequired indicates whether the device config requires this device to start OK.
+func (d *timer) isRequired() bool {
+	// Defaults to required.
+	return shared.IsTrueOrEmpty(d.config["required"])
+}
+
+// Required indicates whether the device must start for the instance to start.
+func (d *timer) Required() bool {
+	return d.isRequired()
+}
+
+// configRules returns the rules that the supplied config is validated with.
+func (d *timer) configRules(instConf instance.ConfigReader) (map[string]func(value string) error, error) {
+	if !instanceSupported(instConf.Type(), instancetype.Container) {
+		return nil, ErrUnsupportedDevType
+	}
+
+	rules := map[string]func(string) error{
+		"timers":   validate.Optional(validate.IsListOf(validate.IsOneOf("hpet", "rtc"))),
+		"uid":      unixValidUserID,
+		"gid":      unixValidUserID,
+		"mode":     unixValidOctalFileMode,
+		"required": validate.Optional(validate.IsBool),
+	}
+
+	return rules, nil
+}
+
+// validateConfig checks the supplied config for correctness.
+func (d *timer) validateConfig(instConf instance.ConfigReader) error {
+	rules, err := d.configRules(instConf)
+	if err != nil {
+		return err
+	}
+
+	err = d.config.Validate(rules)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// timers returns the list of timers to pass into the instance.
+func (d *timer) timers() []string {
+	if d.config["timers"] == "" {
+		return []string{"hpet"}
+	}
+
+	timers := []string{}
+	for _, timer := range strings.Split(d.config["timers"], ",") {
+		timer = strings.TrimSpace(timer)
+		if !shared.StringInSlice(timer, timers) {
+			timers = append(timers, timer)
+		}
+	}
+
+	return timers
+}
+
+// validateEnvironment checks the runtime environment for correctness.
+// Returns the host device nodes of the timers that are available.
+func (d *timer) validateEnvironment() ([]string, error) {
+	paths := []string{}
+
+	for _, timer := range d.timers() {
+		path := timerDevPaths[timer]
+
+		dType, _, _, err := unixDeviceAttributes(path)
+		if err != nil || dType != "c" {
+			if d.isRequired() {
+				return nil, fmt.Errorf("The host doesn't expose the %q timer device at %q", timer, path)
+			}
+
+			d.logger.Warn("Skipping unavailable timer device", logger.Ctx{"timer": timer, "path": path})
+			continue
+		}
+
+		paths = append(paths, path)
+	}
+
+	return paths, nil
+}
+
+// Start is run when the device is added to a running instance or instance is starting up.
+func (d *timer) Start() (*deviceConfig.RunConfig, error) {
+	paths, err := d.validateEnvironment()
+	if err != nil {
+		return nil, err
+	}
+
+	revert := revert.New()
+	defer revert.Fail()
+
+	runConf := deviceConfig.RunConfig{}
+
+	revert.Add(func() { _ = unixDeviceDeleteFiles(d.state, d.inst.DevicesPath(), "unix", d.name, "") })
+
+	for _, path := range paths {
+		_, major, minor, err := unixDeviceAttributes(path)
+		if err != nil {
+			return nil, fmt.Errorf("Failed getting device attributes for %q: %w", path, err)
+		}
+
+		err = unixDeviceSetupCharNum(d.state, d.inst.DevicesPath(), "unix", d.name, d.config, major, minor, path, true, &runConf)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	revert.Success()
+	return &runConf, nil
+}
+
+// Stop is run when the device is removed from the instance.
+func (d *timer) Stop() (*deviceConfig.RunConfig, error) {
+	runConf := deviceConfig.RunConfig{
+		PostHooks: []func() error{d.postStop},
+	}
+
+	err := unixDeviceRemove(d.inst.DevicesPath(), "unix", d.name, "", &runConf)
+	if err != nil {
+		return nil, err
+	}
+
+	return &runConf, nil
+}
+
+// postStop is run after the device is removed from the instance.
+func (d *timer) postStop() error {
+	// Remove host files for this device.
+	err := unixDeviceDeleteFiles(d.state, d.inst.DevicesPath(), "unix", d.name, "")
+	if err != nil {
+		return fmt.Errorf("Failed to delete files for device '%s': %w", d.name, err)
+	}
+
+	return nil
+}
@@ -28,10 +28,10 @@ func (d *tpm) CanMigrate() bool {
 	return true
 }
 
-// validateConfig checks the supplied config for correctness.
-func (d *tpm) validateConfig(instConf instance.ConfigReader) error {
+// configRules returns the rules that the supplied config is validated with.
+func (d *tpm) configRules(instConf instance.ConfigReader) (map[string]func(value string) error, error) {
 	if !instanceSupported(instConf.Type(), instancetype.Container, instancetype.VM) {
-		return ErrUnsupportedDevType
+		return nil, ErrUnsupportedDevType
 	}
 
 	rules := map[string]func(string) error{}
@@ -40,7 +40,17 @@ func (d *tpm) validateConfig(instConf instance.ConfigReader) error {
 		rules["path"] = validate.IsNotEmpty
 	}
 
-	err := d.config.Validate(rules)
+	return rules, nil
+}
+
+// validateConfig checks the supplied config for correctness.
+func (d *tpm) validateConfig(instConf instance.ConfigReader) error {
+	rules, err := d.configRules(instConf)
+	if err != nil {
+		return err
+	}
+
+	err = d.config.Validate(rules)
 	if err != nil {
 		return fmt.Errorf("Failed to validate config: %w", err)
 	}
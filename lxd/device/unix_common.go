@@ -11,6 +11,7 @@ import (
 	"github.com/lxc/lxd/lxd/instance/instancetype"
 	"github.com/lxc/lxd/lxd/storage/filesystem"
 	"github.com/lxc/lxd/shared"
+	"github.com/lxc/lxd/shared/logger"
 	"github.com/lxc/lxd/shared/validate"
 )
 
@@ -41,10 +42,15 @@ func (d *unixCommon) isRequired() bool {
 	return false
 }
 
-// validateConfig checks the supplied config for correctness.
-func (d *unixCommon) validateConfig(instConf instance.ConfigReader) error {
+// Required indicates whether the device must start for the instance to start.
+func (d *unixCommon) Required() bool {
+	return d.isRequired()
+}
+
+// configRules returns the rules that the supplied config is validated with.
+func (d *unixCommon) configRules(instConf instance.ConfigReader) (map[string]func(value string) error, error) {
 	if !instanceSupported(instConf.Type(), instancetype.Container) {
-		return ErrUnsupportedDevType
+		return nil, ErrUnsupportedDevType
 	}
 
 	rules := map[string]func(string) error{
@@ -66,9 +72,32 @@ func (d *unixCommon) validateConfig(instConf instance.ConfigReader) error {
 		"gid":      unixValidUserID,
 		"mode":     unixValidOctalFileMode,
 		"required": validate.Optional(validate.IsBool),
+
+		"udev.settle":         validate.Optional(validate.IsBool),
+		"udev.settle.timeout": validate.Optional(validate.IsUint32),
+
+		"devices_path.unavailable": validate.Optional(validate.IsOneOf("fail", "skip")),
+		"strategy":                 validate.Optional(validate.IsOneOf("auto", "mknod", "bind")),
+
+		"probe":         validate.Optional(validate.IsOneOf("open", "read")),
+		"probe.timeout": validate.Optional(validate.IsUint32),
+
+		"dir.uid":  unixValidUserID,
+		"dir.gid":  unixValidUserID,
+		"dir.mode": unixValidOctalFileMode,
+	}
+
+	return rules, nil
+}
+
+// validateConfig checks the supplied config for correctness.
+func (d *unixCommon) validateConfig(instConf instance.ConfigReader) error {
+	rules, err := d.configRules(instConf)
+	if err != nil {
+		return err
 	}
 
-	err := d.config.Validate(rules)
+	err = d.config.Validate(rules)
 	if err != nil {
 		return err
 	}
@@ -77,9 +106,28 @@ func (d *unixCommon) validateConfig(instConf instance.ConfigReader) error {
 		return fmt.Errorf("Unix device entry is missing the required \"source\" or \"path\" property")
 	}
 
+	err = validateConflictingKeys(d.config, unixStrategyConflict)
+	if err != nil {
+		return err
+	}
+
+	if d.config["devices_path.unavailable"] == "skip" && d.isRequired() {
+		return fmt.Errorf(`"devices_path.unavailable=skip" can only be used with devices that aren't required`)
+	}
+
 	return nil
 }
 
+// ValidateNewConfig checks the rules that only apply to new or changed device configs, which aren't enforced for
+// the configs stored before they were introduced.
+func (d *unixCommon) ValidateNewConfig() error {
+	// The device number of the host device at "source" is used unless overridden by "major" and "minor".
+	return validateConflictingKeys(d.config, deviceKeyConflict{
+		keys:          []string{"source"},
+		conflictsWith: []string{"major", "minor"},
+	})
+}
+
 // Register is run after the device is started or when LXD starts.
 func (d *unixCommon) Register() error {
 	// Don't register for hot plug events if the device is required.
@@ -125,10 +173,19 @@ func (d *unixCommon) Register() error {
 				return nil, fmt.Errorf("Path specified is not a %s device", d.config["type"])
 			}
 
+			// Don't attach the device if it isn't working.
+			err = unixDeviceProbe(devConfig, e.Path)
+			if err != nil {
+				logger.Warn("Device probe failed, not attaching device", logger.Ctx{"device": deviceName, "path": e.Path, "err": err})
+				return nil, nil
+			}
+
 			err = unixDeviceSetup(state, devicesPath, "unix", deviceName, devConfig, true, &runConf)
 			if err != nil {
 				return nil, err
 			}
+
+			unixDeviceDirHook(d.inst, devConfig, &runConf)
 		} else if e.Action == "remove" {
 			// Skip if host side instance device file doesn't exist.
 			if !shared.PathExists(devPath) {
@@ -170,6 +227,8 @@ func (d *unixCommon) Start() (*deviceConfig.RunConfig, error) {
 	runConf.PostHooks = []func() error{d.Register}
 	srcPath := unixDeviceSourcePath(d.config)
 
+	d.settleUdev()
+
 	// If device file already exists on system, proceed to add it whether its required or not.
 	dType, _, _, err := unixDeviceAttributes(srcPath)
 	if err == nil {
@@ -178,6 +237,17 @@ func (d *unixCommon) Start() (*deviceConfig.RunConfig, error) {
 			return nil, fmt.Errorf("Path specified is not a %s device", d.config["type"])
 		}
 
+		// Check the device is working before attaching it.
+		err = unixDeviceProbe(d.config, srcPath)
+		if err != nil {
+			if d.isRequired() {
+				return nil, fmt.Errorf("Device probe failed: %w", err)
+			}
+
+			d.logger.Warn("Device probe failed, not attaching device", logger.Ctx{"path": srcPath, "err": err})
+			return &runConf, nil
+		}
+
 		err = unixDeviceSetup(d.state, d.inst.DevicesPath(), "unix", d.name, d.config, true, &runConf)
 		if err != nil {
 			return nil, err
@@ -192,10 +262,12 @@ func (d *unixCommon) Start() (*deviceConfig.RunConfig, error) {
 			}
 		} else if d.isRequired() {
 			// If the file is missing and the device is required then we cannot proceed.
-			return nil, fmt.Errorf("The required device path doesn't exist and the major and minor settings are not specified")
+			return nil, deviceNotFoundError{msg: "The required device path doesn't exist and the major and minor settings are not specified"}
 		}
 	}
 
+	unixDeviceDirHook(d.inst, d.config, &runConf)
+
 	return &runConf, nil
 }
 
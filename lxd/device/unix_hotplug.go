@@ -38,10 +38,15 @@ func (d *unixHotplug) isRequired() bool {
 	return shared.IsTrue(d.config["required"])
 }
 
-// validateConfig checks the supplied config for correctness.
-func (d *unixHotplug) validateConfig(instConf instance.ConfigReader) error {
+// Required indicates whether the device must start for the instance to start.
+func (d *unixHotplug) Required() bool {
+	return d.isRequired()
+}
+
+// configRules returns the rules that the supplied config is validated with.
+func (d *unixHotplug) configRules(instConf instance.ConfigReader) (map[string]func(value string) error, error) {
 	if !instanceSupported(instConf.Type(), instancetype.Container) {
-		return ErrUnsupportedDevType
+		return nil, ErrUnsupportedDevType
 	}
 
 	rules := map[string]func(string) error{
@@ -51,9 +56,27 @@ func (d *unixHotplug) validateConfig(instConf instance.ConfigReader) error {
 		"gid":       unixValidUserID,
 		"mode":      unixValidOctalFileMode,
 		"required":  validate.Optional(validate.IsBool),
+
+		"udev.settle":         validate.Optional(validate.IsBool),
+		"udev.settle.timeout": validate.Optional(validate.IsUint32),
+
+		"devices_path.unavailable": validate.Optional(validate.IsOneOf("fail", "skip")),
+		"strategy":                 validate.Optional(validate.IsOneOf("auto", "mknod", "bind")),
+	}
+
+	return rules, nil
+}
+
+// validateConfig checks the supplied config for correctness.
+func (d *unixHotplug) validateConfig(instConf instance.ConfigReader) error {
+	rules, err := d.configRules(instConf)
+	if err != nil {
+		return err
 	}
 
-	err := d.config.Validate(rules)
+	d.config.Normalise()
+
+	err = d.config.Validate(rules)
 	if err != nil {
 		return err
 	}
@@ -62,6 +85,15 @@ func (d *unixHotplug) validateConfig(instConf instance.ConfigReader) error {
 		return fmt.Errorf("Unix hotplug devices require a vendorid or a productid")
 	}
 
+	err = validateConflictingKeys(d.config, unixStrategyConflict)
+	if err != nil {
+		return err
+	}
+
+	if d.config["devices_path.unavailable"] == "skip" && d.isRequired() {
+		return fmt.Errorf(`"devices_path.unavailable=skip" can only be used with devices that aren't required`)
+	}
+
 	return nil
 }
 
@@ -127,9 +159,11 @@ func (d *unixHotplug) Start() (*deviceConfig.RunConfig, error) {
 	runConf := deviceConfig.RunConfig{}
 	runConf.PostHooks = []func() error{d.Register}
 
+	d.settleUdev()
+
 	device := d.loadUnixDevice()
 	if d.isRequired() && device == nil {
-		return nil, fmt.Errorf("Required Unix Hotplug device not found")
+		return nil, deviceNotFoundError{msg: "Required Unix Hotplug device not found"}
 	}
 
 	if device == nil {
@@ -1,22 +1,49 @@
 package device
 
 import (
+	"encoding/json"
 	"fmt"
 	"os"
 	"path"
+	"strconv"
 	"strings"
+	"time"
 
+	"github.com/lxc/lxd/lxd/cgroup"
 	deviceConfig "github.com/lxc/lxd/lxd/device/config"
 	"github.com/lxc/lxd/lxd/instance"
 	"github.com/lxc/lxd/lxd/instance/instancetype"
+	"github.com/lxc/lxd/lxd/network"
+	"github.com/lxc/lxd/lxd/resources"
+	"github.com/lxc/lxd/lxd/revert"
+	"github.com/lxc/lxd/lxd/state"
+	"github.com/lxc/lxd/lxd/util"
 	"github.com/lxc/lxd/shared"
+	"github.com/lxc/lxd/shared/api"
+	"github.com/lxc/lxd/shared/logger"
 	"github.com/lxc/lxd/shared/osarch"
+	"github.com/lxc/lxd/shared/units"
 	"github.com/lxc/lxd/shared/validate"
 )
 
-// usbDevPath is the path where USB devices can be enumerated.
+// usbDevPath is the default path where USB devices can be enumerated.
 const usbDevPath = "/sys/bus/usb/devices"
 
+// usbEnvironmentAttributes lists the USB device attributes that can be exported as environment variables.
+var usbEnvironmentAttributes = []string{"vendorid", "productid", "serial", "path", "busnum", "devnum", "syspath"}
+
+// usbValidDiskLimit validates a disk I/O limit in the same format as disk device limits.
+func usbValidDiskLimit(value string) error {
+	_, _, _, _, err := parseDiskLimit(value, "")
+	return err
+}
+
+// usbValidNetworkLimit validates a network bandwidth limit in the same format as NIC device limits.
+func usbValidNetworkLimit(value string) error {
+	_, err := units.ParseBitSizeString(value)
+	return err
+}
+
 // usbIsOurDevice indicates whether the USB device event qualifies as part of our device.
 // This function is not defined against the usb struct type so that it can be used in event
 // callbacks without needing to keep a reference to the usb device struct.
@@ -29,6 +56,48 @@ func usbIsOurDevice(config deviceConfig.Device, usb *USBEvent) bool {
 	return true
 }
 
+// usbValidMatch validates a "vendorid[:productid]" USB device match criterion.
+func usbValidMatch(value string) error {
+	vendorID, productID, hasProductID := strings.Cut(value, ":")
+
+	err := validate.IsDeviceID(vendorID)
+	if err != nil {
+		return fmt.Errorf("Invalid vendor ID in %q: %w", value, err)
+	}
+
+	if hasProductID {
+		err = validate.IsDeviceID(productID)
+		if err != nil {
+			return fmt.Errorf("Invalid product ID in %q: %w", value, err)
+		}
+	}
+
+	return nil
+}
+
+// usbMatchString returns the "vendorid[:productid]" representation of a match criterion.
+func usbMatchString(criterion deviceConfig.Device) string {
+	if criterion["productid"] == "" {
+		return criterion["vendorid"]
+	}
+
+	return fmt.Sprintf("%s:%s", criterion["vendorid"], criterion["productid"])
+}
+
+// usbResolveMatch returns the index of the first match criterion that matches any of the supplied USB
+// devices, or -1 if none of them match.
+func usbResolveMatch(criteria []deviceConfig.Device, usbs []USBEvent) int {
+	for i, criterion := range criteria {
+		for _, usb := range usbs {
+			if usbIsOurDevice(criterion, &usb) {
+				return i
+			}
+		}
+	}
+
+	return -1
+}
+
 type usb struct {
 	deviceCommon
 }
@@ -39,96 +108,1368 @@ func (d *usb) isRequired() bool {
 	return shared.IsTrue(d.config["required"])
 }
 
+// requiredGrace returns how long after the instance starts a required device can be attached for, or zero if it
+// must be present when the instance starts.
+func (d *usb) requiredGrace() time.Duration {
+	if !d.isRequired() || d.config["required.grace"] == "" {
+		return 0
+	}
+
+	seconds, err := strconv.ParseUint(d.config["required.grace"], 10, 32)
+	if err != nil {
+		return 0 // Validated by the device config.
+	}
+
+	return time.Duration(seconds) * time.Second
+}
+
+// isRequiredAtStart indicates whether the device must be present when the instance starts.
+func (d *usb) isRequiredAtStart() bool {
+	return d.isRequired() && d.requiredGrace() == 0
+}
+
+// Required indicates whether the device must start for the instance to start.
+func (d *usb) Required() bool {
+	return d.isRequired()
+}
+
+// CheckResources checks that a USB device matching one of the match criteria is present in the hardware view of a
+// host if the device is required. Devices scanned from other sysfs roots than the default one aren't part of the
+// hardware view, so aren't checked.
+func (d *usb) CheckResources(res *api.Resources) error {
+	_, customSysfsPaths := usbSysfsPathsGet()
+	if !d.isRequired() || customSysfsPaths {
+		return nil
+	}
+
+	criteria := d.matchCriteria()
+	for _, criterion := range criteria {
+		for _, usbDev := range res.USB.Devices {
+			if usbIsOurDevice(criterion, &USBEvent{Vendor: usbDev.VendorID, Product: usbDev.ProductID}) {
+				return nil
+			}
+		}
+	}
+
+	matches := make([]string, 0, len(criteria))
+	for _, criterion := range criteria {
+		matches = append(matches, usbMatchString(criterion))
+	}
+
+	return fmt.Errorf("No USB device matching %s is present", strings.Join(matches, " or "))
+}
+
+// configRules returns the rules that the supplied config is validated with.
+func (d *usb) configRules(instConf instance.ConfigReader) (map[string]func(value string) error, error) {
+	if !instanceSupported(instConf.Type(), instancetype.Container, instancetype.VM) {
+		return nil, ErrUnsupportedDevType
+	}
+
+	rules := map[string]func(string) error{
+		"vendorid":            validate.Optional(validate.IsDeviceID),
+		"productid":           validate.Optional(validate.IsDeviceID),
+		"fallback":            validate.Optional(validate.IsListOf(usbValidMatch)),
+		"uid":                 unixValidUserID,
+		"gid":                 unixValidUserID,
+		"mode":                unixValidOctalFileMode,
+		"required":            validate.Optional(validate.IsBool),
+		"modules":             validate.Optional(validate.IsListOf(validateKernelModuleName)),
+		"modules.unload":      validate.Optional(validate.IsBool),
+		"power.budget":        validate.Optional(validate.IsUint32),
+		"power.budget.policy": validate.Optional(validate.IsOneOf("warn", "refuse")),
+		"udev.settle":         validate.Optional(validate.IsBool),
+		"udev.settle.timeout": validate.Optional(validate.IsUint32),
+		"descriptors":         validate.Optional(validate.IsBool),
+		"probe":               validate.Optional(validate.IsOneOf("open", "read")),
+		"probe.timeout":       validate.Optional(validate.IsUint32),
+		"hotplug.window":      validate.Optional(validateHotplugWindow),
+		"irq.affinity":        validate.Optional(validate.IsBool),
+		"irq.affinity.cpus": validate.Optional(func(value string) error {
+			_, err := resources.ParseCpuset(value)
+			return err
+		}),
+		"usbmon":          validate.Optional(validate.IsBool),
+		"usbmon.size":     validate.Optional(validate.IsSize),
+		"usbmon.duration": validate.Optional(validate.IsUint32),
+	}
+
+	rules["required.grace"] = validate.Optional(validate.IsUint32)
+	rules["required.grace.action"] = validate.Optional(validate.IsOneOf("stop", "alert"))
+
+	// Exporting device attributes into the init environment only applies to containers.
+	if instConf.Type() != instancetype.VM {
+		rules["environment"] = validate.Optional(validate.IsListOf(validate.IsOneOf(usbEnvironmentAttributes...)))
+		rules["environment.prefix"] = validate.Optional(validateEnvironmentVariableName)
+		rules["environment.host_paths"] = validate.Optional(validate.IsBool)
+		rules["devices_path.unavailable"] = validate.Optional(validate.IsOneOf("fail", "skip"))
+		rules["strategy"] = validate.Optional(validate.IsOneOf("auto", "mknod", "bind"))
+		rules["name.template"] = validate.Optional(unixValidNameTemplate("vendorid", "productid", "serial", "busnum", "devnum"))
+		rules["limits.read"] = validate.Optional(usbValidDiskLimit)
+		rules["limits.write"] = validate.Optional(usbValidDiskLimit)
+		rules["limits.max"] = validate.Optional(usbValidDiskLimit)
+		rules["limits.ingress"] = validate.Optional(usbValidNetworkLimit)
+		rules["limits.egress"] = validate.Optional(usbValidNetworkLimit)
+		rules["standby"] = validate.Optional(usbValidMatch)
+		rules["standby.failback"] = validate.Optional(validate.IsBool)
+		rules["dir.uid"] = unixValidUserID
+		rules["dir.gid"] = unixValidUserID
+		rules["dir.mode"] = unixValidOctalFileMode
+	}
+
+	return rules, nil
+}
+
 // validateConfig checks the supplied config for correctness.
 func (d *usb) validateConfig(instConf instance.ConfigReader) error {
-	if !instanceSupported(instConf.Type(), instancetype.Container, instancetype.VM) {
-		return ErrUnsupportedDevType
+	rules, err := d.configRules(instConf)
+	if err != nil {
+		return err
+	}
+
+	d.config.Normalise()
+
+	if instConf.Architecture() == osarch.ARCH_64BIT_S390_BIG_ENDIAN {
+		return fmt.Errorf("USB devices aren't supported on s390x")
+	}
+
+	err = d.config.Validate(rules)
+	if err != nil {
+		return err
+	}
+
+	if d.config["fallback"] != "" && d.config["vendorid"] == "" && d.config["productid"] == "" {
+		return fmt.Errorf(`The "fallback" property requires "vendorid" or "productid" to be set`)
+	}
+
+	if d.config["standby"] != "" {
+		if d.config["vendorid"] == "" && d.config["productid"] == "" {
+			return fmt.Errorf(`The "standby" property requires "vendorid" or "productid" to be set`)
+		}
+
+		criteria := d.matchCriteria()
+		if usbMatchString(criteria[0]) == usbMatchString(criteria[1]) {
+			return fmt.Errorf(`The "standby" property must match a different device than "vendorid" and "productid"`)
+		}
+	}
+
+	// Switching to the standby device is done as soon as the primary one is removed.
+	err = validateConflictingKeys(d.config, deviceKeyConflict{
+		keys:          []string{"standby"},
+		conflictsWith: []string{"fallback", "hotplug.window"},
+	})
+	if err != nil {
+		return err
+	}
+
+	if shared.StringInSlice("syspath", d.environmentAttributes()) && shared.IsFalseOrEmpty(d.config["environment.host_paths"]) {
+		return fmt.Errorf(`The "syspath" environment attribute exposes a host path and requires "environment.host_paths" to be enabled`)
+	}
+
+	err = validateConflictingKeys(d.config, unixStrategyConflict)
+	if err != nil {
+		return err
+	}
+
+	if d.config["irq.affinity.cpus"] != "" {
+		if shared.IsFalseOrEmpty(d.config["irq.affinity"]) {
+			return fmt.Errorf(`The "irq.affinity.cpus" property requires "irq.affinity" to be enabled`)
+		}
+
+		_, err = usbIRQAffinityCPUs(instConf.ExpandedConfig(), d.config["irq.affinity.cpus"])
+		if err != nil {
+			return fmt.Errorf(`Invalid "irq.affinity.cpus": %w`, err)
+		}
+	}
+
+	if d.config["devices_path.unavailable"] == "skip" && d.isRequired() {
+		return fmt.Errorf(`"devices_path.unavailable=skip" can only be used with devices that aren't required`)
+	}
+
+	if !d.isRequired() {
+		for _, key := range []string{"required.grace", "required.grace.action"} {
+			if d.config[key] != "" {
+				return fmt.Errorf(`The %q property requires "required" to be enabled`, key)
+			}
+		}
+	}
+
+	if shared.IsFalseOrEmpty(d.config["usbmon"]) {
+		for _, key := range []string{"usbmon.size", "usbmon.duration"} {
+			if d.config[key] != "" {
+				return fmt.Errorf(`The %q property requires "usbmon" to be enabled`, key)
+			}
+		}
+	}
+
+	return nil
+}
+
+// ValidateNewConfig checks the rules that only apply to new or changed device configs, which aren't enforced for
+// the configs stored before they were introduced.
+func (d *usb) ValidateNewConfig() error {
+	// The "limits.max" key overrides both "limits.read" and "limits.write".
+	return validateConflictingKeys(d.config, deviceKeyConflict{
+		keys:          []string{"limits.max"},
+		conflictsWith: []string{"limits.read", "limits.write"},
+	})
+}
+
+// validateEnvironment checks the runtime environment for correctness.
+func (d *usb) validateEnvironment() error {
+	sysfsPaths, customSysfsPaths := usbSysfsPathsGet()
+	if customSysfsPaths {
+		for _, sysfsPath := range sysfsPaths {
+			err := usbCheckSysfsPath(sysfsPath)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	for _, module := range d.modules() {
+		err := util.ModuleAvailable(module)
+		if err != nil {
+			return fmt.Errorf("Kernel module %q is not available: %w", module, err)
+		}
+	}
+
+	if shared.IsTrue(d.config["usbmon"]) {
+		err := usbmonAvailable()
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// PreStage is run when the device is added to or updated on a stopped instance.
+// It checks the matching USB devices are present and can be used by the instance (within the power budget),
+// recording them so that the next start can report if they have changed since the device was configured.
+func (d *usb) PreStage() error {
+	err := d.validateEnvironment()
+	if err != nil {
+		return fmt.Errorf("Failed to validate environment: %w", err)
 	}
 
-	if instConf.Architecture() == osarch.ARCH_64BIT_S390_BIG_ENDIAN {
-		return fmt.Errorf("USB devices aren't supported on s390x")
+	usbs, err := d.loadUsb()
+	if err != nil {
+		return err
+	}
+
+	// Check the devices of the first criterion in the chain that resolves to a present device.
+	criteria := d.matchCriteria()
+	match := criteria[0]
+
+	i := usbResolveMatch(criteria, usbs)
+	if i >= 0 {
+		match = criteria[i]
+	}
+
+	prestaged := []string{}
+	for _, usb := range usbs {
+		if !usbIsOurDevice(match, &usb) {
+			continue
+		}
+
+		if !shared.PathExists(usb.Path) {
+			return fmt.Errorf("USB device %03d:%03d device node %q doesn't exist", usb.BusNum, usb.DevNum, usb.Path)
+		}
+
+		err = d.checkPowerBudget(usb)
+		if err != nil {
+			return err
+		}
+
+		prestaged = append(prestaged, fmt.Sprintf("%s:%s@%03d:%03d", usb.Vendor, usb.Product, usb.BusNum, usb.DevNum))
+	}
+
+	if d.isRequiredAtStart() && len(prestaged) == 0 {
+		return deviceNotFoundError{msg: "Required USB device not found"}
+	}
+
+	d.logger.Debug("Pre-staged USB device", logger.Ctx{"devices": prestaged})
+
+	return d.volatileSet(map[string]string{"last_state.prestaged": strings.Join(prestaged, ",")})
+}
+
+// checkPreStaged compares the USB devices found when starting with those resolved when the device was
+// pre-staged, logging any that are no longer present, and then clears the cached resolution.
+func (d *usb) checkPreStaged(usbs []USBEvent) error {
+	v := d.volatileGet()
+	if v["last_state.prestaged"] == "" {
+		return nil
+	}
+
+	found := map[string]struct{}{}
+	for _, usb := range usbs {
+		found[fmt.Sprintf("%s:%s@%03d:%03d", usb.Vendor, usb.Product, usb.BusNum, usb.DevNum)] = struct{}{}
+	}
+
+	for _, prestaged := range strings.Split(v["last_state.prestaged"], ",") {
+		_, ok := found[prestaged]
+		if !ok {
+			d.logger.Warn("Pre-staged USB device has changed since it was configured", logger.Ctx{"device": prestaged})
+		}
+	}
+
+	return d.volatileSet(map[string]string{"last_state.prestaged": ""})
+}
+
+// matchCriteria returns the chain of match criteria of the device, starting with the vendorid and productid
+// settings followed by those in the fallback setting (or the standby setting). Each criterion is returned as a
+// device config containing the vendorid and productid keys so that it can be used with usbIsOurDevice.
+func (d *usb) matchCriteria() []deviceConfig.Device {
+	criteria := []deviceConfig.Device{{"vendorid": d.config["vendorid"], "productid": d.config["productid"]}}
+	if d.config["standby"] != "" {
+		vendorID, productID, _ := strings.Cut(d.config["standby"], ":")
+		return append(criteria, deviceConfig.Device{"vendorid": vendorID, "productid": productID})
+	}
+
+	if d.config["fallback"] == "" {
+		return criteria
+	}
+
+	for _, fallback := range strings.Split(d.config["fallback"], ",") {
+		vendorID, productID, _ := strings.Cut(strings.TrimSpace(fallback), ":")
+		criteria = append(criteria, deviceConfig.Device{"vendorid": vendorID, "productid": productID})
+	}
+
+	return criteria
+}
+
+// resolveMatch selects the first match criterion in the chain that matches any of the supplied USB devices
+// and records it, so that hotplug events, stopping the device and the device state use the same criterion.
+// If no criterion matches then the first one is used.
+func (d *usb) resolveMatch(usbs []USBEvent) error {
+	criteria := d.matchCriteria()
+
+	i := usbResolveMatch(criteria, usbs)
+	if i < 0 {
+		return d.volatileSet(map[string]string{"last_state.match": ""})
+	}
+
+	if i > 0 && d.config["standby"] != "" {
+		d.logger.Info("Primary USB device not found, using standby device", logger.Ctx{"match": usbMatchString(criteria[i])})
+	} else if i > 0 {
+		d.logger.Info("Using fallback USB device match", logger.Ctx{"match": usbMatchString(criteria[i])})
+	}
+
+	return d.volatileSet(map[string]string{"last_state.match": strconv.Itoa(i)})
+}
+
+// matchConfig returns the match criterion currently used by the device.
+func (d *usb) matchConfig() deviceConfig.Device {
+	criteria := d.matchCriteria()
+
+	i, err := strconv.Atoi(d.volatileGet()["last_state.match"])
+	if err != nil || i < 0 || i >= len(criteria) {
+		return criteria[0]
+	}
+
+	return criteria[i]
+}
+
+// modules returns the list of host kernel modules required by the device.
+func (d *usb) modules() []string {
+	if d.config["modules"] == "" {
+		return nil
+	}
+
+	modules := []string{}
+	for _, module := range strings.Split(d.config["modules"], ",") {
+		modules = append(modules, strings.TrimSpace(module))
+	}
+
+	return modules
+}
+
+// loadedModules returns the host kernel modules recorded as loaded by LXD for the device.
+func (d *usb) loadedModules() []string {
+	v := d.volatileGet()
+	if v["last_state.modules"] == "" {
+		return []string{}
+	}
+
+	return strings.Split(v["last_state.modules"], ",")
+}
+
+// loadModules loads the host kernel modules required by the device and records those which were
+// loaded by LXD (rather than already being loaded) so they can optionally be unloaded on stop.
+// Returns a revert function that releases the modules, unloading any that were loaded and aren't used by
+// other devices.
+func (d *usb) loadModules() (revert.Hook, error) {
+	revert := revert.New()
+	defer revert.Fail()
+
+	if len(d.modules()) == 0 {
+		return func() {}, nil
+	}
+
+	usbModulesMu.Lock()
+	defer usbModulesMu.Unlock()
+
+	key := deviceRuntimeKey(d.inst.Project().Name, d.inst.Name(), d.name)
+	loaded := d.loadedModules()
+	usbModulesUse(key, d.modules(), loaded)
+	revert.Add(func() { d.releaseModules(true) })
+
+	for _, module := range d.modules() {
+		if util.ModuleLoaded(module) {
+			continue
+		}
+
+		err := util.LoadModule(module)
+		if err != nil {
+			return nil, fmt.Errorf("Failed to load kernel module %q: %w", module, err)
+		}
+
+		d.logger.Debug("Loaded kernel module", logger.Ctx{"module": module})
+		usbModulesUse(key, []string{module}, []string{module})
+
+		if !shared.StringInSlice(module, loaded) {
+			loaded = append(loaded, module)
+		}
+	}
+
+	err := d.volatileSet(map[string]string{"last_state.modules": strings.Join(loaded, ",")})
+	if err != nil {
+		return nil, err
+	}
+
+	cleanup := revert.Clone().Fail
+	revert.Success()
+	return cleanup, nil
+}
+
+// releaseModules removes the device as a user of its host kernel modules. If unload is true, the modules that
+// were loaded by LXD and that aren't used by any other running device are unloaded. Modules that were already
+// loaded before the device started are never unloaded. Only the devices listing a module in "modules" are known
+// to use it. Failure to unload a module (for instance because it is still in use) is logged rather than returned.
+// Returns the modules that failed to unload, so that they stay recorded as loaded by LXD.
+func (d *usb) releaseModules(unload bool) []string {
+	usbModulesMu.Lock()
+	defer usbModulesMu.Unlock()
+
+	failed := []string{}
+	for _, module := range usbModulesRelease(deviceRuntimeKey(d.inst.Project().Name, d.inst.Name(), d.name), d.modules()) {
+		if !unload {
+			continue
+		}
+
+		err := util.UnloadModule(module)
+		if err != nil {
+			d.logger.Warn("Failed to unload kernel module", logger.Ctx{"module": module, "err": err})
+			failed = append(failed, module)
+			continue
+		}
+
+		d.logger.Debug("Unloaded kernel module", logger.Ctx{"module": module})
+		usbModulesUnloaded(module)
+	}
+
+	return failed
+}
+
+// checkPowerBudget checks whether the combined maximum power draw of all the devices connected to the
+// same hub as the supplied USB device exceeds the configured power budget. This is a limit of the hub, so
+// it includes the devices that aren't passed through. Depending on the configured policy either a
+// warning is logged or an error is returned.
+func (d *usb) checkPowerBudget(e USBEvent) error {
+	if d.config["power.budget"] == "" {
+		return nil
+	}
+
+	budget, err := strconv.ParseUint(d.config["power.budget"], 10, 32)
+	if err != nil {
+		return err
+	}
+
+	devPath, err := usbSysfsPath(usbScanSysfsPaths(), e.BusNum, e.DevNum)
+	if err != nil {
+		return err
+	}
+
+	hubMaxPower, err := usbHubMaxPower(devPath)
+	if err != nil {
+		return fmt.Errorf("Failed getting hub power draw for USB device %03d:%03d: %w", e.BusNum, e.DevNum, err)
+	}
+
+	if hubMaxPower <= budget {
+		return nil
+	}
+
+	if d.config["power.budget.policy"] == "refuse" {
+		return fmt.Errorf("USB device %03d:%03d hub power draw %dmA exceeds power budget of %dmA", e.BusNum, e.DevNum, hubMaxPower, budget)
+	}
+
+	d.logger.Warn("USB device hub power draw exceeds power budget", logger.Ctx{"bus": e.BusNum, "device": e.DevNum, "maxPower": hubMaxPower, "budget": budget})
+
+	return nil
+}
+
+// probe checks that the matching USB device is working before it is attached.
+// A warning is logged if it isn't and the device isn't required at start, as the device is then skipped.
+func (d *usb) probe(e USBEvent) error {
+	err := unixDeviceProbe(d.config, e.Path)
+	if err != nil {
+		if d.isRequiredAtStart() {
+			return fmt.Errorf("USB device probe failed: %w", err)
+		}
+
+		d.logger.Warn("USB device probe failed, not attaching device", logger.Ctx{"bus": e.BusNum, "device": e.DevNum, "err": err})
+	}
+
+	return err
+}
+
+// applyIRQAffinity sets the interrupt affinity of the host controllers of the supplied USB devices to the CPUs
+// the instance is pinned to, recording the original affinity so it can be restored when the device stops.
+// This is best-effort, so a warning is logged if it isn't possible.
+func (d *usb) applyIRQAffinity(usbs []USBEvent) {
+	if shared.IsFalseOrEmpty(d.config["irq.affinity"]) || len(usbs) == 0 {
+		return
+	}
+
+	cpus, err := usbIRQAffinityCPUs(d.inst.ExpandedConfig(), d.config["irq.affinity.cpus"])
+	if err != nil {
+		d.logger.Warn("Not setting USB controller interrupt affinity", logger.Ctx{"err": err})
+		return
+	}
+
+	affinities := usbParseIRQAffinities(d.volatileGet()["last_state.irq_affinity"])
+
+	for _, usb := range usbs {
+		devPath, err := usbSysfsPath(usbScanSysfsPaths(), usb.BusNum, usb.DevNum)
+		if err != nil {
+			continue
+		}
+
+		irqs, err := usbControllerIRQs(devPath)
+		if err != nil {
+			d.logger.Warn("Not setting USB controller interrupt affinity", logger.Ctx{"bus": usb.BusNum, "device": usb.DevNum, "err": err})
+			continue
+		}
+
+		for _, irq := range irqs {
+			// Controllers are shared by devices, so only set the affinity of each interrupt once.
+			_, found := affinities[irq]
+			if found {
+				continue
+			}
+
+			affinity, err := usbSetIRQAffinity(irq, cpus)
+			if err != nil {
+				d.logger.Warn("The host doesn't allow setting the USB controller interrupt affinity", logger.Ctx{"irq": irq, "cpus": cpus, "err": err})
+				continue
+			}
+
+			affinities[irq] = affinity
+		}
+	}
+
+	err = d.volatileSet(map[string]string{"last_state.irq_affinity": usbFormatIRQAffinities(affinities)})
+	if err != nil {
+		d.logger.Warn("Failed recording USB controller interrupt affinity", logger.Ctx{"err": err})
+	}
+}
+
+// restoreIRQAffinity restores the original interrupt affinity of the host controllers changed by applyIRQAffinity.
+func (d *usb) restoreIRQAffinity() {
+	for irq, affinity := range usbParseIRQAffinities(d.volatileGet()["last_state.irq_affinity"]) {
+		err := usbRestoreIRQAffinity(irq, affinity)
+		if err != nil {
+			d.logger.Warn("Failed restoring USB controller interrupt affinity", logger.Ctx{"irq": irq, "err": err})
+		}
+	}
+}
+
+// environmentAttributes returns the list of device attributes to export as environment variables.
+func (d *usb) environmentAttributes() []string {
+	if d.config["environment"] == "" {
+		return nil
+	}
+
+	attributes := []string{}
+	for _, attribute := range strings.Split(d.config["environment"], ",") {
+		attributes = append(attributes, strings.TrimSpace(attribute))
+	}
+
+	return attributes
+}
+
+// attributes returns the attributes of the supplied USB device keyed on their name in usbEnvironmentAttributes,
+// other than "path" which depends on where the device node is created in the instance (see targetPath).
+func (d *usb) attributes(e USBEvent) map[string]string {
+	devPath, _ := usbSysfsPath(usbScanSysfsPaths(), e.BusNum, e.DevNum)
+
+	attributes := map[string]string{
+		"vendorid":  e.Vendor,
+		"productid": e.Product,
+		"serial":    "",
+		"busnum":    fmt.Sprintf("%03d", e.BusNum),
+		"devnum":    fmt.Sprintf("%03d", e.DevNum),
+		"syspath":   devPath,
+	}
+
+	if devPath != "" {
+		serial, err := os.ReadFile(path.Join(devPath, "serial"))
+		if err == nil {
+			attributes["serial"] = strings.TrimSpace(string(serial))
+		}
+	}
+
+	return attributes
+}
+
+// environment returns the environment variables exporting the configured attributes of the
+// supplied USB device. The "path" attribute is the path of the device node in the instance.
+func (d *usb) environment(e USBEvent) (map[string]string, error) {
+	prefix := d.config["environment.prefix"]
+	if prefix == "" {
+		prefix = "DEVICE"
+	}
+
+	attributes := d.attributes(e)
+
+	if shared.StringInSlice("path", d.environmentAttributes()) {
+		targetPath, err := d.targetPath(e)
+		if err != nil {
+			return nil, fmt.Errorf("Failed naming USB device node: %w", err)
+		}
+
+		attributes["path"] = targetPath
+	}
+
+	env := map[string]string{}
+	for _, attribute := range d.environmentAttributes() {
+		env[fmt.Sprintf("%s_%s", prefix, strings.ToUpper(attribute))] = attributes[attribute]
+	}
+
+	return env, nil
+}
+
+// targetPath returns the path of the device node of the supplied USB device in the instance. This is the same
+// path as on the host unless "name.template" is set or the node of a standby device has been created already.
+func (d *usb) targetPath(e USBEvent) (string, error) {
+	if d.config["standby"] != "" {
+		standbyPath := d.volatileGet()["last_state.standby.path"]
+		if standbyPath != "" {
+			return standbyPath, nil
+		}
+	}
+
+	if d.config["name.template"] == "" {
+		return e.Path, nil
+	}
+
+	return unixDeviceTemplatePath(d.inst.DevicesPath(), d.config["name.template"], d.attributes(e), e.Major, e.Minor)
+}
+
+// setupNode creates the device node for the supplied USB device in the instance. The node is at the same path
+// as on the host unless "name.template" is set, in which case it is named from the device attributes.
+// If "standby" is set, the node of whichever of the primary and standby devices is attached is created at
+// the path of the first one attached, so that the instance finds the replacement device at the same path.
+func (d *usb) setupNode(e USBEvent, runConf *deviceConfig.RunConfig) error {
+	standbyPath := ""
+	if d.config["standby"] != "" {
+		standbyPath = d.volatileGet()["last_state.standby.path"]
+	}
+
+	if d.config["name.template"] == "" && standbyPath == "" {
+		err := unixDeviceSetupCharNum(d.state, d.inst.DevicesPath(), "unix", d.name, d.config, e.Major, e.Minor, e.Path, false, runConf)
+		if err != nil {
+			return err
+		}
+
+		return d.recordStandbyPath(e.Path)
+	}
+
+	destPath, err := d.targetPath(e)
+	if err != nil {
+		return fmt.Errorf("Failed naming USB device node: %w", err)
+	}
+
+	nodeConfig := d.config.Clone()
+	nodeConfig["source"] = e.Path
+
+	err = unixDeviceSetupCharNum(d.state, d.inst.DevicesPath(), "unix", d.name, nodeConfig, e.Major, e.Minor, destPath, false, runConf)
+	if err != nil {
+		return err
+	}
+
+	return d.recordStandbyPath(destPath)
+}
+
+// recordStandbyPath records the path of the device node in the instance if "standby" is set, so that the
+// primary and standby devices replace each other at the same path.
+func (d *usb) recordStandbyPath(destPath string) error {
+	if d.config["standby"] == "" || d.volatileGet()["last_state.standby.path"] == destPath {
+		return nil
+	}
+
+	return d.volatileSet(map[string]string{"last_state.standby.path": destPath})
+}
+
+// standbyAttached indicates whether the primary or standby device is attached to the instance.
+func (d *usb) standbyAttached() bool {
+	standbyPath := d.volatileGet()["last_state.standby.path"]
+
+	return standbyPath != "" && UnixDeviceExists(d.inst.DevicesPath(), deviceJoinPath("unix", d.name), standbyPath)
+}
+
+// standbyCandidate returns the host USB device to attach in place of a removed one, preferring the primary
+// device over the standby one, or nil if neither is present.
+func (d *usb) standbyCandidate() (*USBEvent, error) {
+	usbs, err := d.loadUsb()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, criterion := range d.matchCriteria() {
+		for _, usb := range usbs {
+			if usbIsOurDevice(criterion, &usb) && shared.PathExists(usb.Path) {
+				return &usb, nil
+			}
+		}
+	}
+
+	return nil, nil
+}
+
+// standbyAttach returns the run config to attach the supplied primary or standby device to the instance,
+// recording which of them is active. Returns nil if the device isn't working.
+func (d *usb) standbyAttach(e USBEvent) (*deviceConfig.RunConfig, error) {
+	err := d.checkPowerBudget(e)
+	if err != nil {
+		return nil, err
+	}
+
+	// Don't attach the device if it isn't working.
+	err = unixDeviceProbe(d.config, e.Path)
+	if err != nil {
+		d.logger.Warn("USB device probe failed, not attaching device", logger.Ctx{"bus": e.BusNum, "device": e.DevNum, "err": err})
+		return nil, nil
+	}
+
+	runConf := deviceConfig.RunConfig{}
+
+	err = d.setupNode(e, &runConf)
+	if err != nil {
+		return nil, err
+	}
+
+	unixDeviceDirHook(d.inst, d.config, &runConf)
+
+	criteria := d.matchCriteria()
+	active := "0"
+	if !usbIsOurDevice(criteria[0], &e) {
+		active = "1"
+	}
+
+	if d.volatileGet()["last_state.match"] != active {
+		if active == "1" {
+			d.logger.Info("Attaching standby USB device in place of the primary device", logger.Ctx{"bus": e.BusNum, "device": e.DevNum})
+		} else {
+			d.logger.Info("Attaching primary USB device in place of the standby device", logger.Ctx{"bus": e.BusNum, "device": e.DevNum})
+		}
+	}
+
+	err = d.volatileSet(map[string]string{"last_state.match": active})
+	if err != nil {
+		return nil, err
+	}
+
+	d.applyIRQAffinity([]USBEvent{e})
+
+	err = d.generateLimits(e, &runConf)
+	if err != nil {
+		d.logger.Warn("Failed applying USB device limits", logger.Ctx{"bus": e.BusNum, "device": e.DevNum, "err": err})
+	}
+
+	err = d.refreshEnvironment()
+	if err != nil {
+		d.logger.Warn("Failed refreshing device environment", logger.Ctx{"err": err})
+	}
+
+	if len(e.UeventParts) > 0 {
+		runConf.Uevents = append(runConf.Uevents, e.UeventParts)
+	}
+
+	return &runConf, nil
+}
+
+// standbyDetach returns the run config to detach the attached primary or standby device from the instance.
+// Once detached, the replacement device (or if nil, the first of the primary and standby devices present) is
+// attached in its place.
+func (d *usb) standbyDetach(e USBEvent, replacement *USBEvent) (*deviceConfig.RunConfig, error) {
+	devicesPath := d.inst.DevicesPath()
+	relativeTargetPath := strings.TrimPrefix(d.volatileGet()["last_state.standby.path"], "/")
+
+	runConf := deviceConfig.RunConfig{}
+
+	err := unixDeviceRemove(devicesPath, "unix", d.name, relativeTargetPath, &runConf)
+	if err != nil {
+		return nil, err
+	}
+
+	runConf.PostHooks = []func() error{func() error {
+		err := unixDeviceDeleteFiles(d.state, devicesPath, "unix", d.name, relativeTargetPath)
+		if err != nil {
+			return fmt.Errorf("Failed to delete files for device '%s': %w", d.name, err)
+		}
+
+		if replacement == nil {
+			replacement, err = d.standbyCandidate()
+			if err != nil {
+				return err
+			}
+		}
+
+		if replacement == nil {
+			d.logger.Warn("Neither the primary nor the standby USB device is present")
+			return d.refreshEnvironment()
+		}
+
+		attachRunConf, err := d.standbyAttach(*replacement)
+		if err != nil {
+			return err
+		}
+
+		if attachRunConf == nil {
+			return nil
+		}
+
+		return d.inst.DeviceEventHandler(attachRunConf)
+	}}
+
+	if len(e.UeventParts) > 0 {
+		runConf.Uevents = append(runConf.Uevents, e.UeventParts)
+	}
+
+	return &runConf, nil
+}
+
+// standbyEvent handles a USB event for a device with a standby device. Only one of the primary and standby
+// devices is attached at a time. When the attached one is removed, the other is attached in its place if it is
+// present. When the primary device is added whilst the standby one is attached, it only replaces it if
+// "standby.failback" is enabled.
+func (d *usb) standbyEvent(e USBEvent) (*deviceConfig.RunConfig, error) {
+	criteria := d.matchCriteria()
+	isPrimary := usbIsOurDevice(criteria[0], &e)
+	if !isPrimary && !usbIsOurDevice(criteria[1], &e) {
+		return nil, nil
+	}
+
+	if e.Action == "add" {
+		if !d.standbyAttached() {
+			return d.standbyAttach(e)
+		}
+
+		if !isPrimary || d.volatileGet()["last_state.match"] != "1" || shared.IsFalseOrEmpty(d.config["standby.failback"]) {
+			return nil, nil
+		}
+
+		return d.standbyDetach(e, &e)
+	} else if e.Action == "remove" {
+		// Only the removal of the attached device needs handling.
+		if unixDeviceFindPath(d.inst.DevicesPath(), "unix", d.name, e.Major, e.Minor) == "" {
+			return nil, nil
+		}
+
+		return d.standbyDetach(e, nil)
+	}
+
+	return nil, nil
+}
+
+// refreshEnvironment records the environment variables for the first matching USB device so that
+// they can be applied to new processes in the instance (such as on hotplug re-attach).
+func (d *usb) refreshEnvironment() error {
+	if len(d.environmentAttributes()) <= 0 {
+		return nil
+	}
+
+	usbs, err := d.loadUsb()
+	if err != nil {
+		return err
+	}
+
+	match := d.matchConfig()
+	for _, usb := range usbs {
+		if !usbIsOurDevice(match, &usb) {
+			continue
+		}
+
+		env, err := d.environment(usb)
+		if err != nil {
+			return err
+		}
+
+		envJSON, err := json.Marshal(env)
+		if err != nil {
+			return err
+		}
+
+		return d.volatileSet(map[string]string{"last_state.environment": string(envJSON)})
+	}
+
+	return d.volatileSet(map[string]string{"last_state.environment": ""})
+}
+
+// hasDiskLimits returns whether any disk I/O limits are configured.
+func (d *usb) hasDiskLimits() bool {
+	return d.config["limits.read"] != "" || d.config["limits.write"] != "" || d.config["limits.max"] != ""
+}
+
+// hasNetworkLimits returns whether any network bandwidth limits are configured.
+func (d *usb) hasNetworkLimits() bool {
+	return d.config["limits.ingress"] != "" || d.config["limits.egress"] != ""
+}
+
+// generateLimits translates the configured limits into limits on the block devices and network
+// interfaces derived from the supplied USB device, based on what the device provides.
+// Disk I/O limits are added to runConf as cgroup rules, whereas network limits are applied directly
+// to the host network interfaces (which are recorded so the limits can be removed on stop).
+func (d *usb) generateLimits(e USBEvent, runConf *deviceConfig.RunConfig) error {
+	if !d.hasDiskLimits() && !d.hasNetworkLimits() {
+		return nil
+	}
+
+	devPath, err := usbSysfsPath(usbScanSysfsPaths(), e.BusNum, e.DevNum)
+	if err != nil {
+		return err
+	}
+
+	if d.hasDiskLimits() {
+		blocks, err := usbBlockDevices(devPath)
+		if err != nil {
+			return fmt.Errorf("Failed getting block devices for USB device %03d:%03d: %w", e.BusNum, e.DevNum, err)
+		}
+
+		if len(blocks) == 0 {
+			d.logger.Warn("USB device doesn't provide any block devices, disk limits not applied", logger.Ctx{"bus": e.BusNum, "device": e.DevNum})
+		} else {
+			if !d.state.OS.CGInfo.Supports(cgroup.Blkio, nil) {
+				return fmt.Errorf("Cannot apply disk limits as blkio cgroup controller is missing")
+			}
+
+			readSpeed := d.config["limits.read"]
+			writeSpeed := d.config["limits.write"]
+			if d.config["limits.max"] != "" {
+				readSpeed = d.config["limits.max"]
+				writeSpeed = d.config["limits.max"]
+			}
+
+			readBps, readIops, writeBps, writeIops, err := parseDiskLimit(readSpeed, writeSpeed)
+			if err != nil {
+				return err
+			}
+
+			cg, err := cgroup.New(&cgroupWriter{runConf})
+			if err != nil {
+				return err
+			}
+
+			for _, block := range blocks {
+				for _, limit := range []struct {
+					oType string
+					uType string
+					value int64
+				}{
+					{"read", "bps", readBps},
+					{"read", "iops", readIops},
+					{"write", "bps", writeBps},
+					{"write", "iops", writeIops},
+				} {
+					if limit.value <= 0 {
+						continue
+					}
+
+					err = cg.SetBlkioLimit(block, limit.oType, limit.uType, limit.value)
+					if err != nil {
+						return err
+					}
+				}
+			}
+		}
+	}
+
+	if d.hasNetworkLimits() {
+		interfaces, err := usbNetInterfaces(devPath)
+		if err != nil {
+			return fmt.Errorf("Failed getting network interfaces for USB device %03d:%03d: %w", e.BusNum, e.DevNum, err)
+		}
+
+		if len(interfaces) == 0 {
+			d.logger.Warn("USB device doesn't provide any network interfaces, network limits not applied", logger.Ctx{"bus": e.BusNum, "device": e.DevNum})
+		}
+
+		limited := []string{}
+		v := d.volatileGet()
+		if v["last_state.limits.interfaces"] != "" {
+			limited = strings.Split(v["last_state.limits.interfaces"], ",")
+		}
+
+		for _, iface := range interfaces {
+			// The limits are applied from the perspective of the host interface, so our ingress
+			// (traffic received by the interface) is the helper's egress and vice versa.
+			err = networkSetupHostVethLimits(deviceConfig.Device{
+				"host_name":      iface,
+				"limits.ingress": d.config["limits.egress"],
+				"limits.egress":  d.config["limits.ingress"],
+			})
+			if err != nil {
+				return fmt.Errorf("Failed applying network limits to %q: %w", iface, err)
+			}
+
+			if !shared.StringInSlice(iface, limited) {
+				limited = append(limited, iface)
+			}
+		}
+
+		err = d.volatileSet(map[string]string{"last_state.limits.interfaces": strings.Join(limited, ",")})
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// clearNetworkLimits removes the network limits applied to any host network interfaces.
+func (d *usb) clearNetworkLimits() {
+	v := d.volatileGet()
+	if v["last_state.limits.interfaces"] == "" {
+		return
+	}
+
+	for _, iface := range strings.Split(v["last_state.limits.interfaces"], ",") {
+		if !network.InterfaceExists(iface) {
+			continue
+		}
+
+		// Applying no limits clears any existing ones.
+		err := networkSetupHostVethLimits(deviceConfig.Device{"host_name": iface})
+		if err != nil {
+			d.logger.Warn("Failed removing network limits", logger.Ctx{"interface": iface, "err": err})
+		}
+	}
+}
+
+// Register is run after the device is started or when LXD starts.
+func (d *usb) Register() error {
+	// Extract variables needed to run the event hook so that the reference to this device
+	// struct is not needed to be kept in memory.
+	projectName := d.inst.Project().Name
+	instanceName := d.inst.Name()
+	devConfig := d.config
+	deviceName := d.name
+	state := d.state
+	criteria := d.matchCriteria()
+	match := d.matchConfig()
+	key := deviceRuntimeKey(projectName, instanceName, deviceName)
+
+	// Record the use of the kernel modules again when LXD starts, so that they aren't unloaded whilst in use.
+	usbModulesMu.Lock()
+	usbModulesUse(key, d.modules(), d.loadedModules())
+	usbModulesMu.Unlock()
+
+	// Handler for when a USB event occurs.
+	f := func(e USBEvent) (*deviceConfig.RunConfig, error) {
+		if devConfig["standby"] != "" {
+			if !usbIsOurDevice(criteria[0], &e) && !usbIsOurDevice(criteria[1], &e) {
+				return nil, nil
+			}
+		} else if !usbIsOurDevice(match, &e) {
+			return nil, nil
+		}
+
+		// Load the device to handle the event, as the instance and its volatile state may have changed since
+		// the device was registered.
+		dev, err := usbHotplugDevice(state, projectName, instanceName, deviceName, devConfig)
+		if err != nil {
+			return nil, err
+		}
+
+		return dev.hotplugEvent(e)
+	}
+
+	usbRegisterHandler(d.inst, d.name, f)
+
+	return nil
+}
+
+// usbHotplugDevice loads the USB device of the instance to handle a hotplug event.
+func usbHotplugDevice(s *state.State, projectName string, instanceName string, deviceName string, conf deviceConfig.Device) (*usb, error) {
+	inst, err := instance.LoadByProjectAndName(s, projectName, instanceName)
+	if err != nil {
+		return nil, err
+	}
+
+	prefix := fmt.Sprintf("volatile.%s.", deviceName)
+
+	volatileGet := func() map[string]string {
+		volatile := map[string]string{}
+		for k, v := range inst.LocalConfig() {
+			if strings.HasPrefix(k, prefix) {
+				volatile[strings.TrimPrefix(k, prefix)] = v
+			}
+		}
+
+		return volatile
 	}
 
-	rules := map[string]func(string) error{
-		"vendorid":  validate.Optional(validate.IsDeviceID),
-		"productid": validate.Optional(validate.IsDeviceID),
-		"uid":       unixValidUserID,
-		"gid":       unixValidUserID,
-		"mode":      unixValidOctalFileMode,
-		"required":  validate.Optional(validate.IsBool),
-	}
+	volatileSet := func(save map[string]string) error {
+		volatileSave := make(map[string]string, len(save))
+		for k, v := range save {
+			volatileSave[prefix+k] = v
+		}
 
-	err := d.config.Validate(rules)
-	if err != nil {
-		return err
+		return inst.VolatileSet(volatileSave)
 	}
 
-	return nil
+	d := &usb{}
+	d.init(inst, s, deviceName, conf, volatileGet, volatileSet)
+
+	return d, nil
 }
 
-// Register is run after the device is started or when LXD starts.
-func (d *usb) Register() error {
-	// Extract variables needed to run the event hook so that the reference to this device
-	// struct is not needed to be kept in memory.
+// hotplugEvent handles a USB event for a matching device whilst the device is started, returning the run config
+// to apply to the instance (if any).
+func (d *usb) hotplugEvent(e USBEvent) (*deviceConfig.RunConfig, error) {
+	if d.config["standby"] != "" {
+		return d.standbyEvent(e)
+	}
+
 	devicesPath := d.inst.DevicesPath()
-	devConfig := d.config
 	deviceName := d.name
 	state := d.state
+	key := deviceRuntimeKey(d.inst.Project().Name, d.inst.Name(), d.name)
 
-	// Handler for when a USB event occurs.
-	f := func(e USBEvent) (*deviceConfig.RunConfig, error) {
-		if !usbIsOurDevice(devConfig, &e) {
+	runConf := deviceConfig.RunConfig{}
+
+	if e.Action == "add" {
+		// Defer attaching the device until the hotplug window opens.
+		wait := hotplugWindowNextOpen(d.config["hotplug.window"], time.Now())
+		if wait > 0 {
+			d.logger.Info("Deferring USB device attachment until the hotplug window opens", logger.Ctx{"bus": e.BusNum, "device": e.DevNum, "wait": wait})
+			usbWindowDefer(d.state, key, e, wait)
 			return nil, nil
 		}
 
-		runConf := deviceConfig.RunConfig{}
+		err := d.checkPowerBudget(e)
+		if err != nil {
+			return nil, err
+		}
+
+		// Don't attach the device if it isn't working.
+		err = unixDeviceProbe(d.config, e.Path)
+		if err != nil {
+			d.logger.Warn("USB device probe failed, not attaching device", logger.Ctx{"bus": e.BusNum, "device": e.DevNum, "err": err})
+			return nil, nil
+		}
 
-		if e.Action == "add" {
-			err := unixDeviceSetupCharNum(state, devicesPath, "unix", deviceName, devConfig, e.Major, e.Minor, e.Path, false, &runConf)
-			if err != nil {
-				return nil, err
+		err = d.setupNode(e, &runConf)
+		if err != nil {
+			return nil, err
+		}
+
+		unixDeviceDirHook(d.inst, d.config, &runConf)
+		usbmonAddBus(key, e.BusNum)
+		d.applyIRQAffinity([]USBEvent{e})
+
+		err = d.generateLimits(e, &runConf)
+		if err != nil {
+			d.logger.Warn("Failed applying USB device limits", logger.Ctx{"bus": e.BusNum, "device": e.DevNum, "err": err})
+		}
+
+		err = d.refreshEnvironment()
+		if err != nil {
+			d.logger.Warn("Failed refreshing device environment", logger.Ctx{"err": err})
+		}
+
+		if usbGraceEnd(key) {
+			d.logger.Info("Required USB device attached within its grace period", logger.Ctx{"bus": e.BusNum, "device": e.DevNum})
+		}
+	} else if e.Action == "remove" {
+		// Removals are always processed straight away, which for a deferred device means discarding it.
+		if usbWindowForget(key, e) {
+			return nil, nil
+		}
+
+		targetPath := e.Path
+		if d.config["name.template"] != "" {
+			// The device attributes used for the name may no longer be available, so find the node instead.
+			targetPath = unixDeviceFindPath(devicesPath, "unix", d.name, e.Major, e.Minor)
+			if targetPath == "" {
+				return nil, nil
 			}
-		} else if e.Action == "remove" {
-			relativeTargetPath := strings.TrimPrefix(e.Path, "/")
-			err := unixDeviceRemove(devicesPath, "unix", deviceName, relativeTargetPath, &runConf)
+		}
+
+		relativeTargetPath := strings.TrimPrefix(targetPath, "/")
+		err := unixDeviceRemove(devicesPath, "unix", d.name, relativeTargetPath, &runConf)
+		if err != nil {
+			return nil, err
+		}
+
+		// Add a post hook function to remove the specific USB device file after unmount.
+		runConf.PostHooks = []func() error{func() error {
+			err := unixDeviceDeleteFiles(state, devicesPath, "unix", deviceName, relativeTargetPath)
 			if err != nil {
-				return nil, err
+				return fmt.Errorf("Failed to delete files for device '%s': %w", deviceName, err)
 			}
 
-			// Add a post hook function to remove the specific USB device file after unmount.
-			runConf.PostHooks = []func() error{func() error {
-				err := unixDeviceDeleteFiles(state, devicesPath, "unix", deviceName, relativeTargetPath)
-				if err != nil {
-					return fmt.Errorf("Failed to delete files for device '%s': %w", deviceName, err)
-				}
+			return nil
+		}}
 
-				return nil
-			}}
+		err = d.refreshEnvironment()
+		if err != nil {
+			d.logger.Warn("Failed refreshing device environment", logger.Ctx{"err": err})
 		}
+	}
 
-		runConf.Uevents = append(runConf.Uevents, e.UeventParts)
-
-		// Add the USB device to runConf so that the device handler can handle physical hotplugging.
-		runConf.USBDevice = append(runConf.USBDevice, deviceConfig.USBDeviceItem{
-			DeviceName:     d.getUniqueDeviceNameFromUSBEvent(e),
-			HostDevicePath: e.Path,
-		})
+	runConf.Uevents = append(runConf.Uevents, e.UeventParts)
 
-		return &runConf, nil
-	}
+	// Add the USB device to runConf so that the device handler can handle physical hotplugging.
+	runConf.USBDevice = append(runConf.USBDevice, deviceConfig.USBDeviceItem{
+		DeviceName:     d.getUniqueDeviceNameFromUSBEvent(e),
+		HostDevicePath: e.Path,
+	})
 
-	usbRegisterHandler(d.inst, d.name, f)
+	return &runConf, nil
 
-	return nil
 }
 
 // Start is run when the device is added to the instance.
 func (d *usb) Start() (*deviceConfig.RunConfig, error) {
+	revert := revert.New()
+	defer revert.Fail()
+
+	validateStart := time.Now()
+	err := d.validateEnvironment()
+	d.recordTiming("validate_environment", validateStart)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to validate environment: %w", err)
+	}
+
+	cleanup, err := d.loadModules()
+	if err != nil {
+		return nil, err
+	}
+
+	revert.Add(cleanup)
+
+	// Wait for udev to process any devices added by loading the modules.
+	d.settleUdev()
+
+	var runConf *deviceConfig.RunConfig
 	if d.inst.Type() == instancetype.VM {
-		return d.startVM()
+		runConf, err = d.startVM()
+	} else {
+		runConf, err = d.startContainer()
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	// Start capturing the traffic once the device is attached.
+	if shared.IsTrue(d.config["usbmon"]) {
+		runConf.PostHooks = append(runConf.PostHooks, d.startUsbmon)
+	}
+
+	revert.Success()
+	return runConf, nil
+}
+
+// startRequiredGrace starts the grace period within which the required device must be attached, as it wasn't
+// present when the instance started.
+func (d *usb) startRequiredGrace() error {
+	grace := d.requiredGrace()
+	d.logger.Warn("Required USB device not found, waiting for it to be attached", logger.Ctx{"grace": grace})
+
+	usbGraceStart(deviceRuntimeKey(d.inst.Project().Name, d.inst.Name(), d.name), grace, d.requiredGraceExpired)
+
+	return nil
+}
+
+// requiredGraceExpired is run when the grace period of the required device elapses without it being attached.
+// The device is marked as failed (publishing a failed event) and, unless "required.grace.action" is "alert", the
+// instance is stopped.
+func (d *usb) requiredGraceExpired() {
+	reason := "Required USB device wasn't attached within its grace period"
+	SetStatus(d.state, d.inst, d.name, StatusFailed, reason)
+
+	if d.config["required.grace.action"] == "alert" {
+		d.logger.Warn(reason)
+		return
+	}
+
+	d.logger.Error("Stopping instance as the required USB device wasn't attached within its grace period")
+
+	// Load the instance again as its state may have changed since the device started.
+	inst, err := instance.LoadByProjectAndName(d.state, d.inst.Project().Name, d.inst.Name())
+	if err != nil {
+		d.logger.Error("Failed loading instance", logger.Ctx{"err": err})
+		return
+	}
+
+	err = inst.Stop(false)
+	if err != nil {
+		d.logger.Error("Failed stopping instance", logger.Ctx{"err": err})
+	}
+}
+
+// usbmonPath returns the path of the file that the traffic of the device's USB buses is captured to, which is
+// always in the instance's log directory.
+func (d *usb) usbmonPath() string {
+	return path.Join(d.inst.LogPath(), fmt.Sprintf("usbmon.%s.pcap", d.name))
+}
+
+// startUsbmon starts capturing the traffic of the USB buses of the matching devices, within the configured size
+// and duration limits.
+func (d *usb) startUsbmon() error {
+	usbs, err := d.loadUsb()
+	if err != nil {
+		return err
+	}
+
+	match := d.matchConfig()
+	buses := []int{}
+	for _, usb := range usbs {
+		if usbIsOurDevice(match, &usb) && !shared.IntInSlice(usb.BusNum, buses) {
+			buses = append(buses, usb.BusNum)
+		}
+	}
+
+	var limit int64
+	if d.config["usbmon.size"] != "" {
+		limit, err = units.ParseByteSizeString(d.config["usbmon.size"])
+		if err != nil {
+			return err
+		}
+	}
+
+	var duration time.Duration
+	if d.config["usbmon.duration"] != "" {
+		seconds, err := strconv.ParseUint(d.config["usbmon.duration"], 10, 32)
+		if err != nil {
+			return err
+		}
+
+		duration = time.Duration(seconds) * time.Second
+	}
+
+	capturePath := d.usbmonPath()
+	err = usbmonStart(deviceRuntimeKey(d.inst.Project().Name, d.inst.Name(), d.name), buses, capturePath, limit, duration)
+	if err != nil {
+		return err
 	}
 
-	return d.startContainer()
+	d.logger.Info("Capturing USB traffic", logger.Ctx{"path": capturePath, "buses": buses})
+
+	return nil
 }
 
 func (d *usb) startContainer() (*deviceConfig.RunConfig, error) {
@@ -137,22 +1478,111 @@ func (d *usb) startContainer() (*deviceConfig.RunConfig, error) {
 		return nil, err
 	}
 
+	err = d.checkPreStaged(usbs)
+	if err != nil {
+		return nil, err
+	}
+
+	err = d.resolveMatch(usbs)
+	if err != nil {
+		return nil, err
+	}
+
+	match := d.matchConfig()
+
 	runConf := deviceConfig.RunConfig{}
 	runConf.PostHooks = []func() error{d.Register}
 
+	// Host device paths of the matching devices that aren't attached (such as if they failed their probe).
+	skipped := map[string]bool{}
+	attached := []USBEvent{}
+
 	for _, usb := range usbs {
-		if !usbIsOurDevice(d.config, &usb) {
+		if !usbIsOurDevice(match, &usb) {
+			continue
+		}
+
+		// Only one of the primary and standby devices is attached.
+		if d.config["standby"] != "" && len(attached) > 0 {
+			skipped[usb.Path] = true
+			continue
+		}
+
+		err := d.checkPowerBudget(usb)
+		if err != nil {
+			return nil, err
+		}
+
+		err = d.probe(usb)
+		if err != nil {
+			if d.isRequiredAtStart() {
+				return nil, err
+			}
+
+			skipped[usb.Path] = true
 			continue
 		}
 
-		err := unixDeviceSetupCharNum(d.state, d.inst.DevicesPath(), "unix", d.name, d.config, usb.Major, usb.Minor, usb.Path, false, &runConf)
+		err = d.setupNode(usb, &runConf)
 		if err != nil {
 			return nil, err
 		}
+
+		attached = append(attached, usb)
 	}
 
 	if d.isRequired() && len(runConf.Mounts) <= 0 {
-		return nil, fmt.Errorf("Required USB device not found")
+		if d.isRequiredAtStart() {
+			return nil, deviceNotFoundError{msg: "Required USB device not found"}
+		}
+
+		runConf.PostHooks = append(runConf.PostHooks, d.startRequiredGrace)
+	}
+
+	unixDeviceDirHook(d.inst, d.config, &runConf)
+	d.applyIRQAffinity(attached)
+
+	// Export the attributes of the first matching device into the container's init environment.
+	for _, usb := range usbs {
+		if !usbIsOurDevice(match, &usb) || skipped[usb.Path] || len(d.environmentAttributes()) <= 0 {
+			continue
+		}
+
+		env, err := d.environment(usb)
+		if err != nil {
+			return nil, err
+		}
+
+		for k, v := range env {
+			runConf.Environment = append(runConf.Environment, deviceConfig.RunConfigItem{Key: k, Value: v})
+		}
+
+		break
+	}
+
+	err = d.refreshEnvironment()
+	if err != nil {
+		return nil, err
+	}
+
+	// Apply limits only after the device mounts have been processed.
+	if d.hasDiskLimits() || d.hasNetworkLimits() {
+		runConf.PostHooks = append(runConf.PostHooks, func() error {
+			limitsRunConf := deviceConfig.RunConfig{}
+
+			for _, usb := range usbs {
+				if !usbIsOurDevice(match, &usb) || skipped[usb.Path] {
+					continue
+				}
+
+				err := d.generateLimits(usb, &limitsRunConf)
+				if err != nil {
+					return err
+				}
+			}
+
+			return d.inst.DeviceEventHandler(&limitsRunConf)
+		})
 	}
 
 	return &runConf, nil
@@ -168,22 +1598,58 @@ func (d *usb) startVM() (*deviceConfig.RunConfig, error) {
 		return nil, err
 	}
 
+	err = d.checkPreStaged(usbs)
+	if err != nil {
+		return nil, err
+	}
+
+	err = d.resolveMatch(usbs)
+	if err != nil {
+		return nil, err
+	}
+
+	match := d.matchConfig()
+
 	runConf := deviceConfig.RunConfig{}
 	runConf.PostHooks = []func() error{d.Register}
 
+	attached := []USBEvent{}
+
 	for _, usb := range usbs {
-		if usbIsOurDevice(d.config, &usb) {
+		if usbIsOurDevice(match, &usb) {
+			err := d.checkPowerBudget(usb)
+			if err != nil {
+				return nil, err
+			}
+
+			err = d.probe(usb)
+			if err != nil {
+				if d.isRequiredAtStart() {
+					return nil, err
+				}
+
+				continue
+			}
+
 			runConf.USBDevice = append(runConf.USBDevice, deviceConfig.USBDeviceItem{
 				DeviceName:     d.getUniqueDeviceNameFromUSBEvent(usb),
 				HostDevicePath: fmt.Sprintf("/dev/bus/usb/%03d/%03d", usb.BusNum, usb.DevNum),
 			})
+
+			attached = append(attached, usb)
 		}
 	}
 
 	if d.isRequired() && len(runConf.USBDevice) <= 0 {
-		return nil, fmt.Errorf("Required USB device not found")
+		if d.isRequiredAtStart() {
+			return nil, deviceNotFoundError{msg: "Required USB device not found"}
+		}
+
+		runConf.PostHooks = append(runConf.PostHooks, d.startRequiredGrace)
 	}
 
+	d.applyIRQAffinity(attached)
+
 	return &runConf, nil
 }
 
@@ -198,8 +1664,9 @@ func (d *usb) Stop() (*deviceConfig.RunConfig, error) {
 		return nil, err
 	}
 
+	match := d.matchConfig()
 	for _, usb := range usbs {
-		if usbIsOurDevice(d.config, &usb) {
+		if usbIsOurDevice(match, &usb) {
 			runConf.USBDevice = append(runConf.USBDevice, deviceConfig.USBDeviceItem{
 				DeviceName:     d.getUniqueDeviceNameFromUSBEvent(usb),
 				HostDevicePath: fmt.Sprintf("/dev/bus/usb/%03d/%03d", usb.BusNum, usb.DevNum),
@@ -222,6 +1689,28 @@ func (d *usb) Stop() (*deviceConfig.RunConfig, error) {
 
 // postStop is run after the device is removed from the instance.
 func (d *usb) postStop() error {
+	// Stop capturing the traffic and flush the capture now that the device is detached.
+	usbmonStop(deviceRuntimeKey(d.inst.Project().Name, d.inst.Name(), d.name))
+	usbGraceEnd(deviceRuntimeKey(d.inst.Project().Name, d.inst.Name(), d.name))
+
+	defer func() {
+		// Release the kernel modules used by the device, unloading those that LXD loaded if requested.
+		// The modules that fail to unload stay recorded so that unloading them is tried again next time.
+		failed := d.releaseModules(shared.IsTrue(d.config["modules.unload"]))
+
+		_ = d.volatileSet(map[string]string{
+			"last_state.modules":           strings.Join(failed, ","),
+			"last_state.environment":       "",
+			"last_state.limits.interfaces": "",
+			"last_state.match":             "",
+			"last_state.irq_affinity":      "",
+			"last_state.standby.path":      "",
+		})
+	}()
+
+	d.clearNetworkLimits()
+	d.restoreIRQAffinity()
+
 	// Remove host files for this device.
 	err := unixDeviceDeleteFiles(d.state, d.inst.DevicesPath(), "unix", d.name, "")
 	if err != nil {
@@ -231,11 +1720,40 @@ func (d *usb) postStop() error {
 	return nil
 }
 
-// loadUsb scans the host machine for USB devices.
+// loadUsb scans the host machine for USB devices across all configured sysfs paths.
+// Devices that appear under multiple sysfs paths are only returned once.
 func (d *usb) loadUsb() ([]USBEvent, error) {
+	defer d.recordTiming("scan", time.Now())
+
 	result := []USBEvent{}
+	seen := map[string]struct{}{}
 
-	ents, err := os.ReadDir(usbDevPath)
+	for _, sysfsPath := range usbScanSysfsPaths() {
+		usbs, err := usbLoadPath(sysfsPath)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, usb := range usbs {
+			key := fmt.Sprintf("%03d:%03d", usb.BusNum, usb.DevNum)
+			_, found := seen[key]
+			if found {
+				continue
+			}
+
+			seen[key] = struct{}{}
+			result = append(result, usb)
+		}
+	}
+
+	return result, nil
+}
+
+// usbLoadPath scans the supplied sysfs path for USB devices.
+func usbLoadPath(sysfsPath string) ([]USBEvent, error) {
+	result := []USBEvent{}
+
+	ents, err := os.ReadDir(sysfsPath)
 	if err != nil {
 		/* if there are no USB devices, let's render an empty list,
 		 * i.e. no usb devices */
@@ -247,7 +1765,7 @@ func (d *usb) loadUsb() ([]USBEvent, error) {
 	}
 
 	for _, ent := range ents {
-		values, err := d.loadRawValues(path.Join(usbDevPath, ent.Name()))
+		values, err := usbLoadRawValues(path.Join(sysfsPath, ent.Name()))
 		if err != nil {
 			if os.IsNotExist(err) {
 				continue
@@ -287,7 +1805,7 @@ func (d *usb) loadUsb() ([]USBEvent, error) {
 	return result, nil
 }
 
-func (d *usb) loadRawValues(p string) (map[string]string, error) {
+func usbLoadRawValues(p string) (map[string]string, error) {
 	values := map[string]string{
 		"idVendor":  "",
 		"idProduct": "",
@@ -320,3 +1838,74 @@ func (d *usb) getUniqueDeviceNameFromUSBEvent(e USBEvent) string {
 func (d *usb) CanHotPlug() bool {
 	return true
 }
+
+// DeviceState returns the state of the host USB devices matched by the device.
+func (d *usb) DeviceState() (*api.InstanceStateDevice, error) {
+	usbs, err := d.loadUsb()
+	if err != nil {
+		return nil, err
+	}
+
+	match := d.matchConfig()
+	state := api.InstanceStateDevice{}
+
+	// Only report the criterion in use when falling back from the primary one.
+	if d.config["fallback"] != "" {
+		state.Match = usbMatchString(match)
+	}
+
+	if d.config["standby"] != "" {
+		state.Match = usbMatchString(match)
+
+		if d.standbyAttached() && d.volatileGet()["last_state.match"] == "1" {
+			state.Active = "standby"
+		} else if d.standbyAttached() {
+			state.Active = "primary"
+		}
+	}
+
+	for _, usb := range usbs {
+		if !usbIsOurDevice(match, &usb) {
+			continue
+		}
+
+		usbState := api.InstanceStateDeviceUSB{
+			VendorID:      usb.Vendor,
+			ProductID:     usb.Product,
+			BusAddress:    usb.BusNum,
+			DeviceAddress: usb.DevNum,
+		}
+
+		devPath, err := usbSysfsPath(usbScanSysfsPaths(), usb.BusNum, usb.DevNum)
+		if err == nil {
+			usbState.MaxPower, _ = usbMaxPower(devPath)
+			usbState.HubMaxPower, _ = usbHubMaxPower(devPath)
+
+			// Only read the descriptors when requested as they are fairly verbose.
+			if shared.IsTrue(d.config["descriptors"]) {
+				usbState.Descriptors, err = usbDescriptors(devPath)
+				if err != nil {
+					d.logger.Warn("Failed reading USB device descriptors", logger.Ctx{"bus": usb.BusNum, "device": usb.DevNum, "err": err})
+				}
+			}
+		}
+
+		state.USB = append(state.USB, usbState)
+	}
+
+	remaining, ok := usbGraceRemaining(deviceRuntimeKey(d.inst.Project().Name, d.inst.Name(), d.name), time.Now())
+	if ok {
+		state.RequiredGraceRemaining = int64(remaining.Round(time.Second).Seconds())
+	}
+
+	for _, usb := range usbWindowPendingEvents(deviceRuntimeKey(d.inst.Project().Name, d.inst.Name(), d.name)) {
+		state.Pending = append(state.Pending, api.InstanceStateDeviceUSB{
+			VendorID:      usb.Vendor,
+			ProductID:     usb.Product,
+			BusAddress:    usb.BusNum,
+			DeviceAddress: usb.DevNum,
+		})
+	}
+
+	return &state, nil
+}
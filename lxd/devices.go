@@ -2,16 +2,20 @@ package main
 
 import (
 	"fmt"
+	"net/url"
 	"os"
 	"path"
 	"path/filepath"
 	"sort"
 	"strconv"
 	"strings"
+	"sync"
 
 	"golang.org/x/sys/unix"
 
+	lxd "github.com/lxc/lxd/client"
 	"github.com/lxc/lxd/lxd/cgroup"
+	"github.com/lxc/lxd/lxd/cluster"
 	"github.com/lxc/lxd/lxd/device"
 	_ "github.com/lxc/lxd/lxd/include" // Used by cgo
 	"github.com/lxc/lxd/lxd/instance"
@@ -599,6 +603,56 @@ func devicesRegister(instances []instance.Instance) {
 	}
 }
 
+// devicesRecheckRestrictionsMu serializes the re-checks of the devices against the restrictions of their projects.
+var devicesRecheckRestrictionsMu sync.Mutex
+
+// devicesRecheckRestrictions re-checks the devices of the running instances on this member against the current
+// restrictions of their projects (or only of the named project if not empty), stopping the devices that are now
+// forbidden and starting those that are allowed again. If the instances can't be loaded, their devices are left
+// as they are.
+func devicesRecheckRestrictions(s *state.State, projectName string) {
+	devicesRecheckRestrictionsMu.Lock()
+	defer devicesRecheckRestrictionsMu.Unlock()
+
+	instances, err := instance.LoadNodeAll(s, instancetype.Any)
+	if err != nil {
+		logger.Error("Failed loading instances to re-check device restrictions", logger.Ctx{"err": err})
+		return
+	}
+
+	for _, inst := range instances {
+		if projectName != "" && inst.Project().Name != projectName {
+			continue
+		}
+
+		if !inst.IsRunning() {
+			continue
+		}
+
+		err = inst.RecheckDeviceRestrictions()
+		if err != nil {
+			logger.Error("Failed re-checking device restrictions", logger.Ctx{"project": inst.Project().Name, "instance": inst.Name(), "err": err})
+		}
+	}
+}
+
+// devicesRecheckProjectRestrictions re-checks the devices of the running instances of the project on this member
+// against its restrictions, and notifies the other members so that they re-check theirs.
+func devicesRecheckProjectRestrictions(d *Daemon, projectName string) error {
+	devicesRecheckRestrictions(d.State(), projectName)
+
+	// Notify all other members. If a member is down, it will be ignored.
+	notifier, err := cluster.NewNotifier(d.State(), d.endpoints.NetworkCert(), d.serverCert(), cluster.NotifyAlive)
+	if err != nil {
+		return err
+	}
+
+	return notifier(func(client lxd.InstanceServer) error {
+		_, _, err := client.RawQuery("POST", fmt.Sprintf("/internal/devices/recheck?project=%s", url.QueryEscape(projectName)), nil, "")
+		return err
+	})
+}
+
 func getHidrawDevInfo(fd int) (string, string, error) {
 	info := C.struct_hidraw_devinfo{}
 	ret, err := C.get_hidraw_devinfo(C.int(fd), &info)
@@ -617,6 +671,11 @@ func ueventParseVendorProduct(props map[string]string, subsystem string, devname
 		return vendor, product, true
 	}
 
+	// SCSI device nodes are matched using the attributes of the SCSI device rather than vendor and product IDs.
+	if subsystem == "scsi_generic" || subsystem == "scsi_tape" {
+		return "", "", true
+	}
+
 	if subsystem != "hidraw" {
 		return "", "", false
 	}
@@ -25,6 +25,7 @@ import (
 	"github.com/lxc/lxd/lxd/instance/instancetype"
 	"github.com/lxc/lxd/lxd/instance/operationlock"
 	"github.com/lxc/lxd/lxd/maas"
+	"github.com/lxc/lxd/lxd/metrics"
 	"github.com/lxc/lxd/lxd/operations"
 	"github.com/lxc/lxd/lxd/project"
 	"github.com/lxc/lxd/lxd/revert"
@@ -64,6 +65,7 @@ type common struct {
 	ephemeral       bool
 	expandedConfig  map[string]string
 	expandedDevices deviceConfig.Devices
+	deviceSources   map[string]string
 	expiryDate      time.Time
 	id              int
 	lastUsedDate    time.Time
@@ -122,6 +124,11 @@ func (d *common) ExpandedDevices() deviceConfig.Devices {
 	return d.expandedDevices
 }
 
+// ExpandedDeviceSource returns where the named expanded device is defined ("instance" or "profile:<name>").
+func (d *common) ExpandedDeviceSource(name string) string {
+	return d.deviceSources[name]
+}
+
 // ExpiryDate returns when this snapshot expires.
 func (d *common) ExpiryDate() time.Time {
 	if d.snapshot {
@@ -494,6 +501,7 @@ func (d *common) deviceVolatileSetFunc(devName string) func(save map[string]stri
 func (d *common) expandConfig() error {
 	d.expandedConfig = db.ExpandInstanceConfig(d.localConfig, d.profiles)
 	d.expandedDevices = db.ExpandInstanceDevices(d.localDevices, d.profiles)
+	d.deviceSources = db.ExpandInstanceDeviceSources(d.localDevices, d.profiles)
 
 	return nil
 }
@@ -1188,6 +1196,23 @@ func (d *common) deviceAdd(dev device.Device, instanceRunning bool) error {
 	return dev.Add()
 }
 
+// devicePreStage calls the PreStage() function of devices that support being pre-staged on a stopped instance.
+func (d *common) devicePreStage(dev device.Device) error {
+	preStager, ok := dev.(device.PreStager)
+	if !ok {
+		return nil
+	}
+
+	d.logger.Debug("Pre-staging device", logger.Ctx{"device": dev.Name(), "type": dev.Config()["type"]})
+
+	err := preStager.PreStage()
+	if err != nil {
+		return fmt.Errorf("Failed to pre-stage device %q: %w", dev.Name(), err)
+	}
+
+	return nil
+}
+
 // deviceRemove loads a new device and calls its Remove() function.
 func (d *common) deviceRemove(dev device.Device, instanceRunning bool) error {
 	l := d.logger.AddContext(logger.Ctx{"device": dev.Name(), "type": dev.Config()["type"]})
@@ -1197,7 +1222,14 @@ func (d *common) deviceRemove(dev device.Device, instanceRunning bool) error {
 		return fmt.Errorf("Device cannot be removed when instance is running")
 	}
 
-	return dev.Remove()
+	err := dev.Remove()
+	if err != nil {
+		return err
+	}
+
+	device.ForgetRuntime(d.Project().Name, d.Name(), dev.Name())
+
+	return nil
 }
 
 // devicesAdd adds devices to instance and registers with MAAS.
@@ -1261,6 +1293,12 @@ func (d *common) devicesRegister(inst instance.Instance) {
 			continue
 		}
 
+		err = device.ValidateRegister(dev)
+		if err != nil {
+			d.logger.Error("Failed to register device", logger.Ctx{"err": err, "device": entry.Name})
+			continue
+		}
+
 		// Check whether device wants to register for any events.
 		err = dev.Register()
 		if err != nil {
@@ -1270,6 +1308,76 @@ func (d *common) devicesRegister(inst instance.Instance) {
 	}
 }
 
+// devicesMetrics adds the device operation timings and hotplug handling errors to the instance metrics.
+func (d *common) devicesMetrics(inst instance.Instance, out *metrics.MetricSet) {
+	for _, entry := range d.ExpandedDevices().Sorted() {
+		for phase, duration := range device.Timings(inst, entry.Name) {
+			out.AddSamples(metrics.DeviceTimingSeconds, metrics.Sample{Value: duration.Seconds(), Labels: map[string]string{"device": entry.Name, "phase": phase}})
+		}
+
+		hotplugErrors, _, registered := device.HotplugErrors(inst, entry.Name)
+		if registered {
+			out.AddSamples(metrics.DeviceHotplugErrors, metrics.Sample{Value: float64(hotplugErrors), Labels: map[string]string{"device": entry.Name}})
+		}
+	}
+}
+
+// devicesState returns the state of the instance's devices that are able to report it.
+func (d *common) devicesState(inst instance.Instance) map[string]api.InstanceStateDevice {
+	devices := map[string]api.InstanceStateDevice{}
+
+	for _, entry := range d.ExpandedDevices().Sorted() {
+		dev, err := d.deviceLoad(inst, entry.Name, entry.Config)
+		if err != nil {
+			if errors.Is(err, device.ErrUnsupportedDevType) {
+				continue // Skip unsupported device (allows for mixed instance type profiles).
+			}
+
+			d.logger.Warn("Failed state validation for device", logger.Ctx{"err": err, "device": entry.Name})
+			continue
+		}
+
+		state := &api.InstanceStateDevice{}
+
+		devState, ok := dev.(device.DeviceState)
+		if ok {
+			state, err = devState.DeviceState()
+			if err != nil {
+				d.logger.Warn("Failed getting device state", logger.Ctx{"err": err, "device": entry.Name})
+				continue
+			}
+
+			if state == nil {
+				state = &api.InstanceStateDevice{}
+			}
+		}
+
+		timings := device.Timings(inst, entry.Name)
+		if timings != nil {
+			state.Timings = make(map[string]float64, len(timings))
+			for phase, duration := range timings {
+				state.Timings[phase] = duration.Seconds()
+			}
+		}
+
+		state.Status, state.StatusReason = device.Status(inst, entry.Name)
+		state.Source = d.ExpandedDeviceSource(entry.Name)
+		state.HotplugErrors, state.HotplugLastError, _ = device.HotplugErrors(inst, entry.Name)
+
+		if state.USB == nil && state.Timings == nil && state.Status == "" && state.Source == "" && state.HotplugErrors == 0 {
+			continue
+		}
+
+		devices[entry.Name] = *state
+	}
+
+	if len(devices) == 0 {
+		return nil
+	}
+
+	return devices
+}
+
 // devicesUpdate applies device changes to an instance.
 func (d *common) devicesUpdate(inst instance.Instance, removeDevices deviceConfig.Devices, addDevices deviceConfig.Devices, updateDevices deviceConfig.Devices, oldExpandedDevices deviceConfig.Devices, instanceRunning bool, userRequested bool) error {
 	revert := revert.New()
@@ -1350,6 +1458,13 @@ func (d *common) devicesUpdate(inst instance.Instance, removeDevices deviceConfi
 
 		revert.Add(func() { _ = d.deviceRemove(dev, instanceRunning) })
 
+		if !instanceRunning && userRequested {
+			err = d.devicePreStage(dev)
+			if err != nil {
+				return err
+			}
+		}
+
 		if instanceRunning {
 			err = dev.PreStartCheck()
 			if err != nil {
@@ -1409,12 +1524,111 @@ func (d *common) devicesUpdate(inst instance.Instance, removeDevices deviceConfi
 		if err != nil {
 			return fmt.Errorf("Failed to update device %q: %w", dev.Name(), err)
 		}
+
+		if !instanceRunning && userRequested {
+			err = d.devicePreStage(dev)
+			if err != nil {
+				return err
+			}
+		}
 	}
 
 	revert.Success()
 	return nil
 }
 
+// devicesRecheckRestrictions re-checks the devices of the running instance against the restrictions of its
+// project. The started devices that are now forbidden are stopped and blocked, whereas the blocked devices that
+// are allowed again are started. Devices that can't be stopped or started are logged and left as they are, as are
+// all the devices if the restrictions can't be checked.
+func (d *common) devicesRecheckRestrictions(inst instance.Instance) error {
+	dm, ok := inst.(deviceManager)
+	if !ok {
+		return fmt.Errorf("Instance is not compatible with deviceManager interface")
+	}
+
+	if !inst.IsRunning() {
+		return nil
+	}
+
+	// Check the restrictions once for all the devices.
+	forbidden, err := project.ForbiddenDevices(d.project, d.expandedDevices.CloneNative())
+	if err != nil {
+		return fmt.Errorf("Failed checking project restrictions: %w", err)
+	}
+
+	// Stop the forbidden devices in reverse order to how they were started.
+	for _, entry := range d.expandedDevices.Reversed() {
+		status, _ := device.Status(inst, entry.Name)
+		if status != device.StatusStarted {
+			continue
+		}
+
+		reason, ok := forbidden[entry.Name]
+		if !ok {
+			continue
+		}
+
+		dev, err := d.deviceLoad(inst, entry.Name, entry.Config)
+		if err != nil {
+			continue
+		}
+
+		l := d.logger.AddContext(logger.Ctx{"device": entry.Name, "reason": reason})
+
+		if !dev.CanHotPlug() {
+			l.Warn("Device forbidden by project restrictions can't be stopped whilst the instance is running, blocking it when the instance next starts")
+			continue
+		}
+
+		err = dm.deviceStop(dev, true, "")
+		if err != nil {
+			l.Error("Failed stopping device forbidden by project restrictions", logger.Ctx{"err": err})
+			continue
+		}
+
+		device.SetStatus(d.state, inst, entry.Name, device.StatusBlocked, reason)
+		l.Warn("Blocked device forbidden by project restrictions")
+	}
+
+	// Start the blocked devices that are allowed again in sorted order, as when adding devices.
+	for _, entry := range d.expandedDevices.Sorted() {
+		status, _ := device.Status(inst, entry.Name)
+		_, ok := forbidden[entry.Name]
+		if status != device.StatusBlocked || ok {
+			continue
+		}
+
+		l := d.logger.AddContext(logger.Ctx{"device": entry.Name})
+
+		dev, err := d.deviceLoad(inst, entry.Name, entry.Config)
+		if err != nil {
+			l.Error("Failed loading device allowed by project restrictions", logger.Ctx{"err": err})
+			continue
+		}
+
+		if !dev.CanHotPlug() {
+			continue
+		}
+
+		err = dev.PreStartCheck()
+		if err != nil {
+			l.Error("Failed pre-start check for device allowed by project restrictions", logger.Ctx{"err": err})
+			continue
+		}
+
+		_, err = dm.deviceStart(dev, true)
+		if err != nil && err != device.ErrUnsupportedDevType {
+			l.Error("Failed starting device allowed by project restrictions", logger.Ctx{"err": err})
+			continue
+		}
+
+		l.Info("Started device allowed by project restrictions")
+	}
+
+	return nil
+}
+
 // devicesRemove runs device removal function for each device.
 func (d *common) devicesRemove(inst instance.Instance) {
 	for _, entry := range d.expandedDevices.Reversed() {
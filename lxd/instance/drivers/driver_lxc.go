@@ -203,7 +203,7 @@ func lxcCreate(s *state.State, args db.InstanceArgs, p api.Project) (instance.In
 		return nil, nil, fmt.Errorf("Invalid config: %w", err)
 	}
 
-	err = instance.ValidDevices(s, d.project, d.Type(), d.localDevices, d.expandedDevices)
+	err = instance.ValidDevices(s, d.project, d.Type(), d.expandedConfig, d.localDevices, d.expandedDevices)
 	if err != nil {
 		return nil, nil, fmt.Errorf("Invalid devices: %w", err)
 	}
@@ -710,12 +710,7 @@ func (d *lxc) initLXC(config bool) error {
 
 	if d.IsPrivileged() {
 		// Base config
-		toDrop := "sys_time sys_module sys_rawio"
-		if !d.state.OS.AppArmorStacking || d.state.OS.AppArmorStacked {
-			toDrop = toDrop + " mac_admin mac_override"
-		}
-
-		err = lxcSetConfigItem(cc, "lxc.cap.drop", toDrop)
+		err = lxcSetConfigItem(cc, "lxc.cap.drop", strings.Join(instance.PrivilegedCapabilitiesDrop(d.state.OS), " "))
 		if err != nil {
 			return err
 		}
@@ -1325,6 +1320,12 @@ func (d *lxc) devlxdEventSend(eventType string, eventMessage map[string]any) err
 	return d.state.DevlxdEvents.Send(d.ID(), eventType, eventMessage)
 }
 
+// RecheckDeviceRestrictions stops the running instance's devices that the restrictions of its project now forbid
+// and starts those that they allow again.
+func (d *lxc) RecheckDeviceRestrictions() error {
+	return d.devicesRecheckRestrictions(d)
+}
+
 // RegisterDevices calls the Register() function on all of the instance's devices.
 func (d *lxc) RegisterDevices() {
 	d.devicesRegister(d)
@@ -1343,18 +1344,66 @@ func (d *lxc) deviceStart(dev device.Device, instanceRunning bool) (*deviceConfi
 		return nil, fmt.Errorf("Device cannot be started when instance is running")
 	}
 
-	runConf, err := dev.Start()
+	// Any lifecycle state left from a previous run of the instance is stale if it isn't running.
+	if !instanceRunning {
+		device.ResetLifecycleState(d, dev.Name())
+	}
+
+	// Skip or fail starting the device if a sibling device it requires isn't started.
+	skip, err := device.CheckRequiredDevice(d.state, d, dev)
 	if err != nil {
 		return nil, err
 	}
 
+	if skip {
+		return nil, nil
+	}
+
+	revert.Add(func() { device.SetStatus(d.state, d, dev.Name(), device.StatusFailed, "") })
+
+	// Starting a device that is already started is a no-op, so that it can be safely retried.
+	runConf, started, err := device.Start(d, dev)
+	if err != nil {
+		return nil, device.Remediate(d, dev, err)
+	}
+
+	if started {
+		l.Debug("Device already started")
+		revert.Success()
+		return runConf, nil
+	}
+
+	revert.Add(func() { _ = dev.Transition(device.LifecycleStateStopped) })
+
+	l.Debug("Started device", logger.Ctx{"duration": device.Timings(d, dev.Name())["start"]})
+
 	revert.Add(func() {
+		release := device.OperationAcquire()
+		defer release()
+
 		runConf, _ := dev.Stop()
 		if runConf != nil {
 			_ = d.runHooks(runConf.PostHooks)
 		}
 	})
 
+	// Check the device doesn't use the same container paths as another device.
+	err = device.CheckPathConflicts(d, dev.Name(), runConf)
+	if err != nil {
+		return nil, err
+	}
+
+	// Run the post start lifecycle hooks along with the device's own post start hooks.
+	if device.LifecycleHooksRegistered(device.LifecycleHookPostStart) {
+		if runConf == nil {
+			runConf = &deviceConfig.RunConfig{}
+		}
+
+		runConf.PostHooks = append(runConf.PostHooks, func() error {
+			return device.RunLifecycleHooks(device.LifecycleHookPostStart, d, dev)
+		})
+	}
+
 	// If runConf supplied, perform any container specific setup of device.
 	if runConf != nil {
 		// Shift device file ownership if needed before mounting into container.
@@ -1366,6 +1415,11 @@ func (d *lxc) deviceStart(dev device.Device, instanceRunning bool) (*deviceConfi
 			}
 		}
 
+		// Named network namespaces are created by the container once running, so don't exist yet.
+		if !instanceRunning && len(runConf.NetworkInterface) > 0 && configCopy["netns"] != "" {
+			l.Warn("Network namespace doesn't exist when starting, NIC left in the default network namespace", logger.Ctx{"netns": configCopy["netns"]})
+		}
+
 		// If container is running and then live attach device.
 		if instanceRunning {
 			// Attach mounts if requested.
@@ -1386,7 +1440,7 @@ func (d *lxc) deviceStart(dev device.Device, instanceRunning bool) (*deviceConfi
 
 			// Attach network interface if requested.
 			if len(runConf.NetworkInterface) > 0 {
-				err = d.deviceAttachNIC(configCopy, runConf.NetworkInterface)
+				err = d.deviceAttachNIC(dev.Name(), configCopy, runConf.NetworkInterface)
 				if err != nil {
 					return nil, err
 				}
@@ -1401,10 +1455,39 @@ func (d *lxc) deviceStart(dev device.Device, instanceRunning bool) (*deviceConfi
 		}
 	}
 
+	device.RecordRunConfig(d, dev.Name(), runConf)
+
+	err = dev.Transition(device.LifecycleStateStarted)
+	if err != nil {
+		return nil, err
+	}
+
+	device.SetStatus(d.state, d, dev.Name(), device.StatusStarted, "")
+
 	revert.Success()
 	return runConf, nil
 }
 
+// devicesStartAsync starts the devices that are started asynchronously by hotplugging them into the running
+// container in the background. The container is stopped if a required device doesn't start within its grace period.
+func (d *lxc) devicesStartAsync(devs []device.Device) {
+	start := func(dev device.Device) error {
+		_, err := d.deviceStart(dev, true)
+		return err
+	}
+
+	stop := func(err error) {
+		d.logger.Error("Stopping instance as a required device failed to start", logger.Ctx{"err": err})
+
+		err = d.Stop(false)
+		if err != nil {
+			d.logger.Error("Failed stopping instance", logger.Ctx{"err": err})
+		}
+	}
+
+	device.StartAsyncRun(d.state, d, devs, start, stop)
+}
+
 // deviceStaticShiftMounts statically shift device mount files ownership to active idmap if needed.
 func (d *lxc) deviceStaticShiftMounts(mounts []deviceConfig.MountEntryItem) error {
 	idmapSet, err := d.CurrentIdmap()
@@ -1456,8 +1539,56 @@ func (d *lxc) deviceAddCgroupRules(cgroups []deviceConfig.RunConfigItem) error {
 	return nil
 }
 
+// deviceCgroupResidue returns the devices cgroup deny rules that haven't taken effect, as the devices are still
+// allowed by the cgroup of the running container. The rules can only be checked with the V1 devices controller.
+func (d *lxc) deviceCgroupResidue(cgroups []deviceConfig.RunConfigItem, instanceRunning bool) []string {
+	if !instanceRunning || !d.isCurrentlyPrivileged() || d.state.OS.RunningInUserNS {
+		return nil
+	}
+
+	cg, err := d.cgroup(nil)
+	if err != nil {
+		return nil
+	}
+
+	version, _ := d.state.OS.CGInfo.SupportsVersion(cgroup.Devices)
+	if version != cgroup.V1 || !d.state.OS.CGInfo.Supports(cgroup.Devices, cg) {
+		return nil
+	}
+
+	allowed, err := cg.GetDevices()
+	if err != nil {
+		d.logger.Warn("Failed getting devices cgroup rules", logger.Ctx{"err": err})
+		return nil
+	}
+
+	residue := []string{}
+	for _, rule := range cgroups {
+		if rule.Key != "devices.deny" {
+			continue
+		}
+
+		// Compare the type and device number of the rules (such as "c 1:3"), ignoring the access.
+		fields := strings.Fields(rule.Value)
+		if len(fields) < 2 {
+			continue
+		}
+
+		for _, entry := range allowed {
+			entryFields := strings.Fields(entry)
+			if len(entryFields) >= 2 && entryFields[0] == fields[0] && entryFields[1] == fields[1] {
+				residue = append(residue, fmt.Sprintf("cgroup rule %q", entry))
+				break
+			}
+		}
+	}
+
+	return residue
+}
+
 // deviceAttachNIC live attaches a NIC device to a container.
-func (d *lxc) deviceAttachNIC(configCopy map[string]string, netIF []deviceConfig.RunConfigItem) error {
+// If the device selects a network namespace, the interface is then moved into it.
+func (d *lxc) deviceAttachNIC(deviceName string, configCopy map[string]string, netIF []deviceConfig.RunConfigItem) error {
 	devName := ""
 	for _, dev := range netIF {
 		if dev.Key == "link" {
@@ -1470,6 +1601,14 @@ func (d *lxc) deviceAttachNIC(configCopy map[string]string, netIF []deviceConfig
 		return fmt.Errorf("Device didn't provide a link property to use")
 	}
 
+	netnsPath := ""
+	if configCopy["netns"] != "" {
+		netnsPath = device.NetworkNamespacePath(d.InitPID(), configCopy["netns"])
+		if !shared.PathExists(netnsPath) {
+			return fmt.Errorf("Network namespace %q doesn't exist in the instance", configCopy["netns"])
+		}
+	}
+
 	// Load the go-lxc struct.
 	err := d.initLXC(false)
 	if err != nil {
@@ -1482,6 +1621,20 @@ func (d *lxc) deviceAttachNIC(configCopy map[string]string, netIF []deviceConfig
 		return fmt.Errorf("Failed to attach interface: %s to %s: %w", devName, configCopy["name"], err)
 	}
 
+	if netnsPath != "" {
+		err = d.moveInterfaceNetns(fmt.Sprintf("/proc/%d/ns/net", d.InitPID()), netnsPath, configCopy["name"])
+		if err != nil {
+			_ = d.c.DetachInterfaceRename(configCopy["name"], devName)
+			return fmt.Errorf("Failed to move interface %q into network namespace %q: %w", configCopy["name"], configCopy["netns"], err)
+		}
+
+		// Record the namespace so that the interface is detached from it even if the config changes.
+		err = d.VolatileSet(map[string]string{fmt.Sprintf("volatile.%s.last_state.netns", deviceName): configCopy["netns"]})
+		if err != nil {
+			return err
+		}
+	}
+
 	return nil
 }
 
@@ -1497,15 +1650,47 @@ func (d *lxc) deviceStop(dev device.Device, instanceRunning bool, stopHookNetnsP
 		return fmt.Errorf("Device cannot be stopped when instance is running")
 	}
 
-	runConf, err := dev.Stop()
+	// Wait for the device to finish starting if it is started asynchronously.
+	device.StartAsyncWait(d, dev.Name())
+
+	// Devices that were skipped, blocked or failed during start don't need stopping.
+	status, _ := device.Status(d, dev.Name())
+	if status == device.StatusSkipped || status == device.StatusBlocked || status == device.StatusFailed {
+		device.SetStatus(d.state, d, dev.Name(), device.StatusStopped, "")
+		return nil
+	}
+
+	// Stopping a device that is already stopped is a no-op, so that it can be safely retried.
+	runConf, stopped, err := device.Stop(d, dev)
 	if err != nil {
 		return err
 	}
 
+	if stopped {
+		l.Debug("Device already stopped")
+		return nil
+	}
+
+	// If stopping fails, consider the device still started so that stopping it can be retried.
+	revert := revert.New()
+	defer revert.Fail()
+	revert.Add(func() { _ = dev.Transition(device.LifecycleStateStarted) })
+
+	// Run the post stop lifecycle hooks along with the device's own post stop hooks.
+	if device.LifecycleHooksRegistered(device.LifecycleHookPostStop) {
+		if runConf == nil {
+			runConf = &deviceConfig.RunConfig{}
+		}
+
+		runConf.PostHooks = append(runConf.PostHooks, func() error {
+			return device.RunLifecycleHooks(device.LifecycleHookPostStop, d, dev)
+		})
+	}
+
 	if runConf != nil {
 		// If network interface settings returned, then detach NIC from container.
 		if len(runConf.NetworkInterface) > 0 {
-			err = d.deviceDetachNIC(configCopy, runConf.NetworkInterface, instanceRunning, stopHookNetnsPath)
+			err = d.deviceDetachNIC(dev.Name(), configCopy, runConf.NetworkInterface, instanceRunning, stopHookNetnsPath)
 			if err != nil {
 				return err
 			}
@@ -1534,13 +1719,33 @@ func (d *lxc) deviceStop(dev device.Device, instanceRunning bool, stopHookNetnsP
 		}
 	}
 
+	// Check the device's files and cgroup rules were cleaned up, re-applying the cgroup rules if they weren't.
+	var cgroups []deviceConfig.RunConfigItem
+	if runConf != nil {
+		cgroups = runConf.CGroups
+	}
+
+	device.VerifyCleanup(d, dev, func() []string {
+		return d.deviceCgroupResidue(cgroups, instanceRunning)
+	}, func() error {
+		return d.deviceAddCgroupRules(cgroups)
+	})
+
+	err = dev.Transition(device.LifecycleStateStopped)
+	if err != nil {
+		return err
+	}
+
+	device.SetStatus(d.state, d, dev.Name(), device.StatusStopped, "")
+
+	revert.Success()
 	return nil
 }
 
 // deviceDetachNIC detaches a NIC device from a container.
 // Accepts a stopHookNetnsPath argument which is required when run from the onStopNS hook before the
 // container's network namespace is unmounted (which is required for NIC device cleanup).
-func (d *lxc) deviceDetachNIC(configCopy map[string]string, netIF []deviceConfig.RunConfigItem, instanceRunning bool, stopHookNetnsPath string) error {
+func (d *lxc) deviceDetachNIC(deviceName string, configCopy map[string]string, netIF []deviceConfig.RunConfigItem, instanceRunning bool, stopHookNetnsPath string) error {
 	// Get requested device name to detach interface back to on the host.
 	devName := ""
 	for _, dev := range netIF {
@@ -1554,8 +1759,27 @@ func (d *lxc) deviceDetachNIC(configCopy map[string]string, netIF []deviceConfig
 		return fmt.Errorf("Device didn't provide a link property to use")
 	}
 
+	// Get the network namespace the interface was moved into when attached (if any).
+	netnsKey := fmt.Sprintf("volatile.%s.last_state.netns", deviceName)
+	netns := d.LocalConfig()[netnsKey]
+	if netns != "" {
+		defer func() { _ = d.VolatileSet(map[string]string{netnsKey: ""}) }()
+	}
+
 	// If container is running, perform live detach of interface back to host.
 	if instanceRunning {
+		// Move the interface back into the container's network namespace first. If the namespace has been
+		// deleted, the interface has gone with it.
+		if netns != "" {
+			netnsPath := device.NetworkNamespacePath(d.InitPID(), netns)
+			if shared.PathExists(netnsPath) {
+				err := d.moveInterfaceNetns(netnsPath, fmt.Sprintf("/proc/%d/ns/net", d.InitPID()), configCopy["name"])
+				if err != nil {
+					d.logger.Warn("Failed to move interface out of network namespace", logger.Ctx{"interface": configCopy["name"], "netns": netns, "err": err})
+				}
+			}
+		}
+
 		// For some reason, having network config confuses detach, so get our own go-lxc struct.
 		cname := project.Instance(d.Project().Name, d.Name())
 		cc, err := liblxc.NewContainer(cname, d.state.OS.LxcPath)
@@ -1630,6 +1854,11 @@ func (d *lxc) deviceHandleMounts(mounts []deviceConfig.MountEntryItem) error {
 				if idmapType == idmap.IdmapStorageNone {
 					return fmt.Errorf("Required idmapping abilities not available")
 				}
+			} else if !d.IsPrivileged() && mount.OwnerShift == deviceConfig.MountOwnerShiftIdmapped {
+				idmapType = d.IdmappedStorage(mount.DevPath)
+				if idmapType != idmap.IdmapStorageIdmapped {
+					return fmt.Errorf("Idmapped mounts not supported")
+				}
 			}
 
 			// Mount it into the container.
@@ -1946,10 +2175,19 @@ func (d *lxc) startCommon() (string, []func() error, error) {
 
 	sortedDevices := d.expandedDevices.Sorted()
 	startDevices := make([]device.Device, 0, len(sortedDevices))
+	asyncDevices := []device.Device{}
+
+	// Skip the devices that the restrictions of the project forbid, checking them once for all the devices.
+	blockedDevices := device.BlockRestrictedDevices(d.state, d, d.expandedDevices)
 
 	// Load devices in sorted order, this ensures that device mounts are added in path order.
 	// Loading all devices first means that validation of all devices occurs before starting any of them.
 	for _, entry := range sortedDevices {
+		_, blocked := blockedDevices[entry.Name]
+		if blocked {
+			continue
+		}
+
 		dev, err := d.deviceLoad(d, entry.Name, entry.Config)
 		if err != nil {
 			if errors.Is(err, device.ErrUnsupportedDevType) {
@@ -1972,6 +2210,13 @@ func (d *lxc) startCommon() (string, []func() error, error) {
 	for i := range startDevices {
 		dev := startDevices[i] // Local var for revert.
 
+		// Devices started asynchronously are hotplugged once the container has started.
+		async, _ := device.StartAsync(dev)
+		if async {
+			asyncDevices = append(asyncDevices, dev)
+			continue
+		}
+
 		// Start the device.
 		runConf, err := d.deviceStart(dev, false)
 		if err != nil {
@@ -2087,7 +2332,13 @@ func (d *lxc) startCommon() (string, []func() error, error) {
 
 				mntOptions := strings.Join(mount.Opts, ",")
 
-				if !d.IsPrivileged() && mount.OwnerShift == deviceConfig.MountOwnerShiftDynamic {
+				if !d.IsPrivileged() && mount.OwnerShift == deviceConfig.MountOwnerShiftIdmapped {
+					if d.IdmappedStorage(mount.DevPath) != idmap.IdmapStorageIdmapped {
+						return "", nil, fmt.Errorf("Failed to setup device mount %q: %w", dev.Name(), fmt.Errorf("Idmapped mounts not supported"))
+					}
+
+					mntOptions = strings.Join([]string{mntOptions, "idmap=container"}, ",")
+				} else if !d.IsPrivileged() && mount.OwnerShift == deviceConfig.MountOwnerShiftDynamic {
 					switch d.IdmappedStorage(mount.DevPath) {
 					case idmap.IdmapStorageIdmapped:
 						mntOptions = strings.Join([]string{mntOptions, "idmap=container"}, ",")
@@ -2138,6 +2389,14 @@ func (d *lxc) startCommon() (string, []func() error, error) {
 			}
 		}
 
+		// Add any environment variables exported by the device.
+		for _, envItem := range runConf.Environment {
+			err = lxcSetConfigItem(d.c, "lxc.environment", fmt.Sprintf("%s=%s", envItem.Key, envItem.Value))
+			if err != nil {
+				return "", nil, fmt.Errorf("Failed to setup device environment %q: %w", dev.Name(), err)
+			}
+		}
+
 		// Add any post start hooks.
 		if len(runConf.PostHooks) > 0 {
 			postStartHooks = append(postStartHooks, runConf.PostHooks...)
@@ -2153,6 +2412,13 @@ func (d *lxc) startCommon() (string, []func() error, error) {
 		}
 	}
 
+	if len(asyncDevices) > 0 {
+		postStartHooks = append(postStartHooks, func() error {
+			d.devicesStartAsync(asyncDevices)
+			return nil
+		})
+	}
+
 	// Override NVIDIA_VISIBLE_DEVICES if we have devices that need it.
 	if len(nvidiaDevices) > 0 {
 		err = lxcSetConfigItem(d.c, "lxc.environment", fmt.Sprintf("NVIDIA_VISIBLE_DEVICES=%s", strings.Join(nvidiaDevices, ",")))
@@ -2215,6 +2481,22 @@ func (d *lxc) startCommon() (string, []func() error, error) {
 	return configPath, postStartHooks, nil
 }
 
+// moveInterfaceNetns enters the network namespace at the srcNetns path and moves the named interface in ifName
+// into the network namespace at the dstNetns path.
+func (d *lxc) moveInterfaceNetns(srcNetns string, dstNetns string, ifName string) error {
+	_, err := shared.RunCommand(
+		d.state.OS.ExecPath,
+		"forknet",
+		"move",
+		"--",
+		srcNetns,
+		dstNetns,
+		ifName,
+	)
+
+	return err
+}
+
 // detachInterfaceRename enters the container's network namespace and moves the named interface
 // in ifName back to the network namespace of the running process as the name specified in hostName.
 func (d *lxc) detachInterfaceRename(netns string, ifName string, hostName string) error {
@@ -3310,6 +3592,7 @@ func (d *lxc) renderState(statusCode api.StatusCode) (*api.InstanceState, error)
 		status.Network = d.networkState()
 		status.Pid = int64(pid)
 		status.Processes = d.processesState()
+		status.Devices = d.devicesState(d)
 	}
 
 	status.Disk = d.diskState()
@@ -3949,8 +4232,16 @@ func (d *lxc) Update(args db.InstanceArgs, userRequested bool) error {
 			return fmt.Errorf("Invalid config: %w", err)
 		}
 
+		// Normalise the new devices so that equivalent configs aren't seen as changes and are stored the same way.
+		args.Devices.Normalise()
+
 		// Validate the new devices without using expanded devices validation (expensive checks disabled).
-		err = instance.ValidDevices(d.state, d.project, d.Type(), args.Devices, nil)
+		err = instance.ValidDevices(d.state, d.project, d.Type(), nil, args.Devices, nil)
+		if err != nil {
+			return fmt.Errorf("Invalid devices: %w", err)
+		}
+
+		err = device.ValidateNewDevices(d.state, d.project.Name, d.localDevices, args.Devices)
 		if err != nil {
 			return fmt.Errorf("Invalid devices: %w", err)
 		}
@@ -4003,6 +4294,9 @@ func (d *lxc) Update(args db.InstanceArgs, userRequested bool) error {
 		return err
 	}
 
+	// The device sources are replaced rather than modified when the config is expanded.
+	oldDeviceSources := d.deviceSources
+
 	oldExpandedConfig := map[string]string{}
 	err = shared.DeepCopy(&d.expandedConfig, &oldExpandedConfig)
 	if err != nil {
@@ -4041,6 +4335,7 @@ func (d *lxc) Update(args db.InstanceArgs, userRequested bool) error {
 			d.ephemeral = oldEphemeral
 			d.expandedConfig = oldExpandedConfig
 			d.expandedDevices = oldExpandedDevices
+			d.deviceSources = oldDeviceSources
 			d.localConfig = oldLocalConfig
 			d.localDevices = oldLocalDevices
 			d.profiles = oldProfiles
@@ -4131,7 +4426,7 @@ func (d *lxc) Update(args db.InstanceArgs, userRequested bool) error {
 		}
 
 		// Do full expanded validation of the devices diff.
-		err = instance.ValidDevices(d.state, d.project, d.Type(), d.localDevices, d.expandedDevices)
+		err = instance.ValidDevices(d.state, d.project, d.Type(), d.expandedConfig, d.localDevices, d.expandedDevices)
 		if err != nil {
 			return fmt.Errorf("Invalid expanded devices: %w", err)
 		}
@@ -7003,6 +7298,8 @@ func (d *lxc) Metrics() (*metrics.MetricSet, error) {
 		out.AddSamples(metrics.ProcsTotal, metrics.Sample{Value: float64(pids)})
 	}
 
+	d.devicesMetrics(d, out)
+
 	return out, nil
 }
 
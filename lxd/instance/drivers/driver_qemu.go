@@ -231,7 +231,7 @@ func qemuCreate(s *state.State, args db.InstanceArgs, p api.Project) (instance.I
 		return nil, nil, fmt.Errorf("Invalid config: %w", err)
 	}
 
-	err = instance.ValidDevices(s, d.project, d.Type(), d.localDevices, d.expandedDevices)
+	err = instance.ValidDevices(s, d.project, d.Type(), d.expandedConfig, d.localDevices, d.expandedDevices)
 	if err != nil {
 		return nil, nil, fmt.Errorf("Invalid devices: %w", err)
 	}
@@ -1115,9 +1115,17 @@ func (d *qemu) Start(stateful bool) error {
 	sortedDevices := d.expandedDevices.Sorted()
 	startDevices := make([]device.Device, 0, len(sortedDevices))
 
+	// Skip the devices that the restrictions of the project forbid, checking them once for all the devices.
+	blockedDevices := device.BlockRestrictedDevices(d.state, d, d.expandedDevices)
+
 	// Load devices in sorted order, this ensures that device mounts are added in path order.
 	// Loading all devices first means that validation of all devices occurs before starting any of them.
 	for _, entry := range sortedDevices {
+		_, blocked := blockedDevices[entry.Name]
+		if blocked {
+			continue
+		}
+
 		dev, err := d.deviceLoad(d, entry.Name, entry.Config)
 		if err != nil {
 			op.Done(err)
@@ -1140,9 +1148,17 @@ func (d *qemu) Start(stateful bool) error {
 	}
 
 	// Start devices in order.
+	asyncDevices := []device.Device{}
 	for i := range startDevices {
 		dev := startDevices[i] // Local var for revert.
 
+		// Devices started asynchronously are hotplugged once the VM has started.
+		async, _ := device.StartAsync(dev)
+		if async {
+			asyncDevices = append(asyncDevices, dev)
+			continue
+		}
+
 		// Start the device.
 		runConf, err := d.deviceStart(dev, false)
 		if err != nil {
@@ -1173,6 +1189,13 @@ func (d *qemu) Start(stateful bool) error {
 		devConfs = append(devConfs, runConf)
 	}
 
+	if len(asyncDevices) > 0 {
+		postStartHooks = append(postStartHooks, func() error {
+			d.devicesStartAsync(asyncDevices)
+			return nil
+		})
+	}
+
 	// Setup the config drive readonly bind mount. Important that this come after the root disk device start.
 	// in order to allow unmounts triggered by deferred resizes of the root volume.
 	configMntPath := d.configDriveMountPath()
@@ -1720,6 +1743,12 @@ func (d *qemu) qemuArchConfig(arch int) (string, string, error) {
 	return "", "", fmt.Errorf("Architecture isn't supported for virtual machines")
 }
 
+// RecheckDeviceRestrictions stops the running instance's devices that the restrictions of its project now forbid
+// and starts those that they allow again.
+func (d *qemu) RecheckDeviceRestrictions() error {
+	return d.devicesRecheckRestrictions(d)
+}
+
 // RegisterDevices calls the Register() function on all of the instance's devices.
 func (d *qemu) RegisterDevices() {
 	d.devicesRegister(d)
@@ -1749,18 +1778,60 @@ func (d *qemu) deviceStart(dev device.Device, instanceRunning bool) (*deviceConf
 		return nil, fmt.Errorf("Device cannot be started when instance is running")
 	}
 
-	runConf, err := dev.Start()
+	// Any lifecycle state left from a previous run of the instance is stale if it isn't running.
+	if !instanceRunning {
+		device.ResetLifecycleState(d, dev.Name())
+	}
+
+	// Skip or fail starting the device if a sibling device it requires isn't started.
+	skip, err := device.CheckRequiredDevice(d.state, d, dev)
 	if err != nil {
 		return nil, err
 	}
 
+	if skip {
+		return nil, nil
+	}
+
+	revert.Add(func() { device.SetStatus(d.state, d, dev.Name(), device.StatusFailed, "") })
+
+	// Starting a device that is already started is a no-op, so that it can be safely retried.
+	runConf, started, err := device.Start(d, dev)
+	if err != nil {
+		return nil, device.Remediate(d, dev, err)
+	}
+
+	if started {
+		l.Debug("Device already started")
+		revert.Success()
+		return runConf, nil
+	}
+
+	revert.Add(func() { _ = dev.Transition(device.LifecycleStateStopped) })
+
+	l.Debug("Started device", logger.Ctx{"duration": device.Timings(d, dev.Name())["start"]})
+
 	revert.Add(func() {
+		release := device.OperationAcquire()
+		defer release()
+
 		runConf, _ := dev.Stop()
 		if runConf != nil {
 			_ = d.runHooks(runConf.PostHooks)
 		}
 	})
 
+	// Run the post start lifecycle hooks along with the device's own post start hooks.
+	if device.LifecycleHooksRegistered(device.LifecycleHookPostStart) {
+		if runConf == nil {
+			runConf = &deviceConfig.RunConfig{}
+		}
+
+		runConf.PostHooks = append(runConf.PostHooks, func() error {
+			return device.RunLifecycleHooks(device.LifecycleHookPostStart, d, dev)
+		})
+	}
+
 	// If runConf supplied, perform any instance specific setup of device.
 	if runConf != nil {
 		// If instance is running and then live attach device.
@@ -1796,6 +1867,15 @@ func (d *qemu) deviceStart(dev device.Device, instanceRunning bool) (*deviceConf
 		}
 	}
 
+	device.RecordRunConfig(d, dev.Name(), runConf)
+
+	err = dev.Transition(device.LifecycleStateStarted)
+	if err != nil {
+		return nil, err
+	}
+
+	device.SetStatus(d.state, d, dev.Name(), device.StatusStarted, "")
+
 	revert.Success()
 	return runConf, nil
 }
@@ -1932,6 +2012,26 @@ func (d *qemu) deviceAttachNIC(deviceName string, configCopy map[string]string,
 	return nil
 }
 
+// devicesStartAsync starts the devices that are started asynchronously by hotplugging them into the running VM
+// in the background. The VM is stopped if a required device doesn't start within its grace period.
+func (d *qemu) devicesStartAsync(devs []device.Device) {
+	start := func(dev device.Device) error {
+		_, err := d.deviceStart(dev, true)
+		return err
+	}
+
+	stop := func(err error) {
+		d.logger.Error("Stopping instance as a required device failed to start", logger.Ctx{"err": err})
+
+		err = d.Stop(false)
+		if err != nil {
+			d.logger.Error("Failed stopping instance", logger.Ctx{"err": err})
+		}
+	}
+
+	device.StartAsyncRun(d.state, d, devs, start, stop)
+}
+
 // deviceStop loads a new device and calls its Stop() function.
 func (d *qemu) deviceStop(dev device.Device, instanceRunning bool, _ string) error {
 	configCopy := dev.Config()
@@ -1942,11 +2042,43 @@ func (d *qemu) deviceStop(dev device.Device, instanceRunning bool, _ string) err
 		return fmt.Errorf("Device cannot be stopped when instance is running")
 	}
 
-	runConf, err := dev.Stop()
+	// Wait for the device to finish starting if it is started asynchronously.
+	device.StartAsyncWait(d, dev.Name())
+
+	// Devices that were skipped, blocked or failed during start don't need stopping.
+	status, _ := device.Status(d, dev.Name())
+	if status == device.StatusSkipped || status == device.StatusBlocked || status == device.StatusFailed {
+		device.SetStatus(d.state, d, dev.Name(), device.StatusStopped, "")
+		return nil
+	}
+
+	// Stopping a device that is already stopped is a no-op, so that it can be safely retried.
+	runConf, stopped, err := device.Stop(d, dev)
 	if err != nil {
 		return err
 	}
 
+	if stopped {
+		l.Debug("Device already stopped")
+		return nil
+	}
+
+	// If stopping fails, consider the device still started so that stopping it can be retried.
+	revert := revert.New()
+	defer revert.Fail()
+	revert.Add(func() { _ = dev.Transition(device.LifecycleStateStarted) })
+
+	// Run the post stop lifecycle hooks along with the device's own post stop hooks.
+	if device.LifecycleHooksRegistered(device.LifecycleHookPostStop) {
+		if runConf == nil {
+			runConf = &deviceConfig.RunConfig{}
+		}
+
+		runConf.PostHooks = append(runConf.PostHooks, func() error {
+			return device.RunLifecycleHooks(device.LifecycleHookPostStop, d, dev)
+		})
+	}
+
 	if instanceRunning {
 		// Detach NIC from running instance.
 		if configCopy["type"] == "nic" {
@@ -1983,6 +2115,16 @@ func (d *qemu) deviceStop(dev device.Device, instanceRunning bool, _ string) err
 		}
 	}
 
+	device.VerifyCleanup(d, dev, nil, nil)
+
+	err = dev.Transition(device.LifecycleStateStopped)
+	if err != nil {
+		return err
+	}
+
+	device.SetStatus(d.state, d, dev.Name(), device.StatusStopped, "")
+
+	revert.Success()
 	return nil
 }
 
@@ -4406,8 +4548,16 @@ func (d *qemu) Update(args db.InstanceArgs, userRequested bool) error {
 			return fmt.Errorf("Invalid config: %w", err)
 		}
 
+		// Normalise the new devices so that equivalent configs aren't seen as changes and are stored the same way.
+		args.Devices.Normalise()
+
 		// Validate the new devices without using expanded devices validation (expensive checks disabled).
-		err = instance.ValidDevices(d.state, d.project, d.Type(), args.Devices, nil)
+		err = instance.ValidDevices(d.state, d.project, d.Type(), nil, args.Devices, nil)
+		if err != nil {
+			return fmt.Errorf("Invalid devices: %w", err)
+		}
+
+		err = device.ValidateNewDevices(d.state, d.project.Name, d.localDevices, args.Devices)
 		if err != nil {
 			return fmt.Errorf("Invalid devices: %w", err)
 		}
@@ -4460,6 +4610,9 @@ func (d *qemu) Update(args db.InstanceArgs, userRequested bool) error {
 		return err
 	}
 
+	// The device sources are replaced rather than modified when the config is expanded.
+	oldDeviceSources := d.deviceSources
+
 	oldExpandedConfig := map[string]string{}
 	err = shared.DeepCopy(&d.expandedConfig, &oldExpandedConfig)
 	if err != nil {
@@ -4493,6 +4646,7 @@ func (d *qemu) Update(args db.InstanceArgs, userRequested bool) error {
 		d.ephemeral = oldEphemeral
 		d.expandedConfig = oldExpandedConfig
 		d.expandedDevices = oldExpandedDevices
+		d.deviceSources = oldDeviceSources
 		d.localConfig = oldLocalConfig
 		d.localDevices = oldLocalDevices
 		d.profiles = oldProfiles
@@ -4559,7 +4713,7 @@ func (d *qemu) Update(args db.InstanceArgs, userRequested bool) error {
 		}
 
 		// Do full expanded validation of the devices diff.
-		err = instance.ValidDevices(d.state, d.project, d.Type(), d.localDevices, d.expandedDevices)
+		err = instance.ValidDevices(d.state, d.project, d.Type(), d.expandedConfig, d.localDevices, d.expandedDevices)
 		if err != nil {
 			return fmt.Errorf("Invalid expanded devices: %w", err)
 		}
@@ -5784,6 +5938,8 @@ func (d *qemu) renderState(statusCode api.StatusCode) (*api.InstanceState, error
 				}
 			}
 		}
+
+		status.Devices = d.devicesState(d)
 	}
 
 	status.Pid = int64(pid)
@@ -6360,11 +6516,22 @@ func (d *qemu) checkFeature(qemu string, args ...string) (bool, error) {
 }
 
 func (d *qemu) Metrics() (*metrics.MetricSet, error) {
+	var out *metrics.MetricSet
+	var err error
+
 	if d.agentMetricsEnabled() {
-		return d.getAgentMetrics()
+		out, err = d.getAgentMetrics()
+	} else {
+		out, err = d.getQemuMetrics()
 	}
 
-	return d.getQemuMetrics()
+	if err != nil {
+		return nil, err
+	}
+
+	d.devicesMetrics(d, out)
+
+	return out, nil
 }
 
 func (d *qemu) getAgentMetrics() (*metrics.MetricSet, error) {
@@ -67,10 +67,12 @@ func load(s *state.State, args db.InstanceArgs, p api.Project) (instance.Instanc
 	return inst, nil
 }
 
-// validDevices validate instance device configs.
-func validDevices(state *state.State, p api.Project, instanceType instancetype.Type, localDevices deviceConfig.Devices, expandedDevices deviceConfig.Devices) error {
+// validDevices validate instance device configs. The expanded config is nil when it isn't known, such as when
+// validating profile devices or the local devices on their own.
+func validDevices(state *state.State, p api.Project, instanceType instancetype.Type, expandedConfig map[string]string, localDevices deviceConfig.Devices, expandedDevices deviceConfig.Devices) error {
 	instConf := &common{
 		dbType:          instanceType,
+		expandedConfig:  expandedConfig,
 		localDevices:    localDevices.Clone(),
 		expandedDevices: expandedDevices.Clone(),
 		project:         p,
@@ -91,6 +93,28 @@ func validDevices(state *state.State, p api.Project, instanceType instancetype.T
 				return fmt.Errorf("The maximum device name length is 64 characters")
 			}
 
+			if shared.IsTrue(deviceConfig["inherit"]) {
+				// Local devices that inherit from a profile device are partial, so the keys they override
+				// are validated against the device they are merged into (once the expanded devices are
+				// known). This is done as a local device so that it isn't skipped if unsupported.
+				if !expanded {
+					merged, found := instConf.expandedDevices[deviceName]
+					if !found || shared.IsTrue(merged["inherit"]) {
+						continue
+					}
+
+					err := device.Validate(instConf, state, deviceName, merged)
+					if err != nil {
+						return fmt.Errorf("Device validation failed for %q: Invalid override of profile device: %w", deviceName, err)
+					}
+
+					checkedDevices = append(checkedDevices, deviceName)
+					continue
+				}
+
+				return fmt.Errorf("Device validation failed for %q: No profile device of the same name and type to inherit from", deviceName)
+			}
+
 			err := device.Validate(instConf, state, deviceName, deviceConfig)
 			if err != nil {
 				if expanded && errors.Is(err, device.ErrUnsupportedDevType) {
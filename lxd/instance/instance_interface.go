@@ -56,6 +56,7 @@ type ConfigReader interface {
 	Architecture() int
 	ExpandedConfig() map[string]string
 	ExpandedDevices() deviceConfig.Devices
+	ExpandedDeviceSource(name string) string
 	LocalConfig() map[string]string
 	LocalDevices() deviceConfig.Devices
 }
@@ -94,6 +95,7 @@ type Instance interface {
 	// Live configuration.
 	CGroup() (*cgroup.CGroup, error)
 	VolatileSet(changes map[string]string) error
+	RecheckDeviceRestrictions() error
 
 	// File handling.
 	FileSFTPConn() (net.Conn, error)
@@ -39,7 +39,7 @@ import (
 )
 
 // ValidDevices is linked from instance/drivers.validDevices to validate device config.
-var ValidDevices func(state *state.State, p api.Project, instanceType instancetype.Type, localDevices deviceConfig.Devices, expandedDevices deviceConfig.Devices) error
+var ValidDevices func(state *state.State, p api.Project, instanceType instancetype.Type, expandedConfig map[string]string, localDevices deviceConfig.Devices, expandedDevices deviceConfig.Devices) error
 
 // Load is linked from instance/drivers.load to allow different instance types to be loaded.
 var Load func(s *state.State, args db.InstanceArgs, p api.Project) (Instance, error)
@@ -129,6 +129,16 @@ func exclusiveConfigKeys(key1 string, key2 string, config map[string]string) (va
 	return "", false, nil
 }
 
+// PrivilegedCapabilitiesDrop returns the capabilities that are dropped from privileged containers.
+func PrivilegedCapabilitiesDrop(sysOS *sys.OS) []string {
+	drop := []string{"sys_time", "sys_module", "sys_rawio"}
+	if !sysOS.AppArmorStacking || sysOS.AppArmorStacked {
+		drop = append(drop, "mac_admin", "mac_override")
+	}
+
+	return drop
+}
+
 // ValidConfig validates an instance's config.
 func ValidConfig(sysOS *sys.OS, config map[string]string, expanded bool, instanceType instancetype.Type) error {
 	if config == nil {
@@ -241,7 +251,9 @@ func validConfigKey(os *sys.OS, key string, value string, instanceType instancet
 	return nil
 }
 
-func lxcParseRawLXC(line string) (string, string, error) {
+// ParseRawLXC returns the key and value of a raw.lxc config line.
+// Returns an empty key for empty and comment lines.
+func ParseRawLXC(line string) (string, string, error) {
 	// Ignore empty lines
 	if len(line) == 0 {
 		return "", "", nil
@@ -268,7 +280,7 @@ func lxcParseRawLXC(line string) (string, string, error) {
 
 func lxcValidConfig(rawLxc string) error {
 	for _, line := range strings.Split(rawLxc, "\n") {
-		key, _, err := lxcParseRawLXC(line)
+		key, _, err := ParseRawLXC(line)
 		if err != nil {
 			return err
 		}
@@ -882,6 +894,9 @@ func CreateInternal(s *state.State, args db.InstanceArgs, clearLogDir bool) (Ins
 		// Unset expiry date since instances don't expire.
 		args.ExpiryDate = time.Time{}
 
+		// Normalise the device config so that equivalent configs are stored the same way.
+		args.Devices.Normalise()
+
 		// Generate a cloud-init instance-id if not provided.
 		//
 		// This is generated here rather than in startCommon as only new
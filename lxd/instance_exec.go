@@ -9,6 +9,7 @@ import (
 	"net/url"
 	"os"
 	"path/filepath"
+	"sort"
 	"strconv"
 	"strings"
 	"sync"
@@ -591,6 +592,37 @@ func instanceExecPost(d *Daemon, r *http.Request) response.Response {
 		}
 	}
 
+	// Add any environment variables exported by devices if not manually specified in post.
+	// Devices are merged sorted by name so that the first device to set a variable consistently wins.
+	localConfig := inst.LocalConfig()
+	deviceEnvKeys := []string{}
+	for k, v := range localConfig {
+		if !strings.HasPrefix(k, shared.ConfigVolatilePrefix) || !strings.HasSuffix(k, ".last_state.environment") || v == "" {
+			continue
+		}
+
+		deviceEnvKeys = append(deviceEnvKeys, k)
+	}
+
+	sort.Strings(deviceEnvKeys)
+
+	for _, k := range deviceEnvKeys {
+		v := localConfig[k]
+		deviceEnv := map[string]string{}
+		err := json.Unmarshal([]byte(v), &deviceEnv)
+		if err != nil {
+			logger.Warn("Failed parsing device environment", logger.Ctx{"key": k, "err": err})
+			continue
+		}
+
+		for envKey, envValue := range deviceEnv {
+			_, found := post.Environment[envKey]
+			if !found {
+				post.Environment[envKey] = envValue
+			}
+		}
+	}
+
 	// Set default value for PATH.
 	_, ok := post.Environment["PATH"]
 	if !ok {
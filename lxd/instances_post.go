@@ -21,6 +21,7 @@ import (
 	"github.com/lxc/lxd/lxd/db"
 	dbCluster "github.com/lxc/lxd/lxd/db/cluster"
 	"github.com/lxc/lxd/lxd/db/operationtype"
+	"github.com/lxc/lxd/lxd/device"
 	deviceConfig "github.com/lxc/lxd/lxd/device/config"
 	"github.com/lxc/lxd/lxd/instance"
 	"github.com/lxc/lxd/lxd/instance/instancetype"
@@ -28,6 +29,7 @@ import (
 	"github.com/lxc/lxd/lxd/operations"
 	"github.com/lxc/lxd/lxd/project"
 	"github.com/lxc/lxd/lxd/request"
+	"github.com/lxc/lxd/lxd/resources"
 	"github.com/lxc/lxd/lxd/response"
 	"github.com/lxc/lxd/lxd/revert"
 	storagePools "github.com/lxc/lxd/lxd/storage"
@@ -1002,6 +1004,8 @@ func instancesPost(d *Daemon, r *http.Request) response.Response {
 			return response.BadRequest(err)
 		}
 
+		var targetNodes []string
+
 		err = d.db.Cluster.Transaction(context.TODO(), func(ctx context.Context, tx *db.ClusterTx) error {
 			defaultArch := ""
 			if targetProject.Config["images.default_architecture"] != "" {
@@ -1019,16 +1023,36 @@ func instancesPost(d *Daemon, r *http.Request) response.Response {
 			}
 
 			var err error
-			targetNode, err = tx.GetNodeWithLeastInstances(ctx, architectures, defaultArchID, group, allowedGroups)
+			targetNodes, err = tx.GetNodesWithLeastInstances(ctx, architectures, defaultArchID, group, allowedGroups)
 			return err
 		})
 		if err != nil {
 			return response.SmartError(err)
 		}
 
-		if targetNode == "" {
+		if len(targetNodes) == 0 {
 			return response.BadRequest(fmt.Errorf("No suitable cluster member could be found"))
 		}
+
+		// Pick the first member that has the hardware the instance devices need.
+		targetNode, err = instancesPostSelectMember(d, r, targetProjectName, &req, targetNodes)
+		if err != nil {
+			return response.SmartError(err)
+		}
+	} else if clustered && !isClusterNotification(r) {
+		// Check the targeted member has the hardware the instance devices need.
+		address, err := cluster.ResolveTarget(d.db.Cluster, targetNode)
+		if err != nil {
+			return response.SmartError(err)
+		}
+
+		// Requests for other members are checked by the member they are forwarded to.
+		if address == "" {
+			_, err = instancesPostSelectMember(d, r, targetProjectName, &req, []string{targetNode})
+			if err != nil {
+				return response.SmartError(err)
+			}
+		}
 	}
 
 	if targetNode != "" {
@@ -1143,6 +1167,15 @@ func instancesPost(d *Daemon, r *http.Request) response.Response {
 		return response.SmartError(err)
 	}
 
+	// Copied and migrated instances keep their devices, so only the devices of new instances are subject to the
+	// rules for new device configs.
+	if shared.StringInSlice(req.Source.Type, []string{"image", "none"}) {
+		err = device.ValidateNewDevices(d.State(), targetProjectName, nil, deviceConfig.NewDevices(req.Devices))
+		if err != nil {
+			return response.BadRequest(err)
+		}
+	}
+
 	switch req.Source.Type {
 	case "image":
 		return createFromImage(d, r, targetProjectName, &req)
@@ -1157,6 +1190,89 @@ func instancesPost(d *Daemon, r *http.Request) response.Response {
 	}
 }
 
+// instancesPostSelectMember returns the first of the candidate cluster members (in order of preference) that has
+// the hardware that the devices of the instance to be created need, such as a required USB device or a GPU, as
+// reported by the resources of each member. If none of them has it, an error is returned listing the reasons for
+// each member. When the devices don't need specific hardware, the first member is returned without checking.
+func instancesPostSelectMember(d *Daemon, r *http.Request, projectName string, req *api.InstancesPost, members []string) (string, error) {
+	s := d.State()
+
+	profileNames := req.Profiles
+	if profileNames == nil {
+		profileNames = []string{"default"}
+	}
+
+	profiles := []api.Profile{}
+	err := d.db.Cluster.Transaction(context.TODO(), func(ctx context.Context, tx *db.ClusterTx) error {
+		dbProfiles, err := dbCluster.GetProfilesIfEnabled(ctx, tx.Tx(), projectName, profileNames)
+		if err != nil {
+			return err
+		}
+
+		for _, profile := range dbProfiles {
+			apiProfile, err := profile.ToAPI(ctx, tx.Tx())
+			if err != nil {
+				return err
+			}
+
+			profiles = append(profiles, *apiProfile)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return "", err
+	}
+
+	devices := db.ExpandInstanceDevices(deviceConfig.NewDevices(req.Devices), profiles)
+	if !device.NeedsResources(s, projectName, devices) {
+		return members[0], nil
+	}
+
+	reasons := make([]string, 0, len(members))
+	for _, member := range members {
+		res, err := clusterMemberResources(d, r, member)
+		if err == nil {
+			err = device.CheckResources(s, projectName, devices, res)
+			if err == nil {
+				return member, nil
+			}
+		}
+
+		reasons = append(reasons, fmt.Sprintf("%s: %v", member, err))
+	}
+
+	if len(members) == 1 {
+		return "", api.StatusErrorf(http.StatusBadRequest, "Cluster member %q doesn't have the hardware needed by the instance devices: %s", members[0], strings.TrimPrefix(reasons[0], members[0]+": "))
+	}
+
+	return "", api.StatusErrorf(http.StatusBadRequest, "No cluster member has the hardware needed by the instance devices (%s)", strings.Join(reasons, ", "))
+}
+
+// clusterMemberResources returns the hardware resources of the cluster member.
+func clusterMemberResources(d *Daemon, r *http.Request, member string) (*api.Resources, error) {
+	address, err := cluster.ResolveTarget(d.db.Cluster, member)
+	if err != nil {
+		return nil, err
+	}
+
+	if address == "" {
+		return resources.GetResources()
+	}
+
+	client, err := cluster.Connect(address, d.endpoints.NetworkCert(), d.serverCert(), r, false)
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := client.GetServerResources()
+	if err != nil {
+		return nil, fmt.Errorf("Failed getting hardware resources: %w", err)
+	}
+
+	return res, nil
+}
+
 func instanceFindStoragePool(d *Daemon, projectName string, req *api.InstancesPost) (string, string, string, map[string]string, response.Response) {
 	// Grab the container's root device if one is specified
 	storagePool := ""
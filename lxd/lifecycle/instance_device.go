@@ -0,0 +1,41 @@
+package lifecycle
+
+import (
+	"github.com/lxc/lxd/shared/api"
+	"github.com/lxc/lxd/shared/version"
+)
+
+// InstanceDeviceAction represents a lifecycle event action for instance devices.
+type InstanceDeviceAction string
+
+// All supported lifecycle events for instance devices.
+const (
+	InstanceDeviceAttached        = InstanceDeviceAction(api.EventLifecycleInstanceDeviceAttached)
+	InstanceDeviceDetached        = InstanceDeviceAction(api.EventLifecycleInstanceDeviceDetached)
+	InstanceDeviceFailed          = InstanceDeviceAction(api.EventLifecycleInstanceDeviceFailed)
+	InstanceDeviceRequiredMissing = InstanceDeviceAction(api.EventLifecycleInstanceDeviceRequiredMissing)
+	InstanceDeviceBlocked         = InstanceDeviceAction(api.EventLifecycleInstanceDeviceBlocked)
+)
+
+// Event creates the lifecycle event for an action on an instance device.
+func (a InstanceDeviceAction) Event(deviceName string, inst instance, ctx map[string]any) api.EventLifecycle {
+	u := api.NewURL().Path(version.APIVersion, "instances", inst.Name()).Project(inst.Project().Name)
+
+	if ctx == nil {
+		ctx = map[string]any{}
+	}
+
+	ctx["device"] = deviceName
+
+	var requestor *api.EventLifecycleRequestor
+	if inst.Operation() != nil {
+		requestor = inst.Operation().Requestor()
+	}
+
+	return api.EventLifecycle{
+		Action:    string(a),
+		Source:    u.String(),
+		Context:   ctx,
+		Requestor: requestor,
+	}
+}
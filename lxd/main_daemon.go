@@ -71,8 +71,9 @@ func (c *cmdDaemon) Run(cmd *cobra.Command, args []string) error {
 	signal.Notify(sigCh, unix.SIGQUIT)
 	signal.Notify(sigCh, unix.SIGTERM)
 
-	chIgnore := make(chan os.Signal, 1)
-	signal.Notify(chIgnore, unix.SIGHUP)
+	// SIGHUP re-checks the devices of the running instances against the restrictions of their projects.
+	sighupCh := make(chan os.Signal, 1)
+	signal.Notify(sighupCh, unix.SIGHUP)
 
 	err := d.Init()
 	if err != nil {
@@ -91,6 +92,10 @@ func (c *cmdDaemon) Run(cmd *cobra.Command, args []string) error {
 				}()
 			}
 
+		case <-sighupCh:
+			logger.Info("Received SIGHUP, re-checking device restrictions")
+			go devicesRecheckRestrictions(d.State(), "")
+
 		case err = <-d.shutdownDoneCh:
 			return err
 		}
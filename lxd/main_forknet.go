@@ -107,7 +107,7 @@ void forknet(void)
 		forkdonetinfo(pidfd, ns_fd);
 	}
 
-	if (strcmp(command, "detach") == 0)
+	if (strcmp(command, "detach") == 0 || strcmp(command, "move") == 0)
 		forkdonetdetach(cur);
 }
 */
@@ -144,6 +144,13 @@ func (c *cmdForknet) Command() *cobra.Command {
 	cmdDetach.RunE = c.RunDetach
 	cmd.AddCommand(cmdDetach)
 
+	// move
+	cmdMove := &cobra.Command{}
+	cmdMove.Use = "move <netns file> <target netns file> <ifname>"
+	cmdMove.Args = cobra.ExactArgs(3)
+	cmdMove.RunE = c.RunMove
+	cmd.AddCommand(cmdMove)
+
 	// Workaround for subcommand usage errors. See: https://github.com/spf13/cobra/issues/706
 	cmd.Args = cobra.NoArgs
 	cmd.Run = func(cmd *cobra.Command, args []string) { _ = cmd.Usage() }
@@ -214,3 +221,25 @@ func (c *cmdForknet) RunDetach(cmd *cobra.Command, args []string) error {
 
 	return nil
 }
+
+func (c *cmdForknet) RunMove(cmd *cobra.Command, args []string) error {
+	targetNetns := args[1]
+	ifName := args[2]
+
+	if targetNetns == "" {
+		return fmt.Errorf("Target netns file argument is required")
+	}
+
+	if ifName == "" {
+		return fmt.Errorf("ifname argument is required")
+	}
+
+	// Move the interface into the target netns, keeping its name.
+	link := &ip.Link{Name: ifName}
+	err := link.SetNetns(targetNetns)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
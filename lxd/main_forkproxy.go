@@ -90,6 +90,7 @@ void forkproxy(void)
 {
 	unsigned int needs_mntns = 0;
 	int connect_pid, connect_pidfd, listen_pid, listen_pidfd;
+	int connect_netnsfd, listen_netnsfd;
 	size_t unix_prefix_len = sizeof("unix:") - 1;
 	ssize_t ret;
 	pid_t pid;
@@ -110,6 +111,13 @@ void forkproxy(void)
 	connect_pidfd = atoi(advance_arg(true));
 	connect_addr = advance_arg(true);
 
+	// Skip the arguments handled in Go to get the selected network namespaces.
+	for (int i = 0; i < 6; i++)
+		advance_arg(true);
+
+	listen_netnsfd = atoi(advance_arg(true));
+	connect_netnsfd = atoi(advance_arg(true));
+
 	if (strncmp(listen_addr, "udp:", sizeof("udp:") - 1) == 0 &&
 	    strncmp(connect_addr, "udp:", sizeof("udp:") - 1) != 0) {
 		    fprintf(stderr, "Error: Proxying from udp to non-udp protocol is not supported\n");
@@ -166,6 +174,12 @@ void forkproxy(void)
 			_exit(EXIT_FAILURE);
 		}
 
+		// Attach to the network namespace selected inside the listener's network namespace
+		if (listen_netnsfd >= 0 && setns(listen_netnsfd, CLONE_NEWNET) < 0) {
+			fprintf(stderr, "Error: %m - Failed setns to selected listener network namespace\n");
+			_exit(EXIT_FAILURE);
+		}
+
 		if ((needs_mntns & LISTEN_NEEDS_MNTNS) && !change_namespaces(listen_pidfd, listen_nsfd, CLONE_NEWNS)) {
 			fprintf(stderr, "Error: %m - Failed setns to listener mount namespace\n");
 			_exit(EXIT_FAILURE);
@@ -212,6 +226,12 @@ void forkproxy(void)
 			_exit(EXIT_FAILURE);
 		}
 
+		// Attach to the network namespace selected inside the connector's network namespace
+		if (connect_netnsfd >= 0 && setns(connect_netnsfd, CLONE_NEWNET) < 0) {
+			fprintf(stderr, "Error: %m - Failed setns to selected connector network namespace\n");
+			_exit(EXIT_FAILURE);
+		}
+
 		// Attach to the mount namespace of the connector
 		if ((needs_mntns & CONNECT_NEEDS_MNTNS) && !change_namespaces(connect_pidfd, connect_nsfd, CLONE_NEWNS)) {
 			fprintf(stderr, "Error: %m - Failed setns to connector mount namespace\n");
@@ -283,7 +303,7 @@ type udpSession struct {
 func (c *cmdForkproxy) Command() *cobra.Command {
 	// Main subcommand
 	cmd := &cobra.Command{}
-	cmd.Use = "forkproxy <listen PID> <listen PidFd> <listen address> <connect PID> <connect PidFd> <connect address> <log path> <pid path> <listen gid> <listen uid> <listen mode> <security gid> <security uid>"
+	cmd.Use = "forkproxy <listen PID> <listen PidFd> <listen address> <connect PID> <connect PidFd> <connect address> <log path> <pid path> <listen gid> <listen uid> <listen mode> <security gid> <security uid> <proxy protocol> <listen netns fd> <connect netns fd>"
 	cmd.Short = "Setup network connection proxying"
 	cmd.Long = `Description:
   Setup network connection proxying
@@ -292,7 +312,7 @@ func (c *cmdForkproxy) Command() *cobra.Command {
   container, connecting one side to the host and the other to the
   container.
 `
-	cmd.Args = cobra.ExactArgs(12)
+	cmd.Args = cobra.ExactArgs(14)
 	cmd.RunE = c.Run
 	cmd.Hidden = true
 
@@ -20,6 +20,10 @@ const (
 	CPUSecondsTotal MetricType = iota
 	// CPUs represents the total number of effective CPUs.
 	CPUs
+	// DeviceTimingSeconds represents the time taken by a phase of a device operation.
+	DeviceTimingSeconds
+	// DeviceHotplugErrors represents the number of consecutive failures of the hotplug handling of a device.
+	DeviceHotplugErrors
 	// DiskReadBytesTotal represents the read bytes for a disk.
 	DiskReadBytesTotal
 	// DiskReadsCompletedTotal represents the completed for a disk.
@@ -150,6 +154,8 @@ const (
 var MetricNames = map[MetricType]string{
 	CPUSecondsTotal:             "lxd_cpu_seconds_total",
 	CPUs:                        "lxd_cpu_effective_total",
+	DeviceTimingSeconds:         "lxd_device_timing_seconds",
+	DeviceHotplugErrors:         "lxd_device_hotplug_errors",
 	DiskReadBytesTotal:          "lxd_disk_read_bytes_total",
 	DiskReadsCompletedTotal:     "lxd_disk_reads_completed_total",
 	DiskWrittenBytesTotal:       "lxd_disk_written_bytes_total",
@@ -218,6 +224,8 @@ var MetricNames = map[MetricType]string{
 var MetricHeaders = map[MetricType]string{
 	CPUSecondsTotal:             "# HELP lxd_cpu_seconds_total The total number of CPU time used in seconds.",
 	CPUs:                        "# HELP lxd_cpu_effective_total The total number of effective CPUs.",
+	DeviceTimingSeconds:         "# HELP lxd_device_timing_seconds The time taken by the most recent run of a device operation phase in seconds.",
+	DeviceHotplugErrors:         "# HELP lxd_device_hotplug_errors The number of consecutive failures of the hotplug handling of a device.",
 	DiskReadBytesTotal:          "# HELP lxd_disk_read_bytes_total The total number of bytes read.",
 	DiskReadsCompletedTotal:     "# HELP lxd_disk_reads_completed_total The total number of completed reads.",
 	DiskWrittenBytesTotal:       "# HELP lxd_disk_written_bytes_total The total number of bytes written.",
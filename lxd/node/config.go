@@ -3,6 +3,7 @@ package node
 import (
 	"context"
 	"fmt"
+	"time"
 
 	"github.com/lxc/lxd/lxd/config"
 	"github.com/lxc/lxd/lxd/db"
@@ -118,6 +119,27 @@ func (c *Config) StorageImagesVolume() string {
 	return c.m.GetString("storage.images_volume")
 }
 
+// DevicesUSBQuiesce returns whether USB hotplug events are quiesced and the policy for the events
+// that occur whilst quiesced.
+func (c *Config) DevicesUSBQuiesce() (bool, string) {
+	return c.m.GetBool("devices.usb.quiesce"), c.m.GetString("devices.usb.quiesce.policy")
+}
+
+// DevicesOperationsConcurrency returns the maximum number of device operations run concurrently (0 for automatic).
+func (c *Config) DevicesOperationsConcurrency() int {
+	return int(c.m.GetInt64("devices.operations.concurrency"))
+}
+
+// DevicesUSBSysfsPaths returns the sysfs paths to scan for USB devices (the default path if empty).
+func (c *Config) DevicesUSBSysfsPaths() []string {
+	return shared.SplitNTrimSpace(c.m.GetString("devices.usb.sysfs.paths"), ",", -1, true)
+}
+
+// DevicesUSBReconcileDelay returns how long USB hotplug events are coalesced for before reconciling the devices.
+func (c *Config) DevicesUSBReconcileDelay() time.Duration {
+	return time.Duration(c.m.GetInt64("devices.usb.reconcile.delay")) * time.Millisecond
+}
+
 // Dump current configuration keys and their values. Keys with values matching
 // their defaults are omitted.
 func (c *Config) Dump() map[string]any {
@@ -179,6 +201,21 @@ var ConfigSchema = config.Schema{
 	// Network address for the storage buckets server
 	"core.storage_buckets_address": {Validator: validate.Optional(validate.IsListenAddress(true, true, false))},
 
+	// Device lifecycle hook plugins to load when LXD starts
+
+	// Limit concurrent device operations to protect the host
+	"devices.operations.concurrency": {Type: config.Int64, Default: "0", Validator: validate.Optional(validate.IsUint32)},
+
+	// Pause processing USB hotplug events during host maintenance
+	"devices.usb.quiesce":        {Type: config.Bool, Default: "false"},
+	"devices.usb.quiesce.policy": {Validator: validate.Optional(validate.IsOneOf("drop", "buffer")), Default: "drop"},
+
+	// Sysfs paths to scan for USB devices when the USB sysfs tree is mounted elsewhere
+	"devices.usb.sysfs.paths": {Validator: validate.Optional(validate.IsListOf(validate.IsAbsFilePath))},
+
+	// Coalesce USB hotplug events on hosts with many instances
+	"devices.usb.reconcile.delay": {Type: config.Int64, Default: "0", Validator: validate.Optional(validate.IsUint32)},
+
 	// MAAS machine this LXD instance is associated with
 	"maas.machine": {},
 
@@ -17,6 +17,7 @@ import (
 	"github.com/lxc/lxd/lxd/cluster"
 	"github.com/lxc/lxd/lxd/db"
 	dbCluster "github.com/lxc/lxd/lxd/db/cluster"
+	"github.com/lxc/lxd/lxd/device"
 	deviceConfig "github.com/lxc/lxd/lxd/device/config"
 	"github.com/lxc/lxd/lxd/instance"
 	"github.com/lxc/lxd/lxd/instance/instancetype"
@@ -272,8 +273,18 @@ func profilesPost(d *Daemon, r *http.Request) response.Response {
 		return response.BadRequest(err)
 	}
 
+	// Normalise the device config so that equivalent configs are stored the same way.
+	for _, devConfig := range req.Devices {
+		deviceConfig.Device(devConfig).Normalise()
+	}
+
 	// At this point we don't know the instance type, so just use instancetype.Any type for validation.
-	err = instance.ValidDevices(d.State(), *p, instancetype.Any, deviceConfig.NewDevices(req.Devices), nil)
+	err = instance.ValidDevices(d.State(), *p, instancetype.Any, nil, deviceConfig.NewDevices(req.Devices), nil)
+	if err != nil {
+		return response.BadRequest(err)
+	}
+
+	err = device.ValidateNewDevices(d.State(), p.Name, nil, deviceConfig.NewDevices(req.Devices))
 	if err != nil {
 		return response.BadRequest(err)
 	}
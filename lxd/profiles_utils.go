@@ -6,6 +6,7 @@ import (
 
 	"github.com/lxc/lxd/lxd/db"
 	"github.com/lxc/lxd/lxd/db/cluster"
+	"github.com/lxc/lxd/lxd/device"
 	deviceConfig "github.com/lxc/lxd/lxd/device/config"
 	"github.com/lxc/lxd/lxd/instance"
 	"github.com/lxc/lxd/lxd/instance/instancetype"
@@ -15,6 +16,11 @@ import (
 )
 
 func doProfileUpdate(d *Daemon, p api.Project, profileName string, id int64, profile *api.Profile, req api.ProfilePut) error {
+	// Normalise the device config so that equivalent configs are stored the same way.
+	for _, devConfig := range req.Devices {
+		deviceConfig.Device(devConfig).Normalise()
+	}
+
 	// Check project limits.
 	err := d.db.Cluster.Transaction(context.TODO(), func(ctx context.Context, tx *db.ClusterTx) error {
 		return project.AllowProfileUpdate(tx, p.Name, profileName, req)
@@ -31,7 +37,12 @@ func doProfileUpdate(d *Daemon, p api.Project, profileName string, id int64, pro
 
 	// Profiles can be applied to any instance type, so just use instancetype.Any type for validation so that
 	// instance type specific validation checks are not performed.
-	err = instance.ValidDevices(d.State(), p, instancetype.Any, deviceConfig.NewDevices(req.Devices), nil)
+	err = instance.ValidDevices(d.State(), p, instancetype.Any, nil, deviceConfig.NewDevices(req.Devices), nil)
+	if err != nil {
+		return err
+	}
+
+	err = device.ValidateNewDevices(d.State(), p.Name, deviceConfig.NewDevices(profile.Devices), deviceConfig.NewDevices(req.Devices))
 	if err != nil {
 		return err
 	}
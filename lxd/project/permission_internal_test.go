@@ -5,9 +5,85 @@ import (
 
 	"github.com/stretchr/testify/assert"
 
+	"github.com/lxc/lxd/shared/api"
 	"github.com/lxc/lxd/shared/idmap"
 )
 
+func TestCheckRestrictionsDeviceTypes(t *testing.T) {
+	for deviceType, description := range RestrictedDeviceTypes {
+		instances := []api.Instance{{Name: "c1", Type: "container", Devices: map[string]map[string]string{"dev0": {"type": deviceType}}}}
+
+		// Check the device type is blocked by default.
+		project := api.Project{Name: "p1", ProjectPut: api.ProjectPut{Config: map[string]string{"restricted": "true"}}}
+		err := checkRestrictions(project, instances, nil)
+		assert.ErrorContains(t, err, description+" are forbidden")
+
+		// Check the device type is allowed by its restricted.devices.<type> setting.
+		project.Config["restricted.devices."+deviceType] = "allow"
+		err = checkRestrictions(project, instances, nil)
+		assert.NoError(t, err)
+	}
+}
+
+func TestCheckRestrictionsUSB(t *testing.T) {
+	instances := []api.Instance{{Name: "c1", Type: "container", Devices: map[string]map[string]string{"usb0": {"type": "usb", "usbmon": "true"}}}}
+	project := api.Project{Name: "p1", ProjectPut: api.ProjectPut{Config: map[string]string{"restricted": "true", "restricted.devices.usb": "allow"}}}
+
+	// Check capturing the USB traffic is blocked by default.
+	err := checkRestrictions(project, instances, nil)
+	assert.ErrorContains(t, err, "Capturing USB traffic is forbidden")
+
+	// Check capturing the USB traffic is allowed by its restriction.
+	project.Config["restricted.devices.usb.usbmon"] = "allow"
+	err = checkRestrictions(project, instances, nil)
+	assert.NoError(t, err)
+
+	// Check loading host kernel modules is blocked unless low-level options are allowed.
+	instances[0].Devices["usb0"]["modules"] = "ftdi_sio"
+	err = checkRestrictions(project, instances, nil)
+	assert.ErrorContains(t, err, "Loading host kernel modules is forbidden")
+
+	project.Config["restricted.containers.lowlevel"] = "allow"
+	err = checkRestrictions(project, instances, nil)
+	assert.NoError(t, err)
+
+	// Check the USB devices are still forbidden if blocked.
+	project.Config["restricted.devices.usb"] = "block"
+	err = checkRestrictions(project, instances, nil)
+	assert.ErrorContains(t, err, "USB devices are forbidden")
+}
+
+func TestForbiddenDevices(t *testing.T) {
+	devices := map[string]map[string]string{
+		"usb0":  {"type": "usb", "vendorid": "1234"},
+		"disk0": {"type": "disk", "source": "/srv", "path": "/srv"},
+	}
+
+	// Check devices aren't forbidden if the project isn't restricted.
+	project := api.Project{Name: "p1", ProjectPut: api.ProjectPut{Config: map[string]string{"restricted.devices.usb": "block"}}}
+	forbidden, err := ForbiddenDevices(project, devices)
+	assert.NoError(t, err)
+	assert.Empty(t, forbidden)
+
+	// Check only the forbidden devices are reported, along with why.
+	project.Config["restricted"] = "true"
+	project.Config["restricted.devices.disk"] = "allow"
+	forbidden, err = ForbiddenDevices(project, devices)
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]string{"usb0": "USB devices are forbidden"}, forbidden)
+
+	// Check devices allowed again aren't forbidden.
+	project.Config["restricted.devices.usb"] = "allow"
+	forbidden, err = ForbiddenDevices(project, devices)
+	assert.NoError(t, err)
+	assert.Empty(t, forbidden)
+
+	// Check invalid restrictions are reported as such rather than as forbidding the devices.
+	project.Config["restricted.idmap.uid"] = "invalid"
+	_, err = ForbiddenDevices(project, devices)
+	assert.Error(t, err)
+}
+
 func TestParseHostIDMapRange(t *testing.T) {
 	for _, mode := range []string{"uid", "gid", "both"} {
 		var isUID, isGID bool
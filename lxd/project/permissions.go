@@ -435,14 +435,26 @@ func parseHostIDMapRange(isUID bool, isGID bool, listValue string) ([]idmap.Idma
 	return idmaps, nil
 }
 
-// Check that the project's restrictions are not violated across the given
-// instances and profiles.
-func checkRestrictions(project api.Project, instances []api.Instance, profiles []api.Profile) error {
+// restrictionChecks holds the checks of the config and devices of instances and profiles that are derived from
+// the restrictions of a project.
+type restrictionChecks struct {
+	containerConfigChecks map[string]func(value string) error
+	devicesChecks         map[string]func(value map[string]string) error
+
+	allowContainerLowLevel bool
+	allowVMLowLevel        bool
+	allowedIDMapHostUIDs   []idmap.IdmapEntry
+	allowedIDMapHostGIDs   []idmap.IdmapEntry
+}
+
+// newRestrictionChecks returns the checks derived from the project's restrictions.
+func newRestrictionChecks(project api.Project) (*restrictionChecks, error) {
 	containerConfigChecks := map[string]func(value string) error{}
 	devicesChecks := map[string]func(value map[string]string) error{}
 
 	allowContainerLowLevel := false
 	allowVMLowLevel := false
+	allowUSBMonitoring := false
 	var allowedIDMapHostUIDs, allowedIDMapHostGIDs []idmap.IdmapEntry
 
 	for i := range allRestrictions {
@@ -504,78 +516,6 @@ func checkRestrictions(project api.Project, instances []api.Instance, profiles [
 				allowVMLowLevel = true
 			}
 
-		case "restricted.devices.unix-char":
-			devicesChecks["unix-char"] = func(device map[string]string) error {
-				if restrictionValue != "allow" {
-					return fmt.Errorf("Unix character devices are forbidden")
-				}
-
-				return nil
-			}
-
-		case "restricted.devices.unix-block":
-			devicesChecks["unix-block"] = func(device map[string]string) error {
-				if restrictionValue != "allow" {
-					return fmt.Errorf("Unix block devices are forbidden")
-				}
-
-				return nil
-			}
-
-		case "restricted.devices.unix-hotplug":
-			devicesChecks["unix-hotplug"] = func(device map[string]string) error {
-				if restrictionValue != "allow" {
-					return fmt.Errorf("Unix hotplug devices are forbidden")
-				}
-
-				return nil
-			}
-
-		case "restricted.devices.infiniband":
-			devicesChecks["infiniband"] = func(device map[string]string) error {
-				if restrictionValue != "allow" {
-					return fmt.Errorf("Infiniband devices are forbidden")
-				}
-
-				return nil
-			}
-
-		case "restricted.devices.gpu":
-			devicesChecks["gpu"] = func(device map[string]string) error {
-				if restrictionValue != "allow" {
-					return fmt.Errorf("GPU devices are forbidden")
-				}
-
-				return nil
-			}
-
-		case "restricted.devices.usb":
-			devicesChecks["usb"] = func(device map[string]string) error {
-				if restrictionValue != "allow" {
-					return fmt.Errorf("USB devices are forbidden")
-				}
-
-				return nil
-			}
-
-		case "restricted.devices.pci":
-			devicesChecks["pci"] = func(device map[string]string) error {
-				if restrictionValue != "allow" {
-					return fmt.Errorf("PCI devices are forbidden")
-				}
-
-				return nil
-			}
-
-		case "restricted.devices.proxy":
-			devicesChecks["proxy"] = func(device map[string]string) error {
-				if restrictionValue != "allow" {
-					return fmt.Errorf("Proxy devices are forbidden")
-				}
-
-				return nil
-			}
-
 		case "restricted.devices.nic":
 			devicesChecks["nic"] = func(device map[string]string) error {
 				// Check if the NICs are allowed at all.
@@ -634,22 +574,90 @@ func checkRestrictions(project api.Project, instances []api.Instance, profiles [
 				return nil
 			}
 
+		case "restricted.devices.usb.usbmon":
+			if restrictionValue == "allow" {
+				allowUSBMonitoring = true
+			}
+
 		case "restricted.idmap.uid":
 			var err error
 			allowedIDMapHostUIDs, err = parseHostIDMapRange(true, false, restrictionValue)
 			if err != nil {
-				return fmt.Errorf("Failed parsing %q: %w", "restricted.idmap.uid", err)
+				return nil, fmt.Errorf("Failed parsing %q: %w", "restricted.idmap.uid", err)
 			}
 
 		case "restricted.idmap.gid":
 			var err error
 			allowedIDMapHostGIDs, err = parseHostIDMapRange(false, true, restrictionValue)
 			if err != nil {
-				return fmt.Errorf("Failed parsing %q: %w", "restricted.idmap.uid", err)
+				return nil, fmt.Errorf("Failed parsing %q: %w", "restricted.idmap.uid", err)
+			}
+
+		default:
+			deviceType := strings.TrimPrefix(restrictionKey, "restricted.devices.")
+			description, ok := RestrictedDeviceTypes[deviceType]
+			if !ok || deviceType == restrictionKey {
+				continue
+			}
+
+			devicesChecks[deviceType] = func(device map[string]string) error {
+				if restrictionValue != "allow" {
+					return fmt.Errorf("%s are forbidden", description)
+				}
+
+				return nil
 			}
 		}
 	}
 
+	// USB devices are also subject to the restrictions of the host features that they use.
+	checkUSB := devicesChecks["usb"]
+	devicesChecks["usb"] = func(device map[string]string) error {
+		err := checkUSB(device)
+		if err != nil {
+			return err
+		}
+
+		// Capturing the traffic of the USB buses also captures that of the other devices on them.
+		if shared.IsTrue(device["usbmon"]) && !allowUSBMonitoring {
+			return fmt.Errorf("Capturing USB traffic is forbidden")
+		}
+
+		// Loading host kernel modules is restricted in the same way as linux.kernel_modules.
+		if device["modules"] != "" && !allowContainerLowLevel {
+			return fmt.Errorf("Loading host kernel modules is forbidden")
+		}
+
+		return nil
+	}
+
+	checks := &restrictionChecks{
+		containerConfigChecks:  containerConfigChecks,
+		devicesChecks:          devicesChecks,
+		allowContainerLowLevel: allowContainerLowLevel,
+		allowVMLowLevel:        allowVMLowLevel,
+		allowedIDMapHostUIDs:   allowedIDMapHostUIDs,
+		allowedIDMapHostGIDs:   allowedIDMapHostGIDs,
+	}
+
+	return checks, nil
+}
+
+// Check that the project's restrictions are not violated across the given
+// instances and profiles.
+func checkRestrictions(project api.Project, instances []api.Instance, profiles []api.Profile) error {
+	checks, err := newRestrictionChecks(project)
+	if err != nil {
+		return err
+	}
+
+	containerConfigChecks := checks.containerConfigChecks
+	devicesChecks := checks.devicesChecks
+	allowContainerLowLevel := checks.allowContainerLowLevel
+	allowVMLowLevel := checks.allowVMLowLevel
+	allowedIDMapHostUIDs := checks.allowedIDMapHostUIDs
+	allowedIDMapHostGIDs := checks.allowedIDMapHostGIDs
+
 	// Common config check logic between instances and profiles.
 	entityConfigChecker := func(instType instancetype.Type, entityName string, config map[string]string) error {
 		entityTypeLabel := instType.String()
@@ -714,7 +722,9 @@ func checkRestrictions(project api.Project, instances []api.Instance, profiles [
 		}
 
 		for name, device := range devices {
-			check, ok := devicesChecks[device["type"]]
+			// Resolve device type aliases so that they are subject to the same restrictions.
+			typeName, _ := deviceconfig.ResolveType(device["type"])
+			check, ok := devicesChecks[typeName]
 			if !ok {
 				continue
 			}
@@ -759,6 +769,37 @@ func checkRestrictions(project api.Project, instances []api.Instance, profiles [
 	return nil
 }
 
+// ForbiddenDevices returns the reasons why the project's restrictions forbid the devices of an instance, keyed on
+// the names of the forbidden devices. The restrictions are only derived once for all the devices. If they can't
+// be, an error is returned.
+func ForbiddenDevices(p api.Project, devices map[string]map[string]string) (map[string]string, error) {
+	if shared.IsFalseOrEmpty(p.Config["restricted"]) {
+		return nil, nil
+	}
+
+	checks, err := newRestrictionChecks(p)
+	if err != nil {
+		return nil, err
+	}
+
+	forbidden := map[string]string{}
+	for name, device := range devices {
+		// Resolve device type aliases so that they are subject to the same restrictions.
+		typeName, _ := deviceconfig.ResolveType(device["type"])
+		check, ok := checks.devicesChecks[typeName]
+		if !ok {
+			continue
+		}
+
+		err := check(device)
+		if err != nil {
+			forbidden[name] = err.Error()
+		}
+	}
+
+	return forbidden, nil
+}
+
 // CheckRestrictedDevicesDiskPaths checks whether the disk's source path is within the allowed paths specified in
 // the project's restricted.devices.disk.paths config setting.
 // If no allowed paths are specified in project, then it allows all paths, and returns true and empty string.
@@ -789,7 +830,33 @@ var allAggregateLimits = []string{
 	"limits.processes",
 }
 
+// RestrictedDeviceTypes lists the device types that are either allowed or blocked entirely by the project's
+// restricted.devices.<type> setting (blocked by default), along with their description used in errors.
+var RestrictedDeviceTypes = map[string]string{
+	"unix-char":    "Unix character devices",
+	"unix-block":   "Unix block devices",
+	"unix-hotplug": "Unix hotplug devices",
+	"infiniband":   "Infiniband devices",
+	"gpu":          "GPU devices",
+	"usb":          "USB devices",
+	"pci":          "PCI devices",
+	"perf":         "Performance counter devices",
+	"input":        "Input devices",
+	"timer":        "Timer devices",
+	"ipmi":         "IPMI devices",
+	"scsi":         "SCSI devices",
+	"hwmon":        "Hardware monitoring devices",
+	"proxy":        "Proxy devices",
+}
+
+func init() {
+	for deviceType := range RestrictedDeviceTypes {
+		allRestrictions["restricted.devices."+deviceType] = "block"
+	}
+}
+
 // allRestrictions lists all available 'restrict.*' config keys along with their default setting.
+// The restricted.devices.<type> keys of RestrictedDeviceTypes are added on init.
 var allRestrictions = map[string]string{
 	"restricted.backups":                   "block",
 	"restricted.cluster.groups":            "",
@@ -799,17 +866,10 @@ var allRestrictions = map[string]string{
 	"restricted.containers.lowlevel":       "block",
 	"restricted.containers.privilege":      "unprivileged",
 	"restricted.virtual-machines.lowlevel": "block",
-	"restricted.devices.unix-char":         "block",
-	"restricted.devices.unix-block":        "block",
-	"restricted.devices.unix-hotplug":      "block",
-	"restricted.devices.infiniband":        "block",
-	"restricted.devices.gpu":               "block",
-	"restricted.devices.usb":               "block",
-	"restricted.devices.pci":               "block",
-	"restricted.devices.proxy":             "block",
 	"restricted.devices.nic":               "managed",
 	"restricted.devices.disk":              "managed",
 	"restricted.devices.disk.paths":        "",
+	"restricted.devices.usb.usbmon":        "block",
 	"restricted.idmap.uid":                 "",
 	"restricted.idmap.gid":                 "",
 	"restricted.networks.access":           "",
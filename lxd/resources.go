@@ -6,6 +6,7 @@ import (
 
 	"github.com/gorilla/mux"
 
+	"github.com/lxc/lxd/lxd/device"
 	"github.com/lxc/lxd/lxd/resources"
 	"github.com/lxc/lxd/lxd/response"
 	storagePools "github.com/lxc/lxd/lxd/storage"
@@ -18,6 +19,18 @@ var api10ResourcesCmd = APIEndpoint{
 	Get: APIEndpointAction{Handler: api10ResourcesGet, AccessHandler: allowAuthenticated},
 }
 
+var api10ResourcesQuarantineCmd = APIEndpoint{
+	Path: "resources/quarantine",
+
+	Get: APIEndpointAction{Handler: api10ResourcesQuarantineGet, AccessHandler: allowAuthenticated},
+}
+
+var api10ResourcesQuarantineDeviceCmd = APIEndpoint{
+	Path: "resources/quarantine/{address}",
+
+	Delete: APIEndpointAction{Handler: api10ResourcesQuarantineDelete},
+}
+
 var storagePoolResourcesCmd = APIEndpoint{
 	Path: "storage-pools/{name}/resources",
 
@@ -80,6 +93,108 @@ func api10ResourcesGet(d *Daemon, r *http.Request) response.Response {
 	return response.SyncResponse(true, res)
 }
 
+// swagger:operation GET /1.0/resources/quarantine server resources_quarantine_get
+//
+// Get the quarantined devices
+//
+// Gets the host PCI devices that were quarantined when they were detached from an instance.
+//
+// ---
+// produces:
+//   - application/json
+// parameters:
+//   - in: query
+//     name: target
+//     description: Cluster member name
+//     type: string
+//     example: lxd01
+// responses:
+//   "200":
+//     description: Quarantined devices
+//     schema:
+//       type: object
+//       description: Sync response
+//       properties:
+//         type:
+//           type: string
+//           description: Response type
+//           example: sync
+//         status:
+//           type: string
+//           description: Status description
+//           example: Success
+//         status_code:
+//           type: integer
+//           description: Status code
+//           example: 200
+//         metadata:
+//           type: array
+//           description: List of quarantined devices
+//           items:
+//             $ref: "#/definitions/ResourcesQuarantinedDevice"
+//   "403":
+//     $ref: "#/responses/Forbidden"
+//   "500":
+//     $ref: "#/responses/InternalServerError"
+func api10ResourcesQuarantineGet(d *Daemon, r *http.Request) response.Response {
+	// If a target was specified, forward the request to the relevant node.
+	resp := forwardedResponseIfTargetIsRemote(d, r)
+	if resp != nil {
+		return resp
+	}
+
+	devices, err := device.Quarantined()
+	if err != nil {
+		return response.SmartError(err)
+	}
+
+	return response.SyncResponse(true, devices)
+}
+
+// swagger:operation DELETE /1.0/resources/quarantine/{address} server resources_quarantine_delete
+//
+// Release a quarantined device
+//
+// Releases the quarantined host PCI device, binding it to its host driver again so that it can be used.
+//
+// ---
+// produces:
+//   - application/json
+// parameters:
+//   - in: query
+//     name: target
+//     description: Cluster member name
+//     type: string
+//     example: lxd01
+// responses:
+//   "200":
+//     $ref: "#/responses/EmptySyncResponse"
+//   "403":
+//     $ref: "#/responses/Forbidden"
+//   "404":
+//     $ref: "#/responses/NotFound"
+//   "500":
+//     $ref: "#/responses/InternalServerError"
+func api10ResourcesQuarantineDelete(d *Daemon, r *http.Request) response.Response {
+	// If a target was specified, forward the request to the relevant node.
+	resp := forwardedResponseIfTargetIsRemote(d, r)
+	if resp != nil {
+		return resp
+	}
+
+	address, err := url.PathUnescape(mux.Vars(r)["address"])
+	if err != nil {
+		return response.SmartError(err)
+	}
+
+	err = device.QuarantineRelease(address)
+	if err != nil {
+		return response.SmartError(err)
+	}
+
+	return response.EmptySyncResponse
+}
+
 // swagger:operation GET /1.0/storage-pools/{name}/resources storage storage_pool_resources
 //
 // Get storage pool resources information
@@ -0,0 +1,88 @@
+package resources
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/lxc/lxd/shared/api"
+)
+
+// GetFingerprint returns a stable fingerprint of the GPU, network, USB and PCI devices of the host.
+func GetFingerprint() (*api.ResourcesFingerprint, error) {
+	gpu, err := GetGPU()
+	if err != nil {
+		return nil, fmt.Errorf("Failed to retrieve GPU information: %w", err)
+	}
+
+	network, err := GetNetwork()
+	if err != nil {
+		return nil, fmt.Errorf("Failed to retrieve network information: %w", err)
+	}
+
+	usb, err := GetUSB()
+	if err != nil {
+		return nil, fmt.Errorf("Failed to retrieve USB information: %w", err)
+	}
+
+	pci, err := GetPCI()
+	if err != nil {
+		return nil, fmt.Errorf("Failed to retrieve PCI information: %w", err)
+	}
+
+	fingerprint := Fingerprint(gpu, network, usb, pci)
+
+	return &fingerprint, nil
+}
+
+// Fingerprint returns the fingerprint of the supplied devices. Only the attributes that identify the hardware
+// and where it is connected (such as IDs and addresses) are included, not those which change at runtime (such
+// as the bound driver, which changes when a device is passed through to a virtual machine, or the device number
+// of USB devices, which changes whenever they are reconnected). As the devices are sorted, the fingerprint only
+// changes when the hardware does.
+func Fingerprint(gpu *api.ResourcesGPU, network *api.ResourcesNetwork, usb *api.ResourcesUSB, pci *api.ResourcesPCI) api.ResourcesFingerprint {
+	gpuEntries := make([]string, 0, len(gpu.Cards))
+	for _, card := range gpu.Cards {
+		gpuEntries = append(gpuEntries, fmt.Sprintf("%s|%s|%s:%s", card.PCIAddress, card.USBAddress, card.VendorID, card.ProductID))
+	}
+
+	networkEntries := make([]string, 0, len(network.Cards))
+	for _, card := range network.Cards {
+		networkEntries = append(networkEntries, fmt.Sprintf("%s|%s|%s:%s", card.PCIAddress, card.USBAddress, card.VendorID, card.ProductID))
+	}
+
+	usbEntries := make([]string, 0, len(usb.Devices))
+	for _, device := range usb.Devices {
+		usbEntries = append(usbEntries, fmt.Sprintf("%03d|%s:%s", device.BusAddress, device.VendorID, device.ProductID))
+	}
+
+	pciEntries := make([]string, 0, len(pci.Devices))
+	for _, device := range pci.Devices {
+		pciEntries = append(pciEntries, fmt.Sprintf("%s|%s:%s|%d", device.PCIAddress, device.VendorID, device.ProductID, device.IOMMUGroup))
+	}
+
+	fingerprint := api.ResourcesFingerprint{
+		GPU:     fingerprintEntries(gpuEntries),
+		Network: fingerprintEntries(networkEntries),
+		USB:     fingerprintEntries(usbEntries),
+		PCI:     fingerprintEntries(pciEntries),
+	}
+
+	fingerprint.Devices = fingerprintEntries([]string{
+		"gpu=" + fingerprint.GPU,
+		"network=" + fingerprint.Network,
+		"usb=" + fingerprint.USB,
+		"pci=" + fingerprint.PCI,
+	})
+
+	return fingerprint
+}
+
+// fingerprintEntries returns the SHA-256 hash of the supplied entries, regardless of their order.
+func fingerprintEntries(entries []string) string {
+	sorted := append([]string(nil), entries...)
+	sort.Strings(sorted)
+
+	return fmt.Sprintf("%x", sha256.Sum256([]byte(strings.Join(sorted, "\n"))))
+}
@@ -68,5 +68,7 @@ func GetResources() (*api.Resources, error) {
 		System:  *system,
 	}
 
+	resources.Fingerprint = Fingerprint(gpu, network, usb, pci)
+
 	return &resources, nil
 }
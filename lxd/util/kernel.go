@@ -20,6 +20,34 @@ func LoadModule(module string) error {
 	return err
 }
 
+// ModuleLoaded returns true if the kernel module with the given name is currently loaded.
+// The kernel exposes modules in sysfs with dashes replaced by underscores.
+func ModuleLoaded(module string) bool {
+	return shared.PathExists(fmt.Sprintf("/sys/module/%s", strings.ReplaceAll(module, "-", "_")))
+}
+
+// ModuleAvailable checks whether the kernel module with the given name can be loaded, by invoking
+// modprobe in dry-run mode. This respects any modprobe configuration on the system.
+func ModuleAvailable(module string) error {
+	if ModuleLoaded(module) {
+		return nil
+	}
+
+	_, err := shared.RunCommand("modprobe", "--dry-run", "-b", module)
+	return err
+}
+
+// UnloadModule unloads the kernel module with the given name, by invoking modprobe.
+// This will fail if the module is still in use.
+func UnloadModule(module string) error {
+	if !ModuleLoaded(module) {
+		return nil
+	}
+
+	_, err := shared.RunCommand("modprobe", "-r", module)
+	return err
+}
+
 // SupportsFilesystem checks whether a given filesystem is already supported
 // by the kernel. Note that if the filesystem is a module, you may need to
 // load it first.
@@ -37,6 +37,11 @@ const (
 	EventLifecycleInstanceConsoleRetrieved          = "instance-console-retrieved"
 	EventLifecycleInstanceCreated                   = "instance-created"
 	EventLifecycleInstanceDeleted                   = "instance-deleted"
+	EventLifecycleInstanceDeviceAttached            = "instance-device-attached"
+	EventLifecycleInstanceDeviceBlocked             = "instance-device-blocked"
+	EventLifecycleInstanceDeviceDetached            = "instance-device-detached"
+	EventLifecycleInstanceDeviceFailed              = "instance-device-failed"
+	EventLifecycleInstanceDeviceRequiredMissing     = "instance-device-required-missing"
 	EventLifecycleInstanceExec                      = "instance-exec"
 	EventLifecycleInstanceFileDeleted               = "instance-file-deleted"
 	EventLifecycleInstanceFilePushed                = "instance-file-pushed"
@@ -56,6 +56,11 @@ type InstanceState struct {
 
 	// CPU usage information
 	CPU InstanceStateCPU `json:"cpu" yaml:"cpu"`
+
+	// Dict of device state
+	//
+	// API extension: usb_power
+	Devices map[string]InstanceStateDevice `json:"devices,omitempty" yaml:"devices,omitempty"`
 }
 
 // InstanceStateDisk represents the disk information section of a LXD instance's state.
@@ -199,3 +204,221 @@ type InstanceStateNetworkCounters struct {
 	// Example: 179
 	PacketsDroppedInbound int64 `json:"packets_dropped_inbound" yaml:"packets_dropped_inbound"`
 }
+
+// InstanceStateDevice represents the device information section of a LXD instance's state.
+//
+// swagger:model
+//
+// API extension: usb_power.
+type InstanceStateDevice struct {
+	// List of host USB devices matched by the device
+	USB []InstanceStateDeviceUSB `json:"usb,omitempty" yaml:"usb,omitempty"`
+
+	// Time taken by the most recent run of each phase of the device's operations in seconds
+	// Example: {"start": 0.052, "validate": 0.001}
+	//
+	// API extension: device_timings
+	Timings map[string]float64 `json:"timings,omitempty" yaml:"timings,omitempty"`
+
+	// Start status of the device (starting, started, stopped, failed or skipped)
+	// Example: skipped
+	//
+	// API extension: device_requires
+	Status string `json:"status,omitempty" yaml:"status,omitempty"`
+
+	// Reason for the start status of the device
+	// Example: Required device "eth0" is failed
+	//
+	// API extension: device_requires
+	StatusReason string `json:"status_reason,omitempty" yaml:"status_reason,omitempty"`
+
+	// Match criterion (vendorid[:productid]) of the chain used to select the host USB devices
+	// Example: 046d:c52b
+	//
+	// API extension: usb_fallback
+	Match string `json:"match,omitempty" yaml:"match,omitempty"`
+
+	// Where the device is defined (instance or profile:<name>)
+	// Example: profile:default
+	//
+	// API extension: device_source
+	Source string `json:"source,omitempty" yaml:"source,omitempty"`
+
+	// List of host USB devices whose attachment is deferred until the hotplug window opens
+	//
+	// API extension: usb_hotplug_window
+	Pending []InstanceStateDeviceUSB `json:"pending,omitempty" yaml:"pending,omitempty"`
+
+	// Which of the primary and standby USB devices is active (primary or standby)
+	// Example: standby
+	//
+	// API extension: usb_standby
+	Active string `json:"active,omitempty" yaml:"active,omitempty"`
+
+	// Number of consecutive failures of the device's hotplug handling (reset once it succeeds)
+	// Example: 3
+	//
+	// API extension: device_hotplug_errors
+	HotplugErrors int64 `json:"hotplug_errors,omitempty" yaml:"hotplug_errors,omitempty"`
+
+	// Most recent error of the device's hotplug handling (cleared once it succeeds)
+	// Example: Failed to add cgroup rule for device
+	//
+	// API extension: device_hotplug_errors
+	HotplugLastError string `json:"hotplug_last_error,omitempty" yaml:"hotplug_last_error,omitempty"`
+
+	// Number of seconds left for the required USB device to be attached before its grace period expires
+	// Example: 240
+	//
+	// API extension: usb_required_grace
+	RequiredGraceRemaining int64 `json:"required_grace_remaining,omitempty" yaml:"required_grace_remaining,omitempty"`
+}
+
+// InstanceStateDeviceUSB represents a host USB device matched by an instance device.
+//
+// swagger:model
+//
+// API extension: usb_power.
+type InstanceStateDeviceUSB struct {
+	// USB vendor ID
+	// Example: 1050
+	VendorID string `json:"vendor_id" yaml:"vendor_id"`
+
+	// USB product ID
+	// Example: 0407
+	ProductID string `json:"product_id" yaml:"product_id"`
+
+	// USB bus number
+	// Example: 1
+	BusAddress int `json:"bus_address" yaml:"bus_address"`
+
+	// USB device number
+	// Example: 4
+	DeviceAddress int `json:"device_address" yaml:"device_address"`
+
+	// Maximum power draw of the device in mA
+	// Example: 100
+	MaxPower uint64 `json:"max_power" yaml:"max_power"`
+
+	// Combined maximum power draw in mA of all devices connected to the same hub
+	// Example: 500
+	HubMaxPower uint64 `json:"hub_max_power" yaml:"hub_max_power"`
+
+	// Parsed USB descriptors of the device (only included if the device has descriptors=true)
+	//
+	// API extension: usb_descriptors
+	Descriptors *InstanceStateDeviceUSBDescriptors `json:"descriptors,omitempty" yaml:"descriptors,omitempty"`
+}
+
+// InstanceStateDeviceUSBDescriptors represents the parsed descriptors of a host USB device.
+//
+// swagger:model
+//
+// API extension: usb_descriptors.
+type InstanceStateDeviceUSBDescriptors struct {
+	// USB specification version supported by the device
+	// Example: 2.00
+	USBVersion string `json:"usb_version" yaml:"usb_version"`
+
+	// Device class code
+	// Example: 0
+	Class int `json:"class" yaml:"class"`
+
+	// Device subclass code
+	// Example: 0
+	SubClass int `json:"subclass" yaml:"subclass"`
+
+	// Device protocol code
+	// Example: 0
+	Protocol int `json:"protocol" yaml:"protocol"`
+
+	// Maximum packet size of the default control endpoint
+	// Example: 64
+	MaxPacketSize int `json:"max_packet_size" yaml:"max_packet_size"`
+
+	// Device release number
+	// Example: 5.43
+	DeviceVersion string `json:"device_version" yaml:"device_version"`
+
+	// Configurations of the device
+	Configurations []InstanceStateDeviceUSBConfiguration `json:"configurations" yaml:"configurations"`
+}
+
+// InstanceStateDeviceUSBConfiguration represents a configuration of a host USB device.
+//
+// swagger:model
+//
+// API extension: usb_descriptors.
+type InstanceStateDeviceUSBConfiguration struct {
+	// Configuration value
+	// Example: 1
+	Value int `json:"value" yaml:"value"`
+
+	// Configuration attributes bitmap
+	// Example: 128
+	Attributes int `json:"attributes" yaml:"attributes"`
+
+	// Maximum power draw in mA in this configuration
+	// Example: 30
+	MaxPower int `json:"max_power" yaml:"max_power"`
+
+	// Interfaces of the configuration
+	Interfaces []InstanceStateDeviceUSBInterface `json:"interfaces" yaml:"interfaces"`
+}
+
+// InstanceStateDeviceUSBInterface represents an interface of a host USB device configuration.
+//
+// swagger:model
+//
+// API extension: usb_descriptors.
+type InstanceStateDeviceUSBInterface struct {
+	// Interface number
+	// Example: 0
+	Number int `json:"number" yaml:"number"`
+
+	// Alternate setting of the interface
+	// Example: 0
+	AlternateSetting int `json:"alternate_setting" yaml:"alternate_setting"`
+
+	// Interface class code
+	// Example: 3
+	Class int `json:"class" yaml:"class"`
+
+	// Interface subclass code
+	// Example: 1
+	SubClass int `json:"subclass" yaml:"subclass"`
+
+	// Interface protocol code
+	// Example: 1
+	Protocol int `json:"protocol" yaml:"protocol"`
+
+	// Endpoints of the interface
+	Endpoints []InstanceStateDeviceUSBEndpoint `json:"endpoints" yaml:"endpoints"`
+}
+
+// InstanceStateDeviceUSBEndpoint represents an endpoint of a host USB device interface.
+//
+// swagger:model
+//
+// API extension: usb_descriptors.
+type InstanceStateDeviceUSBEndpoint struct {
+	// Endpoint address
+	// Example: 129
+	Address int `json:"address" yaml:"address"`
+
+	// Endpoint direction (in or out)
+	// Example: in
+	Direction string `json:"direction" yaml:"direction"`
+
+	// Endpoint transfer type (control, isochronous, bulk or interrupt)
+	// Example: interrupt
+	TransferType string `json:"transfer_type" yaml:"transfer_type"`
+
+	// Maximum packet size of the endpoint
+	// Example: 8
+	MaxPacketSize int `json:"max_packet_size" yaml:"max_packet_size"`
+
+	// Polling interval of the endpoint
+	// Example: 10
+	Interval int `json:"interval" yaml:"interval"`
+}
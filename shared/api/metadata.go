@@ -0,0 +1,42 @@
+package api
+
+// MetadataDevice represents the configuration schema of a device type.
+//
+// swagger:model
+//
+// API extension: device_schema.
+type MetadataDevice struct {
+	// Device type
+	// Example: nic
+	Type string `json:"type" yaml:"type"`
+
+	// Device subtype (the nictype of nic and infiniband devices or the gputype of gpu devices)
+	// Example: bridged
+	Subtype string `json:"subtype,omitempty" yaml:"subtype,omitempty"`
+
+	// Instance types supporting the device type
+	// Example: ["container", "virtual-machine"]
+	InstanceTypes []string `json:"instance_types" yaml:"instance_types"`
+
+	// Configuration keys of the device type
+	Keys map[string]MetadataDeviceKey `json:"keys" yaml:"keys"`
+}
+
+// MetadataDeviceKey represents the schema of a device configuration key.
+//
+// swagger:model
+//
+// API extension: device_schema.
+type MetadataDeviceKey struct {
+	// Type of the values (bool, integer or string)
+	// Example: string
+	Type string `json:"type" yaml:"type"`
+
+	// Whether the key must be set
+	// Example: true
+	Required bool `json:"required" yaml:"required"`
+
+	// Instance types supporting the key
+	// Example: ["container", "virtual-machine"]
+	InstanceTypes []string `json:"instance_types" yaml:"instance_types"`
+}
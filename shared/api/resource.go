@@ -1,5 +1,9 @@
 package api
 
+import (
+	"time"
+)
+
 // Resources represents the system resources available for LXD
 //
 // swagger:model
@@ -41,6 +45,39 @@ type Resources struct {
 	//
 	// API extension: resources_system
 	System ResourcesSystem `json:"system" yaml:"system"`
+
+	// Fingerprint of the devices
+	//
+	// API extension: resources_fingerprint
+	Fingerprint ResourcesFingerprint `json:"fingerprint" yaml:"fingerprint"`
+}
+
+// ResourcesFingerprint represents a stable fingerprint of the devices of the system, which only changes when
+// the hardware does (such as when a device is moved to another slot or replaced)
+//
+// swagger:model
+//
+// API extension: resources_fingerprint.
+type ResourcesFingerprint struct {
+	// Fingerprint of all the GPU, network, USB and PCI devices
+	// Example: 7d1a54127b222502f5b79b5fb0803061152a44f92b37e23c6527baf665d4da9a
+	Devices string `json:"devices" yaml:"devices"`
+
+	// Fingerprint of the GPU devices
+	// Example: 5f78c33274e43fa9de5659265c1d917e25c03722dcb0b8d27db8d5feaa813953
+	GPU string `json:"gpu" yaml:"gpu"`
+
+	// Fingerprint of the network devices
+	// Example: 4b227777d4dd1fc61c6f884f48641d02b4d121d3fd328cb08b5531fcacdabf8a
+	Network string `json:"network" yaml:"network"`
+
+	// Fingerprint of the USB devices
+	// Example: ef2d127de37b942baad06145e54b0c619a1f22327b2ebbcfbec78f5564afe39d
+	USB string `json:"usb" yaml:"usb"`
+
+	// Fingerprint of the PCI devices
+	// Example: e7f6c011776e8db7cd330b54174fd76f7d0216b612387a5ffcfb81e6f0919683
+	PCI string `json:"pci" yaml:"pci"`
 }
 
 // ResourcesCPU represents the cpu resources available on the system
@@ -1068,3 +1105,35 @@ type ResourcesSystemMotherboard struct {
 	// Example: None
 	Version string `json:"version" yaml:"version"`
 }
+
+// ResourcesQuarantinedDevice represents a host PCI device that was quarantined when it was detached from an
+// instance, so that it can't be used again until it is released.
+//
+// swagger:model
+//
+// API extension: device_pci_quarantine.
+type ResourcesQuarantinedDevice struct {
+	// PCI address of the device
+	// Example: 0000:01:00.0
+	PCIAddress string `json:"pci_address" yaml:"pci_address"`
+
+	// Host driver that the device is bound to again once released
+	// Example: nvme
+	Driver string `json:"driver" yaml:"driver"`
+
+	// Project of the instance that the device was detached from
+	// Example: default
+	Project string `json:"project" yaml:"project"`
+
+	// Instance that the device was detached from
+	// Example: c1
+	Instance string `json:"instance" yaml:"instance"`
+
+	// Name of the instance device that the device was detached from
+	// Example: nvme0
+	Device string `json:"device" yaml:"device"`
+
+	// When the device was quarantined
+	// Example: 2022-10-14T10:00:00Z
+	QuarantinedAt time.Time `json:"quarantined_at" yaml:"quarantined_at"`
+}
@@ -366,6 +366,46 @@ func ConfigKeyChecker(key string, instanceType instancetype.Type) (func(value st
 			return validate.IsAny, nil
 		}
 
+		if strings.HasSuffix(key, ".last_state.environment") {
+			return validate.IsAny, nil
+		}
+
+		if strings.HasSuffix(key, ".last_state.limits.interfaces") {
+			return validate.IsAny, nil
+		}
+
+		if strings.HasSuffix(key, ".last_state.modules") {
+			return validate.IsAny, nil
+		}
+
+		if strings.HasSuffix(key, ".last_state.perf_event_paranoid") {
+			return validate.IsAny, nil
+		}
+
+		if strings.HasSuffix(key, ".last_state.prestaged") {
+			return validate.IsAny, nil
+		}
+
+		if strings.HasSuffix(key, ".last_state.match") {
+			return validate.IsAny, nil
+		}
+
+		if strings.HasSuffix(key, ".last_state.irq_affinity") {
+			return validate.IsAny, nil
+		}
+
+		if strings.HasSuffix(key, ".last_state.standby.path") {
+			return validate.IsAny, nil
+		}
+
+		if strings.HasSuffix(key, ".last_state.netns") {
+			return validate.IsAny, nil
+		}
+
+		if strings.HasSuffix(key, ".last_state.hwmon") {
+			return validate.IsAny, nil
+		}
+
 		if strings.HasSuffix(key, ".last_state.ready") {
 			return validate.IsBool, nil
 		}
@@ -352,6 +352,58 @@ var APIExtensions = []string{
 	"internal_metrics",
 	"cluster_join_token_expiry",
 	"remote_token_expiry",
+	"usb_modules",
+	"usb_power",
+	"pci_device_container",
+	"device_inherit",
+	"usb_environment",
+	"disk_shift_idmapped",
+	"pci_rebind_attempts",
+	"usb_sysfs_paths",
+	"device_perf",
+	"usb_limits",
+	"device_timings",
+	"device_requires",
+	"device_input",
+	"device_restrictions_recheck",
+	"device_udev_settle",
+	"usb_descriptors",
+	"device_prestage",
+	"device_path_unavailable",
+	"gpu_user_group",
+	"device_timer",
+	"usb_fallback",
+	"device_strategy",
+	"device_source",
+	"usb_quiesce",
+	"device_probe",
+	"device_name_template",
+	"usb_hotplug_window",
+	"device_lifecycle_events",
+	"usb_irq_affinity",
+	"device_type_aliases",
+	"device_secrets",
+	"usb_reconcile_delay",
+	"device_ipmi",
+	"device_netns",
+	"usb_standby",
+	"device_operations_concurrency",
+	"device_label",
+	"resources_fingerprint",
+	"device_dir_ownership",
+	"device_start_async",
+	"device_scsi",
+	"device_schema",
+	"device_hwmon",
+	"device_capabilities",
+	"usb_usbmon",
+	"device_stop_verify",
+	"device_path_conflicts",
+	"device_hotplug_errors",
+	"instance_placement_device_resources",
+	"usb_required_grace",
+	"device_config_normalisation",
+	"device_pci_quarantine",
 }
 
 // APIExtensionsCount returns the number of available API extensions.